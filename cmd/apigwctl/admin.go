@@ -0,0 +1,256 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"apigw/pkg/client"
+)
+
+// stringList - флаг, который можно указать несколько раз
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// runRoutesList выводит маршруты текущей конфигурации шлюза
+func runRoutesList(a *app, args []string) error {
+	routes, err := a.admin.Routes(a.ctx)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return printJSON(routes)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "МАРШРУТ\tВИД\tОБРАБОТЧИК\tСЕРВИСЫ\tMIDDLEWARE")
+	for _, r := range routes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Pattern, r.Kind, r.Handler, orDash(strings.Join(r.Backends, ", ")), strings.Join(r.Middleware, " -> "))
+	}
+	return tw.Flush()
+}
+
+// runHealthBackends выводит состояние backend-сервисов
+func runHealthBackends(a *app, args []string) error {
+	backends, err := a.admin.Backends(a.ctx)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return printJSON(backends)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "СЕРВИС\tАДРЕС\tДОСТУПЕН\tПРОВЕРКА\tВЫКЛЮЧАТЕЛЬ")
+	for _, b := range backends {
+		check := "-"
+		if b.HealthCheck != nil {
+			check = yesNo(b.HealthCheck.Healthy)
+			if b.HealthCheck.Reason != "" {
+				check += " (" + b.HealthCheck.Reason + ")"
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", strings.Join(b.Services, ", "), b.Upstream, yesNo(b.Healthy), check, orDash(b.CircuitBreaker))
+	}
+	return tw.Flush()
+}
+
+// runCacheFlush сбрасывает весь кэш ответов или записи с тегами
+func runCacheFlush(a *app, args []string) error {
+	fs := flag.NewFlagSet("cache flush", flag.ContinueOnError)
+	tags := fs.String("tags", "", "comma-separated cache tags, e.g. news:5,comments:5")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var list []string
+	if *tags != "" {
+		list = strings.Split(*tags, ",")
+	}
+	flushed, err := a.admin.FlushCache(a.ctx, list...)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return printJSON(map[string][]string{"flushed": flushed})
+	}
+	fmt.Printf("Сброшены теги кэша: %s\n", strings.Join(flushed, ", "))
+	return nil
+}
+
+// runCachePurge сбрасывает кэш ответов по маршрутам, ID новостей или шаблону
+func runCachePurge(a *app, args []string) error {
+	fs := flag.NewFlagSet("cache purge", flag.ContinueOnError)
+	var routes stringList
+	fs.Var(&routes, "route", "cached route, e.g. /api/news (repeatable)")
+	news := fs.String("news", "", "comma-separated IDs of changed news")
+	pattern := fs.String("pattern", "", "route pattern in path.Match syntax, e.g. /api/news*")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	purge := client.CachePurge{Routes: routes, Pattern: *pattern}
+	if *news != "" {
+		for _, value := range strings.Split(*news, ",") {
+			id, err := parseID([]string{strings.TrimSpace(value)}, 1)
+			if err != nil {
+				return err
+			}
+			purge.NewsIDs = append(purge.NewsIDs, id)
+		}
+	}
+	purged, err := a.admin.PurgeCache(a.ctx, purge)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return printJSON(map[string][]string{"purged": purged})
+	}
+	fmt.Printf("Сброшены теги кэша: %s\n", strings.Join(purged, ", "))
+	return nil
+}
+
+// runMaintenanceStatus выводит режим обслуживания
+func runMaintenanceStatus(a *app, args []string) error {
+	mode, err := a.admin.Maintenance(a.ctx)
+	if err != nil {
+		return err
+	}
+	return printMaintenance(a, mode)
+}
+
+// runMaintenanceOn включает режим обслуживания: шлюз отвечает на запросы
+// API 503, и балансировщик выводит экземпляр из работы перед остановкой
+func runMaintenanceOn(a *app, args []string) error {
+	fs := flag.NewFlagSet("maintenance on", flag.ContinueOnError)
+	message := fs.String("message", "", "message returned to clients")
+	retryAfter := fs.Duration("retry-after", 0, "Retry-After sent to clients")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	mode := client.Maintenance{Enabled: true, Message: *message}
+	if *retryAfter > 0 {
+		mode.RetryAfter = retryAfter.String()
+	}
+	result, err := a.admin.SetMaintenance(a.ctx, mode)
+	if err != nil {
+		return err
+	}
+	return printMaintenance(a, result)
+}
+
+// runMaintenanceOff выключает режим обслуживания
+func runMaintenanceOff(a *app, args []string) error {
+	result, err := a.admin.SetMaintenance(a.ctx, client.Maintenance{})
+	if err != nil {
+		return err
+	}
+	return printMaintenance(a, result)
+}
+
+// printMaintenance выводит режим обслуживания
+func printMaintenance(a *app, mode *client.Maintenance) error {
+	if a.json {
+		return printJSON(mode)
+	}
+	if !mode.Enabled {
+		fmt.Println("Режим обслуживания выключен")
+		return nil
+	}
+	fmt.Print("Режим обслуживания включен")
+	if mode.Since != nil {
+		fmt.Printf(" с %s", mode.Since.Local().Format(time.DateTime))
+	}
+	fmt.Println()
+	if mode.Message != "" {
+		fmt.Printf("Сообщение: %s\n", mode.Message)
+	}
+	if mode.RetryAfter != "" {
+		fmt.Printf("Retry-After: %s\n", mode.RetryAfter)
+	}
+	return nil
+}
+
+// runKeysList выводит ключи API без самих ключей
+func runKeysList(a *app, args []string) error {
+	keys, err := a.admin.APIKeys(a.ctx)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return printJSON(keys)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "КЛИЕНТ\tОТПЕЧАТОК\tИСТОЧНИК")
+	for _, k := range keys {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", k.Client, k.Fingerprint, k.Source)
+	}
+	return tw.Flush()
+}
+
+// runKeysAdd создает ключ API клиента и выводит его
+func runKeysAdd(a *app, args []string) error {
+	fs := flag.NewFlagSet("keys add", flag.ContinueOnError)
+	key := fs.String("key", "", "key value (generated by the gateway if empty)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("нужно указать клиента")
+	}
+	created, err := a.admin.AddAPIKey(a.ctx, fs.Arg(0), *key)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return printJSON(created)
+	}
+	fmt.Printf("Ключ клиента %s (отпечаток %s): %s\n", created.Client, created.Fingerprint, created.Key)
+	return nil
+}
+
+// runKeysRevoke отзывает ключи клиента или ключ с отпечатком
+func runKeysRevoke(a *app, args []string) error {
+	fs := flag.NewFlagSet("keys revoke", flag.ContinueOnError)
+	fingerprint := fs.String("fingerprint", "", "fingerprint of the key to revoke")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	clientName := fs.Arg(0)
+	if (clientName == "") == (*fingerprint == "") || fs.NArg() > 1 {
+		return fmt.Errorf("нужно указать клиента или -fingerprint")
+	}
+	revoked, err := a.admin.RevokeAPIKeys(a.ctx, clientName, *fingerprint)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return printJSON(revoked)
+	}
+	for _, k := range revoked {
+		fmt.Printf("Отозван ключ клиента %s (отпечаток %s)\n", k.Client, k.Fingerprint)
+	}
+	return nil
+}
+
+// yesNo возвращает "да" или "нет"
+func yesNo(ok bool) string {
+	if ok {
+		return "да"
+	}
+	return "нет"
+}
+
+// orDash возвращает "-" вместо пустой строки
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
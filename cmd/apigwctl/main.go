@@ -0,0 +1,297 @@
+// apigwctl - утилита командной строки для работы с API Gateway.
+//
+// Использование:
+//
+//	apigwctl [флаги] news list [-page N] [-count N] [-s строка] [-full] [-all]
+//	apigwctl [флаги] news get <id>
+//	apigwctl [флаги] comments list <id новости>
+//	apigwctl [флаги] comments add <id новости> <текст>
+//
+// Команды административного API (-admin-url, -admin-token):
+//
+//	apigwctl [флаги] routes list
+//	apigwctl [флаги] health backends
+//	apigwctl [флаги] cache flush [-tags тег,тег]
+//	apigwctl [флаги] cache purge [-route маршрут] [-news id,id] [-pattern шаблон]
+//	apigwctl [флаги] maintenance status|on|off
+//	apigwctl [флаги] keys list|add|revoke
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"apigw/pkg/client"
+)
+
+// app - общие параметры команд
+type app struct {
+	client *client.Client
+	admin  *client.Admin
+	ctx    context.Context
+	json   bool
+}
+
+// command - команда утилиты
+type command struct {
+	usage string
+	run   func(a *app, args []string) error
+}
+
+// commands - команды по группам: "news list", "comments add" и т.д.
+var commands = map[string]map[string]command{
+	"news": {
+		"list": {"news list [-page N] [-count N] [-s строка] [-full] [-all]", runNewsList},
+		"get":  {"news get <id>", runNewsGet},
+	},
+	"comments": {
+		"list": {"comments list <id новости>", runCommentsList},
+		"add":  {"comments add <id новости> <текст>", runCommentsAdd},
+	},
+	"routes": {
+		"list": {"routes list", runRoutesList},
+	},
+	"health": {
+		"backends": {"health backends", runHealthBackends},
+	},
+	"cache": {
+		"flush": {"cache flush [-tags тег,тег]", runCacheFlush},
+		"purge": {"cache purge [-route маршрут]... [-news id,id] [-pattern шаблон]", runCachePurge},
+	},
+	"maintenance": {
+		"status": {"maintenance status", runMaintenanceStatus},
+		"on":     {"maintenance on [-message текст] [-retry-after 30s]", runMaintenanceOn},
+		"off":    {"maintenance off", runMaintenanceOff},
+	},
+	"keys": {
+		"list":   {"keys list", runKeysList},
+		"add":    {"keys add [-key ключ] <клиент>", runKeysAdd},
+		"revoke": {"keys revoke [-fingerprint отпечаток] [<клиент>]", runKeysRevoke},
+	},
+}
+
+func main() {
+	fs := flag.NewFlagSet("apigwctl", flag.ExitOnError)
+	baseURL := fs.String("url", envOr("APIGW_URL", "http://localhost:8081"), "gateway address (APIGW_URL)")
+	apiKey := fs.String("api-key", os.Getenv("APIGW_API_KEY"), "API key sent in X-API-Key (APIGW_API_KEY)")
+	token := fs.String("token", os.Getenv("APIGW_TOKEN"), "bearer token (APIGW_TOKEN)")
+	adminURL := fs.String("admin-url", envOr("APIGW_ADMIN_URL", "http://localhost:9090"), "admin API address (APIGW_ADMIN_URL)")
+	adminToken := fs.String("admin-token", os.Getenv("APIGW_ADMIN_TOKEN"), "admin API token from admin.token (APIGW_ADMIN_TOKEN)")
+	requestID := fs.String("request-id", "", "request_id passed to the gateway")
+	asJSON := fs.Bool("json", false, "print results as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Использование: apigwctl [флаги] <команда>")
+		fmt.Fprintln(os.Stderr, "\nКоманды:")
+		for _, usage := range usages() {
+			fmt.Fprintln(os.Stderr, "  "+usage)
+		}
+		fmt.Fprintln(os.Stderr, "\nФлаги:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(os.Args[1:])
+
+	args := fs.Args()
+	if len(args) < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[args[0]][args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "неизвестная команда: %s\n\n", strings.Join(args[:2], " "))
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	c, err := client.New(*baseURL, client.Options{APIKey: *apiKey, Token: *token, UserAgent: "apigwctl"})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	admin, err := client.NewAdmin(*adminURL, *adminToken, client.Options{UserAgent: "apigwctl"})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *requestID != "" {
+		ctx = client.WithRequestID(ctx, *requestID)
+	}
+
+	a := &app{client: c, admin: admin, ctx: ctx, json: *asJSON}
+	if err := cmd.run(a, args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s: %v\n", args[0], args[1], err)
+		os.Exit(1)
+	}
+}
+
+// usages возвращает описание команд в постоянном порядке
+func usages() []string {
+	var list []string
+	for _, group := range commands {
+		for _, cmd := range group {
+			list = append(list, cmd.usage)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// runNewsList выводит страницу списка новостей или, с -all, все страницы
+func runNewsList(a *app, args []string) error {
+	fs := flag.NewFlagSet("news list", flag.ContinueOnError)
+	page := fs.Int("page", 0, "page number")
+	count := fs.Int("count", 0, "news per page")
+	search := fs.String("s", "", "search by title")
+	full := fs.Bool("full", false, "include description")
+	all := fs.Bool("all", false, "fetch all pages starting from -page")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	opts := client.ListOptions{Page: *page, Count: *count, Search: *search, Full: *full}
+
+	if *all {
+		var items []client.News
+		err := a.client.EachNews(a.ctx, opts, func(n client.News) error {
+			items = append(items, n)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if a.json {
+			return printJSON(items)
+		}
+		printNews(items, *full)
+		return nil
+	}
+
+	result, err := a.client.ListNews(a.ctx, opts)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return printJSON(result)
+	}
+	printNews(result.Items, *full)
+	fmt.Printf("\nСтраница %d из %d, всего новостей: %d\n", result.CurrentPage, result.TotalPages, result.TotalItems)
+	return nil
+}
+
+// runNewsGet выводит новость по ID
+func runNewsGet(a *app, args []string) error {
+	id, err := parseID(args, 1)
+	if err != nil {
+		return err
+	}
+	news, err := a.client.GetNews(a.ctx, id)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return printJSON(news)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID\t%d\n", news.ID)
+	fmt.Fprintf(tw, "Заголовок\t%s\n", news.Title)
+	fmt.Fprintf(tw, "Описание\t%s\n", news.Description)
+	fmt.Fprintf(tw, "Дата\t%s\n", news.PubDate)
+	fmt.Fprintf(tw, "Источник\t%s\n", news.SourceURL)
+	return tw.Flush()
+}
+
+// runCommentsList выводит комментарии к новости
+func runCommentsList(a *app, args []string) error {
+	id, err := parseID(args, 1)
+	if err != nil {
+		return err
+	}
+	comments, err := a.client.ListComments(a.ctx, id)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return printJSON(comments)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tОТВЕТ НА\tСОЗДАН\tТЕКСТ")
+	for _, c := range comments {
+		parent := "-"
+		if c.ParentID != 0 {
+			parent = strconv.FormatInt(c.ParentID, 10)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", c.ID, parent, c.CreatedAt, c.Text)
+	}
+	return tw.Flush()
+}
+
+// runCommentsAdd добавляет комментарий к новости
+func runCommentsAdd(a *app, args []string) error {
+	id, err := parseID(args, 2)
+	if err != nil {
+		return err
+	}
+	comment, err := a.client.AddComment(a.ctx, id, strings.Join(args[1:], " "))
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return printJSON(comment)
+	}
+	fmt.Printf("Комментарий %d добавлен к новости %d\n", comment.ID, comment.NewsID)
+	return nil
+}
+
+// parseID разбирает ID из первого аргумента и проверяет, что аргументов не меньше min
+func parseID(args []string, min int) (int64, error) {
+	if len(args) < min {
+		return 0, fmt.Errorf("недостаточно аргументов")
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("некорректный ID: %q", args[0])
+	}
+	return id, nil
+}
+
+// printNews выводит новости таблицей
+func printNews(items []client.News, full bool) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if full {
+		fmt.Fprintln(tw, "ID\tДАТА\tЗАГОЛОВОК\tОПИСАНИЕ")
+	} else {
+		fmt.Fprintln(tw, "ID\tДАТА\tЗАГОЛОВОК")
+	}
+	for _, n := range items {
+		if full {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", n.ID, n.PubDate, n.Title, n.Description)
+		} else {
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", n.ID, n.PubDate, n.Title)
+		}
+	}
+	tw.Flush()
+}
+
+// printJSON выводит значение в JSON с отступами
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// envOr возвращает значение переменной окружения или значение по умолчанию
+func envOr(name, def string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return def
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"apigw/pkg/bench"
+)
+
+// listFlag - флаг, который можно указать несколько раз
+type listFlag []string
+
+func (f *listFlag) String() string { return strings.Join(*f, ", ") }
+
+func (f *listFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// defaultBenchRoutes - смесь маршрутов, если -route не указан
+var defaultBenchRoutes = []string{
+	"3:/api/news",
+	"1:/api/fullnews",
+}
+
+// runBench создает нагрузку на запущенный шлюз и выводит перцентили задержки.
+// Завершается с ошибкой, если превышены пороги -max-p99 или -max-errors.
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	baseURL := fs.String("url", "http://localhost:8081", "gateway address")
+	concurrency := fs.Int("c", 10, "number of concurrent clients")
+	duration := fs.Duration("d", 10*time.Second, "load duration")
+	timeout := fs.Duration("timeout", 5*time.Second, "request timeout")
+	asJSON := fs.Bool("json", false, "print report as JSON")
+	maxP99 := fs.Duration("max-p99", 0, "fail if overall p99 latency exceeds this value")
+	maxErrors := fs.Float64("max-errors", -1, "fail if the share of failed requests (transport errors and 5xx) exceeds this value, 0..1")
+	var routes, headers listFlag
+	fs.Var(&routes, "route", `route in the mix: "[weight:][METHOD ]path", may be repeated`)
+	fs.Var(&headers, "H", `request header "Name: value", may be repeated`)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if len(routes) == 0 {
+		routes = defaultBenchRoutes
+	}
+	opts := bench.Options{
+		BaseURL:     *baseURL,
+		Concurrency: *concurrency,
+		Duration:    *duration,
+		Timeout:     *timeout,
+		Header:      http.Header{},
+	}
+	for _, value := range routes {
+		route, err := bench.ParseRoute(value)
+		if err != nil {
+			return err
+		}
+		opts.Routes = append(opts.Routes, route)
+	}
+	for _, value := range headers {
+		name, v, ok := strings.Cut(value, ":")
+		if !ok {
+			return fmt.Errorf("некорректный заголовок %q", value)
+		}
+		opts.Header.Add(strings.TrimSpace(name), strings.TrimSpace(v))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Fprintf(os.Stderr, "Нагрузка на %s: %d клиентов, %s\n", opts.BaseURL, opts.Concurrency, opts.Duration)
+	report, err := bench.Run(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printBenchReport(report)
+	}
+
+	failed := report.Total.Errors
+	for status, n := range report.Total.Statuses {
+		if status >= 500 {
+			failed += n
+		}
+	}
+	if report.Total.Requests == 0 {
+		return fmt.Errorf("не выполнено ни одного запроса")
+	}
+	if share := float64(failed) / float64(report.Total.Requests); *maxErrors >= 0 && share > *maxErrors {
+		return fmt.Errorf("доля ошибок %.4f превышает порог %.4f", share, *maxErrors)
+	}
+	if *maxP99 > 0 && report.Total.P99 > *maxP99 {
+		return fmt.Errorf("p99 %s превышает порог %s", report.Total.P99, *maxP99)
+	}
+	return nil
+}
+
+// printBenchReport выводит результаты нагрузки таблицей
+func printBenchReport(report *bench.Report) {
+	fmt.Printf("Запросов: %d за %s (%.1f в секунду)\n\n", report.Total.Requests, report.Duration.Round(time.Millisecond), report.RPS())
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "МАРШРУТ\tЗАПРОСЫ\tОШИБКИ\tP50\tP90\tP99\tMAX\tСТАТУСЫ")
+	for _, rs := range append(report.Routes, report.Total) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\n", rs.Route, rs.Requests, rs.Errors,
+			rs.P50.Round(time.Microsecond), rs.P90.Round(time.Microsecond), rs.P99.Round(time.Microsecond), rs.Max.Round(time.Microsecond),
+			formatStatuses(rs.Statuses))
+	}
+	tw.Flush()
+}
+
+// formatStatuses выводит количество ответов по статусам, например "200:950 503:50"
+func formatStatuses(statuses map[int]int) string {
+	codes := make([]int, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	parts := make([]string, len(codes))
+	for i, code := range codes {
+		parts[i] = fmt.Sprintf("%d:%d", code, statuses[code])
+	}
+	return strings.Join(parts, " ")
+}
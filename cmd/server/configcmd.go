@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"apigw/pkg/config"
+	"apigw/pkg/server"
+)
+
+// runConfig выполняет подкоманды работы с конфигурацией
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "validate" {
+		return fmt.Errorf("использование: apigw config validate [-q] <файл>")
+	}
+	return runConfigValidate(args[1:])
+}
+
+// runConfigValidate проверяет файл конфигурации без запуска сервера и выводит
+// итоговую конфигурацию с учетом значений по умолчанию. Вывод попадает в
+// журналы CI/CD, поэтому секреты в нем скрыты.
+func runConfigValidate(args []string) error {
+	fs := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	quiet := fs.Bool("q", false, "do not print the effective config")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("использование: apigw config validate [-q] <файл>")
+	}
+
+	cfg, err := config.ReadConfig(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := server.Validate(cfg); err != nil {
+		return fmt.Errorf("некорректная конфигурация: %w", err)
+	}
+
+	if !*quiet {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "    ")
+		if err := encoder.Encode(cfg.Redacted()); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Конфигурация %s корректна\n", fs.Arg(0))
+	return nil
+}
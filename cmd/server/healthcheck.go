@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"apigw/pkg/config"
+)
+
+// runHealthcheck проверяет, что локальный шлюз отвечает, и завершается с ошибкой,
+// если ответ не получен или его статус не 2xx. Предназначена для HEALTHCHECK
+// контейнера, чтобы не добавлять в образ curl.
+func runHealthcheck(args []string) error {
+	fs := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	configPath := fs.String("config", "config.json", "path to config file (used to find the port)")
+	baseURL := fs.String("url", "", "gateway address (default http(s)://127.0.0.1:<server.port>)")
+	path := fs.String("path", "", "path to probe (default health.liveness_path or /openapi.json)")
+	timeout := fs.Duration("timeout", 3*time.Second, "probe timeout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client := http.DefaultClient
+	var cfg *config.Config
+	if *baseURL == "" || *path == "" {
+		var err error
+		if cfg, err = config.LoadConfig(*configPath); err != nil {
+			return err
+		}
+	}
+	if *path == "" {
+		*path = cfg.Health.LivenessPath
+		if *path == "" {
+			*path = "/openapi.json"
+		}
+	}
+	if *baseURL == "" {
+		*baseURL = fmt.Sprintf("http://127.0.0.1:%d", cfg.Server.Port)
+		if cfg.Server.TLS.Enabled() {
+			// Сертификат выдан на имя сервера, а не на 127.0.0.1; проверяется
+			// только то, что шлюз отвечает
+			*baseURL = fmt.Sprintf("https://127.0.0.1:%d", cfg.Server.Port)
+			client = &http.Client{Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			}}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, *baseURL+*path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("шлюз не отвечает: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("шлюз ответил статусом %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -2,13 +2,39 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"apigw/pkg/config"
 	"apigw/pkg/server"
 )
 
+// commands - подкоманды, которые выполняются вместо запуска шлюза
+var commands = map[string]func(args []string) error{
+	"bench":           runBench,
+	"config":          runConfig,
+	"healthcheck":     runHealthcheck,
+	"openapi":         runOpenAPI,
+	"replay":          runReplay,
+	"routes":          runRoutes,
+	"verify-backends": runVerifyBackends,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	configPath := flag.String("config", "config.json", "path to config file")
 	flag.Parse()
 
@@ -16,10 +42,34 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := server.SetupLogging(cfg.Log); err != nil {
+		log.Fatal(err)
+	}
 
-	srv := server.NewServer(cfg)
-	log.Printf("Starting API Gateway on port %d", cfg.Server.Port)
-	if err := srv.Start(); err != nil {
+	srv, err := server.NewServer(cfg)
+	if err != nil {
 		log.Fatal(err)
 	}
+	go reloadOnSignal(srv, *configPath)
+
+	if err := srv.Start(); err != nil {
+		slog.Error("Сервер остановлен", "error", err)
+		os.Exit(1)
+	}
+}
+
+// reloadOnSignal перечитывает конфигурацию по сигналу SIGHUP и применяет ее без перезапуска
+func reloadOnSignal(srv *server.Server, configPath string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			slog.Error("Не удалось перечитать конфигурацию", "error", err)
+			continue
+		}
+		if err := srv.Reload(cfg); err != nil {
+			slog.Error("Не удалось применить конфигурацию", "error", err)
+		}
+	}
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"apigw/pkg/config"
+	"apigw/pkg/openapi"
+	"apigw/pkg/server"
+)
+
+// runOpenAPI выполняет подкоманды работы с описанием API
+func runOpenAPI(args []string) error {
+	if len(args) == 0 || args[0] != "export" {
+		return fmt.Errorf("использование: apigw openapi export [-config файл] [-format json|yaml] [-o файл]")
+	}
+	return runOpenAPIExport(args[1:])
+}
+
+// runOpenAPIExport записывает описание API для конфигурации в файл или на
+// стандартный вывод без запуска шлюза
+func runOpenAPIExport(args []string) error {
+	fs := flag.NewFlagSet("openapi export", flag.ContinueOnError)
+	configPath := fs.String("config", "config.json", "path to config file")
+	output := fs.String("o", "", "output file (default stdout)")
+	format := fs.String("format", "", "json or yaml (default by output file extension, json for stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *format == "" {
+		*format = "json"
+		if ext := strings.ToLower(filepath.Ext(*output)); ext == ".yaml" || ext == ".yml" {
+			*format = "yaml"
+		}
+	}
+	if *format != "json" && *format != "yaml" {
+		return fmt.Errorf("неизвестный формат %q, допустимы json и yaml", *format)
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	doc, err := server.OpenAPIDocument(cfg)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if *format == "yaml" {
+		err = openapi.EncodeYAML(w, doc)
+	} else {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(doc)
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось записать описание API: %w", err)
+	}
+	if *output != "" {
+		fmt.Fprintf(os.Stderr, "Описание API записано в %s\n", *output)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"apigw/pkg/replay"
+)
+
+// runReplay воспроизводит GET запросы из журнала запросов шлюза на проверяемом
+// шлюзе и сравнивает статусы ответов с исходными. Завершается с ошибкой, если
+// превышен порог -max-mismatches.
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	baseURL := fs.String("url", "http://localhost:8081", "target gateway address")
+	speed := fs.Float64("speed", 1, "pace relative to the original traffic: 1 - original, 2 - twice as fast, 0 - no pauses")
+	concurrency := fs.Int("c", 50, "maximum number of concurrent requests")
+	timeout := fs.Duration("timeout", 10*time.Second, "request timeout")
+	asJSON := fs.Bool("json", false, "print result as JSON")
+	maxMismatches := fs.Float64("max-mismatches", -1, "fail if the share of responses whose status differs from the log exceeds this value, 0..1")
+	var headers listFlag
+	fs.Var(&headers, "H", `request header "Name: value", may be repeated`)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apigw replay [flags] [access.log]  (reads stdin if no file is given)")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *speed < 0 {
+		return fmt.Errorf("ускорение не может быть отрицательным")
+	}
+
+	var input io.Reader = os.Stdin
+	if fs.NArg() > 0 && fs.Arg(0) != "-" {
+		file, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+	entries, err := replay.Read(input)
+	if err != nil {
+		return err
+	}
+
+	opts := replay.Options{
+		BaseURL:     *baseURL,
+		Speed:       *speed,
+		Concurrency: *concurrency,
+		Timeout:     *timeout,
+		Header:      http.Header{},
+	}
+	for _, value := range headers {
+		name, v, ok := strings.Cut(value, ":")
+		if !ok {
+			return fmt.Errorf("некорректный заголовок %q", value)
+		}
+		opts.Header.Add(strings.TrimSpace(name), strings.TrimSpace(v))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if len(entries) > 0 {
+		span := entries[len(entries)-1].Time.Sub(entries[0].Time)
+		fmt.Fprintf(os.Stderr, "Воспроизведение %d запросов за %s на %s, ускорение %g\n", len(entries), span, opts.BaseURL, opts.Speed)
+	}
+	result, err := replay.Run(ctx, entries, opts)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	} else {
+		printReplayResult(result)
+	}
+
+	if result.Requests == 0 {
+		return fmt.Errorf("не выполнено ни одного запроса")
+	}
+	if share := float64(result.Mismatches) / float64(result.Requests); *maxMismatches >= 0 && share > *maxMismatches {
+		return fmt.Errorf("доля расхождений статусов %.4f превышает порог %.4f", share, *maxMismatches)
+	}
+	return nil
+}
+
+// printReplayResult выводит результаты воспроизведения
+func printReplayResult(result *replay.Result) {
+	fmt.Printf("Запросов: %d за %s, ошибок: %d, статусы: %s\n", result.Requests, result.Duration.Round(time.Millisecond), result.Errors, formatStatuses(result.Statuses))
+	fmt.Printf("Расхождений статусов: %d\n", result.Mismatches)
+	if len(result.Examples) == 0 {
+		return
+	}
+
+	fmt.Println()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ЗАПРОС\tБЫЛО\tСТАЛО")
+	for _, m := range result.Examples {
+		fmt.Fprintf(tw, "%s\t%d\t%d\n", m.Target, m.Original, m.Replayed)
+	}
+	tw.Flush()
+}
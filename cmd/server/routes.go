@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"apigw/pkg/config"
+	"apigw/pkg/server"
+)
+
+// runRoutes выполняет подкоманды работы с таблицей маршрутов
+func runRoutes(args []string) error {
+	if len(args) == 0 || args[0] != "match" {
+		return fmt.Errorf(`использование: apigw routes match [-config файл] [-json] "[МЕТОД ]путь"`)
+	}
+	return runRoutesMatch(args[1:])
+}
+
+// runRoutesMatch выводит маршрут, цепочку middleware и backend-сервисы,
+// которые обработают запрос при текущей конфигурации
+func runRoutesMatch(args []string) error {
+	fs := flag.NewFlagSet("routes match", flag.ContinueOnError)
+	configPath := fs.String("config", "config.json", "path to config file")
+	asJSON := fs.Bool("json", false, "print result as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf(`укажите запрос, например "GET /api/news/42?comm=1"`)
+	}
+
+	method, target := http.MethodGet, strings.Join(fs.Args(), " ")
+	if m, path, ok := strings.Cut(target, " "); ok {
+		method, target = strings.ToUpper(m), strings.TrimSpace(path)
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	match, err := server.MatchRoute(cfg, method, target)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(match)
+	}
+	fmt.Printf("%s %s\n", method, target)
+	printRouteMatch(match, "")
+	return nil
+}
+
+// printRouteMatch выводит найденный маршрут
+func printRouteMatch(match *server.RouteMatch, indent string) {
+	if match.Redirect != "" {
+		fmt.Printf("%sПеренаправление: %d %s\n", indent, match.RedirectStatus, match.Redirect)
+		return
+	}
+	if match.Rewritten != "" {
+		fmt.Printf("%sПерезапись: %s\n", indent, match.Rewritten)
+	}
+	if match.Pattern == "" {
+		fmt.Printf("%sМаршрут:    не найден (404)\n", indent)
+		return
+	}
+	fmt.Printf("%sМаршрут:    %s (%s)\n", indent, match.Pattern, match.Kind)
+	fmt.Printf("%sОбработчик: %s\n", indent, match.Handler)
+	if len(match.Middleware) > 0 {
+		fmt.Printf("%sMiddleware: %s\n", indent, strings.Join(match.Middleware, " -> "))
+	}
+	for _, backend := range match.Backends {
+		fmt.Printf("%sBackend:    %s\n", indent, backend)
+	}
+	if match.Inner != nil {
+		fmt.Printf("%sВнутренний маршрут:\n", indent)
+		printRouteMatch(match.Inner, indent+"  ")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"apigw/pkg/config"
+	"apigw/pkg/contract"
+)
+
+// runVerifyBackends проверяет контракт сервисов новостей и комментариев из
+// конфигурации и завершается с ошибкой, если найдены несовместимости
+func runVerifyBackends(args []string) error {
+	fs := flag.NewFlagSet("verify-backends", flag.ContinueOnError)
+	configPath := fs.String("config", "config.json", "path to config file")
+	newsURL := fs.String("news", "", "news service address (overrides config)")
+	commentsURL := fs.String("comments", "", "comments service address (overrides config)")
+	write := fs.Bool("write", false, "also check adding a comment (creates a comment in the service)")
+	timeout := fs.Duration("timeout", 10*time.Second, "request timeout")
+	asJSON := fs.Bool("json", false, "print results as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts := contract.Options{
+		NewsURL:     *newsURL,
+		CommentsURL: *commentsURL,
+		Write:       *write,
+		Client:      &http.Client{Timeout: *timeout},
+	}
+	if opts.NewsURL == "" || opts.CommentsURL == "" {
+		cfg, err := config.LoadConfig(*configPath)
+		if err != nil {
+			return err
+		}
+		if opts.NewsURL == "" {
+			opts.NewsURL = cfg.Services.URL(config.ServiceNews)
+		}
+		if opts.CommentsURL == "" {
+			opts.CommentsURL = cfg.Services.URL(config.ServiceComments)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	results := contract.Run(ctx, opts)
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		printContractResults(results)
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Failed() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("не пройдено проверок: %d из %d", failed, len(results))
+	}
+	return nil
+}
+
+// printContractResults выводит результаты проверок таблицей
+func printContractResults(results []contract.Result) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "СЕРВИС\tПРОВЕРКА\tРЕЗУЛЬТАТ")
+	for _, r := range results {
+		status := "ok"
+		switch {
+		case r.Skipped:
+			status = "пропущена"
+		case r.Failed():
+			status = "ошибка"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Service, r.Check, status)
+		for _, problem := range r.Problems {
+			fmt.Fprintf(tw, "\t\t  %s\n", problem)
+		}
+	}
+	tw.Flush()
+}
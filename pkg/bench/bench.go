@@ -0,0 +1,238 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Route - маршрут в нагрузочной смеси
+type Route struct {
+	Method string
+	Path   string
+	// Weight - относительная доля запросов к маршруту
+	Weight int
+}
+
+// ParseRoute разбирает маршрут в формате "[вес:][МЕТОД ]путь",
+// например "3:/api/news?page=1" или "POST /api/batch"
+func ParseRoute(value string) (Route, error) {
+	route := Route{Method: http.MethodGet, Weight: 1}
+
+	if weight, rest, ok := strings.Cut(value, ":"); ok && !strings.HasPrefix(weight, "/") {
+		n, err := strconv.Atoi(weight)
+		if err != nil || n <= 0 {
+			return Route{}, fmt.Errorf("некорректный вес маршрута %q", value)
+		}
+		route.Weight = n
+		value = rest
+	}
+
+	if method, path, ok := strings.Cut(value, " "); ok {
+		route.Method = strings.ToUpper(method)
+		value = strings.TrimSpace(path)
+	}
+	if !strings.HasPrefix(value, "/") {
+		return Route{}, fmt.Errorf("путь маршрута должен начинаться с /: %q", value)
+	}
+	route.Path = value
+	return route, nil
+}
+
+// Options - параметры нагрузки
+type Options struct {
+	// BaseURL - адрес шлюза, например http://localhost:8081
+	BaseURL string
+	Routes  []Route
+	// Concurrency - количество одновременно работающих клиентов
+	Concurrency int
+	// Duration - длительность нагрузки
+	Duration time.Duration
+	// Timeout - таймаут одного запроса
+	Timeout time.Duration
+	// Header - заголовки, добавляемые к каждому запросу
+	Header http.Header
+}
+
+// RouteStats - результаты по маршруту. Задержки в JSON передаются в наносекундах.
+type RouteStats struct {
+	Route    string `json:"route"`
+	Requests int    `json:"requests"`
+	// Errors - запросы, на которые не получен ответ (сетевые ошибки, таймауты)
+	Errors    int         `json:"errors"`
+	Statuses  map[int]int `json:"statuses"`
+	latencies []time.Duration
+	P50       time.Duration `json:"p50"`
+	P90       time.Duration `json:"p90"`
+	P99       time.Duration `json:"p99"`
+	Max       time.Duration `json:"max"`
+}
+
+// Report - результаты нагрузки
+type Report struct {
+	Duration time.Duration `json:"duration"`
+	Total    RouteStats    `json:"total"`
+	Routes   []RouteStats  `json:"routes"`
+}
+
+// RPS возвращает среднее количество запросов в секунду
+func (r *Report) RPS() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Total.Requests) / r.Duration.Seconds()
+}
+
+// sample - результат одного запроса
+type sample struct {
+	route   int
+	status  int
+	err     bool
+	latency time.Duration
+}
+
+// Run создает нагрузку на шлюз и собирает задержки ответов
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	if len(opts.Routes) == 0 {
+		return nil, fmt.Errorf("не задано ни одного маршрута")
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	baseURL := strings.TrimSuffix(opts.BaseURL, "/")
+
+	// Маршрут выбирается по весу через таблицу накопленных весов
+	cumulative := make([]int, len(opts.Routes))
+	totalWeight := 0
+	for i, route := range opts.Routes {
+		totalWeight += route.Weight
+		cumulative[i] = totalWeight
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+
+	client := &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &http.Transport{
+			MaxIdleConnsPerHost: opts.Concurrency,
+		},
+	}
+
+	samples := make(chan sample, opts.Concurrency*4)
+	var wg sync.WaitGroup
+	for worker := 0; worker < opts.Concurrency; worker++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(seed))
+			for ctx.Err() == nil {
+				n := rnd.Intn(totalWeight)
+				i := sort.SearchInts(cumulative, n+1)
+				samples <- send(ctx, client, baseURL, opts.Header, i, opts.Routes[i])
+			}
+		}(time.Now().UnixNano() + int64(worker))
+	}
+
+	start := time.Now()
+	go func() {
+		wg.Wait()
+		close(samples)
+	}()
+
+	report := &Report{Total: newStats("всего")}
+	report.Routes = make([]RouteStats, len(opts.Routes))
+	for i, route := range opts.Routes {
+		report.Routes[i] = newStats(route.Method + " " + route.Path)
+	}
+	for s := range samples {
+		// Запросы, прерванные окончанием нагрузки, не учитываются
+		if s.err && ctx.Err() != nil {
+			continue
+		}
+		report.Routes[s.route].add(s)
+		report.Total.add(s)
+	}
+	report.Duration = time.Since(start)
+
+	report.Total.finish()
+	for i := range report.Routes {
+		report.Routes[i].finish()
+	}
+	return report, nil
+}
+
+// send выполняет один запрос и измеряет время до получения всего ответа
+func send(ctx context.Context, client *http.Client, baseURL string, header http.Header, i int, route Route) sample {
+	s := sample{route: i}
+
+	req, err := http.NewRequestWithContext(ctx, route.Method, baseURL+route.Path, nil)
+	if err != nil {
+		s.err = true
+		return s
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		s.err = true
+		s.latency = time.Since(start)
+		return s
+	}
+	_, err = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	s.latency = time.Since(start)
+	s.status = resp.StatusCode
+	s.err = err != nil
+	return s
+}
+
+// newStats создает пустую статистику
+func newStats(name string) RouteStats {
+	return RouteStats{Route: name, Statuses: map[int]int{}}
+}
+
+// add учитывает результат запроса
+func (rs *RouteStats) add(s sample) {
+	rs.Requests++
+	if s.err {
+		rs.Errors++
+		return
+	}
+	rs.Statuses[s.status]++
+	rs.latencies = append(rs.latencies, s.latency)
+}
+
+// finish вычисляет перцентили задержки
+func (rs *RouteStats) finish() {
+	if len(rs.latencies) == 0 {
+		return
+	}
+	sort.Slice(rs.latencies, func(i, j int) bool { return rs.latencies[i] < rs.latencies[j] })
+	rs.P50 = percentile(rs.latencies, 0.50)
+	rs.P90 = percentile(rs.latencies, 0.90)
+	rs.P99 = percentile(rs.latencies, 0.99)
+	rs.Max = rs.latencies[len(rs.latencies)-1]
+}
+
+// percentile возвращает перцентиль отсортированного списка задержек
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(float64(len(sorted))*p+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
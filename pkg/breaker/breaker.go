@@ -0,0 +1,168 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+// State - состояние автоматического выключателя
+type State int
+
+const (
+	// Closed - запросы проходят, ошибки подсчитываются
+	Closed State = iota
+	// Open - запросы отклоняются без обращения к сервису
+	Open
+	// HalfOpen - пропускается несколько пробных запросов, по результату
+	// которых выключатель замыкается или снова размыкается
+	HalfOpen
+)
+
+// String возвращает название состояния для логов и метрик
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half_open"
+	}
+	return "closed"
+}
+
+// Result - результат запроса, пропущенного выключателем
+type Result int
+
+const (
+	// Success - сервис ответил
+	Success Result = iota
+	// Failure - сервис не ответил или вернул ошибку
+	Failure
+	// Ignored - запрос не дает сведений о сервисе (например, клиент отменил запрос)
+	Ignored
+)
+
+// Options - параметры выключателя
+type Options struct {
+	// FailureThreshold - сколько ошибок подряд размыкают выключатель
+	FailureThreshold int
+	// OpenTimeout - через сколько после размыкания пропускаются пробные запросы
+	OpenTimeout time.Duration
+	// HalfOpenRequests - сколько пробных запросов подряд должны быть успешными,
+	// чтобы выключатель замкнулся
+	HalfOpenRequests int
+	// OnStateChange вызывается при смене состояния. Вызов выполняется под
+	// блокировкой выключателя, обращаться к нему из OnStateChange нельзя.
+	OnStateChange func(from, to State)
+}
+
+// Breaker - автоматический выключатель (circuit breaker): после серии
+// ошибок запросы к сервису некоторое время отклоняются сразу, чтобы не
+// нагружать неработающий сервис и не заставлять клиентов ждать таймаута
+type Breaker struct {
+	opts Options
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	// probes - число пробных запросов в состоянии HalfOpen, successes - из них успешных
+	probes    int
+	successes int
+	// generation увеличивается при смене состояния, чтобы результаты запросов,
+	// начатых в предыдущем состоянии, не учитывались
+	generation uint64
+}
+
+// New создает выключатель. Незаданные параметры заменяются значениями по умолчанию.
+func New(opts Options) *Breaker {
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = 5
+	}
+	if opts.OpenTimeout <= 0 {
+		opts.OpenTimeout = 30 * time.Second
+	}
+	if opts.HalfOpenRequests <= 0 {
+		opts.HalfOpenRequests = 1
+	}
+	return &Breaker{opts: opts}
+}
+
+// Allow проверяет, можно ли выполнить запрос. Если можно, после получения
+// результата нужно вызвать done.
+func (b *Breaker) Allow() (done func(Result), ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Open {
+		if time.Since(b.openedAt) < b.opts.OpenTimeout {
+			return nil, false
+		}
+		b.setState(HalfOpen)
+	}
+	if b.state == HalfOpen {
+		if b.probes >= b.opts.HalfOpenRequests {
+			return nil, false
+		}
+		b.probes++
+	}
+	generation := b.generation
+	return func(result Result) { b.record(generation, result) }, true
+}
+
+// State возвращает текущее состояние выключателя
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// record учитывает результат запроса, пропущенного в поколении состояния generation
+func (b *Breaker) record(generation uint64, result Result) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+	switch b.state {
+	case Closed:
+		switch result {
+		case Success:
+			b.failures = 0
+		case Failure:
+			b.failures++
+			if b.failures >= b.opts.FailureThreshold {
+				b.setState(Open)
+			}
+		}
+	case HalfOpen:
+		switch result {
+		case Success:
+			b.successes++
+			if b.successes >= b.opts.HalfOpenRequests {
+				b.setState(Closed)
+			}
+		case Failure:
+			b.setState(Open)
+		case Ignored:
+			// Место пробного запроса освобождается для следующего
+			b.probes--
+		}
+	}
+}
+
+// setState переводит выключатель в состояние to. Вызывается под b.mu.
+func (b *Breaker) setState(to State) {
+	from := b.state
+	b.state = to
+	b.generation++
+	b.failures = 0
+	b.probes = 0
+	b.successes = 0
+	if to == Open {
+		b.openedAt = time.Now()
+	}
+	if b.opts.OnStateChange != nil && from != to {
+		b.opts.OnStateChange(from, to)
+	}
+}
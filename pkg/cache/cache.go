@@ -0,0 +1,20 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound возвращается, если ключ отсутствует в кэше или его срок истек
+var ErrNotFound = errors.New("cache: ключ не найден")
+
+// Cache описывает хранилище закэшированных значений
+type Cache interface {
+	// Get возвращает значение по ключу или ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set сохраняет значение на время ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete удаляет значение по ключу
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// KeyOptions описывает правила нормализации параметров запроса
+type KeyOptions struct {
+	// Ignored - параметры, не влияющие на ответ (например, request_id)
+	Ignored []string
+	// Defaults - значения параметров по умолчанию; совпадающие с ними значения отбрасываются
+	Defaults map[string]string
+	// CaseInsensitive - параметры, значение которых сравнивается без учета регистра
+	CaseInsensitive []string
+}
+
+// KeyBuilder строит канонические ключи кэша, чтобы эквивалентные запросы
+// попадали в одну запись
+type KeyBuilder struct {
+	ignored         map[string]struct{}
+	defaults        map[string]string
+	caseInsensitive map[string]struct{}
+}
+
+// NewKeyBuilder создает построитель ключей с заданными правилами нормализации
+func NewKeyBuilder(opts KeyOptions) *KeyBuilder {
+	b := &KeyBuilder{
+		ignored:         make(map[string]struct{}, len(opts.Ignored)),
+		defaults:        make(map[string]string, len(opts.Defaults)),
+		caseInsensitive: make(map[string]struct{}, len(opts.CaseInsensitive)),
+	}
+	for _, name := range opts.Ignored {
+		b.ignored[name] = struct{}{}
+	}
+	for name, value := range opts.Defaults {
+		b.defaults[name] = value
+	}
+	for _, name := range opts.CaseInsensitive {
+		b.caseInsensitive[name] = struct{}{}
+	}
+	return b
+}
+
+// Key возвращает канонический ключ для метода, пути и параметров запроса.
+// Параметры сортируются по имени, игнорируемые и пустые отбрасываются,
+// значения по умолчанию опускаются. Обработчики читают только первое
+// значение параметра, поэтому остальные значения в ключ не попадают.
+func (b *KeyBuilder) Key(method, urlPath string, query url.Values) string {
+	names := make([]string, 0, len(query))
+	values := make(map[string]string, len(query))
+
+	for name, vals := range query {
+		if _, skip := b.ignored[name]; skip || len(vals) == 0 {
+			continue
+		}
+
+		value := b.normalizeValue(name, vals[0])
+		if value == "" {
+			continue
+		}
+
+		names = append(names, name)
+		values[name] = value
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString(strings.ToUpper(method))
+	sb.WriteByte(' ')
+	sb.WriteString(urlPath)
+
+	sep := byte('?')
+	for _, name := range names {
+		sb.WriteByte(sep)
+		sb.WriteString(url.QueryEscape(name))
+		sb.WriteByte('=')
+		sb.WriteString(url.QueryEscape(values[name]))
+		sep = '&'
+	}
+
+	return sb.String()
+}
+
+// normalizeValue приводит значение параметра к каноническому виду.
+// Пустая строка означает, что параметр не влияет на ответ.
+func (b *KeyBuilder) normalizeValue(name, value string) string {
+	value = strings.TrimSpace(value)
+	if _, ok := b.caseInsensitive[name]; ok {
+		value = strings.ToLower(value)
+	}
+
+	def, hasDefault := b.defaults[name]
+	if !hasDefault {
+		return value
+	}
+
+	// Для числовых параметров с умолчанием обработчики заменяют
+	// некорректные и неположительные значения на значение по умолчанию
+	if _, err := strconv.Atoi(def); err == nil {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return ""
+		}
+		value = strconv.Itoa(n)
+	}
+
+	if value == def {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// Memcached - кэш, хранящий значения в кластере memcached
+type Memcached struct {
+	client *memcache.Client
+	prefix string
+}
+
+// NewMemcached создает кэш поверх указанных серверов memcached
+func NewMemcached(servers []string, prefix string, timeout time.Duration) *Memcached {
+	client := memcache.New(servers...)
+	if timeout > 0 {
+		client.Timeout = timeout
+	}
+	return &Memcached{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// itemKey приводит ключ к виду, допустимому в memcached:
+// не длиннее 250 байт и без пробелов и управляющих символов
+func (m *Memcached) itemKey(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return m.prefix + hex.EncodeToString(sum[:])
+}
+
+// Get возвращает значение по ключу
+func (m *Memcached) Get(_ context.Context, key string) ([]byte, error) {
+	item, err := m.client.Get(m.itemKey(key))
+	if err != nil {
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return item.Value, nil
+}
+
+// Set сохраняет значение на время ttl (с точностью до секунды)
+func (m *Memcached) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	expiration := int32(ttl / time.Second)
+	if expiration < 1 {
+		expiration = 1
+	}
+	return m.client.Set(&memcache.Item{
+		Key:        m.itemKey(key),
+		Value:      value,
+		Expiration: expiration,
+	})
+}
+
+// Delete удаляет значение по ключу
+func (m *Memcached) Delete(_ context.Context, key string) error {
+	err := m.client.Delete(m.itemKey(key))
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return nil
+	}
+	return err
+}
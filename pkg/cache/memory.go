@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Количество записей, после добавления которых запускается очистка устаревших
+const sweepInterval = 1024
+
+// memoryEntry - запись in-memory кэша
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// Memory - потокобезопасный кэш в памяти процесса. При заполнении
+// вытесняется запись, к которой дольше всего не обращались (LRU).
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// order - записи от недавно использованных к давно не использованным
+	order      *list.List
+	sets       int
+	maxEntries int
+}
+
+// NewMemory создает пустой кэш в памяти, вмещающий не более maxEntries записей
+// (0 - без ограничения)
+func NewMemory(maxEntries int) *Memory {
+	return &Memory{
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		maxEntries: maxEntries,
+	}
+}
+
+// Get возвращает значение по ключу, если его срок еще не истек
+func (m *Memory) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, ok := m.entries[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	entry := elem.Value.(*memoryEntry)
+	if time.Now().After(entry.expiresAt) {
+		m.remove(elem)
+		return nil, ErrNotFound
+	}
+	m.order.MoveToFront(elem)
+	return entry.value, nil
+}
+
+// Set сохраняет значение на время ttl
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expiresAt := time.Now().Add(ttl)
+	if elem, ok := m.entries[key]; ok {
+		entry := elem.Value.(*memoryEntry)
+		entry.value, entry.expiresAt = value, expiresAt
+		m.order.MoveToFront(elem)
+		return nil
+	}
+
+	if m.maxEntries > 0 && len(m.entries) >= m.maxEntries {
+		m.remove(m.order.Back())
+	}
+	m.entries[key] = m.order.PushFront(&memoryEntry{key: key, value: value, expiresAt: expiresAt})
+
+	// Периодически удаляем устаревшие записи, чтобы кэш не рос бесконечно
+	m.sets++
+	if m.sets >= sweepInterval {
+		m.sets = 0
+		now := time.Now()
+		for _, elem := range m.entries {
+			if now.After(elem.Value.(*memoryEntry).expiresAt) {
+				m.remove(elem)
+			}
+		}
+	}
+	return nil
+}
+
+// remove удаляет запись из кэша
+func (m *Memory) remove(elem *list.Element) {
+	m.order.Remove(elem)
+	delete(m.entries, elem.Value.(*memoryEntry).key)
+}
+
+// Delete удаляет значение по ключу
+func (m *Memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elem, ok := m.entries[key]; ok {
+		m.remove(elem)
+	}
+	return nil
+}
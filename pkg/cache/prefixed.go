@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Prefixed - раздел общего хранилища: ко всем ключам добавляется префикс,
+// поэтому записи разных разделов не пересекаются, в том числе версии тегов
+type Prefixed struct {
+	store  Cache
+	prefix string
+}
+
+// NewPrefixed создает раздел хранилища store с префиксом ключей prefix
+func NewPrefixed(store Cache, prefix string) *Prefixed {
+	return &Prefixed{store: store, prefix: prefix}
+}
+
+// Get возвращает значение по ключу раздела
+func (p *Prefixed) Get(ctx context.Context, key string) ([]byte, error) {
+	return p.store.Get(ctx, p.prefix+key)
+}
+
+// Set сохраняет значение по ключу раздела
+func (p *Prefixed) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return p.store.Set(ctx, p.prefix+key, value, ttl)
+}
+
+// Delete удаляет значение по ключу раздела
+func (p *Prefixed) Delete(ctx context.Context, key string) error {
+	return p.store.Delete(ctx, p.prefix+key)
+}
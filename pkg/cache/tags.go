@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Время хранения версий тегов; должно заметно превышать TTL любых записей
+const tagVersionTTL = 24 * time.Hour
+
+// Tags реализует групповую инвалидацию через версии тегов: версия тега
+// входит в ключ записи, поэтому после смены версии старые записи
+// перестают находиться и вытесняются по TTL. Подход работает с любым
+// хранилищем, включая memcached, который не умеет удалять ключи по шаблону.
+type Tags struct {
+	store Cache
+}
+
+// NewTags создает менеджер тегов поверх хранилища кэша
+func NewTags(store Cache) *Tags {
+	return &Tags{store: store}
+}
+
+// tagKey возвращает ключ, под которым хранится версия тега
+func tagKey(tag string) string {
+	return "tag:" + tag
+}
+
+// Version возвращает текущую версию тега ("0", если тег еще не инвалидировался)
+func (t *Tags) Version(ctx context.Context, tag string) (string, error) {
+	data, err := t.store.Get(ctx, tagKey(tag))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return "0", nil
+		}
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Invalidate меняет версии тегов, делая недоступными все связанные с ними записи
+func (t *Tags) Invalidate(ctx context.Context, tags ...string) error {
+	version := []byte(strconv.FormatInt(time.Now().UnixNano(), 36))
+	for _, tag := range tags {
+		if err := t.store.Set(ctx, tagKey(tag), version, tagVersionTTL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
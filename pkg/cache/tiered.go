@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Tiered - двухуровневый кэш: небольшой кэш в памяти процесса с коротким TTL
+// перед общим хранилищем (Redis, memcached). Самые популярные ключи
+// обслуживаются локально без сетевых обращений к общему хранилищу.
+type Tiered struct {
+	local    *Memory
+	shared   Cache
+	localTTL time.Duration
+}
+
+// NewTiered создает двухуровневый кэш
+func NewTiered(local *Memory, shared Cache, localTTL time.Duration) *Tiered {
+	return &Tiered{
+		local:    local,
+		shared:   shared,
+		localTTL: localTTL,
+	}
+}
+
+// Get ищет значение сначала в локальном кэше, затем в общем
+func (t *Tiered) Get(ctx context.Context, key string) ([]byte, error) {
+	if value, err := t.local.Get(ctx, key); err == nil {
+		return value, nil
+	}
+
+	value, err := t.shared.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Оставшееся время жизни записи в общем кэше неизвестно,
+	// поэтому локально храним ее не дольше localTTL
+	t.local.Set(ctx, key, value, t.localTTL)
+	return value, nil
+}
+
+// Set сохраняет значение в обоих уровнях
+func (t *Tiered) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := t.shared.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+
+	localTTL := t.localTTL
+	if ttl < localTTL {
+		localTTL = ttl
+	}
+	return t.local.Set(ctx, key, value, localTTL)
+}
+
+// Delete удаляет значение из обоих уровней
+func (t *Tiered) Delete(ctx context.Context, key string) error {
+	t.local.Delete(ctx, key)
+	return t.shared.Delete(ctx, key)
+}
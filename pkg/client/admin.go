@@ -0,0 +1,208 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Admin - клиент административного API шлюза. Токен admin.token передается
+// в заголовке Authorization: Bearer.
+type Admin struct {
+	c *Client
+}
+
+// NewAdmin создает клиент административного API по адресу baseURL, например
+// http://localhost:9090. Токен из opts.Token заменяется значением token.
+func NewAdmin(baseURL, token string, opts Options) (*Admin, error) {
+	opts.Token = token
+	opts.APIKey = ""
+	c, err := New(baseURL, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Admin{c: c}, nil
+}
+
+// Route - маршрут шлюза с цепочкой middleware и backend-сервисами
+type Route struct {
+	Pattern string `json:"pattern"`
+	// Kind - вид маршрута: "builtin", "version", "proxy" или "static"
+	Kind       string   `json:"kind,omitempty"`
+	Handler    string   `json:"handler,omitempty"`
+	Middleware []string `json:"middleware,omitempty"`
+	Backends   []string `json:"backends,omitempty"`
+}
+
+// HealthCheck - результат активной проверки backend-сервиса
+type HealthCheck struct {
+	Healthy  bool       `json:"healthy"`
+	Reason   string     `json:"reason,omitempty"`
+	Since    *time.Time `json:"since,omitempty"`
+	Failures int        `json:"consecutive_failures"`
+}
+
+// Backend - состояние backend-сервиса
+type Backend struct {
+	Upstream string   `json:"upstream"`
+	Services []string `json:"services"`
+	// Healthy - последний запрос к сервису не завершился ошибкой соединения или ответом 502-504
+	Healthy     bool         `json:"healthy"`
+	HealthCheck *HealthCheck `json:"health_check,omitempty"`
+	// CircuitBreaker - состояние выключателя, пусто - выключатель не настроен
+	CircuitBreaker string `json:"circuit_breaker,omitempty"`
+}
+
+// CachePurge описывает записи кэша ответов, которые нужно сбросить
+type CachePurge struct {
+	// Routes - кэшируемые маршруты, например /api/news или /api/news/
+	Routes []string `json:"routes,omitempty"`
+	// NewsIDs - изменившиеся новости
+	NewsIDs []int64 `json:"news_ids,omitempty"`
+	// Pattern - шаблон кэшируемых маршрутов в синтаксисе path.Match
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// Maintenance - режим обслуживания
+type Maintenance struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+	// RetryAfter - через сколько клиентам стоит повторить запрос, например "30s"
+	RetryAfter string     `json:"retry_after,omitempty"`
+	Since      *time.Time `json:"since,omitempty"`
+}
+
+// APIKey - ключ API клиента. Key заполняется только у созданного ключа.
+type APIKey struct {
+	Client      string `json:"client"`
+	Fingerprint string `json:"fingerprint"`
+	// Source - где задан ключ: "config" (auth.keys) или "file" (auth.keys_file)
+	Source string `json:"source"`
+	Key    string `json:"key,omitempty"`
+}
+
+// Routes возвращает маршруты текущей конфигурации
+func (a *Admin) Routes(ctx context.Context) ([]Route, error) {
+	var resp struct {
+		Routes []Route `json:"routes"`
+	}
+	if err := a.c.do(ctx, http.MethodGet, "/admin/routes", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Routes, nil
+}
+
+// Backends возвращает состояние backend-сервисов
+func (a *Admin) Backends(ctx context.Context) ([]Backend, error) {
+	var resp struct {
+		Backends []Backend `json:"backends"`
+	}
+	if err := a.c.do(ctx, http.MethodGet, "/admin/backends", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Backends, nil
+}
+
+// FlushCache сбрасывает весь кэш ответов или, если указаны tags, записи с
+// этими тегами, и возвращает сброшенные теги
+func (a *Admin) FlushCache(ctx context.Context, tags ...string) ([]string, error) {
+	query := url.Values{}
+	if len(tags) > 0 {
+		query.Set("tags", strings.Join(tags, ","))
+	}
+	var resp struct {
+		Flushed []string `json:"flushed"`
+	}
+	if err := a.c.do(ctx, http.MethodPost, "/admin/cache/flush", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Flushed, nil
+}
+
+// PurgeCache сбрасывает записи кэша ответов по маршрутам, ID новостей или
+// шаблону и возвращает сброшенные теги
+func (a *Admin) PurgeCache(ctx context.Context, purge CachePurge) ([]string, error) {
+	body, err := json.Marshal(purge)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Purged []string `json:"purged"`
+	}
+	if err := a.c.do(ctx, http.MethodPost, "/admin/cache/purge", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Purged, nil
+}
+
+// Maintenance возвращает режим обслуживания
+func (a *Admin) Maintenance(ctx context.Context) (*Maintenance, error) {
+	var mode Maintenance
+	if err := a.c.do(ctx, http.MethodGet, "/admin/maintenance", nil, nil, &mode); err != nil {
+		return nil, err
+	}
+	return &mode, nil
+}
+
+// SetMaintenance включает или выключает режим обслуживания. Пока режим
+// включен, шлюз отвечает на запросы API 503, что позволяет вывести
+// экземпляр из балансировки перед остановкой.
+func (a *Admin) SetMaintenance(ctx context.Context, mode Maintenance) (*Maintenance, error) {
+	mode.Since = nil
+	body, err := json.Marshal(mode)
+	if err != nil {
+		return nil, err
+	}
+	var result Maintenance
+	if err := a.c.do(ctx, http.MethodPut, "/admin/maintenance", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// APIKeys возвращает ключи API без самих ключей
+func (a *Admin) APIKeys(ctx context.Context) ([]APIKey, error) {
+	var resp struct {
+		Keys []APIKey `json:"keys"`
+	}
+	if err := a.c.do(ctx, http.MethodGet, "/admin/keys", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Keys, nil
+}
+
+// AddAPIKey добавляет ключ API клиента в файл ключей шлюза. Пустой key -
+// шлюз создает случайный ключ; созданный ключ возвращается в APIKey.Key.
+func (a *Admin) AddAPIKey(ctx context.Context, client, key string) (*APIKey, error) {
+	body, err := json.Marshal(map[string]string{"client": client, "key": key})
+	if err != nil {
+		return nil, err
+	}
+	var created APIKey
+	if err := a.c.do(ctx, http.MethodPost, "/admin/keys", nil, body, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// RevokeAPIKeys отзывает ключи клиента или ключ с отпечатком fingerprint
+// (указывается одно из двух) и возвращает отозванные ключи
+func (a *Admin) RevokeAPIKeys(ctx context.Context, client, fingerprint string) ([]APIKey, error) {
+	query := url.Values{}
+	if client != "" {
+		query.Set("client", client)
+	}
+	if fingerprint != "" {
+		query.Set("fingerprint", fingerprint)
+	}
+	var resp struct {
+		Revoked []APIKey `json:"revoked"`
+	}
+	if err := a.c.do(ctx, http.MethodDelete, "/admin/keys", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Revoked, nil
+}
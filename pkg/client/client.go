@@ -0,0 +1,316 @@
+// Package client - клиент API Gateway новостного сервиса для Go сервисов.
+// Клиент передает идентификатор запроса и учетные данные, повторяет
+// идемпотентные запросы при временных ошибках и обходит страницы списков.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options - параметры клиента
+type Options struct {
+	// HTTPClient - HTTP клиент для запросов (по умолчанию http.Client с таймаутом 10s)
+	HTTPClient *http.Client
+	// APIKey - ключ API, передается в заголовке X-API-Key
+	APIKey string
+	// Token - токен доступа, передается в заголовке Authorization: Bearer
+	Token string
+	// Retries - количество повторов GET запросов при сетевых ошибках и ответах
+	// 502, 503, 504 (по умолчанию 2, отрицательное значение отключает повторы)
+	Retries int
+	// RetryBackoff - пауза перед первым повтором, далее удваивается (по умолчанию 100ms)
+	RetryBackoff time.Duration
+	// UserAgent - значение заголовка User-Agent
+	UserAgent string
+}
+
+// Client - клиент API Gateway. Безопасен для одновременного использования.
+type Client struct {
+	baseURL *url.URL
+	opts    Options
+}
+
+// New создает клиент для шлюза по адресу baseURL, например http://localhost:8081
+func New(baseURL string, opts Options) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("некорректный адрес шлюза: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("адрес шлюза должен начинаться с http:// или https://: %q", baseURL)
+	}
+
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.Retries == 0 {
+		opts.Retries = 2
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = 100 * time.Millisecond
+	}
+	return &Client{baseURL: u, opts: opts}, nil
+}
+
+// News - новость
+type News struct {
+	ID          int64  `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	PubDate     string `json:"pub_date"`
+	SourceURL   string `json:"source_url"`
+	CreatedAt   string `json:"created_at,omitempty"`
+}
+
+// Comment - комментарий к новости
+type Comment struct {
+	ID        int64  `json:"id"`
+	NewsID    int64  `json:"news_id"`
+	ParentID  int64  `json:"parent_id,omitempty"`
+	Text      string `json:"text"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// NewsPage - страница списка новостей
+type NewsPage struct {
+	Items        []News `json:"items"`
+	TotalPages   int    `json:"total_pages"`
+	CurrentPage  int    `json:"current_page"`
+	ItemsPerPage int    `json:"items_per_page"`
+	TotalItems   int    `json:"total_items"`
+}
+
+// ListOptions - параметры запроса списка новостей
+type ListOptions struct {
+	// Page и Count - номер страницы и количество новостей на странице
+	// (0 - значения шлюза по умолчанию)
+	Page  int
+	Count int
+	// Search - поиск по заголовку
+	Search string
+	// Full - запрашивать новости с описанием (/api/fullnews)
+	Full bool
+}
+
+// ListNews возвращает страницу списка новостей
+func (c *Client) ListNews(ctx context.Context, opts ListOptions) (*NewsPage, error) {
+	query := url.Values{}
+	if opts.Page > 0 {
+		query.Set("page", strconv.Itoa(opts.Page))
+	}
+	if opts.Count > 0 {
+		query.Set("count", strconv.Itoa(opts.Count))
+	}
+	if opts.Search != "" {
+		query.Set("s", opts.Search)
+	}
+	path := "/api/news"
+	if opts.Full {
+		path = "/api/fullnews"
+	}
+
+	var page NewsPage
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// EachNews обходит все страницы списка новостей начиная с opts.Page и вызывает
+// fn для каждой новости. Обход прекращается, если fn вернула ошибку.
+func (c *Client) EachNews(ctx context.Context, opts ListOptions, fn func(News) error) error {
+	if opts.Page <= 0 {
+		opts.Page = 1
+	}
+	for {
+		page, err := c.ListNews(ctx, opts)
+		if err != nil {
+			return err
+		}
+		for _, item := range page.Items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		if len(page.Items) == 0 || opts.Page >= page.TotalPages {
+			return nil
+		}
+		opts.Page++
+	}
+}
+
+// GetNews возвращает новость по ID. Если новость не найдена, возвращается
+// ошибка, для которой IsNotFound возвращает true.
+func (c *Client) GetNews(ctx context.Context, id int64) (*News, error) {
+	var news News
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/news/%d", id), nil, nil, &news); err != nil {
+		return nil, err
+	}
+	return &news, nil
+}
+
+// ListComments возвращает комментарии к новости
+func (c *Client) ListComments(ctx context.Context, newsID int64) ([]Comment, error) {
+	var comments []Comment
+	query := url.Values{"id": {strconv.FormatInt(newsID, 10)}}
+	if err := c.do(ctx, http.MethodGet, "/api/comments", query, nil, &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// AddComment добавляет комментарий к новости. Запрос не повторяется, чтобы
+// не создать комментарий дважды.
+func (c *Client) AddComment(ctx context.Context, newsID int64, text string) (*Comment, error) {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return nil, err
+	}
+
+	var comment Comment
+	query := url.Values{"news_id": {strconv.FormatInt(newsID, 10)}}
+	if err := c.do(ctx, http.MethodPost, "/api/comments/add", query, body, &comment); err != nil {
+		return nil, err
+	}
+	// Сервис комментариев может вернуть только идентификатор
+	if comment.NewsID == 0 {
+		comment.NewsID = newsID
+	}
+	if comment.Text == "" {
+		comment.Text = text
+	}
+	return &comment, nil
+}
+
+// do выполняет запрос к шлюзу и декодирует JSON ответ в out
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body []byte, out interface{}) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	if requestID := RequestID(ctx); requestID != "" {
+		query.Set("request_id", requestID)
+	}
+
+	target := *c.baseURL
+	target.Path = c.baseURL.Path + path
+	target.RawQuery = query.Encode()
+
+	attempts := 1
+	if method == http.MethodGet && c.opts.Retries > 0 {
+		attempts += c.opts.Retries
+	}
+	backoff := c.opts.RetryBackoff
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		resp, err := c.send(ctx, method, target.String(), body)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+
+		err = decodeResponse(resp, out)
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.temporary() {
+			lastErr = err
+			continue
+		}
+		return err
+	}
+	return lastErr
+}
+
+// send отправляет один HTTP запрос
+func (c *Client) send(ctx context.Context, method, target string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.opts.APIKey != "" {
+		req.Header.Set("X-API-Key", c.opts.APIKey)
+	}
+	if c.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.opts.Token)
+	}
+	if c.opts.UserAgent != "" {
+		req.Header.Set("User-Agent", c.opts.UserAgent)
+	}
+	return c.opts.HTTPClient.Do(req)
+}
+
+// decodeResponse декодирует успешный ответ или возвращает *Error
+func decodeResponse(resp *http.Response, out interface{}) error {
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("ошибка при чтении ответа: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newError(resp, data)
+	}
+	if out == nil || len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("ошибка при декодировании ответа: %w", err)
+	}
+	return nil
+}
+
+// newError разбирает ответ шлюза с ошибкой: {"error": {"code": ..., "message": ...}}.
+// Для ответов в другом формате текстом ошибки считается тело целиком.
+func newError(resp *http.Response, data []byte) *Error {
+	apiErr := &Error{
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(data)),
+		RequestID:  resp.Header.Get("X-Request-ID"),
+	}
+	var body struct {
+		Error struct {
+			Code      string `json:"code"`
+			Message   string `json:"message"`
+			RequestID string `json:"request_id"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error.Message != "" {
+		apiErr.Code = body.Error.Code
+		apiErr.Message = body.Error.Message
+		if body.Error.RequestID != "" {
+			apiErr.RequestID = body.Error.RequestID
+		}
+	}
+	return apiErr
+}
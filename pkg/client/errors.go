@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error - ошибка, которую вернул шлюз
+type Error struct {
+	StatusCode int
+	// Code - машиночитаемый код ошибки, например news_not_found
+	Code    string
+	Message string
+	// RequestID - идентификатор запроса для поиска в журналах шлюза
+	RequestID string
+}
+
+// Error возвращает описание ошибки
+func (e *Error) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("apigw: %d %s (request_id %s)", e.StatusCode, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("apigw: %d %s", e.StatusCode, e.Message)
+}
+
+// temporary сообщает, что запрос имеет смысл повторить
+func (e *Error) temporary() bool {
+	switch e.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// IsNotFound проверяет, что шлюз ответил 404
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// requestIDKey - ключ контекста с идентификатором запроса
+type requestIDKey struct{}
+
+// WithRequestID возвращает контекст, запросы с которым передают шлюзу
+// указанный request_id; так вызовы из сервиса связываются с его трассировкой
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID возвращает идентификатор запроса из контекста
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
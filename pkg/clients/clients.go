@@ -0,0 +1,81 @@
+// Package clients содержит общие части клиентов backend-сервисов новостей
+// и комментариев: отправку запросов и строгое декодирование ответов.
+// Сами клиенты находятся в пакетах clients/news и clients/comments.
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Doer отправляет запрос к backend-сервису. Шлюз передает клиентам свой
+// транспорт с повторами, выключателями и передачей request_id; в тестах
+// достаточно http.Client или заглушки.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc позволяет использовать функцию как Doer
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+// Do вызывает f(req)
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// StatusError возвращается, если сервис ответил статусом, отличным от 200
+type StatusError struct {
+	StatusCode int
+}
+
+// Error возвращает описание ошибки
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("сервис вернул статус %d", e.StatusCode)
+}
+
+// IsStatus проверяет, что сервис ответил статусом status
+func IsStatus(err error, status int) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == status
+}
+
+// GetJSON выполняет GET запрос по адресу target и строго декодирует JSON ответ в v
+func GetJSON(ctx context.Context, doer Doer, target string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := doer.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return &StatusError{StatusCode: resp.StatusCode}
+	}
+	if err := Decode(resp.Body, v); err != nil {
+		return fmt.Errorf("ошибка при декодировании ответа: %w", err)
+	}
+	return nil
+}
+
+// Decode строго декодирует JSON значение из r в v: типы полей должны
+// совпадать с моделью, а после значения не должно быть других данных.
+// Неизвестные поля пропускаются, чтобы сервисы могли добавлять новые поля
+// без обновления шлюза.
+func Decode(r io.Reader, v interface{}) error {
+	decoder := json.NewDecoder(r)
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("после JSON значения есть лишние данные")
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Package comments - клиент сервиса комментариев с типизированными моделями ответов
+package comments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"apigw/pkg/clients"
+)
+
+// Comment - комментарий в ответе сервиса комментариев
+type Comment struct {
+	ID     int64 `json:"id"`
+	NewsID int64 `json:"news_id"`
+	// ParentID - комментарий, на который дан ответ; nil для комментариев к новости
+	ParentID  *int64 `json:"parent_id,omitempty"`
+	Text      string `json:"text"`
+	UserID    string `json:"user_id,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Service - операции сервиса комментариев, которые использует шлюз
+type Service interface {
+	// List возвращает комментарии к новости
+	List(ctx context.Context, newsID int64) ([]Comment, error)
+}
+
+// Client - клиент сервиса комментариев по HTTP
+type Client struct {
+	baseURL string
+	doer    clients.Doer
+}
+
+// New создает клиент сервиса комментариев по адресу baseURL
+func New(baseURL string, doer clients.Doer) *Client {
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), doer: doer}
+}
+
+// List возвращает комментарии к новости. Комментарий без id считается
+// ошибкой ответа сервиса.
+func (c *Client) List(ctx context.Context, newsID int64) ([]Comment, error) {
+	var items []rawComment
+	if err := clients.GetJSON(ctx, c.doer, fmt.Sprintf("%s/api/comm_news?id=%d", c.baseURL, newsID), &items); err != nil {
+		return nil, err
+	}
+
+	result := make([]Comment, 0, len(items))
+	for i, raw := range items {
+		comment, err := raw.comment()
+		if err != nil {
+			return nil, fmt.Errorf("комментарий %d в ответе: %w", i, err)
+		}
+		result = append(result, comment)
+	}
+	return result, nil
+}
+
+// rawComment - комментарий в том виде, в котором его вернул сервис. Старые
+// версии сервиса возвращают текст в поле message.
+type rawComment struct {
+	ID        *int64 `json:"id"`
+	NewsID    int64  `json:"news_id"`
+	ParentID  *int64 `json:"parent_id"`
+	Text      string `json:"text"`
+	Message   string `json:"message"`
+	UserID    string `json:"user_id"`
+	CreatedAt string `json:"created_at"`
+}
+
+// comment проверяет обязательные поля и возвращает комментарий
+func (r rawComment) comment() (Comment, error) {
+	if r.ID == nil {
+		return Comment{}, errors.New("не указан id комментария")
+	}
+	c := Comment{
+		ID:        *r.ID,
+		NewsID:    r.NewsID,
+		ParentID:  r.ParentID,
+		Text:      r.Text,
+		UserID:    r.UserID,
+		CreatedAt: r.CreatedAt,
+	}
+	if c.Text == "" {
+		c.Text = r.Message
+	}
+	// Сервис передает 0 для комментариев без родителя
+	if c.ParentID != nil && *c.ParentID == 0 {
+		c.ParentID = nil
+	}
+	return c, nil
+}
@@ -0,0 +1,107 @@
+// Package news - клиент сервиса новостей с типизированными моделями ответов
+package news
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"apigw/pkg/clients"
+)
+
+// ErrNotFound возвращается, если сервис не нашел новость
+var ErrNotFound = errors.New("новость не найдена")
+
+// Item - новость в ответе сервиса новостей
+type Item struct {
+	ID          int64  `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	PubDate     string `json:"pub_date"`
+	SourceURL   string `json:"source_url"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// Service - операции сервиса новостей, которые использует шлюз
+type Service interface {
+	// List возвращает все новости
+	List(ctx context.Context) ([]Item, error)
+	// Get возвращает новость по ID или ErrNotFound
+	Get(ctx context.Context, id int64) (*Item, error)
+}
+
+// Client - клиент сервиса новостей по HTTP
+type Client struct {
+	baseURL string
+	doer    clients.Doer
+}
+
+// New создает клиент сервиса новостей по адресу baseURL
+func New(baseURL string, doer clients.Doer) *Client {
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), doer: doer}
+}
+
+// List возвращает все новости. Новость без id считается ошибкой ответа сервиса.
+func (c *Client) List(ctx context.Context) ([]Item, error) {
+	var items []rawItem
+	if err := clients.GetJSON(ctx, c.doer, c.baseURL+"/api/news/", &items); err != nil {
+		return nil, err
+	}
+
+	result := make([]Item, 0, len(items))
+	for i, raw := range items {
+		item, err := raw.item()
+		if err != nil {
+			return nil, fmt.Errorf("новость %d в ответе: %w", i, err)
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// Get возвращает новость по ID. Сервис отвечает массивом из одного элемента,
+// пустой массив и статус 404 означают, что новости нет.
+func (c *Client) Get(ctx context.Context, id int64) (*Item, error) {
+	var items []rawItem
+	err := clients.GetJSON(ctx, c.doer, fmt.Sprintf("%s/api/news/%d", c.baseURL, id), &items)
+	if clients.IsStatus(err, http.StatusNotFound) || (err == nil && len(items) == 0) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := items[0].item()
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// rawItem - новость в том виде, в котором ее вернул сервис: id проверяется
+// на наличие, поэтому он указатель
+type rawItem struct {
+	ID          *int64 `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	PubDate     string `json:"pub_date"`
+	SourceURL   string `json:"source_url"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// item проверяет обязательные поля и возвращает новость
+func (r rawItem) item() (Item, error) {
+	if r.ID == nil {
+		return Item{}, errors.New("не указан id новости")
+	}
+	return Item{
+		ID:          *r.ID,
+		Title:       r.Title,
+		Description: r.Description,
+		PubDate:     r.PubDate,
+		SourceURL:   r.SourceURL,
+		CreatedAt:   r.CreatedAt,
+	}, nil
+}
@@ -4,28 +4,1265 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 )
 
 // Config представляет конфигурацию приложения
 type Config struct {
 	Server   ServerConfig   `json:"server"`
 	Services ServicesConfig `json:"services"`
+	Cache    CacheConfig    `json:"cache"`
+	Routes   []RouteConfig  `json:"routes"`
+	Docs     DocsConfig     `json:"docs"`
+	Events   EventsConfig   `json:"events"`
+	Batch    BatchConfig    `json:"batch"`
+	GraphQL  GraphQLConfig  `json:"graphql"`
+	Static   StaticConfig   `json:"static"`
+	Search   SearchConfig   `json:"search"`
+	// Log - уровень и формат журнала шлюза
+	Log LogConfig `json:"log"`
+	// Concurrency - адаптивное ограничение одновременных запросов к backend-сервисам
+	Concurrency ConcurrencyConfig `json:"concurrency"`
+	// HTTPClient - настройки HTTP клиентов backend-сервисов по умолчанию
+	HTTPClient HTTPClientConfig `json:"http_client"`
+	// CircuitBreaker - автоматические выключатели backend-сервисов по умолчанию
+	CircuitBreaker CircuitBreakerConfig `json:"circuit_breaker"`
+	// Bulkhead - ограничение одновременных запросов к backend-сервисам по умолчанию
+	Bulkhead BulkheadConfig `json:"bulkhead"`
+	// Retries - повторы запросов к backend-сервисам по умолчанию
+	Retries RetryConfig `json:"retries"`
+	// HealthCheck - активная проверка доступности backend-сервисов по умолчанию
+	HealthCheck HealthCheckConfig `json:"health_check"`
+	// Shedding - отклонение запросов с низким приоритетом при нехватке памяти
+	Shedding SheddingConfig `json:"shedding"`
+	// QoS - классы приоритета запросов при перегрузке
+	QoS QoSConfig `json:"qos"`
+	// Compression - сжатие ответов gzip
+	Compression CompressionConfig `json:"compression"`
+	// Versions - настройки версий API (/api/v1, /api/v2), ключ - версия
+	Versions map[string]APIVersionConfig `json:"versions"`
+	// Faults - внесение сбоев в запросы к backend-сервисам для проверки устойчивости
+	Faults FaultsConfig `json:"faults"`
+	// Tenants - обслуживание нескольких порталов одним шлюзом
+	Tenants TenantsConfig `json:"tenants"`
+	// TrustedProxies - адреса IP и подсети CIDR прокси, заголовкам Forwarded и
+	// X-Forwarded-For которых шлюз доверяет при определении IP-адреса клиента
+	TrustedProxies []string `json:"trusted_proxies"`
+	// RateLimit - ограничение частоты запросов с одного IP-адреса клиента
+	RateLimit ClientRateLimitConfig `json:"rate_limit"`
+	// Auth - проверка ключей API клиентов
+	Auth AuthConfig `json:"auth"`
+	// JWT - проверка токенов пользователей (Bearer JWT)
+	JWT JWTConfig `json:"jwt"`
+	// Policies - политики доступа маршрутов: разрешенные методы, способ
+	// аутентификации и необходимые права
+	Policies []PolicyConfig `json:"policies"`
+	// Rewrites - перенаправления и внутренние перезаписи путей, применяемые до выбора маршрута
+	Rewrites []RewriteConfig `json:"rewrites"`
+	// Transforms - преобразования тела запросов к backend-сервисам
+	Transforms []TransformConfig `json:"transforms"`
+	// Deprecations - устаревшие маршруты и параметры
+	Deprecations []DeprecationConfig `json:"deprecations"`
+	// Metrics - метрики в формате Prometheus
+	Metrics MetricsConfig `json:"metrics"`
+	// Health - проверки работоспособности и готовности шлюза
+	Health HealthConfig `json:"health"`
+	// Dates - приведение дат в ответах к часовому поясу клиента
+	Dates DatesConfig `json:"dates"`
+	// MethodOverride - подмена метода запроса для клиентов за прокси, пропускающими только GET и POST
+	MethodOverride MethodOverrideConfig `json:"method_override"`
+	// CORS - разрешение запросов к API из браузера со страниц других доменов
+	CORS CORSConfig `json:"cors"`
+	// Timeouts - ограничение времени обработки запросов
+	Timeouts TimeoutsConfig `json:"timeouts"`
+	// NewsStream - поток новых новостей по Server-Sent Events
+	NewsStream NewsStreamConfig `json:"news_stream"`
+	// Admin - административный API на отдельном порту
+	Admin AdminConfig `json:"admin"`
+	// Canary - направление части запросов на новую версию backend-сервиса
+	Canary CanaryConfig `json:"canary"`
+	// Coalescing - объединение одновременных одинаковых запросов к backend-сервисам
+	Coalescing CoalescingConfig `json:"coalescing"`
+	// Moderation - проверка комментариев перед отправкой в сервис комментариев
+	Moderation ModerationConfig `json:"moderation"`
+	// Validation - проверка параметров и тел запросов по описанию API
+	Validation ValidationConfig `json:"validation"`
+	// Streaming - передача больших ответов клиенту без буферизации
+	Streaming StreamingConfig `json:"streaming"`
+	// Comments - вид списка комментариев
+	Comments CommentsConfig `json:"comments"`
+	// CommentOutbox - очередь комментариев, которые не удалось сразу
+	// доставить в сервис комментариев
+	CommentOutbox CommentOutboxConfig `json:"comment_outbox"`
+}
+
+// LogConfig представляет настройки журнала шлюза
+type LogConfig struct {
+	// Level - минимальный уровень записей: "debug", "info" (по умолчанию), "warn" или "error"
+	Level string `json:"level"`
+	// Format - формат записей: "text" (по умолчанию, ключ=значение) или "json"
+	Format string `json:"format"`
+	// Access - журнал запросов
+	Access AccessLogConfig `json:"access"`
+}
+
+// AccessLogConfig представляет настройки журнала запросов
+type AccessLogConfig struct {
+	// Format - формат строк: "" (по умолчанию, запись журнала шлюза в формате
+	// log.format), "json", "combined" (Apache combined) или "template"
+	Format string `json:"format"`
+	// Template - шаблон text/template строки для формата "template"
+	Template string `json:"template"`
+	// File - файл журнала запросов; пусто - журнал пишется вместе с журналом шлюза
+	File string `json:"file"`
+	// Backend - добавлять к строкам запросы к backend-сервисам и их задержку
+	Backend bool `json:"backend"`
 }
 
 // ServerConfig представляет конфигурацию сервера
 type ServerConfig struct {
 	Port int `json:"port"`
+	// GRPCPort - порт gRPC сервиса NewsGateway (0 - gRPC отключен)
+	GRPCPort int `json:"grpc_port"`
+	// Environment - окружение: "production" (по умолчанию), "staging", "development" и т.д.
+	// В окружении production внесение сбоев запрещено.
+	Environment string `json:"environment"`
+	// TLS - обслуживание HTTPS на порту Port
+	TLS TLSConfig `json:"tls"`
+}
+
+// TLSConfig представляет настройки HTTPS. Если сертификат не указан, шлюз
+// обслуживает HTTP.
+type TLSConfig struct {
+	// CertFile и KeyFile - файлы сертификата и закрытого ключа в формате PEM
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	// MinVersion - минимальная версия TLS: "1.2" или "1.3"
+	MinVersion string `json:"min_version"`
+	// CipherSuites - наборы шифров для TLS 1.2 по именам из crypto/tls,
+	// например TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256; пусто - наборы Go по умолчанию
+	CipherSuites []string `json:"cipher_suites"`
+	// RedirectPort - порт HTTP, запросы на который перенаправляются на HTTPS (0 - не открывать)
+	RedirectPort int `json:"redirect_port"`
+}
+
+// Enabled сообщает, что шлюз обслуживает HTTPS
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
 }
 
-// ServicesConfig представляет конфигурацию внешних сервисов
-type ServicesConfig struct {
-	News     ServiceConfig `json:"news"`
-	Comments ServiceConfig `json:"comments"`
+// Имена backend-сервисов, которые используют встроенные обработчики API
+const (
+	ServiceNews     = "news"
+	ServiceComments = "comments"
+)
+
+// ServicesConfig представляет конфигурацию внешних сервисов по имени.
+// Сервисы news и comments обязательны, остальные используются маршрутами
+// из секции routes и добавляются без изменения кода шлюза.
+type ServicesConfig map[string]ServiceConfig
+
+// URL возвращает адрес сервиса name или пустую строку, если сервис не задан
+func (s ServicesConfig) URL(name string) string {
+	return s[name].URL
 }
 
 // ServiceConfig представляет конфигурацию отдельного сервиса
 type ServiceConfig struct {
 	URL string `json:"url"`
+	// HealthPath - путь проверки доступности сервиса для /readyz; без него
+	// сервис считается доступным, если на запрос к адресу сервиса пришел ответ
+	// со статусом ниже 500
+	HealthPath string `json:"health_path"`
+	// Timeout - таймаут одного запроса к сервису, включая чтение ответа (0 - без ограничения)
+	Timeout Duration `json:"timeout"`
+	// RequestID - способ передачи request_id сервису: "query" (по умолчанию),
+	// "header" (заголовок X-Request-ID) или "both"
+	RequestID string `json:"request_id"`
+	// Pagination - сервис сам выполняет пагинацию и поиск: принимает параметры
+	// page, count и s (сервис комментариев - page, count и sort) и возвращает
+	// общее количество в заголовке X-Total-Count
+	Pagination bool `json:"pagination"`
+	// Sorting - сервис новостей сам сортирует список: принимает параметры
+	// sort и order и возвращает новости в запрошенном порядке
+	Sorting bool `json:"sorting"`
+	// DateFilter - сервис новостей сам отбирает новости по периоду
+	// публикации: принимает параметры from и until
+	DateFilter bool `json:"date_filter"`
+	// Client - настройки HTTP клиента сервиса; незаданные значения берутся из секции http_client
+	Client HTTPClientConfig `json:"client"`
+	// CircuitBreaker - автоматический выключатель сервиса; незаданные значения берутся из секции circuit_breaker
+	CircuitBreaker CircuitBreakerConfig `json:"circuit_breaker"`
+	// Bulkhead - ограничение одновременных запросов к сервису; незаданные значения берутся из секции bulkhead
+	Bulkhead BulkheadConfig `json:"bulkhead"`
+	// Retries - повторы запросов к сервису; незаданные значения берутся из секции retries
+	Retries RetryConfig `json:"retries"`
+	// HealthCheck - активная проверка доступности сервиса; незаданные значения берутся из секции health_check
+	HealthCheck HealthCheckConfig `json:"health_check"`
+	// GRPC - сервис работает по gRPC: запросы шлюза к сервису перекодируются в вызовы методов (nil - HTTP сервис)
+	GRPC *GRPCConfig `json:"grpc,omitempty"`
+	// Discovery - адреса экземпляров сервиса берутся из DNS SRV записи (nil - из url)
+	Discovery *DiscoveryConfig `json:"discovery,omitempty"`
+	// Mirror - копирование запросов на чтение к сервису на теневой сервис (nil - отключено)
+	Mirror *MirrorConfig `json:"mirror,omitempty"`
+}
+
+// MirrorConfig представляет зеркалирование запросов: копии запросов GET и
+// HEAD к сервису асинхронно отправляются на теневой сервис, его ответы
+// отбрасываются. Так новую версию сервиса можно проверить рабочей нагрузкой.
+type MirrorConfig struct {
+	// URL - адрес теневого сервиса; путь и параметры запроса сохраняются
+	URL string `json:"url"`
+	// Percent - доля копируемых запросов в процентах (0 - все запросы)
+	Percent float64 `json:"percent"`
+	// Timeout - таймаут запроса к теневому сервису (0 - 10s)
+	Timeout Duration `json:"timeout"`
+	// MaxInFlight - сколько копий может выполняться одновременно, остальные
+	// пропускаются (0 - 100)
+	MaxInFlight int `json:"max_in_flight"`
+}
+
+// DiscoveryConfig представляет настройки обнаружения экземпляров сервиса
+// через DNS SRV. Схема и хост из url сервиса остаются его именем: по ним
+// выбираются настройки клиента, выключатель и метрики, хост передается
+// в заголовке Host и используется для проверки TLS сертификата.
+type DiscoveryConfig struct {
+	// SRV - полное имя SRV записи, например _http._tcp.news.default.svc.cluster.local
+	SRV string `json:"srv"`
+	// Interval - через сколько адреса запрашиваются повторно (0 - 30s)
+	Interval Duration `json:"interval"`
+	// Server - адрес DNS сервера host:port, например consul DNS 127.0.0.1:8600 (пусто - системный)
+	Server string `json:"server"`
+}
+
+// GRPCConfig представляет настройки gRPC backend-сервиса. Шлюз обращается
+// к сервису так же, как к HTTP сервису, а транспорт находит правило по
+// методу и пути запроса и вызывает соответствующий метод gRPC. Адрес сервиса
+// со схемой http означает соединение без TLS, https - с TLS.
+type GRPCConfig struct {
+	// Descriptors - файл FileDescriptorSet с описанием методов сервиса
+	// (protoc --include_imports --descriptor_set_out=...)
+	Descriptors string `json:"descriptors"`
+	// Methods - соответствие запросов методам gRPC; применяется первое подходящее правило
+	Methods []GRPCMethodConfig `json:"methods"`
+}
+
+// GRPCMethodConfig представляет правило перекодирования HTTP запроса в вызов
+// метода gRPC. Поля сообщения запроса заполняются из тела запроса JSON,
+// параметров запроса и сегментов пути; ответ передается шлюзу как JSON.
+type GRPCMethodConfig struct {
+	// Method - метод HTTP запроса (пусто - любой)
+	Method string `json:"method"`
+	// Path - шаблон пути запроса, сегмент {поле} заполняет поле сообщения запроса
+	Path string `json:"path"`
+	// RPC - полное имя метода gRPC: пакет.Сервис/Метод
+	RPC string `json:"rpc"`
+	// Params - поля сообщения запроса для параметров запроса, имена которых
+	// отличаются от имен полей, например {"id": "news_id"}
+	Params map[string]string `json:"params"`
+	// Response - поле сообщения ответа, которое передается вместо всего ответа (пусто - весь ответ)
+	Response string `json:"response"`
+	// WrapArray - передать ответ массивом из одного элемента, как сервис
+	// новостей отвечает на запрос новости по ID
+	WrapArray bool `json:"wrap_array"`
+}
+
+// RetryConfig представляет настройки повторов идемпотентных запросов
+// (GET и HEAD) к backend-сервису. Нулевые значения в настройках сервиса
+// означают значение из секции retries.
+type RetryConfig struct {
+	// Enabled - включить повторы (в секции сервиса включает их только для этого сервиса)
+	Enabled bool `json:"enabled"`
+	// MaxAttempts - максимальное число попыток, включая первую
+	MaxAttempts int `json:"max_attempts"`
+	// InitialBackoff - пауза перед первым повтором
+	InitialBackoff Duration `json:"initial_backoff"`
+	// MaxBackoff - максимальная пауза между попытками
+	MaxBackoff Duration `json:"max_backoff"`
+	// BackoffMultiplier - во сколько раз растет пауза с каждой попыткой
+	BackoffMultiplier float64 `json:"backoff_multiplier"`
+	// RetryableStatuses - статусы ответа сервиса, при которых запрос повторяется
+	RetryableStatuses []int `json:"retryable_statuses"`
+	// BudgetRatio - доля повторов от числа запросов к сервису, сверх которой повторы не выполняются
+	BudgetRatio float64 `json:"budget_ratio"`
+	// MinRetriesPerSecond - повторы в секунду, разрешенные независимо от BudgetRatio
+	MinRetriesPerSecond float64 `json:"min_retries_per_second"`
+}
+
+// WithDefaults возвращает настройки, в которых незаданные значения взяты из defaults
+func (c RetryConfig) WithDefaults(defaults RetryConfig) RetryConfig {
+	c.Enabled = c.Enabled || defaults.Enabled
+	if c.MaxAttempts == 0 {
+		c.MaxAttempts = defaults.MaxAttempts
+	}
+	if c.InitialBackoff == 0 {
+		c.InitialBackoff = defaults.InitialBackoff
+	}
+	if c.MaxBackoff == 0 {
+		c.MaxBackoff = defaults.MaxBackoff
+	}
+	if c.BackoffMultiplier == 0 {
+		c.BackoffMultiplier = defaults.BackoffMultiplier
+	}
+	if c.RetryableStatuses == nil {
+		c.RetryableStatuses = defaults.RetryableStatuses
+	}
+	if c.BudgetRatio == 0 {
+		c.BudgetRatio = defaults.BudgetRatio
+	}
+	if c.MinRetriesPerSecond == 0 {
+		c.MinRetriesPerSecond = defaults.MinRetriesPerSecond
+	}
+	return c
+}
+
+// Способы передачи request_id backend-сервису
+const (
+	// RequestIDQuery - в параметре request_id
+	RequestIDQuery = "query"
+	// RequestIDHeader - в заголовке X-Request-ID
+	RequestIDHeader = "header"
+	// RequestIDBoth - в параметре и в заголовке
+	RequestIDBoth = "both"
+)
+
+// Поведение шлюза, когда выключатель сервиса разомкнут
+const (
+	// BreakerFallbackError - клиент сразу получает ошибку 503
+	BreakerFallbackError = "error"
+	// BreakerFallbackCache - клиент получает последний сохраненный ответ, если он есть
+	BreakerFallbackCache = "cache"
+)
+
+// CircuitBreakerConfig представляет настройки автоматического выключателя
+// (circuit breaker): после серии ошибок сервиса запросы к нему временно не
+// отправляются. Нулевые значения в настройках сервиса означают значение из
+// секции circuit_breaker.
+type CircuitBreakerConfig struct {
+	// Enabled - включить выключатель (в секции сервиса включает его только для этого сервиса)
+	Enabled bool `json:"enabled"`
+	// FailureThreshold - сколько ошибок подряд размыкают выключатель
+	FailureThreshold int `json:"failure_threshold"`
+	// OpenTimeout - через сколько после размыкания отправляются пробные запросы
+	OpenTimeout Duration `json:"open_timeout"`
+	// HalfOpenRequests - сколько пробных запросов должны быть успешными, чтобы выключатель замкнулся
+	HalfOpenRequests int `json:"half_open_requests"`
+	// Fallback - ответ клиенту при разомкнутом выключателе: "error" или "cache"
+	Fallback string `json:"fallback"`
+	// StaleTTL - сколько хранится копия ответа для fallback "cache"
+	StaleTTL Duration `json:"stale_ttl"`
+}
+
+// WithDefaults возвращает настройки, в которых незаданные значения взяты из defaults
+func (c CircuitBreakerConfig) WithDefaults(defaults CircuitBreakerConfig) CircuitBreakerConfig {
+	c.Enabled = c.Enabled || defaults.Enabled
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = defaults.FailureThreshold
+	}
+	if c.OpenTimeout == 0 {
+		c.OpenTimeout = defaults.OpenTimeout
+	}
+	if c.HalfOpenRequests == 0 {
+		c.HalfOpenRequests = defaults.HalfOpenRequests
+	}
+	if c.Fallback == "" {
+		c.Fallback = defaults.Fallback
+	}
+	if c.StaleTTL == 0 {
+		c.StaleTTL = defaults.StaleTTL
+	}
+	return c
+}
+
+// BulkheadConfig представляет ограничение одновременных запросов к
+// backend-сервису: медленный сервис не может занять все соединения и
+// горутины шлюза. Запросы сверх лимита недолго ждут освобождения места,
+// затем получают ответ 503. Нулевые значения в настройках сервиса означают
+// значение из секции bulkhead.
+type BulkheadConfig struct {
+	// MaxConcurrent - сколько запросов к сервису может выполняться одновременно (0 - без ограничения)
+	MaxConcurrent int `json:"max_concurrent"`
+	// QueueTimeout - сколько запрос ждет освобождения места
+	QueueTimeout Duration `json:"queue_timeout"`
+}
+
+// WithDefaults возвращает настройки, в которых незаданные значения взяты из defaults
+func (c BulkheadConfig) WithDefaults(defaults BulkheadConfig) BulkheadConfig {
+	if c.MaxConcurrent == 0 {
+		c.MaxConcurrent = defaults.MaxConcurrent
+	}
+	if c.QueueTimeout == 0 {
+		c.QueueTimeout = defaults.QueueTimeout
+	}
+	return c
+}
+
+// HealthCheckConfig представляет настройки активной проверки доступности:
+// шлюз периодически запрашивает сервис и, пока сервис не прошел проверку, не
+// отправляет ему запросы клиентов. Нулевые значения в настройках сервиса
+// означают значение из секции health_check.
+type HealthCheckConfig struct {
+	// Enabled - включить проверку (в секции сервиса включает ее только для этого сервиса)
+	Enabled bool `json:"enabled"`
+	// Path - путь проверки относительно адреса сервиса; по умолчанию health_path сервиса
+	Path string `json:"path"`
+	// Interval - период проверки
+	Interval Duration `json:"interval"`
+	// Timeout - таймаут одной проверки
+	Timeout Duration `json:"timeout"`
+	// UnhealthyThreshold - сколько неудачных проверок подряд делают сервис недоступным
+	UnhealthyThreshold int `json:"unhealthy_threshold"`
+	// HealthyThreshold - сколько успешных проверок подряд возвращают сервис в работу
+	HealthyThreshold int `json:"healthy_threshold"`
+}
+
+// WithDefaults возвращает настройки, в которых незаданные значения взяты из defaults
+func (c HealthCheckConfig) WithDefaults(defaults HealthCheckConfig) HealthCheckConfig {
+	c.Enabled = c.Enabled || defaults.Enabled
+	if c.Path == "" {
+		c.Path = defaults.Path
+	}
+	if c.Interval == 0 {
+		c.Interval = defaults.Interval
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaults.Timeout
+	}
+	if c.UnhealthyThreshold == 0 {
+		c.UnhealthyThreshold = defaults.UnhealthyThreshold
+	}
+	if c.HealthyThreshold == 0 {
+		c.HealthyThreshold = defaults.HealthyThreshold
+	}
+	return c
+}
+
+// HTTPClientConfig представляет настройки HTTP клиента и пула соединений
+// с backend-сервисом. Нулевые значения в настройках сервиса означают
+// значение из секции http_client.
+type HTTPClientConfig struct {
+	// MaxIdleConns - общее число простаивающих соединений клиента
+	MaxIdleConns int `json:"max_idle_conns"`
+	// MaxIdleConnsPerHost - число простаивающих соединений с сервисом
+	MaxIdleConnsPerHost int `json:"max_idle_conns_per_host"`
+	// MaxConnsPerHost - максимальное число соединений с сервисом (0 - без ограничения)
+	MaxConnsPerHost int `json:"max_conns_per_host"`
+	// DialTimeout - таймаут установки соединения
+	DialTimeout Duration `json:"dial_timeout"`
+	// ResponseHeaderTimeout - сколько ждать заголовков ответа сервиса
+	ResponseHeaderTimeout Duration `json:"response_header_timeout"`
+	// KeepAlive - интервал TCP keep-alive (отрицательное значение отключает)
+	KeepAlive Duration `json:"keep_alive"`
+	// IdleConnTimeout - через сколько закрывается простаивающее соединение
+	IdleConnTimeout Duration `json:"idle_conn_timeout"`
+}
+
+// WithDefaults возвращает настройки, в которых незаданные значения взяты из defaults
+func (c HTTPClientConfig) WithDefaults(defaults HTTPClientConfig) HTTPClientConfig {
+	if c.MaxIdleConns == 0 {
+		c.MaxIdleConns = defaults.MaxIdleConns
+	}
+	if c.MaxIdleConnsPerHost == 0 {
+		c.MaxIdleConnsPerHost = defaults.MaxIdleConnsPerHost
+	}
+	if c.MaxConnsPerHost == 0 {
+		c.MaxConnsPerHost = defaults.MaxConnsPerHost
+	}
+	if c.DialTimeout == 0 {
+		c.DialTimeout = defaults.DialTimeout
+	}
+	if c.ResponseHeaderTimeout == 0 {
+		c.ResponseHeaderTimeout = defaults.ResponseHeaderTimeout
+	}
+	if c.KeepAlive == 0 {
+		c.KeepAlive = defaults.KeepAlive
+	}
+	if c.IdleConnTimeout == 0 {
+		c.IdleConnTimeout = defaults.IdleConnTimeout
+	}
+	return c
+}
+
+// RouteConfig представляет маршрут, проксируемый на произвольный backend-сервис
+type RouteConfig struct {
+	// Path - шаблон пути в формате http.ServeMux ("/realtime/" - все пути с префиксом)
+	Path string `json:"path"`
+	// Upstream - адрес backend-сервиса: http://, https://, ws:// или wss://
+	Upstream string `json:"upstream"`
+	// Service - имя сервиса из секции services, адрес которого используется вместо Upstream
+	Service string `json:"service"`
+	// StripPrefix - убирать Path из пути перед передачей backend-сервису
+	StripPrefix bool `json:"strip_prefix"`
+	// MaxConnections - максимальное число одновременных WebSocket соединений (0 - без ограничения)
+	MaxConnections int `json:"max_connections"`
+	// IdleTimeout - закрывать WebSocket соединение после простоя (0 - не закрывать)
+	IdleTimeout Duration `json:"idle_timeout"`
+	// HeartbeatInterval - интервал heartbeat-комментариев в потоках text/event-stream (0 - не отправлять)
+	HeartbeatInterval Duration `json:"heartbeat_interval"`
+	// Invalidates - данные, которые изменяют запросы маршрута: "news" и/или
+	// "comments". После успешного изменяющего запроса (не GET и не HEAD)
+	// шлюз инвалидирует связанные записи кэша.
+	Invalidates []string `json:"invalidates"`
+}
+
+// DocsConfig представляет настройки интерактивной документации API
+type DocsConfig struct {
+	// Enabled - отдавать Swagger UI по адресу /docs
+	Enabled bool `json:"enabled"`
+	// RequireAuth - требовать Basic-аутентификацию для доступа к /docs
+	RequireAuth bool   `json:"require_auth"`
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	// AssetsURL - адрес, с которого загружаются файлы swagger-ui-dist
+	AssetsURL string `json:"assets_url"`
+}
+
+// APIVersionConfig представляет настройки устаревания версии API
+type APIVersionConfig struct {
+	// DeprecatedAt - дата, с которой версия считается устаревшей (RFC 3339 или ГГГГ-ММ-ДД);
+	// ответы сопровождаются заголовком Deprecation
+	DeprecatedAt string `json:"deprecated_at"`
+	// Sunset - дата отключения версии, передается в заголовке Sunset
+	Sunset string `json:"sunset"`
+	// Link - ссылка на описание перехода на новую версию
+	Link string `json:"link"`
+}
+
+// RewriteConfig представляет правило перенаправления или перезаписи пути.
+// Правила проверяются по порядку, применяется первое подходящее.
+type RewriteConfig struct {
+	// Match - регулярное выражение для пути запроса, например "^/news/(\\d+)\\.html$"
+	Match string `json:"match"`
+	// Target - новый путь или адрес со ссылками на группы выражения ($1, ${name}),
+	// может содержать параметры запроса
+	Target string `json:"target"`
+	// Status - статус перенаправления (301, 302, 307, 308);
+	// 0 - внутренняя перезапись: запрос обрабатывается по новому пути без ответа клиенту
+	Status int `json:"status"`
+}
+
+// TransformConfig представляет преобразование JSON тела запроса перед
+// передачей backend-сервису, например переименование полей под новую схему
+// сервиса. Поля удаляются, затем переименовываются, затем добавляются.
+type TransformConfig struct {
+	// Path - путь запроса к шлюзу; путь, оканчивающийся на /, задает префикс
+	Path string `json:"path"`
+	// Method - метод запроса; пустая строка - любой метод
+	Method string `json:"method"`
+	// Remove - удаляемые поля верхнего уровня
+	Remove []string `json:"remove"`
+	// Rename - переименование полей верхнего уровня, например "text": "content"
+	Rename map[string]string `json:"rename"`
+	// Set - поля, которые добавляются или заменяются
+	Set map[string]interface{} `json:"set"`
+	// Encoding - формат тела для сервиса: "json" (по умолчанию) или "form"
+	// (application/x-www-form-urlencoded)
+	Encoding string `json:"encoding"`
+}
+
+// DeprecationConfig представляет устаревший маршрут или параметр запроса.
+// Ответы на подходящие запросы сопровождаются заголовками Deprecation, Sunset
+// и Link, а сами запросы учитываются в метрике apigw_deprecated_requests_total.
+type DeprecationConfig struct {
+	// Name - имя в метрике; по умолчанию путь и параметр, например "/api/news?comm"
+	Name string `json:"name"`
+	// Path - путь запроса; путь, оканчивающийся на /, задает префикс
+	Path string `json:"path"`
+	// Method - метод запроса; пустая строка - любой метод
+	Method string `json:"method"`
+	// Param - параметр запроса; если задан, устаревшими считаются только запросы с ним
+	Param string `json:"param"`
+	// DeprecatedAt, Sunset и Link - как в настройках версий API
+	DeprecatedAt string `json:"deprecated_at"`
+	Sunset       string `json:"sunset"`
+	Link         string `json:"link"`
+}
+
+// MetricsConfig представляет настройки метрик
+type MetricsConfig struct {
+	// Enabled - отдавать метрики в текстовом формате Prometheus
+	Enabled bool `json:"enabled"`
+	// Path - путь, по которому отдаются метрики
+	Path string `json:"path"`
+}
+
+// HealthConfig представляет настройки проверок работоспособности (liveness)
+// и готовности (readiness) шлюза для оркестраторов
+type HealthConfig struct {
+	// LivenessPath - путь проверки того, что процесс шлюза отвечает (пусто - не отдавать)
+	LivenessPath string `json:"liveness_path"`
+	// ReadinessPath - путь проверки готовности: доступности backend-сервисов (пусто - не отдавать)
+	ReadinessPath string `json:"readiness_path"`
+	// Services - backend-сервисы, доступность которых проверяется при готовности
+	Services []string `json:"services"`
+	// Timeout - таймаут проверки одного сервиса
+	Timeout Duration `json:"timeout"`
+	// CacheTTL - время, в течение которого используется результат проверки сервисов
+	CacheTTL Duration `json:"cache_ttl"`
+}
+
+// StaticConfig представляет настройки раздачи статических файлов фронтенда
+type StaticConfig struct {
+	// Enabled - раздавать статические файлы на путях, не занятых API
+	Enabled bool `json:"enabled"`
+	// Dir - каталог с файлами; если не указан, используются файлы,
+	// встроенные в бинарный файл при сборке (каталог web/dist)
+	Dir string `json:"dir"`
+	// Index - файл, который отдается для каталогов и при SPA fallback
+	Index string `json:"index"`
+	// SPAFallback - отдавать Index для неизвестных путей вне /api/
+	SPAFallback bool `json:"spa_fallback"`
+}
+
+// SearchConfig представляет настройки поиска новостей (параметр s)
+type SearchConfig struct {
+	// Index - искать по индексу в памяти вместо загрузки списка новостей
+	// из сервиса на каждый поисковый запрос
+	Index bool `json:"index"`
+	// RefreshInterval - интервал обновления индекса
+	RefreshInterval Duration `json:"refresh_interval"`
+	// MaxItems - сколько новостей из начала списка сервиса индексировать (0 - все)
+	MaxItems int `json:"max_items"`
+}
+
+// ConcurrencyConfig представляет настройки адаптивного ограничения
+// одновременных запросов. Лимит ведется отдельно для каждого backend-сервиса
+// и подстраивается под изменение его задержки.
+type ConcurrencyConfig struct {
+	// Adaptive - включить адаптивное ограничение
+	Adaptive bool `json:"adaptive"`
+	// InitialLimit - лимит одновременных запросов при запуске
+	InitialLimit int `json:"initial_limit"`
+	// MinLimit и MaxLimit - границы, в которых изменяется лимит
+	MinLimit int `json:"min_limit"`
+	MaxLimit int `json:"max_limit"`
+	// Smoothing - скорость изменения лимита (0..1]
+	Smoothing float64 `json:"smoothing"`
+	// Tolerance - во сколько раз задержка может вырасти относительно
+	// обычной, прежде чем лимит начнет снижаться
+	Tolerance float64 `json:"tolerance"`
+}
+
+// SheddingConfig представляет настройки сброса нагрузки при нехватке памяти.
+// Когда память процесса приближается к бюджету, запросы с низким приоритетом
+// (поиск и пути из LowPriorityPaths) отклоняются со статусом 503.
+type SheddingConfig struct {
+	// Enabled - включить сброс нагрузки
+	Enabled bool `json:"enabled"`
+	// MemoryBudgetMB - бюджет памяти в мегабайтах; 0 - использовать GOMEMLIMIT
+	MemoryBudgetMB int `json:"memory_budget_mb"`
+	// Threshold - доля бюджета, начиная с которой запросы отклоняются
+	Threshold float64 `json:"threshold"`
+	// CheckInterval - интервал проверки потребления памяти
+	CheckInterval Duration `json:"check_interval"`
+	// LowPriorityPaths - префиксы путей, запросы к которым отклоняются первыми
+	LowPriorityPaths []string `json:"low_priority_paths"`
+}
+
+// Классы приоритета запросов в порядке убывания приоритета
+const (
+	// QoSInteractive - чтение, от которого зависит отзывчивость портала
+	QoSInteractive = "interactive"
+	// QoSWrite - запросы, изменяющие данные
+	QoSWrite = "write"
+	// QoSExport - выгрузки, поиск и другие тяжелые запросы, которые можно повторить позже
+	QoSExport = "export"
+)
+
+// QoSConfig представляет настройки классов приоритета. При перегрузке
+// шлюз в первую очередь отказывает запросам с низким приоритетом: им
+// доступна только часть лимита одновременных запросов к backend-сервису
+// и они первыми отклоняются при нехватке памяти.
+type QoSConfig struct {
+	// Enabled - учитывать классы приоритета
+	Enabled bool `json:"enabled"`
+	// Classes - настройки классов interactive, write и export
+	Classes map[string]QoSClassConfig `json:"classes"`
+	// Routes - отнесение запросов к классам. Запросы, не попавшие ни в одно
+	// правило, относятся к export (поиск и shedding.low_priority_paths),
+	// interactive (GET, HEAD, OPTIONS) или write (остальные методы).
+	Routes []QoSRouteConfig `json:"routes"`
+}
+
+// QoSClassConfig представляет настройки класса приоритета
+type QoSClassConfig struct {
+	// ConcurrencyShare - доля адаптивного лимита одновременных запросов к
+	// backend-сервису, которую могут занять запросы класса (0..1]
+	ConcurrencyShare float64 `json:"concurrency_share"`
+	// ShedThreshold - доля бюджета памяти (секция shedding), начиная с которой
+	// запросы класса отклоняются; 0 - не отклонять
+	ShedThreshold float64 `json:"shed_threshold"`
+}
+
+// QoSRouteConfig представляет правило отнесения запросов к классу приоритета
+type QoSRouteConfig struct {
+	// PathPrefix - префикс пути запроса
+	PathPrefix string `json:"path_prefix"`
+	// Method - метод запроса; пустая строка - любой метод
+	Method string `json:"method"`
+	// Class - класс приоритета
+	Class string `json:"class"`
+}
+
+// FaultsConfig представляет настройки внесения сбоев (chaos-тестирование).
+// Сбои вносятся только вне окружения production.
+type FaultsConfig struct {
+	// Enabled - вносить сбои в запросы к backend-сервисам
+	Enabled bool `json:"enabled"`
+	// Services - сбои по сервисам; ключ - имя сервиса из секции services
+	// или адрес backend-сервиса маршрута, например "http://localhost:9000"
+	Services map[string]FaultConfig `json:"services"`
+}
+
+// FaultConfig представляет сбои, вносимые в запросы к одному сервису
+type FaultConfig struct {
+	// Latency - дополнительная задержка перед запросом
+	Latency Duration `json:"latency"`
+	// Jitter - случайная добавка к задержке от 0 до Jitter
+	Jitter Duration `json:"jitter"`
+	// ErrorRate - доля запросов, на которые вместо сервиса отвечает шлюз со статусом ErrorStatus
+	ErrorRate float64 `json:"error_rate"`
+	// ErrorStatus - код ответа для ErrorRate (по умолчанию 503)
+	ErrorStatus int `json:"error_status"`
+	// AbortRate - доля запросов, завершающихся сетевой ошибкой без ответа
+	AbortRate float64 `json:"abort_rate"`
+}
+
+// TenantsConfig представляет настройки арендаторов - порталов, которые
+// обслуживаются одним шлюзом изолированно друг от друга: со своими
+// backend-сервисами, лимитами запросов и разделом кэша.
+// Пустой список - шлюз обслуживает один портал.
+type TenantsConfig struct {
+	// IdentifyBy - способы определения арендатора в порядке проверки:
+	// "api_key" (заголовок X-API-Key), "header" (заголовок Header) и "host" (заголовок Host)
+	IdentifyBy []string `json:"identify_by"`
+	// Header - заголовок с именем арендатора для способа "header"
+	Header string `json:"header"`
+	// Default - арендатор запросов, которые не удалось отнести ни к одному
+	// арендатору; если не указан, такие запросы отклоняются со статусом 403
+	Default string `json:"default"`
+	// List - арендаторы по имени
+	List map[string]TenantConfig `json:"list"`
+}
+
+// TenantConfig представляет настройки одного арендатора
+type TenantConfig struct {
+	// APIKeys - ключи API, по которым определяется арендатор
+	APIKeys []string `json:"api_keys"`
+	// Hosts - имена хостов портала без порта, например news.example.com
+	Hosts []string `json:"hosts"`
+	// Services - backend-сервисы арендатора; незаданные сервисы берутся из секции services
+	Services ServicesConfig `json:"services"`
+	// RateLimit - ограничение частоты запросов арендатора
+	RateLimit RateLimitConfig `json:"rate_limit"`
+}
+
+// AuthConfig представляет настройки проверки ключей API. Запросы к путям
+// из Paths без действительного ключа отклоняются со статусом 401.
+type AuthConfig struct {
+	// Enabled - включить проверку ключей
+	Enabled bool `json:"enabled"`
+	// Header - заголовок с ключом API
+	Header string `json:"header"`
+	// Keys - ключи API и имена клиентов, которым они выданы
+	Keys []APIKeyConfig `json:"keys"`
+	// KeysFile - файл с ключами в формате {"ключ": "клиент"}, перечитывается при перезагрузке конфигурации
+	KeysFile string `json:"keys_file"`
+	// Paths - пути, для которых нужен ключ; путь, оканчивающийся на /, задает префикс
+	Paths []string `json:"paths"`
+}
+
+// JWTConfig представляет настройки проверки токенов JWT из заголовка
+// Authorization: Bearer. Подпись проверяется общим секретом (HS256/384/512)
+// или открытым ключом RSA (RS256/384/512) из файла или JWKS издателя.
+type JWTConfig struct {
+	// Enabled - включить проверку токенов
+	Enabled bool `json:"enabled"`
+	// Secret - общий секрет для HMAC
+	Secret string `json:"secret"`
+	// PublicKeyFile - открытый ключ RSA в формате PEM
+	PublicKeyFile string `json:"public_key_file"`
+	// JWKSURL - адрес набора открытых ключей издателя (JWKS)
+	JWKSURL string `json:"jwks_url"`
+	// JWKSRefresh - как часто перечитывается JWKS
+	JWKSRefresh Duration `json:"jwks_refresh"`
+	// Issuer и Audience - ожидаемые значения iss и aud (пусто - не проверяются)
+	Issuer   string `json:"issuer"`
+	Audience string `json:"audience"`
+	// Leeway - допустимое расхождение часов при проверке exp и nbf
+	Leeway Duration `json:"leeway"`
+	// UserClaim - утверждение с идентификатором пользователя
+	UserClaim string `json:"user_claim"`
+	// RolesClaim - утверждение с ролями пользователя для политик доступа
+	RolesClaim string `json:"roles_claim"`
+	// OIDC - издатель OpenID Connect, ключи которого определяются автоматически
+	OIDC OIDCConfig `json:"oidc"`
+	// ClaimHeaders - заголовки запросов к backend-сервисам со значениями
+	// утверждений токена: имя заголовка -> утверждение (путь через точку)
+	ClaimHeaders map[string]string `json:"claim_headers"`
+	// Routes - маршруты, для которых проверяется токен
+	Routes []JWTRouteConfig `json:"routes"`
+}
+
+// OIDCConfig представляет издателя OpenID Connect (Keycloak, Auth0 и др.)
+type OIDCConfig struct {
+	// IssuerURL - адрес издателя; ключи берутся из jwks_uri его документа
+	// /.well-known/openid-configuration, а iss токенов должен совпадать с адресом
+	IssuerURL string `json:"issuer_url"`
+	// ClientID - идентификатор клиента, который должен содержаться в aud
+	// токена или совпадать с azp (пусто - не проверяется)
+	ClientID string `json:"client_id"`
+}
+
+// JWTRouteConfig представляет маршрут, для которого проверяется токен
+type JWTRouteConfig struct {
+	// Path - путь запроса; путь, оканчивающийся на /, задает префикс
+	Path string `json:"path"`
+	// Method - метод запроса (пусто - любой)
+	Method string `json:"method"`
+	// Optional - запрос без токена пропускается, а указанный токен проверяется
+	Optional bool `json:"optional"`
+}
+
+// PolicyConfig представляет политику доступа маршрута
+type PolicyConfig struct {
+	// Path - путь запроса; путь, оканчивающийся на /, задает префикс
+	Path string `json:"path"`
+	// Methods - разрешенные методы (пусто - любые)
+	Methods []string `json:"methods"`
+	// Auth - способ аутентификации: "none" (по умолчанию, маршрут открыт),
+	// "api_key" - ключ API из секции auth или "jwt" - токен из секции jwt
+	Auth string `json:"auth"`
+	// Scopes - области доступа токена, которые нужны все (утверждение scope или scp)
+	Scopes []string `json:"scopes"`
+	// Roles - роли пользователя, из которых нужна хотя бы одна (утверждение jwt.roles_claim)
+	Roles []string `json:"roles"`
+}
+
+// APIKeyConfig представляет ключ API клиента
+type APIKeyConfig struct {
+	Key string `json:"key"`
+	// Client - имя клиента в логах и метриках
+	Client string `json:"client"`
+}
+
+// ClientRateLimitConfig представляет ограничение частоты запросов с одного
+// IP-адреса клиента. Для маршрутов из Routes действуют свои лимиты, для
+// остальных запросов - RequestsPerSecond и Burst (0 - без ограничения).
+type ClientRateLimitConfig struct {
+	// Enabled - включить ограничение
+	Enabled bool `json:"enabled"`
+	RateLimitConfig
+	// Routes - лимиты маршрутов; применяется первое подходящее правило
+	Routes []RouteRateLimitConfig `json:"routes"`
+	// MaxClients - сколько IP-адресов отслеживается одновременно
+	MaxClients int `json:"max_clients"`
+	// Key - по чему считаются запросы клиента: "ip" (по умолчанию) или
+	// "api_key" - по клиенту с действительным ключом API из секции auth
+	Key string `json:"key"`
+	// Store - где хранятся корзины: "memory" (по умолчанию) или "redis" -
+	// общие для всех экземпляров шлюза
+	Store string `json:"store"`
+	// Redis - подключение к Redis для store = redis
+	Redis RedisConfig `json:"redis"`
+}
+
+// RouteRateLimitConfig представляет лимит запросов с одного IP-адреса к маршруту
+type RouteRateLimitConfig struct {
+	// Path - путь запроса; путь, оканчивающийся на /, задает префикс
+	Path string `json:"path"`
+	// Method - метод запроса (пусто - любой)
+	Method string `json:"method"`
+	RateLimitConfig
+}
+
+// RateLimitConfig представляет ограничение частоты запросов
+type RateLimitConfig struct {
+	// RequestsPerSecond - допустимая средняя частота запросов (0 - без ограничения)
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	// Burst - сколько запросов можно выполнить подряд без ожидания
+	// (по умолчанию - частота за одну секунду)
+	Burst int `json:"burst"`
+}
+
+// DatesConfig представляет настройки приведения дат pub_date и created_at
+// в ответах к RFC3339 в часовом поясе, который запросил клиент (параметр tz
+// или заголовок Accept-Language)
+type DatesConfig struct {
+	// SourceTimezone - часовой пояс, в котором backend-сервисы возвращают даты без указания пояса
+	SourceTimezone string `json:"source_timezone"`
+	// Languages - часовой пояс по языку из Accept-Language, например "ru": "Europe/Moscow"
+	Languages map[string]string `json:"languages"`
+}
+
+// MethodOverrideConfig представляет настройки подмены метода запроса.
+// POST запрос с заголовком X-HTTP-Method-Override или полем формы _method
+// обрабатывается как запрос с указанным в них методом.
+type MethodOverrideConfig struct {
+	// Enabled - учитывать заголовок X-HTTP-Method-Override и поле _method
+	Enabled bool `json:"enabled"`
+	// Methods - методы, на которые можно заменить POST
+	Methods []string `json:"methods"`
+}
+
+// TimeoutsConfig представляет ограничения времени обработки запросов. По
+// истечении времени запросы к backend-сервисам прерываются, а клиент получает
+// ответ 504.
+type TimeoutsConfig struct {
+	// Default - время обработки остальных запросов (0 - без ограничения)
+	Default Duration `json:"default"`
+	// Routes - время обработки запросов к маршрутам; применяется первое подходящее правило
+	Routes []RouteTimeoutConfig `json:"routes"`
+}
+
+// RouteTimeoutConfig представляет время обработки запросов к маршруту
+type RouteTimeoutConfig struct {
+	// Path - путь запроса; путь, оканчивающийся на /, задает префикс
+	Path string `json:"path"`
+	// Method - метод запроса (пусто - любой)
+	Method string `json:"method"`
+	// Timeout - время обработки запроса (0 - без ограничения)
+	Timeout Duration `json:"timeout"`
+}
+
+// AdminConfig представляет настройки административного API: просмотр
+// маршрутов и состояния сервисов, сброс кэша, режим обслуживания и уровень
+// журнала без перезапуска
+type AdminConfig struct {
+	// Port - порт административного API (0 - отключен); на основном порту API недоступен
+	Port int `json:"port"`
+	// Token - токен доступа, который передается в заголовке Authorization: Bearer
+	Token string `json:"token"`
+}
+
+// CanaryConfig представляет правила канареечного выпуска: часть запросов
+// к маршрутам направляется на другую версию backend-сервиса
+type CanaryConfig struct {
+	// Routes - правила; для каждого сервиса применяется первое подходящее правило
+	Routes []CanaryRouteConfig `json:"routes"`
+}
+
+// CanaryRouteConfig представляет правило канареечного выпуска
+type CanaryRouteConfig struct {
+	// Path - путь запроса; путь, оканчивающийся на /, задает префикс
+	Path string `json:"path"`
+	// Method - метод запроса (пусто - любой)
+	Method string `json:"method"`
+	// Service - имя сервиса из секции services, запросы к которому делятся
+	Service string `json:"service"`
+	// Target - имя сервиса с новой версией, например news-v2
+	Target string `json:"target"`
+	// Percent - доля запросов в процентах, направляемая в Target
+	Percent float64 `json:"percent"`
+	// Key - по чему клиент закрепляется за версией: "ip" или "header:Имя";
+	// пусто - версия выбирается случайно для каждого запроса
+	Key string `json:"key"`
+}
+
+// CoalescingConfig представляет настройки объединения запросов: одновременные
+// одинаковые запросы GET к backend-сервису выполняются одним запросом, и его
+// ответ получают все ожидающие
+type CoalescingConfig struct {
+	// Enabled - объединять запросы
+	Enabled bool `json:"enabled"`
+	// MaxBodySize - максимальный размер ответа в байтах, который передается
+	// всем ожидающим; при большем ответе они отправляют свои запросы
+	MaxBodySize int `json:"max_body_size"`
+}
+
+// ValidationConfig представляет настройки проверки запросов к эндпоинтам
+// новостей и комментариев по схемам из описания API (/openapi.json).
+// Некорректный запрос отклоняется до обращения к backend-сервисам.
+type ValidationConfig struct {
+	Enabled bool `json:"enabled"`
+	// CommentMaxLength - максимальная длина текста комментария в символах
+	// (0 - без ограничения)
+	CommentMaxLength int `json:"comment_max_length"`
+	// MaxBodySize - максимальный размер проверяемого тела запроса в байтах
+	MaxBodySize int `json:"max_body_size"`
+}
+
+// ModerationConfig представляет настройки модерации комментариев. Комментарий
+// проверяется списком запрещенных слов, затем внешним сервисом модерации;
+// отклоненный комментарий не отправляется в сервис комментариев.
+type ModerationConfig struct {
+	// Words - запрещенные слова; регистр и различие е/ё не учитываются
+	Words []string `json:"words"`
+	// WordsFile - файл с запрещенными словами, по одному на строке
+	WordsFile string `json:"words_file"`
+	// URL - адрес внешнего сервиса модерации; пусто - сервис не используется
+	URL string `json:"url"`
+	// Timeout - таймаут запроса к сервису модерации (по умолчанию 2s)
+	Timeout Duration `json:"timeout"`
+	// FailOpen - принимать комментарии без проверки сервисом, если он
+	// недоступен; по умолчанию такие комментарии отклоняются
+	FailOpen bool `json:"fail_open"`
+}
+
+// StreamingConfig представляет настройки передачи ответов клиенту потоком
+type StreamingConfig struct {
+	// MaxBuffer - максимальный размер ответа в байтах, который шлюз читает в
+	// память для преобразования (новость по ID, кэширование, часовой пояс,
+	// protobuf и MessagePack). Больший ответ передается клиенту потоком без
+	// преобразования (по умолчанию 1 МБ, 0 - без ограничения)
+	MaxBuffer int `json:"max_buffer"`
+	// FlushInterval - как часто данные ответа backend-сервиса отправляются
+	// клиенту во время передачи: 0 - по мере заполнения буфера соединения,
+	// отрицательное значение - после каждой записи
+	FlushInterval Duration `json:"flush_interval"`
+}
+
+// CommentsConfig представляет настройки списка комментариев /api/comments
+type CommentsConfig struct {
+	// View - вид списка по умолчанию: "flat" (по умолчанию, список в порядке
+	// сервиса) или "tree" (ответы вложены в родительские комментарии по
+	// parent_id). Клиент может выбрать вид параметром view.
+	View string `json:"view"`
+}
+
+// CommentOutboxConfig представляет настройки очереди комментариев. Если
+// сервис комментариев недоступен, комментарий сохраняется в очередь, клиент
+// получает ответ 202, а шлюз повторяет доставку в фоне с экспоненциально
+// растущей паузой между попытками.
+type CommentOutboxConfig struct {
+	// Driver - хранилище очереди: "" (очередь отключена), "file" или "redis"
+	Driver string `json:"driver"`
+	// Dir - каталог очереди для драйвера file
+	Dir string `json:"dir"`
+	// Redis - подключение к Redis для драйвера redis
+	Redis RedisConfig `json:"redis"`
+	// Interval - период проверки очереди (по умолчанию 1s)
+	Interval Duration `json:"interval"`
+	// InitialBackoff - пауза перед первым повтором (по умолчанию 1s);
+	// удваивается после каждой неудачной попытки
+	InitialBackoff Duration `json:"initial_backoff"`
+	// MaxBackoff - максимальная пауза между попытками (по умолчанию 5m)
+	MaxBackoff Duration `json:"max_backoff"`
+	// MaxAttempts - число попыток, после которого комментарий удаляется из
+	// очереди (0 - без ограничения)
+	MaxAttempts int `json:"max_attempts"`
+}
+
+// NewsStreamConfig представляет настройки потока новых новостей
+// /api/news/stream. Шлюз опрашивает сервис новостей и отправляет
+// подключенным клиентам новости, которых не было при прошлом опросе.
+type NewsStreamConfig struct {
+	// Enabled - обслуживать /api/news/stream
+	Enabled bool `json:"enabled"`
+	// PollInterval - интервал опроса сервиса новостей
+	PollInterval Duration `json:"poll_interval"`
+	// Heartbeat - интервал комментариев-пульсов, по которым клиент и прокси
+	// понимают, что соединение живо
+	Heartbeat Duration `json:"heartbeat"`
+	// MaxClients - сколько клиентов может быть подключено одновременно (0 - без ограничения)
+	MaxClients int `json:"max_clients"`
+}
+
+// CORSConfig представляет настройки CORS: для остальных путей действует
+// общая политика, для путей из Routes - политика маршрута
+type CORSConfig struct {
+	// Enabled - отвечать на предварительные запросы OPTIONS и добавлять заголовки CORS
+	Enabled bool `json:"enabled"`
+	CORSPolicyConfig
+	// Routes - политики маршрутов; применяется первое подходящее правило
+	Routes []CORSRouteConfig `json:"routes"`
+}
+
+// CORSRouteConfig представляет политику CORS маршрута. Незаданные значения
+// берутся из общей политики.
+type CORSRouteConfig struct {
+	// Path - путь запроса; путь, оканчивающийся на /, задает префикс
+	Path string `json:"path"`
+	CORSPolicyConfig
+}
+
+// CORSPolicyConfig представляет политику CORS
+type CORSPolicyConfig struct {
+	// AllowedOrigins - источники (схема, хост и порт), которым разрешены запросы; "*" - любой
+	AllowedOrigins []string `json:"allowed_origins"`
+	// AllowedMethods - методы, разрешенные в предварительных запросах
+	AllowedMethods []string `json:"allowed_methods"`
+	// AllowedHeaders - заголовки запроса, разрешенные в предварительных запросах
+	AllowedHeaders []string `json:"allowed_headers"`
+	// ExposedHeaders - заголовки ответа, доступные скриптам страницы
+	ExposedHeaders []string `json:"exposed_headers"`
+	// AllowCredentials - разрешить запросы с cookie и заголовком Authorization
+	AllowCredentials bool `json:"allow_credentials"`
+	// MaxAge - сколько браузер хранит результат предварительного запроса
+	MaxAge Duration `json:"max_age"`
+}
+
+// WithDefaults возвращает политику, в которой незаданные значения взяты из defaults
+func (c CORSPolicyConfig) WithDefaults(defaults CORSPolicyConfig) CORSPolicyConfig {
+	if c.AllowedOrigins == nil {
+		c.AllowedOrigins = defaults.AllowedOrigins
+	}
+	if c.AllowedMethods == nil {
+		c.AllowedMethods = defaults.AllowedMethods
+	}
+	if c.AllowedHeaders == nil {
+		c.AllowedHeaders = defaults.AllowedHeaders
+	}
+	if c.ExposedHeaders == nil {
+		c.ExposedHeaders = defaults.ExposedHeaders
+	}
+	c.AllowCredentials = c.AllowCredentials || defaults.AllowCredentials
+	if c.MaxAge == 0 {
+		c.MaxAge = defaults.MaxAge
+	}
+	return c
+}
+
+// CompressionConfig представляет настройки сжатия ответов gzip
+type CompressionConfig struct {
+	// Enabled - сжимать ответы клиентам, поддерживающим gzip
+	Enabled bool `json:"enabled"`
+	// Level - уровень сжатия от 1 (быстрее) до 9 (сильнее)
+	Level int `json:"level"`
+	// MinSize - минимальный размер ответа в байтах, начиная с которого он сжимается
+	MinSize int `json:"min_size"`
+	// Types - типы содержимого, которые сжимаются; остальные передаются как есть
+	Types []CompressionTypeConfig `json:"types"`
+}
+
+// CompressionTypeConfig представляет правило сжатия для типа содержимого.
+// Нулевые Level и MinSize означают значения из секции compression.
+type CompressionTypeConfig struct {
+	// ContentType - тип содержимого без параметров, например application/json
+	ContentType string `json:"content_type"`
+	Level       int    `json:"level"`
+	MinSize     int    `json:"min_size"`
+}
+
+// BatchConfig представляет настройки пакетных запросов /api/batch
+type BatchConfig struct {
+	// MaxItems - максимальное количество запросов в пакете
+	MaxItems int `json:"max_items"`
+	// Concurrency - сколько запросов пакета выполняется одновременно
+	Concurrency int `json:"concurrency"`
+	// ItemTimeout - таймаут выполнения одного запроса пакета
+	ItemTimeout Duration `json:"item_timeout"`
+	// Timeout - таймаут выполнения всего пакета
+	Timeout Duration `json:"timeout"`
+}
+
+// GraphQLConfig представляет настройки эндпоинта /graphql
+type GraphQLConfig struct {
+	// Concurrency - сколько запросов комментариев одного GraphQL запроса
+	// выполняется одновременно (0 - без ограничения)
+	Concurrency int `json:"concurrency"`
+}
+
+// EventsConfig представляет настройки публикации событий шлюза
+type EventsConfig struct {
+	// Driver - брокер сообщений: "" (публикация отключена), "nats" или "kafka"
+	Driver string      `json:"driver"`
+	NATS   NATSConfig  `json:"nats"`
+	Kafka  KafkaConfig `json:"kafka"`
+	// Types - публикуемые типы событий (пусто - все)
+	Types []string `json:"types"`
+	// BufferSize - размер очереди событий; при переполнении новые события отбрасываются
+	BufferSize int `json:"buffer_size"`
+	// Timeout - таймаут отправки одного события
+	Timeout Duration `json:"timeout"`
+	// Source - атрибут source событий CloudEvents (URI-ссылка на экземпляр шлюза)
+	Source string `json:"source"`
+	// TypePrefix - префикс атрибута type событий CloudEvents, например com.example.apigw.
+	TypePrefix string `json:"type_prefix"`
+}
+
+// NATSConfig представляет настройки подключения к NATS
+type NATSConfig struct {
+	URL string `json:"url"`
+	// Subject - префикс темы, события публикуются в <subject>.<тип события>
+	Subject string `json:"subject"`
+}
+
+// KafkaConfig представляет настройки подключения к Kafka
+type KafkaConfig struct {
+	// Brokers - адреса брокеров в формате host:port
+	Brokers []string `json:"brokers"`
+	Topic   string   `json:"topic"`
+}
+
+// CacheConfig представляет настройки кэширования ответов
+type CacheConfig struct {
+	// Driver - хранилище кэша: "memory" (по умолчанию), "memcached" или "redis"
+	Driver string `json:"driver"`
+	// MaxEntries - максимальное количество записей кэша в памяти (драйвер memory,
+	// 0 - без ограничения). При заполнении вытесняются давно не использованные записи.
+	MaxEntries int `json:"max_entries"`
+	// Memcached - настройки подключения к memcached
+	Memcached MemcachedConfig `json:"memcached"`
+	// Redis - настройки подключения к Redis
+	Redis RedisConfig `json:"redis"`
+	// Local - локальный кэш в памяти процесса перед общим хранилищем
+	Local LocalCacheConfig `json:"local"`
+	// Serialization - формат записей с ответами: "json" (по умолчанию) или
+	// "msgpack" - компактнее для общих хранилищ memcached и Redis
+	Serialization string `json:"serialization"`
+	// IgnoredParams - параметры запроса, которые не учитываются в ключе кэша
+	IgnoredParams []string `json:"ignored_params"`
+	// NegativeTTL - время хранения ответов "новость не найдена" (0 - не кэшировать)
+	NegativeTTL Duration `json:"negative_ttl"`
+	// TTL - время хранения успешных ответов на GET запросы (0 - не кэшировать)
+	TTL Duration `json:"ttl"`
+	// StaleIfError - сколько хранится копия ответа, которая отправляется
+	// клиенту, если сервис ответил ошибкой 5xx или не ответил (0 - не отправлять)
+	StaleIfError Duration `json:"stale_if_error"`
+	// Routes - время хранения ответов отдельных маршрутов вместо TTL
+	Routes []CacheRouteConfig `json:"routes"`
+	// PaginationTTL - время хранения общего количества новостей для списков
+	// и поиска (0 - не кэшировать). Пока оно известно, ответ сервиса новостей
+	// читается только до конца запрошенной страницы.
+	PaginationTTL Duration `json:"pagination_ttl"`
+	// Warmup - настройки прогрева кэша
+	Warmup WarmupConfig `json:"warmup"`
+}
+
+// CacheRouteConfig представляет время хранения ответов маршрута
+type CacheRouteConfig struct {
+	// Path - путь запроса; путь, оканчивающийся на /, задает префикс
+	Path string `json:"path"`
+	// TTL - время хранения ответов (0 - ответы маршрута не кэшируются)
+	TTL Duration `json:"ttl"`
+}
+
+// MemcachedConfig представляет настройки подключения к memcached
+type MemcachedConfig struct {
+	// Servers - адреса серверов в формате host:port
+	Servers []string `json:"servers"`
+	// Prefix - префикс ключей, позволяющий разделять кластер между приложениями
+	Prefix string `json:"prefix"`
+	// Timeout - таймаут операций с сервером
+	Timeout Duration `json:"timeout"`
+}
+
+// RedisConfig представляет настройки подключения к Redis
+type RedisConfig struct {
+	Addr     string `json:"addr"`
+	Password string `json:"password"`
+	DB       int    `json:"db"`
+	// Prefix - префикс ключей, позволяющий разделять базу между приложениями
+	Prefix string `json:"prefix"`
+	// Timeout - таймаут подключения и операций
+	Timeout Duration `json:"timeout"`
+}
+
+// LocalCacheConfig представляет настройки локального уровня двухуровневого кэша
+type LocalCacheConfig struct {
+	// MaxEntries - максимальное количество записей в локальном кэше
+	MaxEntries int `json:"max_entries"`
+	// TTL - время хранения записей локально (0 - локальный уровень отключен)
+	TTL Duration `json:"ttl"`
+}
+
+// WarmupConfig представляет настройки прогрева кэша
+type WarmupConfig struct {
+	// Paths - пути с параметрами, которые запрашиваются при запуске и по расписанию
+	Paths []string `json:"paths"`
+	// Interval - интервал повторного прогрева (0 - только при запуске)
+	Interval Duration `json:"interval"`
 }
 
 // LoadConfig загружает конфигурацию из файла
@@ -65,19 +1302,220 @@ func LoadConfig(filename string) (*Config, error) {
 	return cfg, nil
 }
 
+// ReadConfig читает конфигурацию из файла. В отличие от LoadConfig, не создает
+// отсутствующий файл и считает ошибкой неизвестные поля, чтобы опечатки
+// в названиях параметров не оставались незамеченными.
+func ReadConfig(filename string) (*Config, error) {
+	cfg := NewConfig()
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть файл конфигурации: %w", err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("не удалось декодировать конфигурацию: %w", err)
+	}
+	return cfg, nil
+}
+
 // NewConfig создает новый экземпляр конфигурации с значениями по умолчанию
 func NewConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Port: 8081,
+			Port:        8081,
+			Environment: "production",
+			TLS: TLSConfig{
+				MinVersion: "1.2",
+			},
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
 		},
 		Services: ServicesConfig{
-			News: ServiceConfig{
+			ServiceNews: {
 				URL: "http://localhost:8080",
 			},
-			Comments: ServiceConfig{
+			ServiceComments: {
 				URL: "http://localhost:8082",
 			},
 		},
+		Docs: DocsConfig{
+			Enabled:   true,
+			AssetsURL: "https://unpkg.com/swagger-ui-dist@5",
+		},
+		Static: StaticConfig{
+			Index:       "index.html",
+			SPAFallback: true,
+		},
+		Search: SearchConfig{
+			RefreshInterval: Duration(time.Minute),
+		},
+		Concurrency: ConcurrencyConfig{
+			InitialLimit: 20,
+			MinLimit:     2,
+			MaxLimit:     200,
+			Smoothing:    0.2,
+			Tolerance:    1.5,
+		},
+		HTTPClient: HTTPClientConfig{
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   32,
+			DialTimeout:           Duration(5 * time.Second),
+			ResponseHeaderTimeout: Duration(30 * time.Second),
+			KeepAlive:             Duration(30 * time.Second),
+			IdleConnTimeout:       Duration(90 * time.Second),
+		},
+		CircuitBreaker: CircuitBreakerConfig{
+			FailureThreshold: 5,
+			OpenTimeout:      Duration(30 * time.Second),
+			HalfOpenRequests: 1,
+			Fallback:         BreakerFallbackError,
+			StaleTTL:         Duration(time.Hour),
+		},
+		Bulkhead: BulkheadConfig{
+			QueueTimeout: Duration(100 * time.Millisecond),
+		},
+		HealthCheck: HealthCheckConfig{
+			Interval:           Duration(10 * time.Second),
+			Timeout:            Duration(2 * time.Second),
+			UnhealthyThreshold: 3,
+			HealthyThreshold:   2,
+		},
+		Auth: AuthConfig{
+			Header: "X-API-Key",
+			Paths:  []string{"/api/", "/rpc", "/graphql"},
+		},
+		JWT: JWTConfig{
+			JWKSRefresh: Duration(10 * time.Minute),
+			Leeway:      Duration(30 * time.Second),
+			UserClaim:   "sub",
+			RolesClaim:  "roles",
+		},
+		RateLimit: ClientRateLimitConfig{
+			MaxClients: 100000,
+		},
+		Retries: RetryConfig{
+			MaxAttempts:         3,
+			InitialBackoff:      Duration(100 * time.Millisecond),
+			MaxBackoff:          Duration(2 * time.Second),
+			BackoffMultiplier:   2,
+			RetryableStatuses:   []int{502, 503, 504},
+			BudgetRatio:         0.1,
+			MinRetriesPerSecond: 1,
+		},
+		Shedding: SheddingConfig{
+			Threshold:     0.85,
+			CheckInterval: Duration(time.Second),
+		},
+		QoS: QoSConfig{
+			Classes: map[string]QoSClassConfig{
+				QoSInteractive: {ConcurrencyShare: 1},
+				QoSWrite:       {ConcurrencyShare: 0.8, ShedThreshold: 0.95},
+				QoSExport:      {ConcurrencyShare: 0.5, ShedThreshold: 0.85},
+			},
+		},
+		Compression: CompressionConfig{
+			Level:   5,
+			MinSize: 1024,
+			Types: []CompressionTypeConfig{
+				{ContentType: "application/json"},
+				{ContentType: "text/html"},
+				{ContentType: "text/plain"},
+				{ContentType: "text/css"},
+				{ContentType: "application/javascript"},
+				{ContentType: "image/svg+xml"},
+			},
+		},
+		Batch: BatchConfig{
+			MaxItems:    20,
+			Concurrency: 8,
+			ItemTimeout: Duration(5 * time.Second),
+			Timeout:     Duration(10 * time.Second),
+		},
+		GraphQL: GraphQLConfig{
+			Concurrency: 8,
+		},
+		Events: EventsConfig{
+			NATS: NATSConfig{
+				URL:     "nats://localhost:4222",
+				Subject: "apigw.events",
+			},
+			Kafka: KafkaConfig{
+				Topic: "apigw.events",
+			},
+			BufferSize: 1024,
+			Timeout:    Duration(5 * time.Second),
+			Source:     "/apigw",
+			TypePrefix: "apigw.",
+		},
+		Tenants: TenantsConfig{
+			IdentifyBy: []string{"api_key", "host"},
+			Header:     "X-Tenant-ID",
+		},
+		Metrics: MetricsConfig{
+			Enabled: true,
+			Path:    "/metrics",
+		},
+		Health: HealthConfig{
+			LivenessPath:  "/healthz",
+			ReadinessPath: "/readyz",
+			Services:      []string{ServiceNews, ServiceComments},
+			Timeout:       Duration(2 * time.Second),
+			CacheTTL:      Duration(5 * time.Second),
+		},
+		Dates: DatesConfig{
+			SourceTimezone: "UTC",
+		},
+		MethodOverride: MethodOverrideConfig{
+			Methods: []string{"PUT", "PATCH", "DELETE"},
+		},
+		CORS: CORSConfig{
+			CORSPolicyConfig: CORSPolicyConfig{
+				AllowedMethods: []string{"GET", "HEAD", "POST"},
+				AllowedHeaders: []string{"Content-Type", "Authorization", "X-API-Key", "X-Request-ID"},
+				ExposedHeaders: []string{"X-Request-ID"},
+				MaxAge:         Duration(10 * time.Minute),
+			},
+		},
+		Coalescing: CoalescingConfig{
+			MaxBodySize: 1 << 20,
+		},
+		Moderation: ModerationConfig{
+			Timeout: Duration(2 * time.Second),
+		},
+		Validation: ValidationConfig{
+			CommentMaxLength: 2000,
+			MaxBodySize:      64 << 10,
+		},
+		Streaming: StreamingConfig{
+			MaxBuffer: 1 << 20,
+		},
+		Comments: CommentsConfig{
+			View: "flat",
+		},
+		CommentOutbox: CommentOutboxConfig{
+			Interval:       Duration(time.Second),
+			InitialBackoff: Duration(time.Second),
+			MaxBackoff:     Duration(5 * time.Minute),
+		},
+		NewsStream: NewsStreamConfig{
+			PollInterval: Duration(15 * time.Second),
+			Heartbeat:    Duration(30 * time.Second),
+			MaxClients:   1000,
+		},
+		Cache: CacheConfig{
+			Driver:        "memory",
+			MaxEntries:    10000,
+			IgnoredParams: []string{"request_id"},
+			NegativeTTL:   Duration(30 * time.Second),
+			Local: LocalCacheConfig{
+				MaxEntries: 256,
+			},
+		},
 	}
 }
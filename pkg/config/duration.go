@@ -0,0 +1,42 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration - интервал времени, который в JSON записывается строкой вида "30s" или "5m"
+type Duration time.Duration
+
+// MarshalJSON кодирует интервал в строку
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON декодирует интервал из строки или числа секунд
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	switch value := raw.(type) {
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("некорректный интервал %q: %w", value, err)
+		}
+		*d = Duration(parsed)
+	case float64:
+		*d = Duration(time.Duration(value * float64(time.Second)))
+	default:
+		return fmt.Errorf("некорректный интервал: %s", string(data))
+	}
+	return nil
+}
+
+// Std возвращает интервал в виде time.Duration
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
@@ -0,0 +1,48 @@
+package config
+
+// redactedValue заменяет секреты в выводе конфигурации
+const redactedValue = "***"
+
+// Redacted возвращает копию конфигурации, в которой ключи API, токены,
+// секреты и пароли заменены на "***". Копию можно выводить в журналы CI/CD,
+// исходная конфигурация не изменяется.
+func (c *Config) Redacted() *Config {
+	r := *c
+	r.Docs.Password = redact(c.Docs.Password)
+	r.JWT.Secret = redact(c.JWT.Secret)
+	r.Admin.Token = redact(c.Admin.Token)
+	r.RateLimit.Redis.Password = redact(c.RateLimit.Redis.Password)
+	r.CommentOutbox.Redis.Password = redact(c.CommentOutbox.Redis.Password)
+	r.Cache.Redis.Password = redact(c.Cache.Redis.Password)
+
+	if c.Auth.Keys != nil {
+		r.Auth.Keys = make([]APIKeyConfig, len(c.Auth.Keys))
+		for i, key := range c.Auth.Keys {
+			key.Key = redact(key.Key)
+			r.Auth.Keys[i] = key
+		}
+	}
+	if c.Tenants.List != nil {
+		r.Tenants.List = make(map[string]TenantConfig, len(c.Tenants.List))
+		for name, tenant := range c.Tenants.List {
+			if tenant.APIKeys != nil {
+				keys := make([]string, len(tenant.APIKeys))
+				for i, key := range tenant.APIKeys {
+					keys[i] = redact(key)
+				}
+				tenant.APIKeys = keys
+			}
+			r.Tenants.List[name] = tenant
+		}
+	}
+	return &r
+}
+
+// redact скрывает непустое значение; пустое остается пустым, чтобы было
+// видно, что секрет не задан
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redactedValue
+}
@@ -0,0 +1,289 @@
+package contract
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Result - результат одной проверки контракта
+type Result struct {
+	Service string `json:"service"`
+	Check   string `json:"check"`
+	// Problems - найденные несовместимости; пустой список - проверка пройдена
+	Problems []string `json:"problems,omitempty"`
+	// Skipped - проверка не выполнялась, причина в Problems
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// Failed сообщает, что найдены несовместимости
+func (r Result) Failed() bool {
+	return !r.Skipped && len(r.Problems) > 0
+}
+
+// Options - параметры проверки
+type Options struct {
+	// NewsURL, CommentsURL - адреса сервисов новостей и комментариев
+	NewsURL     string
+	CommentsURL string
+	// Write - проверять также добавление комментария (создает комментарий в сервисе)
+	Write bool
+	// Client - HTTP клиент для запросов (по умолчанию http.DefaultClient)
+	Client *http.Client
+}
+
+// maxProblems - количество несовместимостей, сообщаемых по одной проверке
+const maxProblems = 10
+
+// checker выполняет проверки и хранит данные, нужные следующим проверкам
+type checker struct {
+	ctx    context.Context
+	opts   Options
+	client *http.Client
+
+	// firstID, maxID - ID первой и наибольший ID из списка новостей
+	firstID, maxID int64
+}
+
+// Run проверяет, что сервисы новостей и комментариев отвечают в формате,
+// на который рассчитан шлюз
+func Run(ctx context.Context, opts Options) []Result {
+	c := &checker{ctx: ctx, opts: opts, client: opts.Client}
+	if c.client == nil {
+		c.client = http.DefaultClient
+	}
+
+	results := []Result{c.newsList()}
+	if c.firstID == 0 {
+		reason := "список новостей пуст или не получен"
+		results = append(results,
+			Result{Service: "news", Check: "новость по ID", Problems: []string{reason}, Skipped: true},
+			Result{Service: "news", Check: "несуществующая новость", Problems: []string{reason}, Skipped: true},
+			Result{Service: "comments", Check: "комментарии к новости", Problems: []string{reason}, Skipped: true},
+		)
+		return results
+	}
+
+	results = append(results, c.newsItem(), c.missingNews(), c.comments())
+	if opts.Write {
+		results = append(results, c.addComment())
+	}
+	return results
+}
+
+// newsList проверяет GET /api/news/: массив новостей с обязательными полями и уникальными ID
+func (c *checker) newsList() Result {
+	r := Result{Service: "news", Check: "список новостей"}
+
+	var items []map[string]interface{}
+	if problem := c.getJSON(c.opts.NewsURL+"/api/news/", http.StatusOK, &items); problem != "" {
+		r.add(problem)
+		return r
+	}
+
+	seen := make(map[int64]bool, len(items))
+	for i, item := range items {
+		id, ok := checkNews(&r, fmt.Sprintf("новость [%d]", i), item)
+		if !ok {
+			continue
+		}
+		if seen[id] {
+			r.add(fmt.Sprintf("новость [%d]: повторяющийся id %d", i, id))
+		}
+		seen[id] = true
+		if c.firstID == 0 {
+			c.firstID = id
+		}
+		if id > c.maxID {
+			c.maxID = id
+		}
+	}
+	return r
+}
+
+// newsItem проверяет GET /api/news/{id}: массив из одной новости с запрошенным ID
+func (c *checker) newsItem() Result {
+	r := Result{Service: "news", Check: "новость по ID"}
+
+	var items []map[string]interface{}
+	if problem := c.getJSON(fmt.Sprintf("%s/api/news/%d", c.opts.NewsURL, c.firstID), http.StatusOK, &items); problem != "" {
+		r.add(problem)
+		return r
+	}
+	if len(items) != 1 {
+		r.add(fmt.Sprintf("ожидается массив из одной новости, получено элементов: %d", len(items)))
+		return r
+	}
+	if id, ok := checkNews(&r, "новость", items[0]); ok && id != c.firstID {
+		r.add(fmt.Sprintf("запрошена новость %d, получена %d", c.firstID, id))
+	}
+	return r
+}
+
+// missingNews проверяет, что о несуществующей новости сервис сообщает статусом 404
+// или пустым массивом
+func (c *checker) missingNews() Result {
+	r := Result{Service: "news", Check: "несуществующая новость"}
+
+	resp, body, err := c.do(http.MethodGet, fmt.Sprintf("%s/api/news/%d", c.opts.NewsURL, c.maxID+1000000), nil)
+	if err != nil {
+		r.add(err.Error())
+		return r
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return r
+	}
+	var items []json.RawMessage
+	if resp.StatusCode != http.StatusOK || json.Unmarshal(body, &items) != nil || len(items) != 0 {
+		r.add(fmt.Sprintf("ожидается статус 404 или пустой массив, получен статус %d", resp.StatusCode))
+	}
+	return r
+}
+
+// comments проверяет GET /api/comm_news?id={id}: массив комментариев с обязательными полями
+func (c *checker) comments() Result {
+	r := Result{Service: "comments", Check: "комментарии к новости"}
+
+	var items []map[string]interface{}
+	if problem := c.getJSON(fmt.Sprintf("%s/api/comm_news?id=%d", c.opts.CommentsURL, c.firstID), http.StatusOK, &items); problem != "" {
+		r.add(problem)
+		return r
+	}
+	for i, item := range items {
+		name := fmt.Sprintf("комментарий [%d]", i)
+		checkID(&r, name, item, "id", true)
+		checkString(&r, name, item, "text", true)
+		checkString(&r, name, item, "created_at", false)
+		if newsID, ok := checkID(&r, name, item, "news_id", false); ok && newsID != c.firstID {
+			r.add(fmt.Sprintf("%s: news_id %d не совпадает с запрошенной новостью %d", name, newsID, c.firstID))
+		}
+	}
+	return r
+}
+
+// addComment проверяет POST /api/comm_add_news?id={id} с телом {"text": "..."}
+func (c *checker) addComment() Result {
+	r := Result{Service: "comments", Check: "добавление комментария"}
+
+	body := []byte(`{"text":"Проверка контракта apigw verify-backends"}`)
+	resp, data, err := c.do(http.MethodPost, fmt.Sprintf("%s/api/comm_add_news?id=%d", c.opts.CommentsURL, c.firstID), body)
+	if err != nil {
+		r.add(err.Error())
+		return r
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		r.add(fmt.Sprintf("ожидается статус 2xx, получен %d: %s", resp.StatusCode, snippet(data)))
+		return r
+	}
+	if len(bytes.TrimSpace(data)) > 0 && !json.Valid(data) {
+		r.add("ответ не является JSON: " + snippet(data))
+	}
+	return r
+}
+
+// getJSON выполняет GET запрос и декодирует JSON ответ. Возвращает описание
+// несовместимости или пустую строку.
+func (c *checker) getJSON(url string, status int, v interface{}) string {
+	resp, body, err := c.do(http.MethodGet, url, nil)
+	if err != nil {
+		return err.Error()
+	}
+	if resp.StatusCode != status {
+		return fmt.Sprintf("ожидается статус %d, получен %d: %s", status, resp.StatusCode, snippet(body))
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Sprintf("некорректный JSON: %v", err)
+	}
+	return ""
+}
+
+// do выполняет запрос и читает тело ответа
+func (c *checker) do(method, url string, body []byte) (*http.Response, []byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(c.ctx, method, url, reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("сервис недоступен: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка при чтении ответа: %w", err)
+	}
+	return resp, data, nil
+}
+
+// checkNews проверяет обязательные поля новости и возвращает ее ID
+func checkNews(r *Result, name string, item map[string]interface{}) (int64, bool) {
+	id, ok := checkID(r, name, item, "id", true)
+	checkString(r, name, item, "title", true)
+	checkString(r, name, item, "pub_date", true)
+	checkString(r, name, item, "source_url", true)
+	checkString(r, name, item, "description", false)
+	checkString(r, name, item, "created_at", false)
+	return id, ok
+}
+
+// checkID проверяет, что поле - положительное целое число
+func checkID(r *Result, name string, item map[string]interface{}, field string, required bool) (int64, bool) {
+	value, exists := item[field]
+	if !exists || value == nil {
+		if required {
+			r.add(fmt.Sprintf("%s: отсутствует поле %s", name, field))
+		}
+		return 0, false
+	}
+	number, ok := value.(float64)
+	if !ok || number <= 0 || number != float64(int64(number)) {
+		r.add(fmt.Sprintf("%s: поле %s должно быть положительным целым числом, получено %v", name, field, value))
+		return 0, false
+	}
+	return int64(number), true
+}
+
+// checkString проверяет, что поле - строка
+func checkString(r *Result, name string, item map[string]interface{}, field string, required bool) {
+	value, exists := item[field]
+	if !exists || value == nil {
+		if required {
+			r.add(fmt.Sprintf("%s: отсутствует поле %s", name, field))
+		}
+		return
+	}
+	if _, ok := value.(string); !ok {
+		r.add(fmt.Sprintf("%s: поле %s должно быть строкой, получено %v", name, field, value))
+	}
+}
+
+// add добавляет несовместимость, ограничивая их количество
+func (r *Result) add(problem string) {
+	switch {
+	case len(r.Problems) < maxProblems:
+		r.Problems = append(r.Problems, problem)
+	case len(r.Problems) == maxProblems:
+		r.Problems = append(r.Problems, "...")
+	}
+}
+
+// snippet возвращает начало тела ответа для сообщения об ошибке
+func snippet(body []byte) string {
+	text := strings.TrimSpace(string(body))
+	if runes := []rune(text); len(runes) > 200 {
+		return string(runes[:200]) + "..."
+	}
+	return text
+}
@@ -0,0 +1,54 @@
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// ContentType - тип содержимого события CloudEvents в структурированном режиме
+const ContentType = "application/cloudevents+json"
+
+// cloudEvent - событие в формате CloudEvents 1.0 (JSON, структурированный режим)
+type cloudEvent struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Subject         string      `json:"subject,omitempty"`
+	Time            time.Time   `json:"time"`
+	DataContentType string      `json:"datacontenttype,omitempty"`
+	Data            interface{} `json:"data,omitempty"`
+}
+
+// encode кодирует событие в формат CloudEvents
+func (p *Publisher) encode(event Event) ([]byte, error) {
+	id, err := newEventID()
+	if err != nil {
+		return nil, err
+	}
+
+	ce := cloudEvent{
+		SpecVersion: "1.0",
+		ID:          id,
+		Source:      p.source,
+		Type:        p.typePrefix + event.Type,
+		Subject:     event.Subject,
+		Time:        event.Time.UTC(),
+		Data:        event.Data,
+	}
+	if event.Data != nil {
+		ce.DataContentType = "application/json"
+	}
+	return json.Marshal(ce)
+}
+
+// newEventID генерирует уникальный идентификатор события
+func newEventID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
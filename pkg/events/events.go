@@ -0,0 +1,146 @@
+package events
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Типы событий шлюза
+const (
+	TypeRequestCompleted  = "request.completed"
+	TypeCommentCreated    = "comment.created"
+	TypeUpstreamUnhealthy = "upstream.unhealthy"
+	TypeUpstreamHealthy   = "upstream.healthy"
+)
+
+// Event - событие шлюза
+type Event struct {
+	// Type - тип события без префикса, например comment.created
+	Type string
+	// Subject - объект события внутри источника: путь запроса, ID новости, адрес сервиса
+	Subject string
+	Time    time.Time
+	Data    interface{}
+}
+
+// Sink - брокер сообщений, в который отправляются события
+type Sink interface {
+	// Send отправляет закодированное событие указанного типа
+	Send(ctx context.Context, eventType string, payload []byte) error
+	Close() error
+}
+
+// Options - настройки публикации
+type Options struct {
+	// Types - публикуемые типы событий (пусто - все)
+	Types []string
+	// BufferSize - размер очереди событий
+	BufferSize int
+	// Timeout - таймаут отправки одного события
+	Timeout time.Duration
+	// Source - атрибут source событий CloudEvents
+	Source string
+	// TypePrefix - префикс атрибута type событий CloudEvents
+	TypePrefix string
+}
+
+// Publisher публикует события в фоне, не задерживая обработку запросов.
+// Если брокер не успевает принимать события и очередь заполнена,
+// новые события отбрасываются.
+type Publisher struct {
+	sink       Sink
+	types      map[string]bool
+	timeout    time.Duration
+	source     string
+	typePrefix string
+	queue      chan Event
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewPublisher создает издателя событий и запускает отправку в фоне
+func NewPublisher(sink Sink, opts Options) *Publisher {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 1024
+	}
+
+	p := &Publisher{
+		sink:       sink,
+		timeout:    opts.Timeout,
+		source:     opts.Source,
+		typePrefix: opts.TypePrefix,
+		queue:      make(chan Event, opts.BufferSize),
+		done:       make(chan struct{}),
+	}
+	if len(opts.Types) > 0 {
+		p.types = make(map[string]bool, len(opts.Types))
+		for _, t := range opts.Types {
+			p.types[t] = true
+		}
+	}
+
+	go p.run()
+	return p
+}
+
+// Enabled сообщает, публикуются ли события указанного типа
+func (p *Publisher) Enabled(eventType string) bool {
+	return p.types == nil || p.types[eventType]
+}
+
+// Publish ставит событие в очередь на отправку
+func (p *Publisher) Publish(event Event) {
+	if !p.Enabled(event.Type) {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	select {
+	case p.queue <- event:
+	default:
+		log.Printf("Очередь событий заполнена, событие %s отброшено", event.Type)
+	}
+}
+
+// run отправляет события из очереди, пока очередь не закрыта
+func (p *Publisher) run() {
+	defer close(p.done)
+
+	for event := range p.queue {
+		if err := p.send(event); err != nil {
+			log.Printf("Ошибка при публикации события %s: %v", event.Type, err)
+		}
+	}
+}
+
+// send кодирует событие и отправляет его брокеру
+func (p *Publisher) send(event Event) error {
+	payload, err := p.encode(event)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+	return p.sink.Send(ctx, event.Type, payload)
+}
+
+// Close отправляет оставшиеся в очереди события и закрывает соединение с брокером
+func (p *Publisher) Close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		close(p.queue)
+		<-p.done
+		err = p.sink.Close()
+	})
+	return err
+}
@@ -0,0 +1,43 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaSink публикует события в топик Kafka
+type kafkaSink struct {
+	writer *kafka.Writer
+}
+
+// NewKafka создает издателя в топик Kafka. Сообщения передаются в структурированном
+// режиме CloudEvents (заголовок content-type), тип события без префикса
+// дополнительно передается в заголовке type.
+func NewKafka(brokers []string, topic string) Sink {
+	return &kafkaSink{writer: &kafka.Writer{
+		Addr:     kafka.TCP(brokers...),
+		Topic:    topic,
+		Balancer: &kafka.LeastBytes{},
+		// События отправляются по одному, поэтому не ждем накопления пакета
+		BatchTimeout:           10 * time.Millisecond,
+		AllowAutoTopicCreation: true,
+	}}
+}
+
+// Send публикует событие
+func (s *kafkaSink) Send(ctx context.Context, eventType string, payload []byte) error {
+	return s.writer.WriteMessages(ctx, kafka.Message{
+		Value: payload,
+		Headers: []kafka.Header{
+			{Key: "content-type", Value: []byte(ContentType)},
+			{Key: "type", Value: []byte(eventType)},
+		},
+	})
+}
+
+// Close закрывает соединения с брокерами
+func (s *kafkaSink) Close() error {
+	return s.writer.Close()
+}
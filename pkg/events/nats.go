@@ -0,0 +1,49 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsSink публикует события в NATS
+type natsSink struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// NewNATS подключается к NATS. События публикуются в тему subject.<тип события>,
+// например apigw.events.comment.created, чтобы подписчики могли выбирать
+// нужные события шаблонами (apigw.events.upstream.>).
+// Если сервер недоступен при запуске, подключение повторяется в фоне.
+func NewNATS(url, subject string) (Sink, error) {
+	conn, err := nats.Connect(url,
+		nats.Name("apigw"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Printf("Потеряно соединение с NATS: %v", err)
+			}
+		}),
+		nats.ReconnectHandler(func(c *nats.Conn) {
+			log.Printf("Восстановлено соединение с NATS %s", c.ConnectedUrl())
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось подключиться к NATS: %w", err)
+	}
+	return &natsSink{conn: conn, subject: subject}, nil
+}
+
+// Send публикует событие
+func (s *natsSink) Send(_ context.Context, eventType string, payload []byte) error {
+	return s.conn.Publish(s.subject+"."+eventType, payload)
+}
+
+// Close отправляет буферизованные сообщения и закрывает соединение
+func (s *natsSink) Close() error {
+	return s.conn.Drain()
+}
@@ -0,0 +1,128 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Options - параметры HTTP клиента backend-сервиса
+type Options struct {
+	// MaxIdleConns - общее число простаивающих соединений
+	MaxIdleConns int
+	// MaxIdleConnsPerHost - число простаивающих соединений с одним хостом
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost - максимальное число соединений с одним хостом (0 - без ограничения)
+	MaxConnsPerHost int
+	// DialTimeout - таймаут установки соединения
+	DialTimeout time.Duration
+	// KeepAlive - интервал TCP keep-alive (отрицательное значение отключает)
+	KeepAlive time.Duration
+	// IdleConnTimeout - через сколько закрывается простаивающее соединение
+	IdleConnTimeout time.Duration
+	// ResponseHeaderTimeout - сколько ждать заголовков ответа после отправки запроса
+	ResponseHeaderTimeout time.Duration
+	// TLSHandshakeTimeout - таймаут установки TLS соединения
+	TLSHandshakeTimeout time.Duration
+	// ServerName - имя сервера для проверки TLS сертификата (пусто - хост из адреса запроса)
+	ServerName string
+}
+
+// New создает HTTP клиент с отдельным пулом соединений. Общий таймаут
+// запроса не задается: время ожидания ограничивает контекст запроса,
+// а потоковые ответы могут передаваться сколь угодно долго.
+func New(opts Options) *http.Client {
+	return &http.Client{Transport: NewTransport(opts)}
+}
+
+// NewTransport создает транспорт клиента New с отдельным пулом соединений
+func NewTransport(opts Options) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   opts.DialTimeout,
+		KeepAlive: opts.KeepAlive,
+	}
+	var tlsConfig *tls.Config
+	if opts.ServerName != "" {
+		tlsConfig = &tls.Config{ServerName: opts.ServerName}
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          opts.MaxIdleConns,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
+		TLSHandshakeTimeout:   opts.TLSHandshakeTimeout,
+		TLSClientConfig:       tlsConfig,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// Pool - HTTP клиенты backend-сервисов. Клиент выбирается по схеме и хосту
+// адреса запроса; для адресов, которые не были добавлены, используется
+// клиент по умолчанию.
+type Pool struct {
+	fallback *http.Client
+
+	mu      sync.RWMutex
+	clients map[string]*http.Client
+}
+
+// NewPool создает набор клиентов с клиентом по умолчанию, построенным из opts
+func NewPool(opts Options) *Pool {
+	return &Pool{
+		fallback: New(opts),
+		clients:  make(map[string]*http.Client),
+	}
+}
+
+// Add создает отдельный клиент для сервиса с адресом base. Клиент,
+// ранее добавленный для той же схемы и хоста, заменяется.
+func (p *Pool) Add(base *url.URL, opts Options) {
+	client := New(opts)
+	p.mu.Lock()
+	p.clients[hostKey(base)] = client
+	p.mu.Unlock()
+}
+
+// AddTransport добавляет для сервиса с адресом base клиент, который
+// отправляет запросы через rt вместо пула HTTP соединений
+func (p *Pool) AddTransport(base *url.URL, rt http.RoundTripper) {
+	p.mu.Lock()
+	p.clients[hostKey(base)] = &http.Client{Transport: rt}
+	p.mu.Unlock()
+}
+
+// For возвращает клиент для запроса по адресу u
+func (p *Pool) For(u *url.URL) *http.Client {
+	p.mu.RLock()
+	client, ok := p.clients[hostKey(u)]
+	p.mu.RUnlock()
+	if ok {
+		return client
+	}
+	return p.fallback
+}
+
+// CloseIdleConnections закрывает простаивающие соединения всех клиентов.
+// Соединения, по которым выполняются запросы, не затрагиваются. Транспорт,
+// добавленный через AddTransport, получает вызов своего CloseIdleConnections.
+func (p *Pool) CloseIdleConnections() {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	p.fallback.CloseIdleConnections()
+	for _, client := range p.clients {
+		client.CloseIdleConnections()
+	}
+}
+
+// hostKey возвращает ключ клиента по адресу: схема и хост
+func hostKey(u *url.URL) string {
+	return u.Scheme + "://" + u.Host
+}
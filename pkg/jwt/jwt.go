@@ -0,0 +1,244 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Ошибки проверки токена
+var (
+	ErrMalformed     = errors.New("некорректный формат токена")
+	ErrAlgorithm     = errors.New("неподдерживаемый алгоритм подписи")
+	ErrUnknownKey    = errors.New("нет ключа для проверки подписи")
+	ErrSignature     = errors.New("неверная подпись токена")
+	ErrExpired       = errors.New("срок действия токена истек")
+	ErrNotYetValid   = errors.New("токен еще не действителен")
+	ErrWrongIssuer   = errors.New("токен выпущен другим издателем")
+	ErrWrongAudience = errors.New("токен предназначен другому получателю")
+)
+
+// Claims - утверждения (payload) токена
+type Claims map[string]interface{}
+
+// Value возвращает утверждение name. Имя с точками, которого нет среди
+// утверждений верхнего уровня, задает путь во вложенных объектах, например
+// realm_access.roles в токенах Keycloak.
+func (c Claims) Value(name string) (interface{}, bool) {
+	if v, ok := c[name]; ok {
+		return v, true
+	}
+	var current interface{} = map[string]interface{}(c)
+	for _, part := range strings.Split(name, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// String возвращает строковое утверждение name или пустую строку
+func (c Claims) String(name string) string {
+	v, _ := c.Value(name)
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
+
+// Strings возвращает утверждение name со списком строк: массив строк или
+// строку, разделенную пробелами (как scope в OAuth 2.0)
+func (c Claims) Strings(name string) []string {
+	v, _ := c.Value(name)
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// time возвращает утверждение name с временем в секундах Unix
+func (c Claims) time(name string) (time.Time, bool, error) {
+	value, ok := c[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	n, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("%w: утверждение %s должно быть числом", ErrMalformed, name)
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%w: утверждение %s: %v", ErrMalformed, name, err)
+	}
+	return time.Unix(0, int64(f*float64(time.Second))), true, nil
+}
+
+// hasAudience проверяет, что утверждение aud содержит audience
+func (c Claims) hasAudience(audience string) bool {
+	switch aud := c["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, v := range aud {
+			if s, ok := v.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// header - заголовок токена
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// algorithms - поддерживаемые алгоритмы подписи
+var algorithms = map[string]crypto.Hash{
+	"HS256": crypto.SHA256,
+	"HS384": crypto.SHA384,
+	"HS512": crypto.SHA512,
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+}
+
+// Validator проверяет подпись и утверждения токенов в компактном формате JWS
+type Validator struct {
+	// Keys - ключи проверки подписи
+	Keys *KeySet
+	// Issuer - ожидаемое значение iss (пусто - не проверяется)
+	Issuer string
+	// Audience - значение, которое должно содержаться в aud (пусто - не проверяется)
+	Audience string
+	// ClientID - идентификатор клиента OpenID Connect, который должен
+	// содержаться в aud или совпадать с azp (пусто - не проверяется)
+	ClientID string
+	// Leeway - допустимое расхождение часов при проверке exp и nbf
+	Leeway time.Duration
+}
+
+// Validate проверяет токен и возвращает его утверждения
+func (v *Validator) Validate(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, err
+	}
+	hash, ok := algorithms[h.Alg]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrAlgorithm, h.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if err := v.verify(h, hash, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// verify проверяет подпись ключом, тип которого соответствует алгоритму
+// заголовка: HMAC токен нельзя проверить открытым ключом RSA и наоборот
+func (v *Validator) verify(h header, hash crypto.Hash, signed, signature []byte) error {
+	switch h.Alg[:2] {
+	case "HS":
+		secret := v.Keys.HMAC
+		if len(secret) == 0 {
+			return ErrUnknownKey
+		}
+		mac := hmac.New(hash.New, secret)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return ErrSignature
+		}
+	case "RS":
+		key, err := v.Keys.rsaKey(h.Kid)
+		if err != nil {
+			return err
+		}
+		hasher := hash.New()
+		hasher.Write(signed)
+		if err := rsa.VerifyPKCS1v15(key, hash, hasher.Sum(nil), signature); err != nil {
+			return ErrSignature
+		}
+	}
+	return nil
+}
+
+// checkClaims проверяет сроки действия, издателя и получателя токена
+func (v *Validator) checkClaims(claims Claims) error {
+	now := time.Now()
+	if exp, ok, err := claims.time("exp"); err != nil {
+		return err
+	} else if ok && now.After(exp.Add(v.Leeway)) {
+		return ErrExpired
+	}
+	if nbf, ok, err := claims.time("nbf"); err != nil {
+		return err
+	} else if ok && now.Add(v.Leeway).Before(nbf) {
+		return ErrNotYetValid
+	}
+	if v.Issuer != "" && claims.String("iss") != v.Issuer {
+		return ErrWrongIssuer
+	}
+	if v.Audience != "" && !claims.hasAudience(v.Audience) {
+		return ErrWrongAudience
+	}
+	// Токены доступа Keycloak выдаются для aud "account" и содержат
+	// клиента в azp, токены Auth0 - в aud
+	if v.ClientID != "" && !claims.hasAudience(v.ClientID) && claims.String("azp") != v.ClientID {
+		return ErrWrongAudience
+	}
+	return nil
+}
+
+// decodeSegment декодирует часть токена в формате base64url JSON
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ErrMalformed
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return ErrMalformed
+	}
+	return nil
+}
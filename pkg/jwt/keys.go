@@ -0,0 +1,210 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeySet - ключи проверки подписи токенов
+type KeySet struct {
+	// HMAC - общий секрет для алгоритмов HS256, HS384 и HS512
+	HMAC []byte
+	// RSA - открытый ключ для алгоритмов RS256, RS384 и RS512
+	RSA *rsa.PublicKey
+	// JWKS - набор открытых ключей издателя; используется вместо RSA, если задан
+	JWKS *JWKS
+}
+
+// rsaKey возвращает открытый ключ RSA с идентификатором kid
+func (k *KeySet) rsaKey(kid string) (*rsa.PublicKey, error) {
+	if k.JWKS != nil {
+		return k.JWKS.key(kid)
+	}
+	if k.RSA == nil {
+		return nil, ErrUnknownKey
+	}
+	return k.RSA, nil
+}
+
+// ParseRSAPublicKey разбирает открытый ключ RSA в формате PEM
+// (PUBLIC KEY, RSA PUBLIC KEY или сертификат)
+func ParseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("ключ должен быть в формате PEM")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("ключ не является открытым ключом RSA")
+	}
+	return rsaKey, nil
+}
+
+// minJWKSRefresh - не чаще какого интервала набор ключей перечитывается из-за
+// неизвестного kid, чтобы токены с чужим kid не вызывали лавину запросов
+const minJWKSRefresh = 10 * time.Second
+
+// JWKS - набор открытых ключей издателя токенов (JSON Web Key Set), который
+// загружается по адресу и периодически обновляется
+type JWKS struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+	// issuer - издатель OpenID Connect, по которому адрес набора
+	// определяется при первой загрузке
+	issuer string
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+// NewJWKS создает набор ключей, загружаемый по адресу url. Ключи
+// перечитываются раз в refresh и при появлении токена с неизвестным kid.
+func NewJWKS(url string, refresh time.Duration, client *http.Client) *JWKS {
+	return &JWKS{url: url, refresh: refresh, client: client}
+}
+
+// NewOIDCJWKS создает набор ключей издателя OpenID Connect. Адрес набора
+// (jwks_uri) берется из документа /.well-known/openid-configuration издателя
+// при первой загрузке ключей.
+func NewOIDCJWKS(issuer string, refresh time.Duration, client *http.Client) *JWKS {
+	return &JWKS{issuer: issuer, refresh: refresh, client: client}
+}
+
+// key возвращает ключ kid. Пустой kid допускается, если в наборе один ключ.
+func (j *JWKS) key(kid string) (*rsa.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	stale := time.Since(j.fetched) >= j.refresh
+	_, known := j.keys[kid]
+	if stale || (!known && kid != "" && time.Since(j.fetched) >= minJWKSRefresh) {
+		if err := j.fetch(); err != nil && j.keys == nil {
+			return nil, err
+		}
+	}
+
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, nil
+		}
+	}
+	key, ok := j.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: kid %q", ErrUnknownKey, kid)
+	}
+	return key, nil
+}
+
+// fetch загружает набор ключей. При ошибке остаются ранее загруженные ключи.
+// Вызывается под j.mu.
+func (j *JWKS) fetch() error {
+	// Время запоминается и при ошибке, чтобы недоступный издатель не
+	// опрашивался на каждый запрос
+	j.fetched = time.Now()
+
+	if j.url == "" {
+		url, err := discoverJWKS(j.client, j.issuer)
+		if err != nil {
+			return err
+		}
+		j.url = url
+	}
+
+	resp, err := j.client.Get(j.url)
+	if err != nil {
+		return fmt.Errorf("не удалось загрузить JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("не удалось загрузить JWKS: статус %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("некорректный JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	j.keys = keys
+	return nil
+}
+
+// discoverJWKS загружает документ OpenID Connect Discovery издателя и
+// возвращает адрес его набора ключей
+func discoverJWKS(client *http.Client, issuer string) (string, error) {
+	resp, err := client.Get(strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return "", fmt.Errorf("не удалось загрузить настройки OpenID Connect: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("не удалось загрузить настройки OpenID Connect: статус %d", resp.StatusCode)
+	}
+
+	var doc struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return "", fmt.Errorf("некорректные настройки OpenID Connect: %w", err)
+	}
+	// Документ другого издателя может указать чужие ключи (OpenID Connect Discovery, п. 4.3)
+	if doc.Issuer != issuer {
+		return "", fmt.Errorf("настройки OpenID Connect выданы издателем %q вместо %q", doc.Issuer, issuer)
+	}
+	if doc.JWKSURI == "" {
+		return "", fmt.Errorf("в настройках OpenID Connect не указан jwks_uri")
+	}
+	return doc.JWKSURI, nil
+}
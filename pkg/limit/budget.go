@@ -0,0 +1,57 @@
+package limit
+
+import (
+	"math"
+	"sync"
+)
+
+// budgetWindow - по скольким последним запросам накапливается бюджет повторов
+const budgetWindow = 100
+
+// Budget - бюджет повторов запросов: каждый запрос пополняет бюджет на ratio,
+// каждый повтор расходует единицу. Так при отказе сервиса повторы составляют
+// не больше доли ratio от запросов и не умножают нагрузку на него.
+type Budget struct {
+	ratio float64
+	max   float64
+	// min - повторы, разрешенные независимо от бюджета (nil - не разрешены)
+	min *Rate
+
+	mu     sync.Mutex
+	tokens float64
+}
+
+// NewBudget создает бюджет с долей повторов ratio. minPerSecond повторов в
+// секунду разрешаются, даже если бюджет исчерпан, чтобы при малом трафике
+// повторы оставались возможными.
+func NewBudget(ratio, minPerSecond float64) *Budget {
+	b := &Budget{ratio: ratio, max: math.Max(1, ratio*budgetWindow)}
+	if minPerSecond > 0 {
+		b.min = NewRate(minPerSecond, 0)
+	}
+	return b
+}
+
+// Deposit учитывает исходный запрос
+func (b *Budget) Deposit() {
+	b.mu.Lock()
+	b.tokens = math.Min(b.max, b.tokens+b.ratio)
+	b.mu.Unlock()
+}
+
+// Withdraw расходует бюджет на повтор. Возвращает false, если повтор не разрешен.
+func (b *Budget) Withdraw() bool {
+	b.mu.Lock()
+	if b.tokens >= 1 {
+		b.tokens--
+		b.mu.Unlock()
+		return true
+	}
+	b.mu.Unlock()
+
+	if b.min == nil {
+		return false
+	}
+	ok, _ := b.min.Allow()
+	return ok
+}
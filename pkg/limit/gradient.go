@@ -0,0 +1,182 @@
+package limit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Outcome - результат запроса, выполненного под ограничением
+type Outcome int
+
+const (
+	// Success - запрос выполнен, его время учитывается при расчете лимита
+	Success Outcome = iota
+	// Dropped - backend-сервис не справился (ошибка, таймаут, перегрузка),
+	// лимит сразу снижается
+	Dropped
+	// Ignored - запрос не дает сведений о backend-сервисе (например, клиент
+	// отменил запрос) и не учитывается
+	Ignored
+)
+
+// Options - параметры адаптивного ограничителя
+type Options struct {
+	// InitialLimit - лимит одновременных запросов при запуске
+	InitialLimit int
+	// MinLimit и MaxLimit - границы лимита
+	MinLimit int
+	MaxLimit int
+	// Smoothing - доля нового значения при пересчете лимита (0..1]
+	Smoothing float64
+	// Tolerance - во сколько раз текущая задержка может превышать
+	// долгосрочную, прежде чем лимит начнет снижаться
+	Tolerance float64
+	// LongWindow - количество запросов, по которым усредняется долгосрочная задержка
+	LongWindow int
+}
+
+// backoffRatio - во сколько раз снижается лимит при отказе backend-сервиса
+const backoffRatio = 0.9
+
+// shortWindow - количество запросов, по которым усредняется текущая задержка
+const shortWindow = 10
+
+// Gradient - адаптивный ограничитель одновременных запросов по градиенту
+// задержки (по образцу Gradient2 из Netflix concurrency-limits). Текущая
+// задержка сравнивается с долгосрочной: пока backend-сервис отвечает так же
+// быстро, лимит растет, при росте задержки или отказах - снижается.
+type Gradient struct {
+	opts Options
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	shortRTT float64
+	longRTT  float64
+}
+
+// NewGradient создает ограничитель. Незаданные параметры заменяются значениями по умолчанию.
+func NewGradient(opts Options) *Gradient {
+	if opts.MinLimit <= 0 {
+		opts.MinLimit = 1
+	}
+	if opts.MaxLimit <= 0 {
+		opts.MaxLimit = 200
+	}
+	if opts.MaxLimit < opts.MinLimit {
+		opts.MaxLimit = opts.MinLimit
+	}
+	if opts.InitialLimit <= 0 {
+		opts.InitialLimit = 20
+	}
+	if opts.Smoothing <= 0 || opts.Smoothing > 1 {
+		opts.Smoothing = 0.2
+	}
+	if opts.Tolerance < 1 {
+		opts.Tolerance = 1.5
+	}
+	if opts.LongWindow <= 0 {
+		opts.LongWindow = 600
+	}
+
+	g := &Gradient{opts: opts}
+	g.limit = g.clamp(float64(opts.InitialLimit))
+	return g
+}
+
+// Acquire занимает место для запроса. Если лимит исчерпан, возвращает false.
+// После завершения запроса нужно вызвать release с его результатом.
+func (g *Gradient) Acquire() (release func(Outcome), ok bool) {
+	return g.AcquireShare(1)
+}
+
+// AcquireShare занимает место для запроса, которому доступна только доля share
+// лимита (0..1]: запросы с низким приоритетом получают отказ раньше остальных,
+// оставляя часть лимита запросам с высоким приоритетом. Лимит для любой доли
+// не меньше одного запроса.
+func (g *Gradient) AcquireShare(share float64) (release func(Outcome), ok bool) {
+	g.mu.Lock()
+	if g.inflight >= int(math.Max(1, g.limit*share)) {
+		g.mu.Unlock()
+		return nil, false
+	}
+	g.inflight++
+	inflight := g.inflight
+	g.mu.Unlock()
+
+	start := time.Now()
+	var once sync.Once
+	return func(outcome Outcome) {
+		once.Do(func() {
+			g.release(time.Since(start), inflight, outcome)
+		})
+	}, true
+}
+
+// Limit возвращает текущий лимит
+func (g *Gradient) Limit() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return int(g.limit)
+}
+
+// Inflight возвращает количество выполняющихся запросов
+func (g *Gradient) Inflight() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.inflight
+}
+
+// release освобождает место и пересчитывает лимит
+func (g *Gradient) release(rtt time.Duration, inflight int, outcome Outcome) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.inflight--
+
+	switch outcome {
+	case Ignored:
+		return
+	case Dropped:
+		g.limit = g.clamp(g.limit * backoffRatio)
+		return
+	}
+
+	sample := float64(rtt)
+	if g.longRTT == 0 {
+		g.shortRTT = sample
+		g.longRTT = sample
+		return
+	}
+	g.shortRTT = ewma(g.shortRTT, sample, shortWindow)
+	g.longRTT = ewma(g.longRTT, sample, g.opts.LongWindow)
+
+	// После периода деградации долгосрочная задержка остается завышенной;
+	// ускоряем ее снижение, чтобы новая деградация не маскировалась старым средним
+	if g.longRTT/g.shortRTT > 2 {
+		g.longRTT *= 0.95
+	}
+
+	// Сервис не нагружен до текущего лимита - данных для его роста нет
+	if float64(inflight) < g.limit/2 {
+		return
+	}
+
+	gradient := math.Max(0.5, math.Min(1, g.opts.Tolerance*g.longRTT/g.shortRTT))
+	// Запас на очередь позволяет лимиту расти, пока задержка не увеличивается
+	queue := math.Sqrt(g.limit)
+	newLimit := g.limit*gradient + queue
+	g.limit = g.clamp(g.limit*(1-g.opts.Smoothing) + newLimit*g.opts.Smoothing)
+}
+
+// clamp ограничивает лимит границами из настроек
+func (g *Gradient) clamp(limit float64) float64 {
+	return math.Max(float64(g.opts.MinLimit), math.Min(float64(g.opts.MaxLimit), limit))
+}
+
+// ewma обновляет экспоненциальное скользящее среднее по окну из window значений
+func ewma(avg, sample float64, window int) float64 {
+	factor := 2 / float64(window+1)
+	return avg*(1-factor) + sample*factor
+}
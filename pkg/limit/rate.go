@@ -0,0 +1,80 @@
+package limit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Rate - ограничитель частоты запросов по алгоритму token bucket: корзина
+// вмещает burst жетонов и пополняется со скоростью perSecond жетонов в секунду,
+// каждый запрос забирает один жетон
+type Rate struct {
+	perSecond float64
+	burst     float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewRate создает ограничитель с полной корзиной. burst <= 0 - частота за одну секунду.
+func NewRate(perSecond float64, burst int) *Rate {
+	b := float64(burst)
+	if b <= 0 {
+		b = math.Max(1, math.Ceil(perSecond))
+	}
+	return &Rate{perSecond: perSecond, burst: b, tokens: b, last: time.Now()}
+}
+
+// Burst возвращает вместимость корзины
+func (r *Rate) Burst() int {
+	return int(r.burst)
+}
+
+// Allow забирает жетон для запроса. Если корзина пуста, возвращает false
+// и время, через которое появится следующий жетон.
+func (r *Rate) Allow() (bool, time.Duration) {
+	d := r.Take()
+	return d.Allowed, d.RetryAfter
+}
+
+// Decision - результат проверки запроса ограничителем частоты
+type Decision struct {
+	// Allowed - запрос разрешен, жетон забран
+	Allowed bool
+	// Limit - вместимость корзины
+	Limit int
+	// Remaining - сколько жетонов осталось в корзине
+	Remaining int
+	// RetryAfter - через сколько появится следующий жетон, если запрос не разрешен
+	RetryAfter time.Duration
+	// Reset - через сколько корзина заполнится полностью
+	Reset time.Duration
+}
+
+// Take забирает жетон для запроса и возвращает состояние корзины
+func (r *Rate) Take() Decision {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.tokens = math.Min(r.burst, r.tokens+now.Sub(r.last).Seconds()*r.perSecond)
+	r.last = now
+
+	d := Decision{Limit: int(r.burst)}
+	if r.tokens >= 1 {
+		r.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = r.wait(1 - r.tokens)
+	}
+	d.Remaining = int(r.tokens)
+	d.Reset = r.wait(r.burst - r.tokens)
+	return d
+}
+
+// wait возвращает время, за которое в корзине появится tokens жетонов
+func (r *Rate) wait(tokens float64) time.Duration {
+	return time.Duration(tokens / r.perSecond * float64(time.Second))
+}
@@ -0,0 +1,103 @@
+package limit
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript атомарно забирает жетон из корзины, хранящейся в хеше KEYS[1]
+// (поля tokens и last). ARGV: perSecond, burst, текущее время (мс).
+// Возвращает {разрешен (0/1), остаток жетонов}; остаток - строкой, так как
+// Redis отбрасывает дробную часть чисел, возвращаемых из Lua.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(state[1])
+local last = tonumber(state[2])
+if tokens == nil or last == nil then
+	tokens = burst
+	last = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - last) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'last', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisRates - корзины token bucket в Redis, общие для нескольких
+// экземпляров шлюза. Корзина удаляется, когда полностью заполнится.
+type RedisRates struct {
+	client *redis.Client
+	prefix string
+}
+
+// RedisOptions - параметры подключения к Redis
+type RedisOptions struct {
+	Addr     string
+	Password string
+	DB       int
+	// Prefix - префикс ключей корзин
+	Prefix  string
+	Timeout time.Duration
+}
+
+// NewRedisRates создает корзины в Redis
+func NewRedisRates(opts RedisOptions) *RedisRates {
+	client := redis.NewClient(&redis.Options{
+		Addr:         opts.Addr,
+		Password:     opts.Password,
+		DB:           opts.DB,
+		DialTimeout:  opts.Timeout,
+		ReadTimeout:  opts.Timeout,
+		WriteTimeout: opts.Timeout,
+		// Отказ Redis должен быстро переводить ограничение на локальные
+		// корзины, а не задерживать запросы повторами
+		MaxRetries: -1,
+	})
+	return &RedisRates{client: client, prefix: opts.Prefix + "ratelimit:"}
+}
+
+// Take забирает жетон из корзины key с частотой perSecond и вместимостью
+// burst (burst <= 0 - частота за одну секунду) и возвращает ее состояние
+func (r *RedisRates) Take(ctx context.Context, key string, perSecond float64, burst int) (Decision, error) {
+	b := float64(burst)
+	if b <= 0 {
+		b = math.Max(1, math.Ceil(perSecond))
+	}
+	values, err := takeScript.Run(ctx, r.client, []string{r.prefix + key},
+		strconv.FormatFloat(perSecond, 'f', -1, 64), strconv.FormatFloat(b, 'f', -1, 64), time.Now().UnixMilli()).Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+	allowed, _ := values[0].(int64)
+	remaining, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(remaining, 64)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	wait := func(n float64) time.Duration {
+		return time.Duration(n / perSecond * float64(time.Second))
+	}
+	d := Decision{Allowed: allowed == 1, Limit: int(b), Remaining: int(tokens), Reset: wait(b - tokens)}
+	if !d.Allowed {
+		d.RetryAfter = wait(1 - tokens)
+	}
+	return d, nil
+}
+
+// Close закрывает подключение к Redis
+func (r *RedisRates) Close() error {
+	return r.client.Close()
+}
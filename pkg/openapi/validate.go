@@ -0,0 +1,178 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// FieldError - ошибка проверки значения поля по схеме
+type FieldError struct {
+	// Field - путь к полю: имя параметра или поле тела, например items[0].path
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Validate проверяет значение, разобранное из JSON с json.Decoder.UseNumber,
+// по схеме. Поддерживается подмножество JSON Schema, которое используется в
+// описании API: type, format, properties, required, items, oneOf, enum,
+// minimum, maximum, minLength, maxLength и pattern. Ссылки $ref не
+// разрешаются, такие значения считаются корректными.
+func (s *Schema) Validate(field string, value interface{}) []FieldError {
+	if s == nil || s.Ref != "" {
+		return nil
+	}
+
+	if len(s.OneOf) > 0 {
+		for _, option := range s.OneOf {
+			if len(option.Validate(field, value)) == 0 {
+				return nil
+			}
+		}
+		return []FieldError{{Field: field, Message: "значение не соответствует ни одному из допустимых вариантов"}}
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return typeError(field, "объект")
+		}
+		var errs []FieldError
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				errs = append(errs, FieldError{Field: joinField(field, name), Message: "обязательное поле"})
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := s.Properties[name]; ok {
+				errs = append(errs, property.Validate(joinField(field, name), object[name])...)
+			} else if s.AdditionalProperties != nil {
+				errs = append(errs, s.AdditionalProperties.Validate(joinField(field, name), object[name])...)
+			}
+		}
+		return errs
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return typeError(field, "массив")
+		}
+		var errs []FieldError
+		for i, item := range items {
+			errs = append(errs, s.Items.Validate(fmt.Sprintf("%s[%d]", field, i), item)...)
+		}
+		return errs
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return typeError(field, "строка")
+		}
+		return s.validateString(field, str)
+	case "integer", "number":
+		// Для значений других типов validateNumber сообщит о несоответствии типа
+		number, _ := value.(json.Number)
+		return s.validateNumber(field, number.String())
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return typeError(field, "логическое значение")
+		}
+	}
+	return s.validateEnum(field, value)
+}
+
+// ValidateParam проверяет по схеме строковое значение параметра запроса или пути
+func (s *Schema) ValidateParam(field, raw string) []FieldError {
+	if s == nil || s.Ref != "" {
+		return nil
+	}
+	switch s.Type {
+	case "integer", "number":
+		return s.validateNumber(field, raw)
+	case "boolean":
+		if _, err := strconv.ParseBool(raw); err != nil {
+			return typeError(field, "логическое значение")
+		}
+		return nil
+	}
+	return s.validateString(field, raw)
+}
+
+// validateString проверяет длину, шаблон и допустимые значения строки
+func (s *Schema) validateString(field, value string) []FieldError {
+	length := utf8.RuneCountInString(value)
+	switch {
+	case s.MinLength != nil && length < *s.MinLength:
+		if *s.MinLength == 1 {
+			return []FieldError{{Field: field, Message: "значение не может быть пустым"}}
+		}
+		return []FieldError{{Field: field, Message: fmt.Sprintf("длина должна быть не меньше %d символов", *s.MinLength)}}
+	case s.MaxLength != nil && length > *s.MaxLength:
+		return []FieldError{{Field: field, Message: fmt.Sprintf("длина должна быть не больше %d символов", *s.MaxLength)}}
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err == nil && !re.MatchString(value) {
+			return []FieldError{{Field: field, Message: fmt.Sprintf("значение не соответствует шаблону %s", s.Pattern)}}
+		}
+	}
+	return s.validateEnum(field, value)
+}
+
+// validateNumber проверяет, что строка - число нужного типа в допустимых границах
+func (s *Schema) validateNumber(field, raw string) []FieldError {
+	var value float64
+	if s.Type == "integer" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return typeError(field, "целое число")
+		}
+		value = float64(n)
+	} else {
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return typeError(field, "число")
+		}
+		value = n
+	}
+	switch {
+	case s.Minimum != nil && value < *s.Minimum:
+		return []FieldError{{Field: field, Message: fmt.Sprintf("значение должно быть не меньше %v", *s.Minimum)}}
+	case s.Maximum != nil && value > *s.Maximum:
+		return []FieldError{{Field: field, Message: fmt.Sprintf("значение должно быть не больше %v", *s.Maximum)}}
+	}
+	return nil
+}
+
+// validateEnum проверяет, что значение входит в enum схемы
+func (s *Schema) validateEnum(field string, value interface{}) []FieldError {
+	if len(s.Enum) == 0 {
+		return nil
+	}
+	for _, allowed := range s.Enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return nil
+		}
+	}
+	return []FieldError{{Field: field, Message: fmt.Sprintf("допустимые значения: %v", s.Enum)}}
+}
+
+// typeError возвращает ошибку несоответствия типа значения
+func typeError(field, expected string) []FieldError {
+	return []FieldError{{Field: field, Message: "ожидается " + expected}}
+}
+
+// joinField возвращает путь к полю name объекта field
+func joinField(field, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
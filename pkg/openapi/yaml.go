@@ -0,0 +1,39 @@
+package openapi
+
+import (
+	"encoding/json"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EncodeYAML записывает документ в формате YAML. Порядок полей совпадает с JSON.
+func EncodeYAML(w io.Writer, doc *Document) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	// JSON - подмножество YAML: узлы сохраняют порядок ключей,
+	// остается только перевести их из потокового стиля в блочный
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	resetStyle(&node)
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// resetStyle сбрасывает стиль узлов, чтобы они выводились в стиле YAML по умолчанию
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
@@ -0,0 +1,162 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// messageExt - расширение файлов сообщений
+const messageExt = ".json"
+
+// Dir - очередь в каталоге на диске: каждое сообщение хранится в отдельном
+// файле. Файл записывается во временный и переименовывается, поэтому после
+// сбоя в каталоге не остается частично записанных сообщений. Каталог
+// используется одним экземпляром шлюза.
+type Dir struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewDir создает очередь в каталоге path, создавая его при необходимости
+func NewDir(path string) (*Dir, error) {
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, err
+	}
+	return &Dir{path: path}, nil
+}
+
+// Put сохраняет сообщение в файл
+func (d *Dir) Put(ctx context.Context, msg Message) error {
+	if !validID(msg.ID) {
+		return fmt.Errorf("некорректный ID сообщения: %q", msg.ID)
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tmp, err := os.CreateTemp(d.path, "."+msg.ID+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), d.file(msg.ID)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// Due читает сообщения каталога и возвращает те, время попытки которых наступило
+func (d *Dir) Due(ctx context.Context, now time.Time, limit int) ([]Message, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	names, err := d.messageFiles()
+	if err != nil {
+		return nil, err
+	}
+	var due []Message
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(d.path, name))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, fmt.Errorf("некорректное сообщение %s: %w", name, err)
+		}
+		if !msg.NextAttempt.After(now) {
+			due = append(due, msg)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttempt.Before(due[j].NextAttempt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+// Delete удаляет файл сообщения
+func (d *Dir) Delete(ctx context.Context, id string) error {
+	if !validID(id) {
+		return fmt.Errorf("некорректный ID сообщения: %q", id)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := os.Remove(d.file(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Len возвращает число файлов сообщений в каталоге
+func (d *Dir) Len(ctx context.Context) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	names, err := d.messageFiles()
+	return len(names), err
+}
+
+// Close ничего не делает: файлы закрываются после каждой операции
+func (d *Dir) Close() error {
+	return nil
+}
+
+// messageFiles возвращает имена файлов сообщений без временных файлов
+func (d *Dir) messageFiles() ([]string, error) {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasSuffix(name, messageExt) && !strings.HasPrefix(name, ".") {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// file возвращает путь к файлу сообщения
+func (d *Dir) file(id string) string {
+	return filepath.Join(d.path, id+messageExt)
+}
+
+// validID проверяет, что ID сообщения можно использовать как имя файла
+func validID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, `/\.`)
+}
@@ -0,0 +1,46 @@
+// Package outbox хранит сообщения, которые не удалось доставить сразу, вместе
+// с расписанием повторных попыток доставки
+package outbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Message - сообщение очереди
+type Message struct {
+	ID string `json:"id"`
+	// Payload - содержимое сообщения, которое передается при доставке
+	Payload json.RawMessage `json:"payload"`
+	// Attempts - число неудачных попыток доставки
+	Attempts int `json:"attempts"`
+	// NextAttempt - время следующей попытки доставки
+	NextAttempt time.Time `json:"next_attempt"`
+	Created     time.Time `json:"created"`
+}
+
+// Store - хранилище очереди сообщений
+type Store interface {
+	// Put сохраняет сообщение; сообщение с тем же ID заменяется
+	Put(ctx context.Context, msg Message) error
+	// Due возвращает не больше limit сообщений, время попытки доставки
+	// которых наступило к моменту now, в порядке времени попытки
+	Due(ctx context.Context, now time.Time, limit int) ([]Message, error)
+	// Delete удаляет сообщение
+	Delete(ctx context.Context, id string) error
+	// Len возвращает число сообщений в очереди
+	Len(ctx context.Context) (int, error)
+	Close() error
+}
+
+// NewID возвращает случайный ID сообщения
+func NewID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
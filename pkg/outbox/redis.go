@@ -0,0 +1,127 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// claimTimeout - на сколько Due откладывает выданные сообщения, чтобы их не
+// получили другие экземпляры шлюза, пока идет доставка
+const claimTimeout = time.Minute
+
+// claimScript атомарно выбирает сообщения, время попытки которых наступило,
+// и откладывает их на claimTimeout. KEYS[1] - расписание, KEYS[2] - сообщения;
+// ARGV: текущее время, время окончания захвата (мс), limit.
+var claimScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[3])
+if #ids == 0 then
+	return {}
+end
+for _, id in ipairs(ids) do
+	redis.call('ZADD', KEYS[1], ARGV[2], id)
+end
+return redis.call('HMGET', KEYS[2], unpack(ids))
+`)
+
+// Redis - очередь в Redis, общая для нескольких экземпляров шлюза. Сообщения
+// хранятся в хеше, расписание попыток - в упорядоченном множестве.
+type Redis struct {
+	client   *redis.Client
+	schedule string
+	messages string
+}
+
+// RedisOptions - параметры подключения к Redis
+type RedisOptions struct {
+	Addr     string
+	Password string
+	DB       int
+	// Prefix - префикс ключей очереди
+	Prefix  string
+	Timeout time.Duration
+}
+
+// NewRedis создает очередь в Redis
+func NewRedis(opts RedisOptions) *Redis {
+	client := redis.NewClient(&redis.Options{
+		Addr:         opts.Addr,
+		Password:     opts.Password,
+		DB:           opts.DB,
+		DialTimeout:  opts.Timeout,
+		ReadTimeout:  opts.Timeout,
+		WriteTimeout: opts.Timeout,
+	})
+	return &Redis{
+		client:   client,
+		schedule: opts.Prefix + "outbox:schedule",
+		messages: opts.Prefix + "outbox:messages",
+	}
+}
+
+// Put сохраняет сообщение и время следующей попытки
+func (r *Redis) Put(ctx context.Context, msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, r.messages, msg.ID, data)
+		pipe.ZAdd(ctx, r.schedule, redis.Z{Score: float64(msg.NextAttempt.UnixMilli()), Member: msg.ID})
+		return nil
+	})
+	return err
+}
+
+// Due выбирает сообщения, время попытки которых наступило. Выбранные
+// сообщения откладываются на claimTimeout: если экземпляр шлюза не успеет их
+// доставить или перепланировать, их получит другой экземпляр.
+func (r *Redis) Due(ctx context.Context, now time.Time, limit int) ([]Message, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	values, err := claimScript.Run(ctx, r.client, []string{r.schedule, r.messages},
+		now.UnixMilli(), now.Add(claimTimeout).UnixMilli(), limit).Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	due := make([]Message, 0, len(values))
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			// Расписание без сообщения: запись удалена не полностью
+			continue
+		}
+		var msg Message
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			return nil, fmt.Errorf("некорректное сообщение в очереди: %w", err)
+		}
+		due = append(due, msg)
+	}
+	return due, nil
+}
+
+// Delete удаляет сообщение и его расписание
+func (r *Redis) Delete(ctx context.Context, id string) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, r.schedule, id)
+		pipe.HDel(ctx, r.messages, id)
+		return nil
+	})
+	return err
+}
+
+// Len возвращает число сообщений в очереди
+func (r *Redis) Len(ctx context.Context) (int, error) {
+	n, err := r.client.HLen(ctx, r.messages).Result()
+	return int(n), err
+}
+
+// Close закрывает подключение к Redis
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
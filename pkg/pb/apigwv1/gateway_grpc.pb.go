@@ -0,0 +1,291 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: apigw/v1/gateway.proto
+
+package apigwv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	NewsGateway_ListNews_FullMethodName     = "/apigw.v1.NewsGateway/ListNews"
+	NewsGateway_ListFullNews_FullMethodName = "/apigw.v1.NewsGateway/ListFullNews"
+	NewsGateway_GetNews_FullMethodName      = "/apigw.v1.NewsGateway/GetNews"
+	NewsGateway_ListComments_FullMethodName = "/apigw.v1.NewsGateway/ListComments"
+	NewsGateway_AddComment_FullMethodName   = "/apigw.v1.NewsGateway/AddComment"
+)
+
+// NewsGatewayClient is the client API for NewsGateway service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// NewsGateway предоставляет API новостей и комментариев для внутренних сервисов.
+// Вызовы обслуживаются той же цепочкой обработчиков, что и HTTP API,
+// поэтому кэширование, трассировка и проверки доступа у них общие.
+type NewsGatewayClient interface {
+	// ListNews возвращает страницу новостей в кратком формате
+	ListNews(ctx context.Context, in *ListNewsRequest, opts ...grpc.CallOption) (*ListNewsResponse, error)
+	// ListFullNews возвращает страницу новостей с описанием
+	ListFullNews(ctx context.Context, in *ListNewsRequest, opts ...grpc.CallOption) (*ListNewsResponse, error)
+	// GetNews возвращает новость по ID, при необходимости вместе с комментариями
+	GetNews(ctx context.Context, in *GetNewsRequest, opts ...grpc.CallOption) (*GetNewsResponse, error)
+	// ListComments возвращает комментарии к новости
+	ListComments(ctx context.Context, in *ListCommentsRequest, opts ...grpc.CallOption) (*ListCommentsResponse, error)
+	// AddComment добавляет комментарий к новости
+	AddComment(ctx context.Context, in *AddCommentRequest, opts ...grpc.CallOption) (*AddCommentResponse, error)
+}
+
+type newsGatewayClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNewsGatewayClient(cc grpc.ClientConnInterface) NewsGatewayClient {
+	return &newsGatewayClient{cc}
+}
+
+func (c *newsGatewayClient) ListNews(ctx context.Context, in *ListNewsRequest, opts ...grpc.CallOption) (*ListNewsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListNewsResponse)
+	err := c.cc.Invoke(ctx, NewsGateway_ListNews_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *newsGatewayClient) ListFullNews(ctx context.Context, in *ListNewsRequest, opts ...grpc.CallOption) (*ListNewsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListNewsResponse)
+	err := c.cc.Invoke(ctx, NewsGateway_ListFullNews_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *newsGatewayClient) GetNews(ctx context.Context, in *GetNewsRequest, opts ...grpc.CallOption) (*GetNewsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetNewsResponse)
+	err := c.cc.Invoke(ctx, NewsGateway_GetNews_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *newsGatewayClient) ListComments(ctx context.Context, in *ListCommentsRequest, opts ...grpc.CallOption) (*ListCommentsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListCommentsResponse)
+	err := c.cc.Invoke(ctx, NewsGateway_ListComments_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *newsGatewayClient) AddComment(ctx context.Context, in *AddCommentRequest, opts ...grpc.CallOption) (*AddCommentResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AddCommentResponse)
+	err := c.cc.Invoke(ctx, NewsGateway_AddComment_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// NewsGatewayServer is the server API for NewsGateway service.
+// All implementations must embed UnimplementedNewsGatewayServer
+// for forward compatibility.
+//
+// NewsGateway предоставляет API новостей и комментариев для внутренних сервисов.
+// Вызовы обслуживаются той же цепочкой обработчиков, что и HTTP API,
+// поэтому кэширование, трассировка и проверки доступа у них общие.
+type NewsGatewayServer interface {
+	// ListNews возвращает страницу новостей в кратком формате
+	ListNews(context.Context, *ListNewsRequest) (*ListNewsResponse, error)
+	// ListFullNews возвращает страницу новостей с описанием
+	ListFullNews(context.Context, *ListNewsRequest) (*ListNewsResponse, error)
+	// GetNews возвращает новость по ID, при необходимости вместе с комментариями
+	GetNews(context.Context, *GetNewsRequest) (*GetNewsResponse, error)
+	// ListComments возвращает комментарии к новости
+	ListComments(context.Context, *ListCommentsRequest) (*ListCommentsResponse, error)
+	// AddComment добавляет комментарий к новости
+	AddComment(context.Context, *AddCommentRequest) (*AddCommentResponse, error)
+	mustEmbedUnimplementedNewsGatewayServer()
+}
+
+// UnimplementedNewsGatewayServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedNewsGatewayServer struct{}
+
+func (UnimplementedNewsGatewayServer) ListNews(context.Context, *ListNewsRequest) (*ListNewsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListNews not implemented")
+}
+func (UnimplementedNewsGatewayServer) ListFullNews(context.Context, *ListNewsRequest) (*ListNewsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFullNews not implemented")
+}
+func (UnimplementedNewsGatewayServer) GetNews(context.Context, *GetNewsRequest) (*GetNewsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetNews not implemented")
+}
+func (UnimplementedNewsGatewayServer) ListComments(context.Context, *ListCommentsRequest) (*ListCommentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListComments not implemented")
+}
+func (UnimplementedNewsGatewayServer) AddComment(context.Context, *AddCommentRequest) (*AddCommentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddComment not implemented")
+}
+func (UnimplementedNewsGatewayServer) mustEmbedUnimplementedNewsGatewayServer() {}
+func (UnimplementedNewsGatewayServer) testEmbeddedByValue()                     {}
+
+// UnsafeNewsGatewayServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NewsGatewayServer will
+// result in compilation errors.
+type UnsafeNewsGatewayServer interface {
+	mustEmbedUnimplementedNewsGatewayServer()
+}
+
+func RegisterNewsGatewayServer(s grpc.ServiceRegistrar, srv NewsGatewayServer) {
+	// If the following call pancis, it indicates UnimplementedNewsGatewayServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&NewsGateway_ServiceDesc, srv)
+}
+
+func _NewsGateway_ListNews_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListNewsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NewsGatewayServer).ListNews(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NewsGateway_ListNews_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NewsGatewayServer).ListNews(ctx, req.(*ListNewsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NewsGateway_ListFullNews_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListNewsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NewsGatewayServer).ListFullNews(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NewsGateway_ListFullNews_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NewsGatewayServer).ListFullNews(ctx, req.(*ListNewsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NewsGateway_GetNews_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetNewsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NewsGatewayServer).GetNews(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NewsGateway_GetNews_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NewsGatewayServer).GetNews(ctx, req.(*GetNewsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NewsGateway_ListComments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCommentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NewsGatewayServer).ListComments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NewsGateway_ListComments_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NewsGatewayServer).ListComments(ctx, req.(*ListCommentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NewsGateway_AddComment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddCommentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NewsGatewayServer).AddComment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NewsGateway_AddComment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NewsGatewayServer).AddComment(ctx, req.(*AddCommentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// NewsGateway_ServiceDesc is the grpc.ServiceDesc for NewsGateway service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var NewsGateway_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "apigw.v1.NewsGateway",
+	HandlerType: (*NewsGatewayServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListNews",
+			Handler:    _NewsGateway_ListNews_Handler,
+		},
+		{
+			MethodName: "ListFullNews",
+			Handler:    _NewsGateway_ListFullNews_Handler,
+		},
+		{
+			MethodName: "GetNews",
+			Handler:    _NewsGateway_GetNews_Handler,
+		},
+		{
+			MethodName: "ListComments",
+			Handler:    _NewsGateway_ListComments_Handler,
+		},
+		{
+			MethodName: "AddComment",
+			Handler:    _NewsGateway_AddComment_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "apigw/v1/gateway.proto",
+}
@@ -0,0 +1,306 @@
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry - запрос из журнала запросов шлюза
+type Entry struct {
+	Time   time.Time
+	Method string
+	// Target - путь с параметрами
+	Target string
+	// Status - код ответа при исходном запросе
+	Status int
+}
+
+// logTimeFormat - формат времени начала запроса в журнале запросов шлюза
+const logTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// combinedTimeFormat - формат времени в журнале запросов формата Apache combined
+const combinedTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// combinedLine соответствует строке журнала запросов формата Apache combined:
+// IP - пользователь [время] "МЕТОД путь протокол" код ...
+var combinedLine = regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] "(\S+) (\S+) [^"]*" (\d{3}) `)
+
+// accessLine соответствует строке журнала запросов старых версий:
+// [время] Request: МЕТОД путь | IP: ... | Status: код | ...
+var accessLine = regexp.MustCompile(`\[([^\]]+)\] Request: (\S+) (\S+) \| IP: .* \| Status: (\d+) \|`)
+
+// ParseLine разбирает строку журнала запросов в формате text (ключ=значение),
+// json или combined, а также в формате старых версий. Возвращает false для строк
+// другого формата и записей журнала, не относящихся к запросам.
+func ParseLine(line string) (Entry, bool) {
+	if m := accessLine.FindStringSubmatch(line); m != nil {
+		status, _ := strconv.Atoi(m[4])
+		return newEntry(m[1], m[2], m[3], status)
+	}
+	if m := combinedLine.FindStringSubmatch(line); m != nil {
+		status, _ := strconv.Atoi(m[4])
+		return newEntry(m[1], m[2], m[3], status)
+	}
+
+	if strings.HasPrefix(line, "{") {
+		var record struct {
+			Start  string `json:"start"`
+			Method string `json:"method"`
+			Target string `json:"target"`
+			Status int    `json:"status"`
+		}
+		if json.Unmarshal([]byte(line), &record) != nil {
+			return Entry{}, false
+		}
+		return newEntry(record.Start, record.Method, record.Target, record.Status)
+	}
+
+	fields := textFields(line)
+	status, err := strconv.Atoi(fields["status"])
+	if err != nil {
+		return Entry{}, false
+	}
+	return newEntry(fields["start"], fields["method"], fields["target"], status)
+}
+
+// newEntry создает запись по полям строки журнала запросов
+func newEntry(start, method, target string, status int) (Entry, bool) {
+	if method == "" || !strings.HasPrefix(target, "/") {
+		return Entry{}, false
+	}
+	t, err := time.Parse(logTimeFormat, start)
+	if err != nil {
+		// Журналы старых версий и формата combined содержат время с точностью до секунды
+		if t, err = time.Parse(time.RFC3339, start); err != nil {
+			if t, err = time.Parse(combinedTimeFormat, start); err != nil {
+				return Entry{}, false
+			}
+		}
+	}
+	return Entry{Time: t, Method: method, Target: target, Status: status}, true
+}
+
+// textFields разбирает строку журнала в формате ключ=значение. Значения
+// с пробелами и специальными символами записаны в кавычках, как в Go.
+func textFields(line string) map[string]string {
+	fields := make(map[string]string)
+	for line != "" {
+		line = strings.TrimLeft(line, " ")
+		key, rest, ok := strings.Cut(line, "=")
+		if !ok || key == "" || strings.Contains(key, " ") {
+			break
+		}
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			// Ищем закрывающую кавычку, пропуская экранированные символы
+			end := 1
+			for end < len(rest) && rest[end] != '"' {
+				if rest[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(rest) {
+				break
+			}
+			unquoted, err := strconv.Unquote(rest[:end+1])
+			if err != nil {
+				break
+			}
+			value, line = unquoted, rest[end+1:]
+		} else {
+			value, line, _ = strings.Cut(rest, " ")
+		}
+		fields[key] = value
+	}
+	return fields
+}
+
+// Read читает GET запросы из журнала запросов в порядке времени. Параметр
+// request_id удаляется, чтобы шлюз назначил воспроизведенным запросам новые.
+func Read(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		entry, ok := ParseLine(scanner.Text())
+		if !ok || entry.Method != http.MethodGet {
+			continue
+		}
+		entry.Target = stripRequestID(entry.Target)
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при чтении журнала: %w", err)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	return entries, nil
+}
+
+// stripRequestID удаляет параметр request_id из пути
+func stripRequestID(target string) string {
+	path, rawQuery, ok := strings.Cut(target, "?")
+	if !ok {
+		return target
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil || !query.Has("request_id") {
+		return target
+	}
+	query.Del("request_id")
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
+
+// Options - параметры воспроизведения
+type Options struct {
+	// BaseURL - адрес проверяемого шлюза
+	BaseURL string
+	// Speed - ускорение относительно исходного темпа: 1 - исходный темп,
+	// 2 - вдвое быстрее, 0 - без пауз между запросами
+	Speed float64
+	// Concurrency - максимальное количество одновременных запросов
+	Concurrency int
+	// Timeout - таймаут одного запроса
+	Timeout time.Duration
+	// Header - заголовки, добавляемые к каждому запросу
+	Header http.Header
+}
+
+// Mismatch - запрос, статус ответа которого отличается от исходного
+type Mismatch struct {
+	Target   string `json:"target"`
+	Original int    `json:"original"`
+	// Replayed - статус ответа при воспроизведении, 0 - ответ не получен
+	Replayed int `json:"replayed"`
+}
+
+// Result - результаты воспроизведения
+type Result struct {
+	Duration time.Duration `json:"duration"`
+	Requests int           `json:"requests"`
+	// Errors - запросы, на которые не получен ответ (сетевые ошибки, таймауты)
+	Errors   int         `json:"errors"`
+	Statuses map[int]int `json:"statuses"`
+	// Mismatches - количество запросов со статусом, отличным от исходного
+	Mismatches int `json:"mismatches"`
+	// Examples - первые расхождения статусов
+	Examples []Mismatch `json:"examples"`
+}
+
+// maxExamples - количество сохраняемых примеров расхождений
+const maxExamples = 20
+
+// Run воспроизводит запросы на проверяемом шлюзе, сохраняя интервалы между
+// ними с учетом ускорения, и сравнивает статусы ответов с исходными
+func Run(ctx context.Context, entries []Entry, opts Options) (*Result, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("в журнале нет GET запросов")
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	baseURL := strings.TrimSuffix(opts.BaseURL, "/")
+
+	client := &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &http.Transport{
+			MaxIdleConnsPerHost: opts.Concurrency,
+		},
+	}
+
+	result := &Result{Statuses: map[int]int{}}
+	var mu sync.Mutex
+	record := func(entry Entry, status int) {
+		mu.Lock()
+		defer mu.Unlock()
+		result.Requests++
+		if status == 0 {
+			result.Errors++
+		} else {
+			result.Statuses[status]++
+		}
+		if status != entry.Status {
+			result.Mismatches++
+			if len(result.Examples) < maxExamples {
+				result.Examples = append(result.Examples, Mismatch{Target: entry.Target, Original: entry.Status, Replayed: status})
+			}
+		}
+	}
+
+	slots := make(chan struct{}, opts.Concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	first := entries[0].Time
+
+loop:
+	for _, entry := range entries {
+		if opts.Speed > 0 {
+			due := start.Add(time.Duration(float64(entry.Time.Sub(first)) / opts.Speed))
+			if wait := time.Until(due); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					break loop
+				}
+			}
+		}
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			break loop
+		}
+		wg.Add(1)
+		go func(entry Entry) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			status := send(ctx, client, baseURL, opts.Header, entry)
+			// Запросы, прерванные остановкой воспроизведения, не учитываются
+			if status == 0 && ctx.Err() != nil {
+				return
+			}
+			record(entry, status)
+		}(entry)
+	}
+
+	wg.Wait()
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+// send выполняет запрос и возвращает статус ответа или 0, если ответ не получен
+func send(ctx context.Context, client *http.Client, baseURL string, header http.Header, entry Entry) int {
+	req, err := http.NewRequestWithContext(ctx, entry.Method, baseURL+entry.Target, nil)
+	if err != nil {
+		return 0
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return 0
+	}
+	return resp.StatusCode
+}
@@ -0,0 +1,122 @@
+package search
+
+import (
+	"sort"
+	"strings"
+)
+
+// trigramSize - длина n-граммы, по которой строится индекс
+const trigramSize = 3
+
+// Index - инвертированный индекс по триграммам для поиска подстроки без учета
+// регистра. Документы задаются строками и идентифицируются позицией в списке,
+// переданном в NewIndex. Индекс неизменяем и безопасен для одновременного чтения.
+type Index struct {
+	texts []string
+	// postings - позиции документов для каждой триграммы, по возрастанию
+	postings map[string][]int
+}
+
+// NewIndex строит индекс по текстам документов
+func NewIndex(texts []string) *Index {
+	ix := &Index{
+		texts:    make([]string, len(texts)),
+		postings: make(map[string][]int),
+	}
+	for i, text := range texts {
+		text = strings.ToLower(text)
+		ix.texts[i] = text
+
+		seen := make(map[string]struct{})
+		for _, gram := range trigrams(text) {
+			if _, ok := seen[gram]; ok {
+				continue
+			}
+			seen[gram] = struct{}{}
+			ix.postings[gram] = append(ix.postings[gram], i)
+		}
+	}
+	return ix
+}
+
+// Len возвращает количество документов в индексе
+func (ix *Index) Len() int {
+	return len(ix.texts)
+}
+
+// Search возвращает позиции документов, содержащих query (без учета регистра),
+// в порядке возрастания. Для пустого запроса возвращаются все документы.
+func (ix *Index) Search(query string) []int {
+	query = strings.ToLower(query)
+
+	grams := trigrams(query)
+	if len(grams) == 0 {
+		// Запрос короче триграммы: проверяем все документы
+		var result []int
+		for i, text := range ix.texts {
+			if strings.Contains(text, query) {
+				result = append(result, i)
+			}
+		}
+		return result
+	}
+
+	// Пересекаем списки, начиная с самого короткого
+	lists := make([][]int, 0, len(grams))
+	for _, gram := range grams {
+		list, ok := ix.postings[gram]
+		if !ok {
+			return nil
+		}
+		lists = append(lists, list)
+	}
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+
+	candidates := lists[0]
+	for _, list := range lists[1:] {
+		candidates = intersect(candidates, list)
+		if len(candidates) == 0 {
+			return nil
+		}
+	}
+
+	// Совпадение всех триграмм не гарантирует совпадения подстроки
+	result := make([]int, 0, len(candidates))
+	for _, i := range candidates {
+		if strings.Contains(ix.texts[i], query) {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+// trigrams разбивает строку на триграммы символов (не байтов)
+func trigrams(text string) []string {
+	runes := []rune(text)
+	if len(runes) < trigramSize {
+		return nil
+	}
+	grams := make([]string, 0, len(runes)-trigramSize+1)
+	for i := 0; i+trigramSize <= len(runes); i++ {
+		grams = append(grams, string(runes[i:i+trigramSize]))
+	}
+	return grams
+}
+
+// intersect возвращает пересечение двух отсортированных списков позиций
+func intersect(a, b []int) []int {
+	var result []int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
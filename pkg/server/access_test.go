@@ -0,0 +1,152 @@
+package server_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"apigw/pkg/config"
+	"apigw/pkg/testbackends"
+)
+
+// versionedPaths - один и тот же маршрут без версии и в версиях API
+var versionedPaths = []string{"/api/comments/add", "/api/v1/comments/add", "/api/v2/comments/add"}
+
+// newAccessGateway запускает шлюз с конфигурацией, измененной setup
+func newAccessGateway(t *testing.T, setup func(cfg *config.Config)) *testbackends.Gateway {
+	t.Helper()
+	cfg := config.NewConfig()
+	cfg.Log.Level = "error"
+	setup(cfg)
+	g, err := testbackends.NewGateway(cfg, testbackends.GenerateNews(5), nil)
+	if err != nil {
+		t.Fatalf("не удалось запустить шлюз: %v", err)
+	}
+	t.Cleanup(g.Close)
+	return g
+}
+
+// hs256Token подписывает токен с утверждениями claims секретом secret
+func hs256Token(t *testing.T, secret string, claims map[string]interface{}) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := base64.RawURLEncoding.EncodeToString
+	signed := encode([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + encode(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + encode(mac.Sum(nil))
+}
+
+// addComment добавляет комментарий через шлюз и возвращает статус ответа
+func addComment(t *testing.T, g *testbackends.Gateway, path string, header http.Header) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, g.URL+path+"?news_id=1", strings.NewReader(`{"text":"Комментарий"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := g.Client().Do(req)
+	if err != nil {
+		t.Fatalf("POST %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode
+}
+
+// TestAPIKeyVersionedPaths проверяет, что auth.paths защищают маршрут и в
+// версиях API
+func TestAPIKeyVersionedPaths(t *testing.T) {
+	g := newAccessGateway(t, func(cfg *config.Config) {
+		cfg.Auth = config.AuthConfig{
+			Enabled: true,
+			Header:  "X-API-Key",
+			Keys:    []config.APIKeyConfig{{Key: "secret-mobile", Client: "mobile"}},
+			Paths:   []string{"/api/comments/add"},
+		}
+	})
+
+	for _, path := range versionedPaths {
+		if status := addComment(t, g, path, nil); status != http.StatusUnauthorized {
+			t.Errorf("POST %s без ключа: статус %d, ожидался 401", path, status)
+		}
+		if status := addComment(t, g, path, http.Header{"X-Api-Key": {"secret-mobile"}}); status >= 300 {
+			t.Errorf("POST %s с ключом: статус %d", path, status)
+		}
+	}
+}
+
+// TestJWTVersionedPaths проверяет, что токен маршрута из jwt.routes
+// требуется и в версиях API, а обработчик получает пользователя из токена
+func TestJWTVersionedPaths(t *testing.T) {
+	g := newAccessGateway(t, func(cfg *config.Config) {
+		cfg.JWT.Enabled = true
+		cfg.JWT.Secret = "jwt-secret"
+		cfg.JWT.UserClaim = "sub"
+		cfg.JWT.Routes = []config.JWTRouteConfig{{Path: "/api/comments/add", Method: http.MethodPost}}
+	})
+	bearer := http.Header{"Authorization": {"Bearer " + hs256Token(t, "jwt-secret", map[string]interface{}{"sub": "user-1"})}}
+
+	for _, path := range versionedPaths {
+		if status := addComment(t, g, path, nil); status != http.StatusUnauthorized {
+			t.Errorf("POST %s без токена: статус %d, ожидался 401", path, status)
+		}
+		if status := addComment(t, g, path, bearer); status >= 300 {
+			t.Errorf("POST %s с токеном: статус %d", path, status)
+		}
+	}
+
+	added := g.Comments.ForNews(1)
+	if len(added) != len(versionedPaths) {
+		t.Fatalf("добавлено %d комментариев, ожидалось %d", len(added), len(versionedPaths))
+	}
+	for _, comment := range added {
+		if comment.UserID != "user-1" {
+			t.Errorf("комментарий %d: user_id %q, ожидался user-1", comment.ID, comment.UserID)
+		}
+	}
+}
+
+// TestPolicyVersionedPaths проверяет, что политики доступа нельзя обойти
+// префиксом версии API
+func TestPolicyVersionedPaths(t *testing.T) {
+	g := newAccessGateway(t, func(cfg *config.Config) {
+		cfg.Auth = config.AuthConfig{
+			Enabled: true,
+			Header:  "X-API-Key",
+			Keys:    []config.APIKeyConfig{{Key: "secret-mobile", Client: "mobile"}},
+		}
+		cfg.Policies = []config.PolicyConfig{
+			{Path: "/api/comments/add", Methods: []string{http.MethodPost}, Auth: "api_key"},
+		}
+	})
+
+	for _, path := range versionedPaths {
+		if status := addComment(t, g, path, nil); status != http.StatusUnauthorized {
+			t.Errorf("POST %s без ключа: статус %d, ожидался 401", path, status)
+		}
+		if status := addComment(t, g, path, http.Header{"X-Api-Key": {"secret-mobile"}}); status >= 300 {
+			t.Errorf("POST %s с ключом: статус %d", path, status)
+		}
+		req, _ := http.NewRequest(http.MethodPut, g.URL+path, nil)
+		resp, err := g.Client().Do(req)
+		if err != nil {
+			t.Fatalf("PUT %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != http.MethodPost {
+			t.Errorf("PUT %s: статус %d, Allow %q, ожидались 405 и POST", path, resp.StatusCode, resp.Header.Get("Allow"))
+		}
+	}
+}
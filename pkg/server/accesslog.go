@@ -0,0 +1,320 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"apigw/pkg/config"
+)
+
+// Ключ контекста для сбора запросов к backend-сервисам в журнал запросов
+const backendCallsKey contextKey = "backend_calls"
+
+// accessLogTimeFormat - формат времени начала запроса в журнале запросов
+const accessLogTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// combinedTimeFormat - формат времени в формате Apache combined
+const combinedTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// accessLogMessage - сообщение записи журнала запросов
+const accessLogMessage = "Запрос обработан"
+
+// accessLog - текущий журнал запросов процесса; задается SetupLogging.
+// nil означает запись журнала шлюза по умолчанию.
+var accessLog atomic.Pointer[accessLogger]
+
+// accessLogger пишет строки журнала запросов в формате секции log.access
+type accessLogger struct {
+	cfg      config.AccessLogConfig
+	template *template.Template
+	// format - формат журнала шлюза для записей формата по умолчанию в отдельном файле
+	format string
+
+	// out - файл журнала запросов или stderr; logger - журнал для формата по
+	// умолчанию в отдельном файле (nil - журнал шлюза)
+	out    io.Writer
+	file   *os.File
+	logger *slog.Logger
+	// mu не дает строкам разных запросов перемешиваться
+	mu sync.Mutex
+}
+
+// accessRecord - строка журнала запросов. Поля start, method, target и
+// status позволяют воспроизвести запрос (apigw replay).
+type accessRecord struct {
+	Start     string  `json:"start"`
+	Method    string  `json:"method"`
+	Target    string  `json:"target"`
+	Proto     string  `json:"proto"`
+	IP        string  `json:"ip"`
+	Status    int     `json:"status"`
+	Bytes     int64   `json:"bytes"`
+	LatencyMS float64 `json:"latency_ms"`
+	RequestID string  `json:"request_id,omitempty"`
+	Route     string  `json:"route"`
+	Client    string  `json:"client,omitempty"`
+	Tenant    string  `json:"tenant,omitempty"`
+	Canary    string  `json:"canary,omitempty"`
+	Referer   string  `json:"referer,omitempty"`
+	UserAgent string  `json:"user_agent,omitempty"`
+	// Backends - запросы к backend-сервисам, если включено log.access.backend
+	Backends []backendCall `json:"backends,omitempty"`
+	// BackendMS - суммарная задержка запросов к backend-сервисам
+	BackendMS float64 `json:"backend_ms,omitempty"`
+
+	time    time.Time
+	latency time.Duration
+}
+
+// backendCall - запрос к backend-сервису при обработке запроса клиента
+type backendCall struct {
+	Backend   string  `json:"backend"`
+	Method    string  `json:"method"`
+	URL       string  `json:"url"`
+	Status    int     `json:"status,omitempty"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMS float64 `json:"latency_ms"`
+
+	latency time.Duration
+}
+
+// backendCalls собирает запросы к backend-сервисам одного запроса клиента;
+// запросы к разным сервисам могут выполняться параллельно
+type backendCalls struct {
+	mu    sync.Mutex
+	calls []backendCall
+}
+
+// newAccessLogger проверяет секцию log.access. Файл журнала открывается
+// методом open.
+func newAccessLogger(cfg config.LogConfig) (*accessLogger, error) {
+	l := &accessLogger{cfg: cfg.Access, format: cfg.Format}
+	switch cfg.Access.Format {
+	case "", "json", "combined":
+		if cfg.Access.Template != "" {
+			return nil, errors.New("шаблон log.access.template используется только с форматом template")
+		}
+	case "template":
+		if cfg.Access.Template == "" {
+			return nil, errors.New("не задан шаблон журнала запросов log.access.template")
+		}
+		tmpl, err := template.New("access").Option("missingkey=error").Parse(cfg.Access.Template)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный шаблон журнала запросов log.access.template: %w", err)
+		}
+		l.template = tmpl
+	default:
+		return nil, fmt.Errorf("неизвестный формат журнала запросов log.access.format: %q", cfg.Access.Format)
+	}
+	return l, nil
+}
+
+// open открывает файл журнала запросов, если он задан
+func (l *accessLogger) open() error {
+	l.out = os.Stderr
+	if l.cfg.File == "" {
+		return nil
+	}
+	file, err := os.OpenFile(l.cfg.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть файл журнала запросов: %w", err)
+	}
+	l.out, l.file = file, file
+	if l.cfg.Format == "" {
+		// Строки журнала запросов в отдельном файле не зависят от уровня журнала шлюза
+		handler, err := newFormatHandler(l.format, file, nil)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		l.logger = slog.New(handler)
+	}
+	return nil
+}
+
+// close закрывает файл журнала запросов
+func (l *accessLogger) close() {
+	if l.file != nil {
+		l.file.Close()
+	}
+}
+
+// collectBackend сообщает, нужно ли собирать запросы к backend-сервисам
+func (l *accessLogger) collectBackend() bool {
+	return l != nil && l.cfg.Backend
+}
+
+// log записывает строку журнала запросов
+func (l *accessLogger) log(ctx context.Context, rec *accessRecord) {
+	if l == nil || l.cfg.Format == "" {
+		l.logRecord(ctx, rec)
+		return
+	}
+
+	var buf bytes.Buffer
+	switch l.cfg.Format {
+	case "json":
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.Encode(rec)
+	case "combined":
+		writeCombined(&buf, rec, l.cfg.Backend)
+	case "template":
+		if err := l.template.Execute(&buf, rec); err != nil {
+			slog.DebugContext(ctx, "Не удалось записать строку журнала запросов по шаблону", "error", err)
+			return
+		}
+		if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+			buf.WriteByte('\n')
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(buf.Bytes())
+}
+
+// logRecord записывает строку журнала запросов записью журнала шлюза.
+// request_id, маршрут и клиент добавляются из контекста.
+func (l *accessLogger) logRecord(ctx context.Context, rec *accessRecord) {
+	attrs := []slog.Attr{
+		slog.String("start", rec.Start),
+		slog.String("method", rec.Method),
+		slog.String("target", rec.Target),
+		slog.String("ip", rec.IP),
+		slog.Int("status", rec.Status),
+		slog.Int64("bytes", rec.Bytes),
+		slog.Duration("latency", rec.latency),
+	}
+	if l.collectBackend() {
+		var backendLatency time.Duration
+		for _, call := range rec.Backends {
+			backendLatency += call.latency
+		}
+		attrs = append(attrs,
+			slog.Int("backend_requests", len(rec.Backends)),
+			slog.Duration("backend_latency", backendLatency),
+		)
+	}
+
+	logger := slog.Default()
+	if l != nil && l.logger != nil {
+		logger = l.logger
+	}
+	logger.LogAttrs(ctx, slog.LevelInfo, accessLogMessage, attrs...)
+}
+
+// writeCombined записывает строку в формате Apache combined. Вместо
+// пользователя записывается клиент (ключ API). С backend к строке
+// добавляются запросы к backend-сервисам: "сервис статус задержка, ...".
+func writeCombined(buf *bytes.Buffer, rec *accessRecord, backend bool) {
+	bytesSent := "-"
+	if rec.Bytes > 0 {
+		bytesSent = fmt.Sprint(rec.Bytes)
+	}
+	fmt.Fprintf(buf, "%s - %s [%s] %q %d %s %q %q",
+		rec.IP, combinedField(rec.Client), rec.time.Format(combinedTimeFormat),
+		rec.Method+" "+rec.Target+" "+rec.Proto, rec.Status, bytesSent,
+		combinedField(rec.Referer), combinedField(rec.UserAgent))
+	if backend {
+		calls := make([]string, len(rec.Backends))
+		for i, call := range rec.Backends {
+			result := fmt.Sprint(call.Status)
+			if call.Error != "" {
+				result = "error"
+			}
+			calls[i] = fmt.Sprintf("%s %s %s", call.Backend, result, call.latency.Round(time.Microsecond))
+		}
+		fmt.Fprintf(buf, " %q", combinedField(strings.Join(calls, ", ")))
+	}
+	buf.WriteByte('\n')
+}
+
+// combinedField возвращает значение поля формата combined; пустые поля записываются как "-"
+func combinedField(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// newAccessRecord заполняет строку журнала запросов по запросу r
+func newAccessRecord(ctx context.Context, r *http.Request, route string, start time.Time, status int, bytesSent int64, latency time.Duration) *accessRecord {
+	rec := &accessRecord{
+		Start:     start.Format(accessLogTimeFormat),
+		Method:    r.Method,
+		Target:    r.URL.RequestURI(),
+		Proto:     r.Proto,
+		IP:        clientIP(r),
+		Status:    status,
+		Bytes:     bytesSent,
+		LatencyMS: durationMS(latency),
+		Route:     route,
+		Client:    clientFrom(ctx),
+		Canary:    strings.Join(canaryTargets(ctx), ","),
+		Referer:   r.Referer(),
+		UserAgent: r.UserAgent(),
+		time:      start,
+		latency:   latency,
+	}
+	rec.RequestID, _ = ctx.Value(requestIDKey).(string)
+	if t := tenantFrom(ctx); t != nil {
+		rec.Tenant = t.name
+	}
+	if calls, ok := ctx.Value(backendCallsKey).(*backendCalls); ok {
+		calls.mu.Lock()
+		rec.Backends = append([]backendCall(nil), calls.calls...)
+		calls.mu.Unlock()
+		for _, call := range rec.Backends {
+			rec.BackendMS += call.LatencyMS
+		}
+	}
+	return rec
+}
+
+// withBackendCalls добавляет в контекст сбор запросов к backend-сервисам
+func withBackendCalls(ctx context.Context) context.Context {
+	return context.WithValue(ctx, backendCallsKey, &backendCalls{})
+}
+
+// recordBackendCall добавляет запрос к backend-сервису в строку журнала
+// запросов, если сбор включен для запроса
+func recordBackendCall(req *http.Request, resp *http.Response, err error, latency time.Duration) {
+	calls, ok := req.Context().Value(backendCallsKey).(*backendCalls)
+	if !ok {
+		return
+	}
+	call := backendCall{
+		Backend:   upstreamName(req.URL),
+		Method:    req.Method,
+		URL:       req.URL.Redacted(),
+		LatencyMS: durationMS(latency),
+		latency:   latency,
+	}
+	if err != nil {
+		call.Error = err.Error()
+	} else {
+		call.Status = resp.StatusCode
+	}
+
+	calls.mu.Lock()
+	calls.calls = append(calls.calls, call)
+	calls.mu.Unlock()
+}
+
+// durationMS возвращает длительность в миллисекундах
+func durationMS(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
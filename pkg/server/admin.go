@@ -0,0 +1,274 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"apigw/pkg/config"
+)
+
+// maintenanceMode - режим обслуживания, включенный через административный API.
+// Режим хранится в общем состоянии и не сбрасывается при перезагрузке конфигурации.
+type maintenanceMode struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+	// RetryAfter - через сколько клиентам стоит повторить запрос (заголовок Retry-After)
+	RetryAfter config.Duration `json:"retry_after,omitempty"`
+	Since      *time.Time      `json:"since,omitempty"`
+}
+
+// Сообщение клиентам в режиме обслуживания по умолчанию
+const defaultMaintenanceMessage = "Сервис на обслуживании, повторите запрос позже"
+
+// maintenanceMiddleware в режиме обслуживания отвечает на запросы 503 с кодом
+// maintenance. Проверки работоспособности и метрики продолжают работать.
+func (s *Server) maintenanceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mode := s.shared.maintenance.Load()
+		if mode == nil || !mode.Enabled || s.isHealthPath(r.URL.Path) || s.isMetricsPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if mode.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(mode.RetryAfter.Std().Seconds())))
+		}
+		message := mode.Message
+		if message == "" {
+			message = defaultMaintenanceMessage
+		}
+		writeError(w, r, http.StatusServiceUnavailable, codeMaintenance, message)
+	})
+}
+
+// checkAdmin проверяет секцию admin
+func checkAdmin(cfg config.AdminConfig) error {
+	if cfg.Port < 0 {
+		return fmt.Errorf("некорректный порт административного API admin.port: %d", cfg.Port)
+	}
+	if cfg.Port > 0 && cfg.Token == "" {
+		return fmt.Errorf("для административного API нужно указать admin.token")
+	}
+	return nil
+}
+
+// serveAdmin обслуживает административный API на порту port
+func (s *Server) serveAdmin(port int) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/routes", s.handleAdminRoutes)
+	mux.HandleFunc("/admin/backends", s.handleAdminBackends)
+	mux.HandleFunc("/admin/cache/flush", s.handleAdminCacheFlush)
+	mux.HandleFunc("/admin/cache/purge", s.handleAdminCachePurge)
+	mux.HandleFunc("/admin/maintenance", s.handleAdminMaintenance)
+	mux.HandleFunc("/admin/log-level", s.handleAdminLogLevel)
+	mux.HandleFunc("/admin/keys", s.handleAdminKeys)
+	mux.HandleFunc("/", handleNotFound)
+
+	slog.Info("Административный API запущен", "port", port)
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: s.adminAuthMiddleware(mux)}
+	return srv.ListenAndServe()
+}
+
+// adminAuthMiddleware пропускает только запросы с токеном из admin.token.
+// Токен берется из текущей конфигурации, поэтому его можно сменить перезагрузкой.
+func (s *Server) adminAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.current().config.Admin.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="API Gateway admin"`)
+			writeError(w, r, http.StatusUnauthorized, codeUnauthorized, "Требуется токен административного API")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowMethods проверяет метод запроса и отвечает 405, если он не из methods
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Метод не поддерживается")
+	return false
+}
+
+// handleAdminRoutes возвращает маршруты текущего поколения с обработчиками и backend-сервисами
+func (s *Server) handleAdminRoutes(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	srv := s.current()
+
+	patterns := make([]string, 0, len(builtinRoutes)+len(srv.proxies)+4)
+	for pattern := range builtinRoutes {
+		patterns = append(patterns, pattern)
+	}
+	for pattern := range srv.proxies {
+		patterns = append(patterns, pattern)
+	}
+	if srv.config.Metrics.Enabled {
+		patterns = append(patterns, srv.config.Metrics.Path)
+	}
+	for _, path := range []string{srv.config.Health.LivenessPath, srv.config.Health.ReadinessPath} {
+		if path != "" {
+			patterns = append(patterns, path)
+		}
+	}
+	if srv.config.Static.Enabled {
+		patterns = append(patterns, "/")
+	}
+	sort.Strings(patterns)
+
+	routes := make([]*RouteMatch, 0, len(patterns))
+	for _, pattern := range patterns {
+		req := &http.Request{Method: http.MethodGet, URL: &url.URL{Path: pattern}, Header: http.Header{}}
+		// Маршрут из списка может быть не зарегистрирован, если его функция отключена
+		if match := srv.matchRoute(req.WithContext(r.Context()), nil); match.Pattern == pattern {
+			routes = append(routes, match)
+		}
+	}
+	writeJSON(w, map[string]interface{}{"routes": routes})
+}
+
+// backendStatus - состояние backend-сервиса для административного API
+type backendStatus struct {
+	Upstream string   `json:"upstream"`
+	Services []string `json:"services"`
+	// Healthy - последний запрос к сервису не завершился ошибкой соединения или ответом 502-504
+	Healthy        bool               `json:"healthy"`
+	HealthCheck    *healthCheckStatus `json:"health_check,omitempty"`
+	CircuitBreaker string             `json:"circuit_breaker,omitempty"`
+}
+
+// handleAdminBackends возвращает состояние backend-сервисов: результаты
+// запросов, активной проверки и выключателей
+func (s *Server) handleAdminBackends(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	srv := s.current()
+
+	services := make(map[string][]string)
+	add := func(name string, service config.ServiceConfig) {
+		if u, err := url.Parse(service.URL); err == nil && u.Host != "" {
+			upstream := upstreamName(u)
+			services[upstream] = append(services[upstream], name)
+		}
+	}
+	for name, service := range srv.config.Services {
+		add(name, service)
+	}
+	for tenantName, tenant := range srv.config.Tenants.List {
+		for name, service := range tenant.Services {
+			add(tenantName+"/"+name, service)
+		}
+	}
+
+	backends := make([]backendStatus, 0, len(services))
+	for upstream, names := range services {
+		sort.Strings(names)
+		backends = append(backends, backendStatus{
+			Upstream:       upstream,
+			Services:       names,
+			Healthy:        srv.health.healthy(upstream),
+			HealthCheck:    srv.checker.status(upstream),
+			CircuitBreaker: srv.breakers.state(upstream),
+		})
+	}
+	sort.Slice(backends, func(i, j int) bool { return backends[i].Upstream < backends[j].Upstream })
+	writeJSON(w, map[string]interface{}{"backends": backends})
+}
+
+// handleAdminCacheFlush сбрасывает кэш ответов: весь или записи с тегами из
+// параметра tags (например, news:5,comments:5). Записи перестают находиться
+// сразу и вытесняются из хранилища по TTL.
+func (s *Server) handleAdminCacheFlush(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	srv := s.current()
+
+	tags := []string{cacheAllTag}
+	if value := r.URL.Query().Get("tags"); value != "" {
+		tags = strings.Split(value, ",")
+	}
+
+	if err := srv.invalidateEverywhere(r.Context(), tags); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка при сбросе кэша", "tags", tags, "error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, "Не удалось сбросить кэш")
+		return
+	}
+	slog.Info("Кэш ответов сброшен через административный API", "tags", tags)
+	writeJSON(w, map[string]interface{}{"flushed": tags})
+}
+
+// handleAdminMaintenance возвращает (GET) или меняет (PUT) режим обслуживания
+func (s *Server) handleAdminMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPut) {
+		return
+	}
+	if r.Method == http.MethodPut {
+		var mode maintenanceMode
+		if err := json.NewDecoder(r.Body).Decode(&mode); err != nil {
+			writeError(w, r, http.StatusBadRequest, codeInvalidJSON, "Некорректный JSON в теле запроса")
+			return
+		}
+		if mode.RetryAfter < 0 {
+			writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "Некорректное значение retry_after")
+			return
+		}
+		mode.Since = nil
+		if mode.Enabled {
+			now := time.Now()
+			mode.Since = &now
+			slog.Warn("Включен режим обслуживания", "message", mode.Message)
+		} else {
+			slog.Info("Режим обслуживания выключен")
+		}
+		s.shared.maintenance.Store(&mode)
+	}
+
+	mode := s.shared.maintenance.Load()
+	if mode == nil {
+		mode = &maintenanceMode{}
+	}
+	writeJSON(w, mode)
+}
+
+// handleAdminLogLevel возвращает (GET) или меняет (PUT) уровень журнала.
+// Уровень действует до следующей перезагрузки конфигурации.
+func (s *Server) handleAdminLogLevel(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPut) {
+		return
+	}
+	if r.Method == http.MethodPut {
+		var req struct {
+			Level string `json:"level"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, codeInvalidJSON, "Некорректный JSON в теле запроса")
+			return
+		}
+		level, ok := logLevels[strings.ToLower(req.Level)]
+		if !ok {
+			writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "Неизвестный уровень журнала: допустимы debug, info, warn и error")
+			return
+		}
+		logLevel.Set(level)
+		slog.Warn("Уровень журнала изменен через административный API", "level", strings.ToLower(req.Level))
+	}
+	writeJSON(w, map[string]string{"level": strings.ToLower(logLevel.Level().String())})
+}
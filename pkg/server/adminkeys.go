@@ -0,0 +1,263 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"apigw/pkg/config"
+)
+
+// Источники ключей API в ответах административного API
+const (
+	apiKeySourceConfig = "config"
+	apiKeySourceFile   = "file"
+)
+
+// apiKeyInfo - ключ API в ответах административного API. Сам ключ
+// возвращается только при создании, в списке ключи различаются по отпечатку.
+type apiKeyInfo struct {
+	Client string `json:"client"`
+	// Fingerprint - начало SHA-256 ключа
+	Fingerprint string `json:"fingerprint"`
+	// Source - где задан ключ: "config" (auth.keys) или "file" (auth.keys_file)
+	Source string `json:"source"`
+	Key    string `json:"key,omitempty"`
+}
+
+// keyFingerprint возвращает отпечаток ключа API
+func keyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// newAPIKey создает случайный ключ API
+func newAPIKey() (string, error) {
+	key := make([]byte, 24)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// readKeysFile читает файл ключей в формате {"ключ": "клиент"}; отсутствующий
+// файл считается пустым
+func readKeysFile(path string) (map[string]string, error) {
+	byKey := make(map[string]string)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return byKey, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл ключей API: %w", err)
+	}
+	if err := json.Unmarshal(data, &byKey); err != nil {
+		return nil, fmt.Errorf("некорректный файл ключей API %s: %w", path, err)
+	}
+	return byKey, nil
+}
+
+// writeKeysFile записывает файл ключей через временный файл, чтобы
+// перезагрузка конфигурации не прочитала его частично
+func writeKeysFile(path string, byKey map[string]string) error {
+	data, err := json.MarshalIndent(byKey, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// listAPIKeys возвращает ключи из auth.keys и auth.keys_file, упорядоченные по клиенту
+func listAPIKeys(cfg config.AuthConfig, fileKeys map[string]string) []apiKeyInfo {
+	keys := make([]apiKeyInfo, 0, len(cfg.Keys)+len(fileKeys))
+	for _, k := range cfg.Keys {
+		keys = append(keys, apiKeyInfo{Client: k.Client, Fingerprint: keyFingerprint(k.Key), Source: apiKeySourceConfig})
+	}
+	for key, client := range fileKeys {
+		keys = append(keys, apiKeyInfo{Client: client, Fingerprint: keyFingerprint(key), Source: apiKeySourceFile})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Client != keys[j].Client {
+			return keys[i].Client < keys[j].Client
+		}
+		return keys[i].Fingerprint < keys[j].Fingerprint
+	})
+	return keys
+}
+
+// handleAdminKeys управляет ключами API: GET возвращает список ключей, POST
+// создает ключ клиента ({"client": "...", "key": "..."}, без key ключ
+// генерируется), DELETE с параметром client или fingerprint отзывает ключи.
+// Изменяется только файл auth.keys_file, после чего конфигурация
+// перезагружается; ключи из auth.keys меняются правкой конфигурации.
+func (s *Server) handleAdminKeys(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost, http.MethodDelete) {
+		return
+	}
+	cfg := s.current().config
+	if !cfg.Auth.Enabled {
+		writeError(w, r, http.StatusConflict, codeInvalidRequest, "Проверка ключей API отключена (auth.enabled)")
+		return
+	}
+	if r.Method != http.MethodGet && cfg.Auth.KeysFile == "" {
+		writeError(w, r, http.StatusConflict, codeInvalidRequest, "Для управления ключами нужно указать файл ключей auth.keys_file")
+		return
+	}
+
+	// Изменения файла ключей выполняются по одному
+	s.shared.keysMu.Lock()
+	defer s.shared.keysMu.Unlock()
+
+	fileKeys := make(map[string]string)
+	if cfg.Auth.KeysFile != "" {
+		var err error
+		if fileKeys, err = readKeysFile(cfg.Auth.KeysFile); err != nil {
+			slog.ErrorContext(r.Context(), "Ошибка при чтении файла ключей API", "error", err)
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Не удалось прочитать файл ключей API")
+			return
+		}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, map[string]interface{}{"keys": listAPIKeys(cfg.Auth, fileKeys)})
+	case http.MethodPost:
+		s.addAPIKey(w, r, cfg, fileKeys)
+	case http.MethodDelete:
+		s.revokeAPIKeys(w, r, cfg, fileKeys)
+	}
+}
+
+// addAPIKey добавляет ключ клиента в файл ключей
+func (s *Server) addAPIKey(w http.ResponseWriter, r *http.Request, cfg *config.Config, fileKeys map[string]string) {
+	var req struct {
+		Client string `json:"client"`
+		Key    string `json:"key"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidJSON, "Некорректный JSON в теле запроса")
+		return
+	}
+	req.Client = strings.TrimSpace(req.Client)
+	if req.Client == "" {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "Не указан клиент")
+		return
+	}
+	if req.Key == "" {
+		key, err := newAPIKey()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Не удалось создать ключ API", "error", err)
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Не удалось создать ключ API")
+			return
+		}
+		req.Key = key
+	}
+	if s.current().auth != nil {
+		if _, taken := s.current().auth.authenticate(req.Key); taken {
+			writeError(w, r, http.StatusConflict, codeInvalidRequest, "Ключ API уже используется")
+			return
+		}
+	}
+	if _, taken := fileKeys[req.Key]; taken {
+		writeError(w, r, http.StatusConflict, codeInvalidRequest, "Ключ API уже используется")
+		return
+	}
+
+	next := make(map[string]string, len(fileKeys)+1)
+	for key, client := range fileKeys {
+		next[key] = client
+	}
+	next[req.Key] = req.Client
+	if !s.applyKeysFile(w, r, cfg, fileKeys, next) {
+		return
+	}
+
+	slog.Warn("Добавлен ключ API через административный API", "client", req.Client, "fingerprint", keyFingerprint(req.Key))
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, apiKeyInfo{Client: req.Client, Fingerprint: keyFingerprint(req.Key), Source: apiKeySourceFile, Key: req.Key})
+}
+
+// revokeAPIKeys удаляет из файла ключей ключи клиента (параметр client) или
+// ключ с отпечатком (параметр fingerprint)
+func (s *Server) revokeAPIKeys(w http.ResponseWriter, r *http.Request, cfg *config.Config, fileKeys map[string]string) {
+	query := r.URL.Query()
+	client, fingerprint := query.Get("client"), query.Get("fingerprint")
+	if (client == "") == (fingerprint == "") {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "Нужно указать один из параметров client или fingerprint")
+		return
+	}
+	matches := func(info apiKeyInfo) bool {
+		return (client != "" && info.Client == client) || (fingerprint != "" && info.Fingerprint == fingerprint)
+	}
+
+	next := make(map[string]string, len(fileKeys))
+	var revoked []apiKeyInfo
+	for key, keyClient := range fileKeys {
+		info := apiKeyInfo{Client: keyClient, Fingerprint: keyFingerprint(key), Source: apiKeySourceFile}
+		if matches(info) {
+			revoked = append(revoked, info)
+			continue
+		}
+		next[key] = keyClient
+	}
+	if len(revoked) == 0 {
+		for _, info := range listAPIKeys(cfg.Auth, nil) {
+			if matches(info) {
+				writeError(w, r, http.StatusConflict, codeInvalidRequest, "Ключ задан в auth.keys и отзывается правкой конфигурации")
+				return
+			}
+		}
+		writeError(w, r, http.StatusNotFound, codeNotFound, "Ключ API не найден")
+		return
+	}
+	if !s.applyKeysFile(w, r, cfg, fileKeys, next) {
+		return
+	}
+
+	sort.Slice(revoked, func(i, j int) bool { return revoked[i].Fingerprint < revoked[j].Fingerprint })
+	slog.Warn("Отозваны ключи API через административный API", "client", client, "fingerprint", fingerprint, "count", len(revoked))
+	writeJSON(w, map[string]interface{}{"revoked": revoked})
+}
+
+// applyKeysFile записывает новый набор ключей и перезагружает конфигурацию.
+// Если новое поколение не построено, прежний файл восстанавливается.
+func (s *Server) applyKeysFile(w http.ResponseWriter, r *http.Request, cfg *config.Config, prev, next map[string]string) bool {
+	if err := writeKeysFile(cfg.Auth.KeysFile, next); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка при записи файла ключей API", "error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, "Не удалось записать файл ключей API")
+		return false
+	}
+	if err := s.Reload(cfg); err != nil {
+		slog.ErrorContext(r.Context(), "Не удалось применить файл ключей API", "error", err)
+		if err := writeKeysFile(cfg.Auth.KeysFile, prev); err != nil {
+			slog.ErrorContext(r.Context(), "Ошибка при восстановлении файла ключей API", "error", err)
+		}
+		writeError(w, r, http.StatusInternalServerError, codeInternal, "Не удалось применить ключи API: "+err.Error())
+		return false
+	}
+	return true
+}
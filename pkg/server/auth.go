@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"apigw/pkg/config"
+	"apigw/pkg/metrics"
+)
+
+// Ключ контекста для хранения имени клиента, определенного по ключу API
+const clientKey contextKey = "client"
+
+// apiKeyAuth проверяет ключи API клиентов
+type apiKeyAuth struct {
+	header string
+	// clients - имена клиентов по хэшу ключа: ключи не хранятся в памяти
+	// в открытом виде, а поиск по хэшу не зависит от совпадающего префикса
+	clients map[[sha256.Size]byte]string
+	paths   []string
+
+	requests *metrics.CounterVec
+	failures *metrics.CounterVec
+}
+
+// newAPIKeyAuth проверяет секцию auth и загружает ключи. Возвращает nil, если проверка отключена.
+func newAPIKeyAuth(cfg config.AuthConfig, registry *metrics.Registry) (*apiKeyAuth, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.Header == "" {
+		return nil, fmt.Errorf("не указан заголовок ключа API auth.header")
+	}
+
+	a := &apiKeyAuth{
+		header:  cfg.Header,
+		clients: make(map[[sha256.Size]byte]string),
+		paths:   cfg.Paths,
+		requests: registry.Counter("apigw_client_requests_total",
+			"Запросы клиентов, прошедшие проверку ключа API", "client"),
+		failures: registry.Counter("apigw_auth_failures_total",
+			"Запросы, отклоненные проверкой ключа API", "reason"),
+	}
+
+	keys := cfg.Keys
+	if cfg.KeysFile != "" {
+		fileKeys, err := loadAPIKeys(cfg.KeysFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(append([]config.APIKeyConfig(nil), keys...), fileKeys...)
+	}
+	for _, k := range keys {
+		if k.Key == "" || k.Client == "" {
+			return nil, fmt.Errorf("для ключа API нужно указать key и client")
+		}
+		hash := sha256.Sum256([]byte(k.Key))
+		if _, ok := a.clients[hash]; ok {
+			return nil, fmt.Errorf("ключ API клиента %s указан повторно", k.Client)
+		}
+		a.clients[hash] = k.Client
+	}
+	if len(a.clients) == 0 {
+		return nil, fmt.Errorf("не задан ни один ключ API")
+	}
+
+	for _, path := range a.paths {
+		if !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("путь проверки ключа API должен начинаться с /: %q", path)
+		}
+	}
+	return a, nil
+}
+
+// loadAPIKeys читает файл ключей в формате {"ключ": "клиент"}
+func loadAPIKeys(path string) ([]config.APIKeyConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл ключей API: %w", err)
+	}
+	var byKey map[string]string
+	if err := json.Unmarshal(data, &byKey); err != nil {
+		return nil, fmt.Errorf("некорректный файл ключей API %s: %w", path, err)
+	}
+
+	keys := make([]config.APIKeyConfig, 0, len(byKey))
+	for key, client := range byKey {
+		keys = append(keys, config.APIKeyConfig{Key: key, Client: client})
+	}
+	return keys, nil
+}
+
+// protects проверяет, что для пути нужен ключ API
+func (a *apiKeyAuth) protects(path string) bool {
+	for _, pattern := range a.paths {
+		if pathMatches(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate возвращает имя клиента по ключу API
+func (a *apiKeyAuth) authenticate(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	client, ok := a.clients[sha256.Sum256([]byte(key))]
+	return client, ok
+}
+
+// authMiddleware проверяет ключ API и передает имя клиента обработчикам
+// через контекст. Имя клиента попадает в логи и метрики.
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	if s.auth == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.auth.protects(unversionedPath(r.URL.Path)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := r.Header.Get(s.auth.header)
+		client, ok := s.auth.authenticate(key)
+		if !ok {
+			reason := "invalid"
+			if key == "" {
+				reason = "missing"
+			}
+			s.auth.failures.Inc(reason)
+			slog.WarnContext(r.Context(), "Запрос отклонен: ключ API не указан или недействителен", "method", r.Method, "path", r.URL.Path, "ip", clientIP(r))
+			writeError(w, r, http.StatusUnauthorized, codeUnauthorized, "Требуется действительный ключ API")
+			return
+		}
+
+		s.auth.requests.Inc(client)
+		next.ServeHTTP(w, r.WithContext(withClient(r.Context(), client)))
+	})
+}
+
+// withClient сохраняет имя клиента в контексте
+func withClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientKey, client)
+}
+
+// clientFrom возвращает имя клиента запроса или пустую строку
+func clientFrom(ctx context.Context) string {
+	client, _ := ctx.Value(clientKey).(string)
+	return client
+}
@@ -0,0 +1,693 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"apigw/pkg/clients/comments"
+	"apigw/pkg/clients/news"
+	"apigw/pkg/config"
+	"apigw/pkg/httpclient"
+)
+
+// upstreamNews - новость в ответе сервиса новостей
+type upstreamNews struct {
+	// ID отсутствует у некорректных записей, такие новости пропускаются
+	ID          *int64 `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	PubDate     string `json:"pub_date"`
+	SourceURL   string `json:"source_url"`
+	CreatedAt   string `json:"created_at"`
+
+	// matchedIn - поля, в которых найден поисковый запрос; заполняется шлюзом
+	matchedIn []string
+}
+
+// errNewsNotFound возвращается, если сервис новостей не нашел новость
+var errNewsNotFound = errors.New("новость не найдена")
+
+// checkServices проверяет адреса backend-сервисов. Сервисы news и comments
+// обязательны: их используют встроенные обработчики API.
+func checkServices(services config.ServicesConfig) error {
+	for _, name := range []string{config.ServiceNews, config.ServiceComments} {
+		if _, ok := services[name]; !ok {
+			return fmt.Errorf("не задан сервис %s в секции services", name)
+		}
+	}
+	for name, service := range services {
+		if u, err := url.Parse(service.URL); err != nil || u.Host == "" {
+			return fmt.Errorf("некорректный адрес сервиса %s: %q", name, service.URL)
+		}
+	}
+	return nil
+}
+
+// newUpstreamClients создает HTTP клиенты backend-сервисов из секции services
+// и сервисов арендаторов. Сервисы с одним адресом используют общий клиент.
+func newUpstreamClients(cfg *config.Config) (*httpclient.Pool, error) {
+	settings, err := upstreamSettings(cfg, "клиента", func(service config.ServiceConfig) config.HTTPClientConfig {
+		return service.Client.WithDefaults(cfg.HTTPClient)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	transcoding, err := upstreamSettings(cfg, "gRPC", func(service config.ServiceConfig) *config.GRPCConfig {
+		return service.GRPC
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	discovery, err := upstreamSettings(cfg, "обнаружения", func(service config.ServiceConfig) *config.DiscoveryConfig {
+		return service.Discovery
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	clients := httpclient.NewPool(clientOptions(cfg.HTTPClient))
+	for upstream, sc := range settings {
+		u, _ := url.Parse(upstream)
+		dc := discovery[upstream]
+		if dc == nil {
+			clients.Add(u, clientOptions(sc))
+			continue
+		}
+		if transcoding[upstream] != nil {
+			return nil, fmt.Errorf("сервис %s: обнаружение через DNS SRV не поддерживается для gRPC сервисов", upstream)
+		}
+		// Соединения устанавливаются с экземплярами сервиса, а сертификат
+		// проверяется по имени сервиса из его адреса
+		opts := clientOptions(sc)
+		opts.ServerName = u.Hostname()
+		transport, err := newSRVTransport(u, dc, httpclient.NewTransport(opts))
+		if err != nil {
+			return nil, fmt.Errorf("сервис %s: %w", upstream, err)
+		}
+		clients.AddTransport(u, transport)
+	}
+	// Запросы к gRPC сервисам перекодируются транспортом клиента, поэтому
+	// проходят через повторы, выключатели и лимиты так же, как запросы к HTTP сервисам
+	for upstream, gc := range transcoding {
+		if gc == nil {
+			continue
+		}
+		u, _ := url.Parse(upstream)
+		transcoder, err := newGRPCTranscoder(u, gc)
+		if err != nil {
+			return nil, fmt.Errorf("gRPC сервис %s: %w", upstream, err)
+		}
+		clients.AddTransport(u, transcoder)
+	}
+	return clients, nil
+}
+
+// clientOptions переводит настройки клиента из конфигурации в параметры httpclient
+func clientOptions(cfg config.HTTPClientConfig) httpclient.Options {
+	return httpclient.Options{
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		DialTimeout:           cfg.DialTimeout.Std(),
+		KeepAlive:             cfg.KeepAlive.Std(),
+		IdleConnTimeout:       cfg.IdleConnTimeout.Std(),
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout.Std(),
+		TLSHandshakeTimeout:   cfg.DialTimeout.Std(),
+	}
+}
+
+// upstreamSettings возвращает настройки backend-сервисов из секции services
+// и сервисов арендаторов по адресу сервиса (схема и хост). Сервисы с одним
+// адресом обслуживаются вместе, поэтому их настройки what должны совпадать.
+func upstreamSettings[T any](cfg *config.Config, what string, settings func(config.ServiceConfig) T) (map[string]T, error) {
+	result := make(map[string]T)
+	add := func(name string, service config.ServiceConfig) error {
+		u, err := url.Parse(service.URL)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("некорректный адрес сервиса %s: %q", name, service.URL)
+		}
+		upstream := upstreamName(u)
+		value := settings(service)
+		if prev, ok := result[upstream]; ok && !reflect.DeepEqual(prev, value) {
+			return fmt.Errorf("у сервисов с адресом %s заданы разные настройки %s", upstream, what)
+		}
+		result[upstream] = value
+		return nil
+	}
+
+	for name, service := range cfg.Services {
+		if err := add(name, service); err != nil {
+			return nil, err
+		}
+	}
+	for tenantName, tenant := range cfg.Tenants.List {
+		for name, service := range tenant.Services {
+			if err := add(tenantName+"/"+name, service); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// serviceURL возвращает адрес backend-сервиса name арендатора запроса
+func (s *Server) serviceURL(ctx context.Context, name string) string {
+	return s.services(ctx).URL(name)
+}
+
+// fetchJSON выполняет GET запрос к backend-сервису и декодирует JSON ответ
+func (s *Server) fetchJSON(ctx context.Context, url string, v interface{}) (int, error) {
+	resp, err := s.makeBackendRequest(http.MethodGet, url, ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("сервис вернул статус %d", resp.StatusCode)
+	}
+
+	buf, err := readPooled(resp.Body)
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("ошибка при чтении ответа: %w", err)
+	}
+	defer putBuffer(buf)
+
+	body := buf.Bytes()
+	if len(body) == 0 {
+		return resp.StatusCode, nil
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return resp.StatusCode, fmt.Errorf("ошибка при декодировании ответа: %w", err)
+	}
+	return resp.StatusCode, nil
+}
+
+// upstreamDoer отправляет запросы клиентов сервисов новостей и комментариев
+// через транспорт поколения с передачей request_id, как makeBackendRequest
+type upstreamDoer struct {
+	s *Server
+}
+
+// Do отправляет запрос к backend-сервису
+func (d upstreamDoer) Do(req *http.Request) (*http.Response, error) {
+	d.s.requestIDs.apply(req)
+	d.s.claimHeaders.apply(req)
+	return d.s.transport().RoundTrip(req)
+}
+
+// newsService возвращает клиент сервиса новостей арендатора запроса
+func (s *Server) newsService(ctx context.Context) news.Service {
+	return news.New(s.serviceURL(ctx, config.ServiceNews), upstreamDoer{s})
+}
+
+// commentsService возвращает клиент сервиса комментариев арендатора запроса
+func (s *Server) commentsService(ctx context.Context) comments.Service {
+	return comments.New(s.serviceURL(ctx, config.ServiceComments), upstreamDoer{s})
+}
+
+// fetchAllNews получает полный список новостей от сервиса новостей
+func (s *Server) fetchAllNews(ctx context.Context) ([]news.Item, error) {
+	allNews, err := s.newsService(ctx).List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить новости: %w", err)
+	}
+	return allNews, nil
+}
+
+// fetchNewsItem получает одну новость от сервиса новостей
+func (s *Server) fetchNewsItem(ctx context.Context, newsID int64) (*news.Item, error) {
+	if s.isNewsMissing(ctx, newsID) {
+		return nil, errNewsNotFound
+	}
+
+	item, err := s.newsService(ctx).Get(ctx, newsID)
+	if errors.Is(err, news.ErrNotFound) {
+		s.rememberNewsMissing(ctx, newsID)
+		return nil, errNewsNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить новость: %w", err)
+	}
+	return item, nil
+}
+
+// fetchRawNewsItem получает новость в том виде, в котором ее вернул сервис
+// новостей. Для отсутствующей новости возвращается responseError с кодом
+// news_not_found.
+func (s *Server) fetchRawNewsItem(ctx context.Context, newsID int64) (json.RawMessage, error) {
+	// Не обращаемся к сервису новостей, если недавно узнали, что новости нет
+	if s.isNewsMissing(ctx, newsID) {
+		return nil, &responseError{http.StatusNotFound, codeNewsNotFound, "Новость не найдена"}
+	}
+
+	resp, err := s.makeBackendRequest(http.MethodGet, fmt.Sprintf("%s/api/news/%d", s.serviceURL(ctx, config.ServiceNews), newsID), ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return s.readNewsItem(ctx, resp, newsID)
+}
+
+// fetchComments получает комментарии к новости от сервиса комментариев
+func (s *Server) fetchComments(ctx context.Context, newsID int64) ([]comments.Comment, error) {
+	list, err := s.commentsService(ctx).List(ctx, newsID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить комментарии: %w", err)
+	}
+	return list, nil
+}
+
+// fetchRawComments получает комментарии к новости в том виде, в котором их
+// вернул сервис комментариев. При ошибке возвращается пустой список: новость
+// показывается и без комментариев.
+func (s *Server) fetchRawComments(ctx context.Context, newsID int64) []json.RawMessage {
+	comments := []json.RawMessage{}
+	if _, err := s.fetchJSON(ctx, fmt.Sprintf("%s/api/comm_news?id=%d", s.serviceURL(ctx, config.ServiceComments), newsID), &comments); err != nil {
+		slog.WarnContext(ctx, "Ошибка при получении комментариев", "backend", config.ServiceComments, "news_id", newsID, "error", err)
+		return []json.RawMessage{}
+	}
+	if comments == nil {
+		return []json.RawMessage{}
+	}
+	return comments
+}
+
+// totalCountHeader - заголовок, в котором сервис новостей с пагинацией
+// возвращает общее количество новостей, подходящих запросу
+const totalCountHeader = "X-Total-Count"
+
+// newsPagination проверяет, что сервис новостей арендатора запроса сам
+// выполняет пагинацию и поиск
+func (s *Server) newsPagination(ctx context.Context) bool {
+	return s.services(ctx)[config.ServiceNews].Pagination
+}
+
+// newsSorting проверяет, что сервис новостей арендатора запроса сам сортирует список
+func (s *Server) newsSorting(ctx context.Context) bool {
+	return s.services(ctx)[config.ServiceNews].Sorting
+}
+
+// newsPageURL возвращает адрес страницы списка новостей у сервиса с пагинацией
+func (s *Server) newsPageURL(ctx context.Context, filter newsFilter, order newsSort, page, count int) string {
+	query := url.Values{}
+	query.Set("page", strconv.Itoa(page))
+	query.Set("count", strconv.Itoa(count))
+	if filter.search != "" {
+		query.Set("s", filter.search)
+	}
+	filter.addPeriodTo(query)
+	order.addTo(query)
+	return fmt.Sprintf("%s/api/news/?%s", s.serviceURL(ctx, config.ServiceNews), query.Encode())
+}
+
+// newsListURL возвращает адрес всего списка новостей. Сервису, который сам
+// сортирует список или отбирает новости по периоду, передаются порядок order
+// и период из filter.
+func (s *Server) newsListURL(ctx context.Context, filter newsFilter, order newsSort) string {
+	query := url.Values{}
+	filter.addPeriodTo(query)
+	order.addTo(query)
+	if len(query) == 0 {
+		return fmt.Sprintf("%s/api/news/", s.serviceURL(ctx, config.ServiceNews))
+	}
+	return fmt.Sprintf("%s/api/news/?%s", s.serviceURL(ctx, config.ServiceNews), query.Encode())
+}
+
+// readNewsPage читает страницу новостей из ответа сервиса с пагинацией.
+// Ответ без заголовка X-Total-Count означает, что сервис не поддерживает
+// параметры пагинации и вернул весь список: страница выбирается шлюзом.
+func readNewsPage(resp *http.Response, filter newsFilter, page, count int) ([]upstreamNews, int, error) {
+	total, err := strconv.Atoi(resp.Header.Get(totalCountHeader))
+	if err != nil || total < 0 {
+		slog.WarnContext(resp.Request.Context(), "Сервис новостей не вернул общее количество новостей, пагинация выполняется шлюзом", "backend", config.ServiceNews, "header", totalCountHeader)
+		return streamNewsPage(resp.Body, filter, page, count, -1)
+	}
+
+	var items []upstreamNews
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		return nil, 0, err
+	}
+	if len(items) > count {
+		items = items[:count]
+	}
+	return items, total, nil
+}
+
+// commentCountConcurrency ограничивает число одновременных запросов к сервису
+// комментариев при подсчете комментариев к новостям страницы
+const commentCountConcurrency = 10
+
+// withCommentCounts сообщает, что клиент запросил количество комментариев к
+// новостям списка (with_comment_counts=1)
+func withCommentCounts(query url.Values) bool {
+	enabled, _ := strconv.ParseBool(query.Get("with_comment_counts"))
+	return enabled
+}
+
+// fillCommentCounts запрашивает у сервиса комментариев комментарии к новостям
+// параллельно и записывает их количество в CommentCount. Если комментарии к
+// новости получить не удалось, количество не заполняется: список новостей
+// показывается и без него.
+func (s *Server) fillCommentCounts(ctx context.Context, news []NewsItem) {
+	sem := make(chan struct{}, commentCountConcurrency)
+	var wg sync.WaitGroup
+	for i := range news {
+		wg.Add(1)
+		go func(item *NewsItem) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			var comments []json.RawMessage
+			if _, err := s.fetchJSON(ctx, fmt.Sprintf("%s/api/comm_news?id=%d", s.serviceURL(ctx, config.ServiceComments), item.ID), &comments); err != nil {
+				slog.WarnContext(ctx, "Ошибка при получении количества комментариев", "backend", config.ServiceComments, "news_id", item.ID, "error", err)
+				return
+			}
+			count := len(comments)
+			item.CommentCount = &count
+		}(&news[i])
+	}
+	wg.Wait()
+}
+
+// commentSorts - допустимые значения параметра sort списка комментариев;
+// минус означает обратный порядок
+var commentSorts = map[string]bool{"id": true, "-id": true, "created_at": true, "-created_at": true}
+
+// commentsPaginated сообщает, что клиент запросил страницу комментариев.
+// Без параметров page, count и sort список передается как есть.
+func commentsPaginated(query url.Values) bool {
+	return query.Has("page") || query.Has("count") || query.Has("sort")
+}
+
+// commentsPageURL возвращает адрес комментариев к новости. Сервису с
+// пагинацией передаются параметры страницы и сортировки.
+func (s *Server) commentsPageURL(ctx context.Context, newsID int64, sortBy string, page, count int) string {
+	query := url.Values{}
+	query.Set("id", strconv.FormatInt(newsID, 10))
+	if s.services(ctx)[config.ServiceComments].Pagination {
+		query.Set("page", strconv.Itoa(page))
+		query.Set("count", strconv.Itoa(count))
+		if sortBy != "" {
+			query.Set("sort", sortBy)
+		}
+	}
+	return fmt.Sprintf("%s/api/comm_news?%s", s.serviceURL(ctx, config.ServiceComments), query.Encode())
+}
+
+// readCommentsPage читает страницу комментариев из ответа сервиса. Ответ с
+// заголовком X-Total-Count содержит уже выбранную сервисом страницу, иначе
+// ответ содержит все комментарии, и шлюз сортирует их и выбирает страницу сам.
+// С tree страница выбирается из веток комментариев верхнего уровня, а если
+// страницу выбрал сервис, дерево строится из комментариев этой страницы.
+func (s *Server) readCommentsPage(resp *http.Response, sortBy string, page, count int, tree bool) ([]json.RawMessage, int, error) {
+	var items []json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+
+	if total, err := strconv.Atoi(resp.Header.Get(totalCountHeader)); err == nil && total >= 0 {
+		if len(items) > count {
+			items = items[:count]
+		}
+		if tree {
+			if items, err = buildCommentTree(items); err != nil {
+				return nil, 0, err
+			}
+		}
+		return items, total, nil
+	}
+	if s.services(resp.Request.Context())[config.ServiceComments].Pagination {
+		slog.WarnContext(resp.Request.Context(), "Сервис комментариев не вернул общее количество комментариев, пагинация выполняется шлюзом", "backend", config.ServiceComments, "header", totalCountHeader)
+	}
+
+	s.sortComments(items, sortBy)
+	if tree {
+		var err error
+		if items, err = buildCommentTree(items); err != nil {
+			return nil, 0, err
+		}
+	}
+	total := len(items)
+	if page > (total+count-1)/count {
+		return nil, total, nil
+	}
+	start := (page - 1) * count
+	return items[start:min(start+count, total)], total, nil
+}
+
+// newsSortFields - допустимые значения параметра sort списков новостей
+var newsSortFields = map[string]bool{"pub_date": true, "title": true}
+
+// newsSort - порядок списка новостей из параметров sort и order.
+// Пустое поле field - порядок сервиса новостей.
+type newsSort struct {
+	field string
+	desc  bool
+}
+
+// parseNewsSort разбирает параметры sort и order; order без sort не учитывается
+func parseNewsSort(query url.Values) (newsSort, error) {
+	field := query.Get("sort")
+	if field == "" {
+		return newsSort{}, nil
+	}
+	if !newsSortFields[field] {
+		return newsSort{}, fmt.Errorf("неизвестное поле %s", field)
+	}
+
+	order := newsSort{field: field}
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		order.desc = true
+	default:
+		return newsSort{}, fmt.Errorf("неизвестный порядок %s", query.Get("order"))
+	}
+	return order, nil
+}
+
+// addTo добавляет параметры sort и order в запрос к сервису новостей
+func (o newsSort) addTo(query url.Values) {
+	if o.field == "" {
+		return
+	}
+	query.Set("sort", o.field)
+	if o.desc {
+		query.Set("order", "desc")
+	} else {
+		query.Set("order", "asc")
+	}
+}
+
+// sortNews сортирует новости в порядке order. Новости с равными значениями
+// упорядочиваются по ID, поэтому порядок не зависит от порядка сервиса, а
+// desc - точно обратный asc. Заголовки сравниваются без учета регистра,
+// даты pub_date распознаются в тех же форматах, что и при приведении к
+// часовому поясу; новости с нераспознанной датой считаются самыми старыми.
+func (s *Server) sortNews(items []upstreamNews, order newsSort) {
+	if order.field == "" {
+		return
+	}
+
+	type sortable struct {
+		item  upstreamNews
+		title string
+		date  time.Time
+	}
+	list := make([]sortable, len(items))
+	for i, item := range items {
+		list[i] = sortable{item: item, title: strings.ToLower(item.Title)}
+		list[i].date, _ = s.dates.parseDate(item.PubDate)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if order.desc {
+			a, b = b, a
+		}
+		switch {
+		case order.field == "pub_date" && !a.date.Equal(b.date):
+			return a.date.Before(b.date)
+		case order.field == "title" && a.title != b.title:
+			return a.title < b.title
+		}
+		return *a.item.ID < *b.item.ID
+	})
+	for i := range list {
+		items[i] = list[i].item
+	}
+}
+
+// sortComments сортирует комментарии по sortBy (см. commentSorts) с
+// сохранением порядка сервиса для равных значений. Даты created_at
+// распознаются в тех же форматах, что и при приведении к часовому поясу;
+// комментарии с нераспознанной датой считаются самыми старыми.
+func (s *Server) sortComments(items []json.RawMessage, sortBy string) {
+	if sortBy == "" {
+		return
+	}
+	field, desc := strings.CutPrefix(sortBy, "-")
+
+	type sortable struct {
+		item    json.RawMessage
+		id      int64
+		created time.Time
+	}
+	list := make([]sortable, len(items))
+	for i, item := range items {
+		var comment struct {
+			ID        int64  `json:"id"`
+			CreatedAt string `json:"created_at"`
+		}
+		json.Unmarshal(item, &comment)
+		list[i] = sortable{item: item, id: comment.ID}
+		list[i].created, _ = s.dates.parseDate(comment.CreatedAt)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if desc {
+			a, b = b, a
+		}
+		if field == "created_at" {
+			return a.created.Before(b.created)
+		}
+		return a.id < b.id
+	})
+	for i := range list {
+		items[i] = list[i].item
+	}
+}
+
+// streamNewsPage читает массив новостей из ответа сервиса по одному элементу,
+// применяя поиск по заголовку и пагинацию по мере чтения. Полностью декодируются
+// только новости запрошенной страницы, остальные лишь учитываются в общем количестве.
+// Если общее количество уже известно (knownTotal >= 0), чтение прекращается после
+// конца страницы. Пустой ответ и null считаются пустым списком.
+func streamNewsPage(body io.Reader, filter newsFilter, page, count, knownTotal int) ([]upstreamNews, int, error) {
+	decoder := json.NewDecoder(body)
+
+	token, err := decoder.Token()
+	if err == io.EOF || (err == nil && token == nil) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, 0, fmt.Errorf("ожидается массив новостей")
+	}
+
+	start := (page - 1) * count
+	end := start + count
+
+	var items []upstreamNews
+	total := 0
+	for decoder.More() {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, 0, err
+		}
+
+		if filter.selective() {
+			var selected upstreamNews
+			if json.Unmarshal(raw, &selected) != nil || !filter.match(selected) {
+				continue
+			}
+		}
+
+		if total >= start && total < end {
+			var item upstreamNews
+			if err := json.Unmarshal(raw, &item); err != nil {
+				return nil, 0, err
+			}
+			items = append(items, item)
+		}
+		total++
+
+		if knownTotal >= 0 && total >= end {
+			if total < knownTotal {
+				total = knownTotal
+			}
+			return items, total, nil
+		}
+	}
+
+	// Закрывающая скобка массива
+	if _, err := decoder.Token(); err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+// firstArrayElement читает из JSON массива только первый элемент, не дочитывая
+// остальные. Для пустого массива и null возвращает nil.
+func firstArrayElement(body io.Reader) (json.RawMessage, error) {
+	decoder := json.NewDecoder(body)
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, nil
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, fmt.Errorf("ожидается массив")
+	}
+	if !decoder.More() {
+		return nil, nil
+	}
+
+	var item json.RawMessage
+	if err := decoder.Decode(&item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// filterNewsByTitle оставляет новости, заголовок которых содержит поисковый запрос (без учета регистра)
+func filterNewsByTitle(items []news.Item, searchTerm string) []news.Item {
+	if searchTerm == "" {
+		return items
+	}
+
+	searchTerm = strings.ToLower(searchTerm)
+	var filtered []news.Item
+	for _, item := range items {
+		if strings.Contains(strings.ToLower(item.Title), searchTerm) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
+// pageBounds вычисляет границы страницы и общее количество страниц
+func pageBounds(totalItems, page, count int) (start, end, totalPages int) {
+	totalPages = (totalItems + count - 1) / count // Округление вверх
+	start = (page - 1) * count
+	if start > totalItems {
+		start = totalItems
+	}
+	end = start + count
+	if end > totalItems {
+		end = totalItems
+	}
+	return start, end, totalPages
+}
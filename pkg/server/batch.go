@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// batchRequest - запрос в составе пакета
+type batchRequest struct {
+	// ID - необязательный идентификатор, возвращается в ответе на запрос
+	ID     string          `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// batchResponse - ответ на запрос из пакета
+type batchResponse struct {
+	ID      string            `json:"id,omitempty"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body - JSON ответа как есть, либо строка, если ответ не в формате JSON
+	Body interface{} `json:"body,omitempty"`
+}
+
+// handleBatch выполняет несколько запросов к API за одно обращение клиента.
+// Запросы выполняются параллельно через общую цепочку обработчиков,
+// ответы возвращаются в том же порядке, что и запросы.
+func (s *Server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Метод не разрешен")
+		return
+	}
+
+	var items []batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		slog.DebugContext(r.Context(), "Ошибка при чтении пакета запросов", "error", err)
+		writeError(w, r, http.StatusBadRequest, codeInvalidJSON, "Некорректный формат запроса. Ожидается массив запросов.")
+		return
+	}
+
+	cfg := s.config.Batch
+	if len(items) == 0 {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "Пакет не содержит запросов")
+		return
+	}
+	if cfg.MaxItems > 0 && len(items) > cfg.MaxItems {
+		writeErrorDetails(w, r, http.StatusBadRequest, codeTooManyItems,
+			fmt.Sprintf("Слишком много запросов в пакете (максимум %d)", cfg.MaxItems),
+			map[string]int{"max_items": cfg.MaxItems})
+		return
+	}
+
+	slog.DebugContext(r.Context(), "Получен пакет запросов", "items", len(items))
+
+	ctx := r.Context()
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout.Std())
+		defer cancel()
+	}
+
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = len(items)
+	}
+	sem := make(chan struct{}, concurrency)
+
+	results := make([]batchResponse, len(items))
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		go func(i int, item batchRequest) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+				results[i] = s.executeBatchItem(ctx, r, item)
+			case <-ctx.Done():
+				results[i] = batchError(r, item, http.StatusGatewayTimeout, codeTimeout, "Превышено время выполнения пакета")
+			}
+		}(i, item)
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(results)
+}
+
+// executeBatchItem выполняет один запрос пакета с заголовками исходного запроса
+func (s *Server) executeBatchItem(ctx context.Context, parent *http.Request, item batchRequest) batchResponse {
+	method := strings.ToUpper(item.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	if !strings.HasPrefix(item.Path, "/") || strings.HasPrefix(item.Path, "//") {
+		return batchError(parent, item, http.StatusBadRequest, codeInvalidRequest, "Путь запроса должен начинаться с /")
+	}
+	if strings.HasPrefix(item.Path, "/api/batch") {
+		return batchError(parent, item, http.StatusBadRequest, codeInvalidRequest, "Вложенные пакеты не поддерживаются")
+	}
+
+	header := internalHeader(parent)
+
+	var body []byte
+	if len(item.Body) > 0 && string(item.Body) != "null" {
+		body = item.Body
+		header.Set("Content-Type", "application/json")
+	}
+
+	// Все запросы пакета трассируются под request_id пакета
+	target := propagateRequestID(item.Path, parent)
+
+	if timeout := s.config.Batch.ItemTimeout.Std(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	type result struct {
+		rw  *bufferWriter
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		rw, err := s.serveInternal(ctx, method, target, header, body, parent.RemoteAddr)
+		done <- result{rw, err}
+	}()
+
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		return batchError(parent, item, http.StatusGatewayTimeout, codeTimeout, "Превышено время выполнения запроса")
+	}
+	if res.err != nil {
+		return batchError(parent, item, http.StatusBadRequest, codeInvalidRequest, "Некорректный запрос: "+res.err.Error())
+	}
+
+	resp := batchResponse{ID: item.ID, Status: res.rw.status, Headers: map[string]string{}}
+	for name := range res.rw.header {
+		resp.Headers[name] = res.rw.header.Get(name)
+	}
+	if respBody := res.rw.body.Bytes(); len(respBody) > 0 {
+		if json.Valid(respBody) {
+			resp.Body = json.RawMessage(respBody)
+		} else {
+			resp.Body = string(respBody)
+		}
+	}
+	return resp
+}
+
+// batchError формирует ответ на запрос пакета, который не удалось выполнить.
+// В ошибке указывается request_id пакета.
+func batchError(parent *http.Request, item batchRequest, status int, code, message string) batchResponse {
+	requestID, _ := parent.Context().Value(requestIDKey).(string)
+	return batchResponse{ID: item.ID, Status: status, Body: errorResponse{Error: errorBody{
+		Code:      code,
+		Message:   message,
+		RequestID: requestID,
+	}}}
+}
@@ -0,0 +1,116 @@
+package server_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"apigw/pkg/config"
+	"apigw/pkg/testbackends"
+)
+
+func TestMain(m *testing.M) {
+	// Журнал каждого запроса искажает результаты бенчмарков
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// benchComments создает по perNews комментариев к каждой из n новостей
+func benchComments(n, perNews int) []testbackends.CommentItem {
+	comments := make([]testbackends.CommentItem, 0, n*perNews)
+	for newsID := int64(1); newsID <= int64(n); newsID++ {
+		for i := 0; i < perNews; i++ {
+			comments = append(comments, testbackends.CommentItem{
+				ID:        int64(len(comments) + 1),
+				NewsID:    newsID,
+				Text:      "Комментарий",
+				CreatedAt: "2024-01-01T00:00:00Z",
+			})
+		}
+	}
+	return comments
+}
+
+// newBenchGateway запускает шлюз поверх фейковых сервисов со 100 новостями
+// и 10 комментариями к каждой. cacheTTL 0 отключает кэш ответов, чтобы
+// каждый запрос доходил до сервисов.
+func newBenchGateway(b *testing.B, cacheTTL time.Duration) *testbackends.Gateway {
+	b.Helper()
+	cfg := config.NewConfig()
+	cfg.Cache.TTL = config.Duration(cacheTTL)
+
+	g, err := testbackends.NewGateway(cfg, testbackends.GenerateNews(100), benchComments(100, 10))
+	if err != nil {
+		b.Fatalf("не удалось запустить шлюз: %v", err)
+	}
+	b.Cleanup(g.Close)
+	return g
+}
+
+// benchHandler выполняет GET запросы к обработчику шлюза без сетевого
+// обращения к самому шлюзу; сервисы вызываются по HTTP
+func benchHandler(b *testing.B, g *testbackends.Gateway, target string) {
+	b.Helper()
+	handler := g.Gateway.Handler()
+
+	// Первый запрос проверяет маршрут и заполняет кэш, если он включен
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != http.StatusOK {
+		b.Fatalf("GET %s: статус %d: %s", target, rec.Code, rec.Body.String())
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			b.Fatalf("GET %s: статус %d", target, rec.Code)
+		}
+	}
+}
+
+func BenchmarkNewsList(b *testing.B) {
+	benchHandler(b, newBenchGateway(b, 0), "/api/news?page=2&count=10")
+}
+
+func BenchmarkFullNewsList(b *testing.B) {
+	benchHandler(b, newBenchGateway(b, 0), "/api/fullnews?page=2&count=10")
+}
+
+func BenchmarkNewsItem(b *testing.B) {
+	benchHandler(b, newBenchGateway(b, 0), "/api/news/42")
+}
+
+func BenchmarkNewsWithComments(b *testing.B) {
+	benchHandler(b, newBenchGateway(b, 0), "/api/news?comm=42")
+}
+
+func BenchmarkComments(b *testing.B) {
+	benchHandler(b, newBenchGateway(b, 0), "/api/comments?id=42")
+}
+
+// benchCacheHit измеряет ответы из кэша: первый запрос заполняет кэш, и
+// сервисы больше не вызываются
+func benchCacheHit(b *testing.B, target string) {
+	g := newBenchGateway(b, time.Minute)
+	benchHandler(b, g, target)
+
+	b.StopTimer()
+	if requests := g.News.Requests() + g.Comments.Requests(); requests > 2 {
+		b.Fatalf("GET %s: запросов к сервисам %d, ответы не берутся из кэша", target, requests)
+	}
+}
+
+func BenchmarkNewsListCacheHit(b *testing.B) {
+	benchCacheHit(b, "/api/news?page=2&count=10")
+}
+
+func BenchmarkNewsItemCacheHit(b *testing.B) {
+	benchCacheHit(b, "/api/news/42")
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"apigw/pkg/breaker"
+	"apigw/pkg/config"
+	"apigw/pkg/metrics"
+)
+
+// errCircuitOpen возвращается, если выключатель backend-сервиса разомкнут
+var errCircuitOpen = errors.New("выключатель сервиса разомкнут после серии ошибок")
+
+// serviceBreaker - выключатель backend-сервиса и поведение при его размыкании
+type serviceBreaker struct {
+	breaker  *breaker.Breaker
+	fallback string
+}
+
+// upstreamBreakers - автоматические выключатели backend-сервисов по адресу
+// сервиса. nil означает, что выключатели отключены.
+type upstreamBreakers struct {
+	breakers map[string]*serviceBreaker
+	rejected *metrics.CounterVec
+	// staleTTL - время хранения копий ответов для fallback "cache", 0 - не сохранять
+	staleTTL time.Duration
+}
+
+// newUpstreamBreakers создает выключатели для сервисов, у которых они включены.
+// Сервисы с одним адресом используют общий выключатель.
+func newUpstreamBreakers(cfg *config.Config, registry *metrics.Registry) (*upstreamBreakers, error) {
+	b := &upstreamBreakers{
+		breakers: make(map[string]*serviceBreaker),
+		rejected: registry.Counter("apigw_circuit_breaker_rejected_total",
+			"Запросы, отклоненные разомкнутым выключателем сервиса", "upstream"),
+	}
+	transitions := registry.Counter("apigw_circuit_breaker_transitions_total",
+		"Смены состояния выключателей сервисов", "upstream", "state")
+	settings, err := upstreamSettings(cfg, "выключателя", func(service config.ServiceConfig) config.CircuitBreakerConfig {
+		return service.CircuitBreaker.WithDefaults(cfg.CircuitBreaker)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for upstream, bc := range settings {
+		if !bc.Enabled {
+			continue
+		}
+		switch bc.Fallback {
+		case config.BreakerFallbackError, config.BreakerFallbackCache:
+		default:
+			return nil, fmt.Errorf("неизвестное поведение выключателя сервиса %s: %q", upstream, bc.Fallback)
+		}
+
+		b.breakers[upstream] = &serviceBreaker{
+			breaker: breaker.New(breaker.Options{
+				FailureThreshold: bc.FailureThreshold,
+				OpenTimeout:      bc.OpenTimeout.Std(),
+				HalfOpenRequests: bc.HalfOpenRequests,
+				OnStateChange: func(from, to breaker.State) {
+					slog.Warn("Состояние выключателя сервиса изменилось", "backend", upstream, "from", from.String(), "to", to.String())
+					transitions.Inc(upstream, to.String())
+				},
+			}),
+			fallback: bc.Fallback,
+		}
+		if bc.Fallback == config.BreakerFallbackCache && bc.StaleTTL.Std() > b.staleTTL {
+			b.staleTTL = bc.StaleTTL.Std()
+		}
+	}
+	if len(b.breakers) == 0 {
+		return nil, nil
+	}
+	return b, nil
+}
+
+// state возвращает состояние выключателя сервиса upstream, пусто - выключатель отключен
+func (b *upstreamBreakers) state(upstream string) string {
+	if b == nil {
+		return ""
+	}
+	sb, ok := b.breakers[upstream]
+	if !ok {
+		return ""
+	}
+	return sb.breaker.State().String()
+}
+
+// acquire проверяет выключатель сервиса запроса. Возвращает false, если
+// выключатель разомкнут. После получения ответа нужно вызвать done.
+func (b *upstreamBreakers) acquire(req *http.Request) (done func(*http.Response, error), ok bool) {
+	noop := func(*http.Response, error) {}
+	if b == nil {
+		return noop, true
+	}
+	upstream := upstreamName(req.URL)
+	sb, exists := b.breakers[upstream]
+	if !exists {
+		return noop, true
+	}
+
+	record, ok := sb.breaker.Allow()
+	if !ok {
+		b.rejected.Inc(upstream)
+		if sb.fallback == config.BreakerFallbackCache {
+			if fallback, _ := req.Context().Value(staleFallbackKey).(*staleFallback); fallback != nil {
+				fallback.tripped.Store(true)
+			}
+		}
+		return nil, false
+	}
+	return func(resp *http.Response, err error) {
+		record(breakerResult(resp, err))
+	}, true
+}
+
+// breakerResult оценивает результат запроса для выключателя. Ошибкой
+// сервиса считаются сетевые ошибки и ответы со статусом 5xx.
+func breakerResult(resp *http.Response, err error) breaker.Result {
+	switch {
+	case errors.Is(err, context.Canceled), err == nil && resp == nil:
+		return breaker.Ignored
+	case err != nil, resp.StatusCode >= http.StatusInternalServerError:
+		return breaker.Failure
+	}
+	return breaker.Success
+}
+
+// canServeStale проверяет, что разомкнут выключатель сервиса с fallback "cache"
+func (b *upstreamBreakers) canServeStale() bool {
+	if b == nil || b.staleTTL == 0 {
+		return false
+	}
+	for _, sb := range b.breakers {
+		if sb.fallback == config.BreakerFallbackCache && sb.breaker.State() != breaker.Closed {
+			return true
+		}
+	}
+	return false
+}
+
+// Ключ контекста, по которому запросы к сервисам сообщают cacheMiddleware,
+// что запрос отклонен выключателем с fallback "cache"
+const staleFallbackKey contextKey = "staleFallback"
+
+// staleFallback отмечает, что при обработке запроса выключатель с fallback
+// "cache" отклонил запрос к сервису
+type staleFallback struct {
+	tripped atomic.Bool
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"apigw/pkg/config"
+	"apigw/pkg/metrics"
+)
+
+// bulkheadFullError возвращается, если запрос не дождался места в лимите
+// одновременных запросов к сервису. Обрабатывается как errUpstreamOverloaded.
+type bulkheadFullError struct {
+	upstream string
+	wait     time.Duration
+}
+
+func (e *bulkheadFullError) Error() string {
+	return fmt.Sprintf("превышено число одновременных запросов к сервису %s", e.upstream)
+}
+
+// Unwrap позволяет проверять ошибку через errors.Is(err, errUpstreamOverloaded)
+func (e *bulkheadFullError) Unwrap() error {
+	return errUpstreamOverloaded
+}
+
+// retryAfter возвращает значение заголовка Retry-After в секундах: клиенту
+// стоит повторить запрос не раньше, чем истечет время ожидания в очереди
+func (e *bulkheadFullError) retryAfter() string {
+	return strconv.Itoa(int(math.Max(1, math.Ceil(e.wait.Seconds()))))
+}
+
+// upstreamBulkheads ограничивает число одновременных запросов к
+// backend-сервисам. Место занято, пока не закрыто тело ответа, поэтому
+// потоковые ответы занимают его все время передачи.
+type upstreamBulkheads struct {
+	bulkheads map[string]*bulkhead
+	rejected  *metrics.CounterVec
+}
+
+// bulkhead - лимит одновременных запросов к одному сервису
+type bulkhead struct {
+	slots        chan struct{}
+	queueTimeout time.Duration
+}
+
+// newUpstreamBulkheads создает лимиты для сервисов, у которых задан
+// max_concurrent. Сервисы с одним адресом используют общий лимит.
+func newUpstreamBulkheads(cfg *config.Config, registry *metrics.Registry) (*upstreamBulkheads, error) {
+	settings, err := upstreamSettings(cfg, "bulkhead", func(service config.ServiceConfig) config.BulkheadConfig {
+		return service.Bulkhead.WithDefaults(cfg.Bulkhead)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	b := &upstreamBulkheads{
+		bulkheads: make(map[string]*bulkhead),
+		rejected: registry.Counter("apigw_bulkhead_rejected_total",
+			"Запросы, не дождавшиеся места в лимите одновременных запросов к сервису", "upstream"),
+	}
+	for upstream, bc := range settings {
+		if bc.MaxConcurrent < 0 || bc.QueueTimeout < 0 {
+			return nil, fmt.Errorf("некорректные max_concurrent или queue_timeout bulkhead сервиса %s", upstream)
+		}
+		if bc.MaxConcurrent == 0 {
+			continue
+		}
+		b.bulkheads[upstream] = &bulkhead{
+			slots:        make(chan struct{}, bc.MaxConcurrent),
+			queueTimeout: bc.QueueTimeout.Std(),
+		}
+	}
+	return b, nil
+}
+
+// acquire занимает место для запроса, при необходимости ожидая его не
+// дольше queue_timeout сервиса. Функцию finish нужно вызвать с результатом
+// запроса: при успехе место освобождается при закрытии тела ответа.
+func (b *upstreamBulkheads) acquire(req *http.Request) (finish func(*http.Response, error), err error) {
+	if b == nil {
+		return func(*http.Response, error) {}, nil
+	}
+	upstream := upstreamName(req.URL)
+	bh, ok := b.bulkheads[upstream]
+	if !ok {
+		return func(*http.Response, error) {}, nil
+	}
+
+	select {
+	case bh.slots <- struct{}{}:
+	default:
+		if err := bh.wait(req); err != nil {
+			if err == errUpstreamOverloaded {
+				b.rejected.Inc(upstream)
+				slog.WarnContext(req.Context(), "Превышено число одновременных запросов к сервису", "backend", upstream, "max_concurrent", cap(bh.slots))
+				return nil, &bulkheadFullError{upstream: upstream, wait: bh.queueTimeout}
+			}
+			return nil, err
+		}
+	}
+
+	var once sync.Once
+	release := func() { once.Do(func() { <-bh.slots }) }
+	return func(resp *http.Response, err error) {
+		if err != nil || resp == nil {
+			release()
+			return
+		}
+		resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+	}, nil
+}
+
+// wait ждет освобождения места не дольше queueTimeout. Возвращает
+// errUpstreamOverloaded, если место не освободилось, или ошибку контекста
+// запроса.
+func (bh *bulkhead) wait(req *http.Request) error {
+	if bh.queueTimeout <= 0 {
+		return errUpstreamOverloaded
+	}
+	timer := time.NewTimer(bh.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case bh.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return errUpstreamOverloaded
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// releaseOnClose освобождает место в лимите при закрытии тела ответа
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+// Close закрывает тело ответа и освобождает место
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.release()
+	return err
+}
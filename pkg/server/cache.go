@@ -0,0 +1,589 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"apigw/pkg/cache"
+	"apigw/pkg/config"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Форматы записей кэша с ответами (cache.serialization)
+const (
+	cacheSerializationJSON    = "json"
+	cacheSerializationMsgpack = "msgpack"
+)
+
+// newCacheStore создает хранилище кэша согласно cache.driver.
+// Для общих хранилищ при заданном cache.local.ttl перед ними ставится
+// локальный кэш в памяти процесса.
+func newCacheStore(cfg config.CacheConfig) (cache.Cache, error) {
+	var shared cache.Cache
+
+	switch cfg.Driver {
+	case "", "memory":
+		if cfg.MaxEntries < 0 {
+			return nil, fmt.Errorf("отрицательный размер кэша cache.max_entries: %d", cfg.MaxEntries)
+		}
+		return cache.NewMemory(cfg.MaxEntries), nil
+	case "memcached":
+		if len(cfg.Memcached.Servers) == 0 {
+			return nil, fmt.Errorf("не указаны серверы memcached (cache.memcached.servers)")
+		}
+		shared = cache.NewMemcached(cfg.Memcached.Servers, cfg.Memcached.Prefix, cfg.Memcached.Timeout.Std())
+	case "redis":
+		if cfg.Redis.Addr == "" {
+			return nil, fmt.Errorf("не указан адрес Redis (cache.redis.addr)")
+		}
+		shared = cache.NewRedis(cache.RedisOptions{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+			Prefix:   cfg.Redis.Prefix,
+			Timeout:  cfg.Redis.Timeout.Std(),
+		})
+	default:
+		return nil, fmt.Errorf("неизвестный драйвер кэша: %q", cfg.Driver)
+	}
+
+	if cfg.Local.TTL > 0 {
+		return cache.NewTiered(cache.NewMemory(cfg.Local.MaxEntries), shared, cfg.Local.TTL.Std()), nil
+	}
+	return shared, nil
+}
+
+// checkCacheRoutes проверяет время хранения ответов отдельных маршрутов
+func checkCacheRoutes(routes []config.CacheRouteConfig) error {
+	for _, rc := range routes {
+		if !strings.HasPrefix(rc.Path, "/") {
+			return fmt.Errorf("путь маршрута кэша должен начинаться с /: %q", rc.Path)
+		}
+		if rc.TTL < 0 {
+			return fmt.Errorf("отрицательное время хранения ответов маршрута %s", rc.Path)
+		}
+	}
+	return nil
+}
+
+// cacheEnabled проверяет, что кэширование ответов включено хотя бы для одного маршрута
+func (s *Server) cacheEnabled() bool {
+	if s.config.Cache.TTL > 0 {
+		return true
+	}
+	for _, rc := range s.config.Cache.Routes {
+		if rc.TTL > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// responseTTL возвращает время хранения ответа на запрос и имя маршрута кэша
+// для метрик. Учитывается первый подходящий маршрут из cache.routes.
+func (s *Server) responseTTL(r *http.Request) (time.Duration, string) {
+	for _, rc := range s.config.Cache.Routes {
+		if pathMatches(rc.Path, r.URL.Path) {
+			return rc.TTL.Std(), rc.Path
+		}
+	}
+	return s.config.Cache.TTL.Std(), "default"
+}
+
+// countCacheLookup учитывает результат обращения к кэшу ответов:
+// hit, miss или stale
+func (s *Server) countCacheLookup(route, result string) {
+	s.shared.metrics.Counter("apigw_cache_requests_total",
+		"Обращения к кэшу ответов по результату (hit, miss, stale)", "route", "result").Inc(route, result)
+}
+
+// Значение, которым в кэше помечаются отсутствующие новости
+var missingMarker = []byte("1")
+
+// missingNewsKey возвращает ключ кэша для отметки об отсутствии новости
+func (s *Server) missingNewsKey(newsID int64) string {
+	return "missing:" + s.keys.Key(http.MethodGet, fmt.Sprintf("/api/news/%d", newsID), nil)
+}
+
+// isNewsMissing проверяет, известно ли, что сервис новостей недавно вернул для ID ответ "не найдено"
+func (s *Server) isNewsMissing(ctx context.Context, newsID int64) bool {
+	if s.config.Cache.NegativeTTL <= 0 {
+		return false
+	}
+
+	_, err := s.cacheStore(ctx).Get(ctx, s.missingNewsKey(newsID))
+	if err != nil {
+		if !errors.Is(err, cache.ErrNotFound) {
+			slog.ErrorContext(ctx, "Ошибка при чтении кэша", "error", err)
+		}
+		return false
+	}
+
+	slog.DebugContext(ctx, "Новость отсутствует по данным кэша", "news_id", newsID)
+	return true
+}
+
+// rememberNewsMissing запоминает, что новость с указанным ID не найдена
+func (s *Server) rememberNewsMissing(ctx context.Context, newsID int64) {
+	ttl := s.config.Cache.NegativeTTL.Std()
+	if ttl <= 0 {
+		return
+	}
+
+	if err := s.cacheStore(ctx).Set(ctx, s.missingNewsKey(newsID), missingMarker, ttl); err != nil {
+		slog.ErrorContext(ctx, "Ошибка при записи в кэш", "error", err)
+	}
+}
+
+// newsTotalKey возвращает ключ кэша общего количества новостей для поискового
+// запроса (с полями и режимом поиска) и периода
+func (s *Server) newsTotalKey(filter newsFilter) string {
+	query := url.Values{}
+	if filter.search != "" {
+		query.Set("s", filter.search)
+	}
+	if filter.inParam != "" {
+		query.Set("in", filter.inParam)
+	}
+	if filter.matchParam != "" {
+		query.Set("match", filter.matchParam)
+	}
+	if filter.fromParam != "" {
+		query.Set("from", filter.fromParam)
+	}
+	if filter.untilParam != "" {
+		query.Set("until", filter.untilParam)
+	}
+	return "total:" + s.keys.Key(http.MethodGet, "/api/news", query) + regexSearchKey(query)
+}
+
+// regexSearchKey возвращает часть ключа кэша с регулярным выражением поиска
+// в исходном виде. Построитель ключей сравнивает параметр s без учета
+// регистра, но в регулярных выражениях регистр меняет смысл (\d и \D,
+// \w и \W), поэтому такие запросы не должны попадать в одну запись.
+func regexSearchKey(query url.Values) string {
+	if query.Get("match") != searchRegex || query.Get("s") == "" {
+		return ""
+	}
+	return "#re=" + url.QueryEscape(query.Get("s"))
+}
+
+// cachedNewsTotal возвращает закэшированное общее количество новостей для
+// поискового запроса и периода или -1 и false, если оно неизвестно
+func (s *Server) cachedNewsTotal(ctx context.Context, filter newsFilter) (int, bool) {
+	if s.config.Cache.PaginationTTL <= 0 {
+		return -1, false
+	}
+
+	data, err := s.cacheStore(ctx).Get(ctx, s.newsTotalKey(filter))
+	if err != nil {
+		if !errors.Is(err, cache.ErrNotFound) {
+			slog.ErrorContext(ctx, "Ошибка при чтении кэша", "error", err)
+		}
+		return -1, false
+	}
+
+	total, err := strconv.Atoi(string(data))
+	if err != nil || total < 0 {
+		return -1, false
+	}
+	return total, true
+}
+
+// rememberNewsTotal запоминает общее количество новостей для поискового запроса и периода
+func (s *Server) rememberNewsTotal(ctx context.Context, filter newsFilter, total int) {
+	ttl := s.config.Cache.PaginationTTL.Std()
+	if ttl <= 0 {
+		return
+	}
+
+	if err := s.cacheStore(ctx).Set(ctx, s.newsTotalKey(filter), []byte(strconv.Itoa(total)), ttl); err != nil {
+		slog.ErrorContext(ctx, "Ошибка при записи в кэш", "error", err)
+	}
+}
+
+// cachedResponse - сохраненный в кэше ответ обработчика
+type cachedResponse struct {
+	Status      int    `json:"status" msgpack:"status"`
+	ContentType string `json:"content_type" msgpack:"content_type"`
+	Body        []byte `json:"body" msgpack:"body"`
+}
+
+// validCacheSerialization проверяет формат записей кэша
+func validCacheSerialization(format string) bool {
+	return format == "" || format == cacheSerializationJSON || format == cacheSerializationMsgpack
+}
+
+// encodeCachedResponse кодирует ответ для кэша в формате cache.serialization.
+// msgpack хранит тело как есть, а JSON - в base64, что на треть больше.
+func (s *Server) encodeCachedResponse(c cachedResponse) ([]byte, error) {
+	if s.config.Cache.Serialization == cacheSerializationMsgpack {
+		return msgpack.Marshal(c)
+	}
+	return json.Marshal(c)
+}
+
+// decodeCachedResponse декодирует запись кэша в любом из форматов: формат
+// определяется по первому байту, поэтому записи, сохраненные до смены
+// cache.serialization или другими экземплярами шлюза, остаются читаемыми
+func decodeCachedResponse(data []byte) (*cachedResponse, error) {
+	var cached cachedResponse
+	var err error
+	if len(data) > 0 && data[0] == '{' {
+		err = json.Unmarshal(data, &cached)
+	} else {
+		err = msgpack.Unmarshal(data, &cached)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cached, nil
+}
+
+// captureWriter передает ответ клиенту и одновременно сохраняет его копию
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	body   *bytes.Buffer
+	// limit - максимальный размер копии (0 - без ограничения); копия
+	// большего ответа не сохраняется, overflow сообщает об этом
+	limit    int
+	overflow bool
+}
+
+// WriteHeader запоминает статус-код ответа
+func (cw *captureWriter) WriteHeader(code int) {
+	cw.status = code
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+// Write копирует тело ответа в буфер, пока не превышен limit
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	if !cw.overflow {
+		if cw.limit > 0 && cw.body.Len()+len(b) > cw.limit {
+			cw.overflow = true
+			cw.body.Reset()
+		} else {
+			cw.body.Write(b)
+		}
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush отправляет клиенту переданную часть ответа
+func (cw *captureWriter) Flush() {
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// responseCacheKey возвращает ключ кэша ответа для запроса с учетом версий его тегов
+func (s *Server) responseCacheKey(r *http.Request) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("resp:")
+	sb.WriteString(s.keys.Key(r.Method, r.URL.Path, r.URL.Query()))
+	sb.WriteString(regexSearchKey(r.URL.Query()))
+
+	// Общий тег и тег маршрута позволяют сбросить весь кэш ответов или кэш
+	// маршрута через административный API
+	for _, tag := range append([]string{cacheAllTag, routeTag(cacheRoute(r.URL.Path))}, cacheTags(r)...) {
+		version, err := s.cacheTagVersions(r.Context()).Version(r.Context(), tag)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString("#")
+		sb.WriteString(tag)
+		sb.WriteString("=")
+		sb.WriteString(version)
+	}
+	// Ответы новых версий сервисов не должны доставаться клиентам основной версии
+	if targets := canaryTargets(r.Context()); len(targets) > 0 {
+		sb.WriteString("#canary=")
+		sb.WriteString(strings.Join(targets, ","))
+	}
+	// Страница комментариев и весь список отдаются в разном формате, а page=1
+	// и count по умолчанию в ключ не попадают
+	if r.URL.Path == "/api/comments" && commentsPaginated(r.URL.Query()) {
+		sb.WriteString("#page")
+	}
+	// Вид списка по умолчанию задается в конфигурации и может измениться при перезагрузке
+	if r.URL.Path == "/api/comments" && s.commentsView(r.URL.Query()) == commentsViewTree {
+		sb.WriteString("#tree")
+	}
+	return sb.String(), nil
+}
+
+// cacheTags возвращает теги, от которых зависит ответ на GET запрос
+func cacheTags(r *http.Request) []string {
+	query := r.URL.Query()
+
+	switch {
+	case r.URL.Path == "/api/news":
+		if id, err := strconv.ParseInt(query.Get("comm"), 10, 64); err == nil {
+			return []string{newsTag(id), commentsTag(id)}
+		}
+		if withCommentCounts(query) {
+			return []string{newsListTag, commentCountsTag}
+		}
+		return []string{newsListTag}
+	case r.URL.Path == "/api/fullnews":
+		return []string{newsListTag}
+	case r.URL.Path == "/api/comments":
+		if id, err := strconv.ParseInt(query.Get("id"), 10, 64); err == nil {
+			return []string{commentsTag(id)}
+		}
+	case r.URL.Path == "/api/news/batch":
+		ids, _ := parseNewsIDs(query.Get("ids"))
+		tags := make([]string, len(ids))
+		for i, id := range ids {
+			tags[i] = newsTag(id)
+		}
+		return tags
+	case strings.HasPrefix(r.URL.Path, "/api/news/"):
+		if id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/news/"), 10, 64); err == nil {
+			return []string{newsTag(id)}
+		}
+	}
+	return nil
+}
+
+// mutationTags возвращает теги, которые устаревают после успешного изменяющего
+// запроса. Встроенные маршруты новостей только читают данные; новости
+// изменяются через маршруты из секции routes (см. proxyRoute.mutationTags).
+func mutationTags(r *http.Request) []string {
+	query := r.URL.Query()
+
+	switch {
+	case r.URL.Path == "/api/comments/add":
+		idStr := query.Get("news_id")
+		if idStr == "" {
+			idStr = query.Get("id")
+		}
+		if id, err := strconv.ParseInt(idStr, 10, 64); err == nil {
+			return []string{commentsTag(id), commentCountsTag}
+		}
+	}
+	return nil
+}
+
+// Ключ контекста, по которому proxyUpstream сообщает cacheMiddleware, что
+// сервис выполнил изменяющий запрос
+const upstreamWriteKey contextKey = "upstreamWrite"
+
+// upstreamWrite отмечает, что при обработке запроса сервис успешно выполнил
+// изменяющий запрос. Без отметки cacheMiddleware не инвалидирует кэш: ответ
+// 2xx на POST мог сформировать сам шлюз, не изменив данных.
+type upstreamWrite struct {
+	done atomic.Bool
+}
+
+// markUpstreamWrite отмечает успешный изменяющий запрос к сервису
+func markUpstreamWrite(ctx context.Context) {
+	if write, _ := ctx.Value(upstreamWriteKey).(*upstreamWrite); write != nil {
+		write.done.Store(true)
+	}
+}
+
+// Теги кэша
+const (
+	newsListTag = "news:list"
+	// commentCountsTag - списки новостей с количеством комментариев
+	commentCountsTag = "comments:counts"
+	// cacheAllTag входит в ключи всех ответов
+	cacheAllTag = "all"
+)
+
+func newsTag(id int64) string      { return "news:" + strconv.FormatInt(id, 10) }
+func commentsTag(id int64) string  { return "comments:" + strconv.FormatInt(id, 10) }
+func routeTag(route string) string { return "route:" + route }
+
+// cachedRoutes - маршруты, ответы которых кэшируются
+var cachedRoutes = []string{"/api/news", "/api/fullnews", "/api/comments", "/api/news/batch", "/api/news/"}
+
+// cacheRoute возвращает маршрут из cachedRoutes, которому соответствует путь
+// запроса, или пустую строку
+func cacheRoute(path string) string {
+	if containsString(cachedRoutes, path) {
+		return path
+	}
+	if strings.HasPrefix(path, "/api/news/") {
+		return "/api/news/"
+	}
+	return ""
+}
+
+// invalidateCache инвалидирует записи кэша, связанные с тегами
+func (s *Server) invalidateCache(ctx context.Context, tags ...string) {
+	if len(tags) == 0 {
+		return
+	}
+	if err := s.cacheTagVersions(ctx).Invalidate(ctx, tags...); err != nil {
+		slog.ErrorContext(ctx, "Ошибка при инвалидации кэша", "tags", tags, "error", err)
+		return
+	}
+	slog.DebugContext(ctx, "Инвалидированы записи кэша", "tags", tags)
+}
+
+// cacheMiddleware кэширует успешные ответы на GET запросы
+func (s *Server) cacheMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.cacheEnabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Изменяющие запросы не кэшируются, а после успешного выполнения
+		// инвалидируют связанные записи, не дожидаясь истечения TTL
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			rw := newResponseWriter(w)
+			write := &upstreamWrite{}
+			next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), upstreamWriteKey, write)))
+			if write.done.Load() && rw.statusCode >= 200 && rw.statusCode < 300 {
+				s.invalidateCache(r.Context(), mutationTags(r)...)
+			}
+			return
+		}
+		ttl, route := s.responseTTL(r)
+		if r.Method != http.MethodGet || ttl <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, err := s.responseCacheKey(r)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Ошибка при чтении версий тегов кэша", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Прогрев кэша всегда обращается к обработчику, чтобы обновить запись
+		if refresh, _ := r.Context().Value(cacheRefreshKey).(bool); !refresh {
+			if cached, ok := s.cachedResponse(r.Context(), key); ok {
+				s.countCacheLookup(route, "hit")
+				cached.write(w, "HIT")
+				return
+			}
+		}
+
+		// При включенном cache.stale_if_error, а также пока выключатель
+		// сервиса с fallback "cache" разомкнут, ответ обработчика
+		// буферизуется: если сервис ответил ошибкой 5xx, не ответил вовремя
+		// или запрос отклонен выключателем, клиент получает последнюю
+		// сохраненную копию ответа
+		s.countCacheLookup(route, "miss")
+		staleIfError := s.config.Cache.StaleIfError > 0
+		if staleIfError || s.breakers.canServeStale() {
+			if stale, ok := s.cachedResponse(r.Context(), staleCacheKey(key)); ok {
+				fallback := &staleFallback{}
+				bw := newBufferWriter()
+				next.ServeHTTP(bw, r.WithContext(context.WithValue(r.Context(), staleFallbackKey, fallback)))
+				tripped := fallback.tripped.Load() && bw.status != http.StatusOK
+				if tripped || (staleIfError && bw.status >= http.StatusInternalServerError) {
+					slog.WarnContext(r.Context(), "Сервис недоступен, отправлена сохраненная копия ответа", "path", r.URL.Path, "status", bw.status)
+					s.countCacheLookup(route, "stale")
+					w.Header().Set("Warning", `111 - "Revalidation Failed"`)
+					stale.write(w, "STALE")
+					return
+				}
+
+				for name, values := range bw.header {
+					w.Header()[name] = values
+				}
+				w.Header().Set("X-Cache", "MISS")
+				w.WriteHeader(bw.status)
+				w.Write(bw.body.Bytes())
+				s.storeResponse(r.Context(), key, ttl, bw.status, bw.header.Get("Content-Type"), bw.body.Bytes())
+				return
+			}
+		}
+
+		w.Header().Set("X-Cache", "MISS")
+		cw := &captureWriter{ResponseWriter: w, status: http.StatusOK, body: getBuffer(), limit: s.config.Streaming.MaxBuffer}
+		defer putBuffer(cw.body)
+		next.ServeHTTP(cw, r)
+		if cw.overflow {
+			slog.DebugContext(r.Context(), "Ответ больше streaming.max_buffer не сохранен в кэш", "path", r.URL.Path)
+			return
+		}
+		s.storeResponse(r.Context(), key, ttl, cw.status, w.Header().Get("Content-Type"), cw.body.Bytes())
+	})
+}
+
+// staleCacheKey возвращает ключ копии ответа для fallback "cache" выключателей
+func staleCacheKey(key string) string {
+	return "stale:" + key
+}
+
+// cachedResponse читает из кэша ответ по ключу key
+func (s *Server) cachedResponse(ctx context.Context, key string) (*cachedResponse, bool) {
+	data, err := s.cacheStore(ctx).Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, cache.ErrNotFound) {
+			slog.ErrorContext(ctx, "Ошибка при чтении кэша", "error", err)
+		}
+		return nil, false
+	}
+
+	cached, err := decodeCachedResponse(data)
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка при декодировании записи кэша", "key", key, "error", err)
+		return nil, false
+	}
+	return cached, true
+}
+
+// write отправляет сохраненный ответ клиенту с заголовком X-Cache: source
+func (c *cachedResponse) write(w http.ResponseWriter, source string) {
+	w.Header().Set("Content-Type", c.ContentType)
+	w.Header().Set("X-Cache", source)
+	w.WriteHeader(c.Status)
+	w.Write(c.Body)
+}
+
+// storeResponse сохраняет успешный ответ обработчика в кэш на время ttl, а также его копию
+// на случай размыкания выключателя сервиса с fallback "cache"
+func (s *Server) storeResponse(ctx context.Context, key string, ttl time.Duration, status int, contentType string, body []byte) {
+	// Кэшируем только успешные ответы
+	if status != http.StatusOK {
+		return
+	}
+
+	data, err := s.encodeCachedResponse(cachedResponse{
+		Status:      status,
+		ContentType: contentType,
+		Body:        body,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка при кодировании записи кэша", "error", err)
+		return
+	}
+	if err := s.cacheStore(ctx).Set(ctx, key, data, ttl); err != nil {
+		slog.ErrorContext(ctx, "Ошибка при записи в кэш", "error", err)
+	}
+	if staleTTL := s.staleTTL(); staleTTL > 0 {
+		if err := s.cacheStore(ctx).Set(ctx, staleCacheKey(key), data, staleTTL); err != nil {
+			slog.ErrorContext(ctx, "Ошибка при записи в кэш", "error", err)
+		}
+	}
+}
+
+// staleTTL возвращает время хранения копий ответов для отправки при отказе
+// сервиса: наибольшее из cache.stale_if_error и stale_ttl выключателей
+func (s *Server) staleTTL() time.Duration {
+	ttl := s.config.Cache.StaleIfError.Std()
+	if s.breakers != nil && s.breakers.staleTTL > ttl {
+		ttl = s.breakers.staleTTL
+	}
+	return ttl
+}
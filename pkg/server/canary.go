@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strings"
+
+	"apigw/pkg/config"
+)
+
+// Ключ контекста для хранения выбранных версий сервисов
+const canaryKey contextKey = "canary"
+
+// canaryBuckets - на сколько частей делятся клиенты; доля задается с точностью до 0.01%
+const canaryBuckets = 10000
+
+// canaryRule - правило канареечного выпуска
+type canaryRule struct {
+	path    string
+	method  string
+	service string
+	target  string
+	// buckets - сколько частей из canaryBuckets направляется в target
+	buckets uint32
+	// header - заголовок, по которому клиент закрепляется за версией
+	header string
+	// byIP - клиент закрепляется за версией по IP-адресу
+	byIP bool
+}
+
+// newCanaryRules проверяет секцию canary. Сервисы правил должны быть в services.
+func newCanaryRules(cfg config.CanaryConfig, services config.ServicesConfig) ([]canaryRule, error) {
+	rules := make([]canaryRule, 0, len(cfg.Routes))
+	for _, rc := range cfg.Routes {
+		if !strings.HasPrefix(rc.Path, "/") {
+			return nil, fmt.Errorf("путь правила canary должен начинаться с /: %q", rc.Path)
+		}
+		for _, name := range []string{rc.Service, rc.Target} {
+			if _, ok := services[name]; !ok {
+				return nil, fmt.Errorf("сервис %q правила canary %s отсутствует в секции services", name, rc.Path)
+			}
+		}
+		if rc.Service == rc.Target {
+			return nil, fmt.Errorf("в правиле canary %s service и target совпадают", rc.Path)
+		}
+		if rc.Percent < 0 || rc.Percent > 100 {
+			return nil, fmt.Errorf("некорректная доля запросов правила canary %s: %g", rc.Path, rc.Percent)
+		}
+
+		rule := canaryRule{
+			path:    rc.Path,
+			method:  strings.ToUpper(rc.Method),
+			service: rc.Service,
+			target:  rc.Target,
+			buckets: uint32(rc.Percent * canaryBuckets / 100),
+		}
+		switch header, isHeader := strings.CutPrefix(rc.Key, "header:"); {
+		case rc.Key == "":
+		case rc.Key == "ip":
+			rule.byIP = true
+		case isHeader && header != "":
+			rule.header = textproto.CanonicalMIMEHeaderKey(header)
+		default:
+			return nil, fmt.Errorf("неизвестный ключ правила canary %s: %q (допустимы ip и header:Имя)", rc.Path, rc.Key)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// choose сообщает, направить ли запрос в новую версию сервиса. Клиенты с
+// одним ключом всегда получают одну версию, пока не изменится доля правила.
+// Запросы без заголовка-ключа направляются в основную версию.
+func (c canaryRule) choose(r *http.Request) bool {
+	var key string
+	switch {
+	case c.byIP:
+		key = clientIP(r)
+	case c.header != "":
+		key = r.Header.Get(c.header)
+		if key == "" {
+			return false
+		}
+	default:
+		return uint32(rand.Intn(canaryBuckets)) < c.buckets
+	}
+
+	// Имя сервиса входит в хэш, чтобы доли разных сервисов не совпадали по клиентам
+	h := fnv.New32a()
+	h.Write([]byte(c.service))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return h.Sum32()%canaryBuckets < c.buckets
+}
+
+// canaryMiddleware выбирает версии сервисов для запроса по правилам секции
+// canary. Встроенные обработчики и маршруты с service обращаются к
+// выбранной версии, кэш ответов хранит ответы версий отдельно.
+func (s *Server) canaryMiddleware(next http.Handler) http.Handler {
+	if len(s.canaries) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var chosen map[string]string
+		decided := make(map[string]bool)
+		for _, rule := range s.canaries {
+			if decided[rule.service] || (rule.method != "" && rule.method != r.Method) || !pathMatches(rule.path, r.URL.Path) {
+				continue
+			}
+			decided[rule.service] = true
+			if !rule.choose(r) {
+				continue
+			}
+			if chosen == nil {
+				chosen = make(map[string]string)
+			}
+			chosen[rule.service] = rule.target
+		}
+		if chosen != nil {
+			r = r.WithContext(context.WithValue(r.Context(), canaryKey, chosen))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// canaryService возвращает имя версии сервиса name, выбранной для запроса
+func canaryService(ctx context.Context, name string) string {
+	chosen, _ := ctx.Value(canaryKey).(map[string]string)
+	if target, ok := chosen[name]; ok {
+		return target
+	}
+	return name
+}
+
+// canaryTargets возвращает выбранные для запроса новые версии сервисов
+// в порядке имен, например "news-v2"
+func canaryTargets(ctx context.Context) []string {
+	chosen, _ := ctx.Value(canaryKey).(map[string]string)
+	targets := make([]string, 0, len(chosen))
+	for _, target := range chosen {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	return targets
+}
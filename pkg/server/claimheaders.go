@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"apigw/pkg/config"
+)
+
+// claimHeaders - заголовки запросов к backend-сервисам со значениями
+// утверждений проверенного токена: имя заголовка -> утверждение
+type claimHeaders map[string]string
+
+// newClaimHeaders проверяет поле jwt.claim_headers
+func newClaimHeaders(cfg config.JWTConfig) (claimHeaders, error) {
+	if !cfg.Enabled || len(cfg.ClaimHeaders) == 0 {
+		return nil, nil
+	}
+	headers := make(claimHeaders, len(cfg.ClaimHeaders))
+	for name, claim := range cfg.ClaimHeaders {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return nil, fmt.Errorf("некорректное имя заголовка утверждения: %q", name)
+		}
+		if claim == "" {
+			return nil, fmt.Errorf("не указано утверждение для заголовка %s", name)
+		}
+		headers[http.CanonicalHeaderKey(name)] = claim
+	}
+	return headers, nil
+}
+
+// apply заменяет заголовки утверждений запроса req к backend-сервису
+// значениями из токена запроса клиента. Заголовки, переданные клиентом,
+// удаляются всегда, чтобы их нельзя было подделать.
+func (h claimHeaders) apply(req *http.Request) {
+	if len(h) == 0 {
+		return
+	}
+	claims := claimsFrom(req.Context())
+	for name, claim := range h {
+		req.Header.Del(name)
+		value, ok := claims.Value(claim)
+		if !ok {
+			continue
+		}
+		if s := claimHeaderValue(value); s != "" && !strings.ContainsAny(s, "\r\n") {
+			req.Header.Set(name, s)
+		}
+	}
+}
+
+// claimHeaderValue возвращает значение утверждения для заголовка: строку,
+// число, логическое значение или список таких значений через запятую
+func claimHeaderValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			if _, nested := item.([]interface{}); nested {
+				continue
+			}
+			if s := claimHeaderValue(item); s != "" {
+				items = append(items, s)
+			}
+		}
+		return strings.Join(items, ",")
+	}
+	return ""
+}
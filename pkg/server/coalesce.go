@@ -0,0 +1,191 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"apigw/pkg/config"
+	"apigw/pkg/metrics"
+)
+
+// requestCoalescer объединяет одновременные одинаковые запросы GET к
+// backend-сервисам: первый запрос отправляется сервису, остальные ждут его
+// ответа и получают копию. nil означает, что объединение отключено.
+type requestCoalescer struct {
+	maxBodySize int64
+
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+
+	shared *metrics.CounterVec
+}
+
+// coalescedCall - запрос к сервису, ответа на который ждут другие запросы
+type coalescedCall struct {
+	done chan struct{}
+
+	// Результат запроса; заполняется до закрытия done
+	status int
+	header http.Header
+	body   []byte
+	err    error
+	// unshared - ответ нельзя передать ожидающим (поток или слишком большой
+	// ответ), и они отправляют свои запросы
+	unshared bool
+	// canceled - запрос прерван своим клиентом, ожидающие объединяются заново
+	canceled bool
+}
+
+// newRequestCoalescer проверяет секцию coalescing. Возвращает nil, если
+// объединение отключено.
+func newRequestCoalescer(cfg config.CoalescingConfig, registry *metrics.Registry) (*requestCoalescer, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.MaxBodySize <= 0 {
+		return nil, fmt.Errorf("некорректный размер ответа coalescing.max_body_size: %d", cfg.MaxBodySize)
+	}
+	return &requestCoalescer{
+		maxBodySize: int64(cfg.MaxBodySize),
+		calls:       make(map[string]*coalescedCall),
+		shared: registry.Counter("apigw_coalesced_requests_total",
+			"Запросы к backend-сервисам, получившие ответ одновременного одинакового запроса", "upstream"),
+	}, nil
+}
+
+// do выполняет запрос через send или, если такой же запрос уже выполняется,
+// ждет его ответа. Каждый запрос получает свою копию тела ответа.
+func (c *requestCoalescer) do(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	if c == nil || req.Method != http.MethodGet || (req.Body != nil && req.Body != http.NoBody) {
+		return send(req)
+	}
+
+	key := coalesceKey(req)
+	c.mu.Lock()
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		return c.wait(req, call, send)
+	}
+	call := &coalescedCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	resp, err := send(req)
+	resp, err = call.finish(req, resp, err, c.maxBodySize)
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	c.mu.Unlock()
+	close(call.done)
+	return resp, err
+}
+
+// wait ждет ответа запроса call. Если ответ нельзя передать, запрос
+// отправляется сервису отдельно, а если запрос call прерван его клиентом -
+// объединяется с другими ожидавшими заново.
+func (c *requestCoalescer) wait(req *http.Request, call *coalescedCall, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	select {
+	case <-call.done:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	if call.canceled {
+		return c.do(req, send)
+	}
+	if call.unshared {
+		return send(req)
+	}
+
+	c.shared.Inc(upstreamName(req.URL))
+	if call.err != nil {
+		return nil, call.err
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", call.status, http.StatusText(call.status)),
+		StatusCode:    call.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        call.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(call.body)),
+		ContentLength: int64(len(call.body)),
+		Request:       req,
+	}, nil
+}
+
+// finish сохраняет результат запроса для ожидающих и возвращает ответ для
+// самого запроса. Тело ответа читается в память, если это не поток событий
+// и его размер не больше maxBodySize.
+func (call *coalescedCall) finish(req *http.Request, resp *http.Response, err error, maxBodySize int64) (*http.Response, error) {
+	if err != nil {
+		// Ошибка из-за отмены запроса клиентом не относится к остальным запросам
+		call.err = err
+		call.canceled = req.Context().Err() != nil
+		return nil, err
+	}
+	if resp.ContentLength > maxBodySize || isEventStream(resp.Header) {
+		call.unshared = true
+		return resp, nil
+	}
+
+	body, readErr := io.ReadAll(io.LimitReader(resp.Body, maxBodySize+1))
+	switch {
+	case readErr != nil:
+		resp.Body.Close()
+		call.err = readErr
+		call.canceled = req.Context().Err() != nil
+		return nil, readErr
+	case int64(len(body)) > maxBodySize:
+		call.unshared = true
+		resp.Body = readCloser{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	call.status = resp.StatusCode
+	call.header = resp.Header.Clone()
+	call.body = body
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	return resp, nil
+}
+
+// readCloser читает из Reader и закрывает Closer
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// coalesceKey возвращает ключ запроса: одинаковыми считаются запросы с
+// одним адресом и заголовками. request_id в параметрах и заголовке не
+// учитывается, он у каждого запроса свой.
+func coalesceKey(req *http.Request) string {
+	u := *req.URL
+	if query := u.Query(); query.Has("request_id") {
+		query.Del("request_id")
+		u.RawQuery = query.Encode()
+	}
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		if name != http.CanonicalHeaderKey(requestIDHeaderName) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString(u.String())
+	for _, name := range names {
+		sb.WriteString("\n")
+		sb.WriteString(name)
+		sb.WriteString(": ")
+		sb.WriteString(strings.Join(req.Header[name], ", "))
+	}
+	return sb.String()
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"apigw/pkg/config"
+)
+
+// gzipWriters - пулы gzip.Writer по уровням сжатия: создание writer'а
+// выделяет сотни килобайт, поэтому они используются повторно
+var gzipWriters [gzip.BestCompression + 1]sync.Pool
+
+// compressionRule - правило сжатия для типа содержимого
+type compressionRule struct {
+	level   int
+	minSize int
+}
+
+// compressor сжимает ответы согласно секции compression
+type compressor struct {
+	rules map[string]compressionRule
+}
+
+// newCompressor проверяет настройки сжатия. Возвращает nil, если сжатие отключено.
+func newCompressor(cfg config.CompressionConfig) (*compressor, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	c := &compressor{rules: make(map[string]compressionRule)}
+	for _, t := range cfg.Types {
+		rule := compressionRule{level: cfg.Level, minSize: cfg.MinSize}
+		if t.Level != 0 {
+			rule.level = t.Level
+		}
+		if t.MinSize != 0 {
+			rule.minSize = t.MinSize
+		}
+		if rule.level < gzip.BestSpeed || rule.level > gzip.BestCompression {
+			return nil, fmt.Errorf("уровень сжатия для %s должен быть от %d до %d: %d", t.ContentType, gzip.BestSpeed, gzip.BestCompression, rule.level)
+		}
+		c.rules[strings.ToLower(t.ContentType)] = rule
+	}
+	return c, nil
+}
+
+// ruleFor возвращает правило сжатия для типа содержимого ответа
+func (c *compressor) ruleFor(contentType string) (compressionRule, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return compressionRule{}, false
+	}
+	rule, ok := c.rules[mediaType]
+	return rule, ok
+}
+
+// acceptsGzip проверяет, что клиент принимает ответы в gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		q := strings.TrimSpace(params)
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
+
+// compressionMiddleware сжимает ответы, тип и размер которых подходят под
+// правила из конфигурации. Запросы Range, HEAD и WebSocket не сжимаются.
+func (s *Server) compressionMiddleware(next http.Handler) http.Handler {
+	if s.compressor == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		if !acceptsGzip(r) || r.Method == http.MethodHead || r.Header.Get("Range") != "" || isWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, compressor: s.compressor}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// isWebSocketUpgrade проверяет, что запрос открывает WebSocket соединение
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// compressWriter откладывает отправку заголовков, пока не станет ясно,
+// нужно ли сжимать ответ: до min_size байт тело накапливается в буфере
+type compressWriter struct {
+	http.ResponseWriter
+	compressor *compressor
+
+	status int
+	rule   compressionRule
+	// buffering - ответ подходит для сжатия, начало тела копится в pending
+	buffering bool
+	decided   bool
+	pending   []byte
+	gz        *gzip.Writer
+}
+
+// WriteHeader запоминает статус; заголовки отправляются после решения о сжатии
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.status != 0 || cw.decided {
+		return
+	}
+	cw.status = code
+
+	// Ответы без тела отправляем сразу
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		cw.passthrough()
+	}
+}
+
+// Write накапливает начало ответа до min_size байт, затем включает сжатие
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if !cw.decided {
+		if !cw.buffering && !cw.eligible(p) {
+			cw.passthrough()
+		} else {
+			cw.buffering = true
+			cw.pending = append(cw.pending, p...)
+			if len(cw.pending) < cw.rule.minSize {
+				return len(p), nil
+			}
+			return len(p), cw.startGzip()
+		}
+	}
+
+	if cw.gz != nil {
+		return cw.gz.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// eligible проверяет тип, размер и кодирование ответа
+func (cw *compressWriter) eligible(p []byte) bool {
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	if h.Get("Content-Type") == "" {
+		// Определяем тип так же, как это сделал бы net/http при первой записи
+		h.Set("Content-Type", http.DetectContentType(p))
+	}
+
+	rule, ok := cw.compressor.ruleFor(h.Get("Content-Type"))
+	if !ok {
+		return false
+	}
+	if length, err := strconv.Atoi(h.Get("Content-Length")); err == nil && length < rule.minSize {
+		return false
+	}
+	cw.rule = rule
+	return true
+}
+
+// passthrough отправляет ответ без сжатия
+func (cw *compressWriter) passthrough() {
+	cw.decided = true
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if len(cw.pending) > 0 {
+		cw.ResponseWriter.Write(cw.pending)
+		cw.pending = nil
+	}
+}
+
+// startGzip отправляет заголовки сжатого ответа и накопленное начало тела
+func (cw *compressWriter) startGzip() error {
+	cw.decided = true
+
+	h := cw.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", "gzip")
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	gz, ok := gzipWriters[cw.rule.level].Get().(*gzip.Writer)
+	if ok {
+		gz.Reset(cw.ResponseWriter)
+	} else {
+		gz, _ = gzip.NewWriterLevel(cw.ResponseWriter, cw.rule.level)
+	}
+	cw.gz = gz
+
+	_, err := gz.Write(cw.pending)
+	cw.pending = nil
+	return err
+}
+
+// Flush отправляет накопленные данные клиенту. Если к этому моменту ответ
+// не набрал min_size байт, он передается без сжатия.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.passthrough()
+	}
+	if cw.gz != nil {
+		cw.gz.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// close завершает ответ: дописывает короткий ответ без сжатия или закрывает поток gzip
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if cw.status == 0 && len(cw.pending) == 0 {
+			// Обработчик ничего не записал - net/http сам отправит 200
+			return
+		}
+		cw.passthrough()
+		return
+	}
+	if cw.gz != nil {
+		cw.gz.Close()
+		cw.gz.Reset(io.Discard)
+		gzipWriters[cw.rule.level].Put(cw.gz)
+		cw.gz = nil
+	}
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"apigw/pkg/config"
+)
+
+// corsPolicy - политика CORS пути с подготовленными значениями заголовков
+type corsPolicy struct {
+	path string
+	// anyOrigin - разрешен любой источник ("*")
+	anyOrigin   bool
+	origins     map[string]bool
+	methods     string
+	headers     string
+	exposed     string
+	credentials bool
+	maxAge      string
+}
+
+// corsPolicies - политики CORS маршрутов и общая политика. nil означает, что CORS отключен.
+type corsPolicies struct {
+	routes   []*corsPolicy
+	fallback *corsPolicy
+}
+
+// newCORSPolicies проверяет секцию cors
+func newCORSPolicies(cfg config.CORSConfig) (*corsPolicies, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	fallback, err := newCORSPolicy("", cfg.CORSPolicyConfig)
+	if err != nil {
+		return nil, err
+	}
+	c := &corsPolicies{fallback: fallback}
+	for _, rc := range cfg.Routes {
+		if !strings.HasPrefix(rc.Path, "/") {
+			return nil, fmt.Errorf("путь политики CORS должен начинаться с /: %q", rc.Path)
+		}
+		policy, err := newCORSPolicy(rc.Path, rc.CORSPolicyConfig.WithDefaults(cfg.CORSPolicyConfig))
+		if err != nil {
+			return nil, fmt.Errorf("политика CORS %s: %w", rc.Path, err)
+		}
+		c.routes = append(c.routes, policy)
+	}
+	return c, nil
+}
+
+// newCORSPolicy проверяет политику и готовит значения заголовков
+func newCORSPolicy(path string, cfg config.CORSPolicyConfig) (*corsPolicy, error) {
+	p := &corsPolicy{
+		path:        path,
+		origins:     make(map[string]bool),
+		headers:     strings.Join(cfg.AllowedHeaders, ", "),
+		exposed:     strings.Join(cfg.ExposedHeaders, ", "),
+		credentials: cfg.AllowCredentials,
+		maxAge:      strconv.Itoa(int(cfg.MaxAge.Std().Seconds())),
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			p.anyOrigin = true
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return nil, fmt.Errorf("некорректный источник в allowed_origins: %q", origin)
+		}
+		p.origins[strings.ToLower(u.Scheme+"://"+u.Host)] = true
+	}
+	if p.anyOrigin && p.credentials {
+		return nil, fmt.Errorf("allow_credentials нельзя использовать с allowed_origins \"*\"")
+	}
+
+	methods := make([]string, 0, len(cfg.AllowedMethods))
+	for _, method := range cfg.AllowedMethods {
+		methods = append(methods, strings.ToUpper(method))
+	}
+	p.methods = strings.Join(methods, ", ")
+	return p, nil
+}
+
+// policy возвращает политику для пути запроса
+func (c *corsPolicies) policy(path string) *corsPolicy {
+	for _, p := range c.routes {
+		if pathMatches(p.path, path) {
+			return p
+		}
+	}
+	return c.fallback
+}
+
+// allows проверяет, что запросы с источника origin разрешены
+func (p *corsPolicy) allows(origin string) bool {
+	return p.anyOrigin || p.origins[strings.ToLower(origin)]
+}
+
+// allowOrigin добавляет к ответу заголовки разрешенного источника
+func (p *corsPolicy) allowOrigin(h http.Header, origin string) {
+	if p.anyOrigin {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// corsMiddleware добавляет заголовки CORS к ответам на запросы из браузера
+// и сам отвечает на предварительные запросы OPTIONS, не передавая их дальше:
+// браузер не прикладывает к ним ключ API и токен
+func (s *Server) corsMiddleware(next http.Handler) http.Handler {
+	if s.cors == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		policy := s.cors.policy(r.URL.Path)
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		h := w.Header()
+		if !policy.anyOrigin {
+			h.Add("Vary", "Origin")
+		}
+
+		if !policy.allows(origin) {
+			if preflight {
+				writeError(w, r, http.StatusForbidden, codeForStatus(http.StatusForbidden), "Источник запроса не разрешен политикой CORS")
+				return
+			}
+			// Ответ отдается без заголовков CORS, браузер не передаст его странице
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		policy.allowOrigin(h, origin)
+		if !preflight {
+			if policy.exposed != "" {
+				h.Set("Access-Control-Expose-Headers", policy.exposed)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		if policy.methods != "" {
+			h.Set("Access-Control-Allow-Methods", policy.methods)
+		}
+		if policy.headers != "" {
+			h.Set("Access-Control-Allow-Headers", policy.headers)
+		}
+		h.Set("Access-Control-Max-Age", policy.maxAge)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,281 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"apigw/pkg/config"
+)
+
+// CursorResponse - страница списка при пагинации курсорами (?cursor=...&limit=...)
+type CursorResponse struct {
+	Items interface{} `json:"items"`
+	Limit int         `json:"limit"`
+	// NextCursor и PrevCursor - курсоры следующей и предыдущей страниц;
+	// отсутствуют на последней и первой странице
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	// Links - адреса соседних страниц; передаются в теле, а не в заголовке
+	// Link, чтобы сохраняться в кэше ответов
+	Links *CursorLinks `json:"links,omitempty"`
+}
+
+// CursorLinks - адреса следующей и предыдущей страниц списка
+type CursorLinks struct {
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// newsCursor - положение страницы в списке новостей: новость, после
+// (или перед) которой начинается страница, и ее позиция на момент выдачи
+// курсора. Позиция используется, если новость удалили из списка.
+type newsCursor struct {
+	After  *int64 `json:"a,omitempty"`
+	Before *int64 `json:"b,omitempty"`
+	Offset int    `json:"o"`
+}
+
+// encodeNewsCursor возвращает непрозрачное значение курсора
+func encodeNewsCursor(c newsCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeNewsCursor разбирает значение курсора
+func decodeNewsCursor(value string) (newsCursor, error) {
+	var c newsCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, err
+	}
+	if (c.After == nil) == (c.Before == nil) || c.Offset < 0 {
+		return c, fmt.Errorf("курсор должен содержать одну новость и неотрицательную позицию")
+	}
+	return c, nil
+}
+
+// cursorItem - новость списка с ее позицией среди подходящих поиску
+type cursorItem struct {
+	news upstreamNews
+	pos  int
+}
+
+// cursorWindow выбирает страницу из списка новостей, просматриваемого по
+// одной новости, и сообщает, когда остаток списка можно не читать
+type cursorWindow struct {
+	cursor *newsCursor
+	limit  int
+
+	pos   int
+	found bool
+	// items - новости страницы и одна следующая (или предыдущая), чтобы
+	// узнать, есть ли следующая (предыдущая) страница
+	items []cursorItem
+	// fallback - новости страницы по позиции из курсора
+	fallback []cursorItem
+}
+
+// add учитывает очередную новость списка и возвращает true, когда страница собрана
+func (cw *cursorWindow) add(item upstreamNews) bool {
+	entry := cursorItem{news: item, pos: cw.pos}
+	cw.pos++
+
+	switch {
+	case cw.cursor == nil:
+		cw.items = append(cw.items, entry)
+		return len(cw.items) > cw.limit
+	case cw.cursor.After != nil:
+		if cw.found {
+			cw.items = append(cw.items, entry)
+			return len(cw.items) > cw.limit
+		}
+		if *item.ID == *cw.cursor.After {
+			cw.found = true
+			return false
+		}
+		if entry.pos >= cw.cursor.Offset && len(cw.fallback) <= cw.limit {
+			cw.fallback = append(cw.fallback, entry)
+		}
+	default:
+		if *item.ID == *cw.cursor.Before {
+			cw.found = true
+			return true
+		}
+		cw.items = appendLast(cw.items, entry, cw.limit+1)
+		if entry.pos < cw.cursor.Offset {
+			cw.fallback = appendLast(cw.fallback, entry, cw.limit+1)
+		}
+	}
+	return false
+}
+
+// appendLast добавляет элемент, оставляя не больше n последних
+func appendLast(items []cursorItem, item cursorItem, n int) []cursorItem {
+	items = append(items, item)
+	if len(items) > n {
+		items = items[len(items)-n:]
+	}
+	return items
+}
+
+// page возвращает новости страницы и курсоры соседних страниц
+func (cw *cursorWindow) page() ([]upstreamNews, string, string) {
+	items := cw.items
+	if cw.cursor != nil && !cw.found {
+		// Новость курсора удалили: страница выбирается по позиции
+		items = cw.fallback
+	}
+
+	backward := cw.cursor != nil && cw.cursor.Before != nil
+	hasMore := len(items) > cw.limit
+	if hasMore {
+		if backward {
+			items = items[1:]
+		} else {
+			items = items[:cw.limit]
+		}
+	}
+	if len(items) == 0 {
+		return nil, "", ""
+	}
+
+	first, last := items[0], items[len(items)-1]
+	var next, prev string
+	if (!backward && hasMore) || (backward && (cw.found || last.pos+1 < cw.pos)) {
+		next = encodeNewsCursor(newsCursor{After: last.news.ID, Offset: last.pos + 1})
+	}
+	if (backward && hasMore) || (!backward && first.pos > 0) {
+		prev = encodeNewsCursor(newsCursor{Before: first.news.ID, Offset: first.pos})
+	}
+
+	news := make([]upstreamNews, len(items))
+	for i, item := range items {
+		news[i] = item.news
+	}
+	return news, next, prev
+}
+
+// scanNewsList читает массив новостей по одному элементу и передает add
+// новости, подходящие filter, пока add не вернет true
+func scanNewsList(body io.Reader, filter newsFilter, add func(upstreamNews) bool) error {
+	decoder := json.NewDecoder(body)
+
+	token, err := decoder.Token()
+	if err == io.EOF || (err == nil && token == nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("ожидается массив новостей")
+	}
+
+	for decoder.More() {
+		var item upstreamNews
+		if err := decoder.Decode(&item); err != nil {
+			return err
+		}
+		if item.ID == nil || !filter.match(item) {
+			continue
+		}
+		if add(item) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// cursorPageURL возвращает адрес страницы с курсором cursor
+func cursorPageURL(requestURL *url.URL, cursor string) string {
+	u := *requestURL
+	query := u.Query()
+	query.Del("request_id")
+	query.Del("page")
+	query.Del("count")
+	query.Set("cursor", cursor)
+	u.RawQuery = query.Encode()
+	return u.RequestURI()
+}
+
+// serveNewsCursor отвечает страницей списка новостей при пагинации курсорами.
+// Курсор указывает на новость, после (перед) которой начинается страница,
+// поэтому новости, добавленные в начало списка между запросами, не сдвигают
+// страницы и не повторяются. Список запрашивается у сервиса целиком и
+// читается только до конца страницы; при сортировке шлюзом (order) список
+// читается полностью.
+func (s *Server) serveNewsCursor(w http.ResponseWriter, r *http.Request, filter newsFilter, order newsSort, convert func([]upstreamNews) interface{}) {
+	query := r.URL.Query()
+	window := &cursorWindow{limit: positiveParam(query.Get("limit"), defaultCount)}
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := decodeNewsCursor(value)
+		if err != nil {
+			slog.DebugContext(r.Context(), "Некорректный курсор", "cursor", value, "error", err)
+			writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "Некорректный курсор")
+			return
+		}
+		window.cursor = &cursor
+	}
+
+	pageResponse := func() CursorResponse {
+		news, next, prev := window.page()
+		filter.annotate(news)
+		response := CursorResponse{Items: convert(news), Limit: window.limit, NextCursor: next, PrevCursor: prev}
+		if next != "" || prev != "" {
+			response.Links = &CursorLinks{}
+			if next != "" {
+				response.Links.Next = cursorPageURL(r.URL, next)
+			}
+			if prev != "" {
+				response.Links.Prev = cursorPageURL(r.URL, prev)
+			}
+		}
+		return response
+	}
+
+	sortInGateway := order.field != "" && !s.newsSorting(r.Context())
+	listOrder := order
+	if sortInGateway {
+		listOrder = newsSort{}
+	}
+
+	s.proxyUpstream(w, r, upstreamCall{
+		target:  s.newsListURL(r.Context(), filter, listOrder),
+		message: "Не удалось получить новости",
+		response: func(resp *http.Response) error {
+			if resp.StatusCode >= http.StatusInternalServerError {
+				slog.WarnContext(r.Context(), "Сервис новостей вернул ошибку", "backend", config.ServiceNews, "status", resp.StatusCode)
+				return upstreamStatusError(resp.StatusCode, "Ошибка при получении новостей")
+			}
+			if resp.StatusCode == http.StatusOK {
+				add := window.add
+				var all []upstreamNews
+				if sortInGateway {
+					add = func(item upstreamNews) bool {
+						all = append(all, item)
+						return false
+					}
+				}
+				if err := scanNewsList(resp.Body, filter, add); err != nil {
+					slog.ErrorContext(r.Context(), "Ошибка при декодировании новостей", "backend", config.ServiceNews, "error", err)
+					return &responseError{http.StatusBadGateway, codeUpstreamError, "Ошибка при обработке новостей"}
+				}
+				s.sortNews(all, order)
+				for _, item := range all {
+					if window.add(item) {
+						break
+					}
+				}
+			}
+			return replaceJSON(resp, http.StatusOK, pageResponse())
+		},
+	})
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	// База часовых поясов встроена в бинарный файл: в контейнерах ее может не быть
+	_ "time/tzdata"
+
+	"apigw/pkg/config"
+)
+
+// dateFields - поля ответа с датами, которые приводятся к часовому поясу клиента
+var dateFields = []string{"pub_date", "created_at"}
+
+// zonedDateLayouts - форматы дат с указанием часового пояса
+var zonedDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02T15:04:05Z0700",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC822Z,
+	time.RFC822,
+}
+
+// localDateLayouts - форматы дат без часового пояса, они считаются датами в
+// поясе dates.source_timezone
+var localDateLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+	"02.01.2006",
+}
+
+// locations - загруженные часовые пояса по имени
+var locations sync.Map
+
+// loadLocation возвращает часовой пояс по имени из базы IANA, например Europe/Moscow
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// dateLocalizer приводит даты в ответах к часовому поясу клиента
+type dateLocalizer struct {
+	source *time.Location
+	// languages - часовые пояса по языку в нижнем регистре
+	languages map[string]*time.Location
+}
+
+// newDateLocalizer проверяет настройки секции dates
+func newDateLocalizer(cfg config.DatesConfig) (*dateLocalizer, error) {
+	source, err := loadLocation(cfg.SourceTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный часовой пояс dates.source_timezone %q: %w", cfg.SourceTimezone, err)
+	}
+
+	d := &dateLocalizer{source: source, languages: make(map[string]*time.Location)}
+	for language, name := range cfg.Languages {
+		loc, err := loadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный часовой пояс для языка %s: %q", language, name)
+		}
+		d.languages[strings.ToLower(language)] = loc
+	}
+	return d, nil
+}
+
+// locationFor возвращает часовой пояс, запрошенный клиентом: из параметра tz
+// или по языку из Accept-Language. nil - клиент не запросил пояс, даты
+// передаются как есть.
+func (d *dateLocalizer) locationFor(r *http.Request) (*time.Location, error) {
+	if name := r.URL.Query().Get("tz"); name != "" {
+		return loadLocation(name)
+	}
+	if len(d.languages) == 0 {
+		return nil, nil
+	}
+
+	for _, language := range acceptedLanguages(r.Header.Get("Accept-Language")) {
+		if loc := d.languages[language]; loc != nil {
+			return loc, nil
+		}
+		if base, _, found := strings.Cut(language, "-"); found {
+			if loc := d.languages[base]; loc != nil {
+				return loc, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// acceptedLanguages возвращает языки из заголовка Accept-Language в нижнем
+// регистре в порядке убывания веса q
+func acceptedLanguages(header string) []string {
+	type weighted struct {
+		language string
+		q        float64
+	}
+
+	var languages []weighted
+	for _, part := range strings.Split(header, ",") {
+		language, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		language = strings.ToLower(strings.TrimSpace(language))
+		if language == "" || language == "*" {
+			continue
+		}
+		q := 1.0
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			languages = append(languages, weighted{language, q})
+		}
+	}
+	sort.SliceStable(languages, func(i, j int) bool { return languages[i].q > languages[j].q })
+
+	result := make([]string, len(languages))
+	for i, l := range languages {
+		result[i] = l.language
+	}
+	return result
+}
+
+// parseDate разбирает дату в одном из форматов backend-сервисов
+func (d *dateLocalizer) parseDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range zonedDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	for _, layout := range localDateLayouts {
+		if t, err := time.ParseInLocation(layout, value, d.source); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// localize приводит даты в JSON ответе к RFC3339 в поясе loc. Исходное
+// значение сохраняется в поле с суффиксом _raw, например pub_date_raw.
+// Нераспознанные даты не изменяются.
+func (d *dateLocalizer) localize(body []byte, loc *time.Location) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	d.localizeValue(value, loc)
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// localizeValue приводит даты во вложенных объектах и массивах value
+func (d *dateLocalizer) localizeValue(value interface{}, loc *time.Location) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, field := range dateFields {
+			raw, ok := v[field].(string)
+			if !ok {
+				continue
+			}
+			if t, ok := d.parseDate(raw); ok {
+				v[field] = t.In(loc).Format(time.RFC3339)
+				v[field+"_raw"] = raw
+			}
+		}
+		for _, item := range v {
+			d.localizeValue(item, loc)
+		}
+	case []interface{}:
+		for _, item := range v {
+			d.localizeValue(item, loc)
+		}
+	}
+}
+
+// datesMiddleware приводит даты в успешных JSON ответах к часовому поясу,
+// который запросил клиент. Middleware располагается снаружи кэша, поэтому в
+// кэше хранятся даты в том виде, в каком их вернули backend-сервисы.
+func (s *Server) datesMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(s.dates.languages) > 0 {
+			w.Header().Add("Vary", "Accept-Language")
+		}
+
+		loc, err := s.dates.locationFor(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("Неизвестный часовой пояс: %s", r.URL.Query().Get("tz")))
+			return
+		}
+		if loc == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rw := s.newSpillWriter(w)
+		next.ServeHTTP(rw, r)
+		if rw.spilled {
+			slog.DebugContext(r.Context(), "Ответ больше streaming.max_buffer, даты не приведены к поясу", "timezone", loc.String())
+			return
+		}
+
+		for name, values := range rw.header {
+			w.Header()[name] = values
+		}
+
+		body := rw.body.Bytes()
+		mediaType, _, _ := mime.ParseMediaType(rw.header.Get("Content-Type"))
+		if rw.status == http.StatusOK && mediaType == "application/json" {
+			if localized, err := s.dates.localize(body, loc); err == nil {
+				w.Header().Del("Content-Length")
+				body = localized
+			} else {
+				slog.WarnContext(r.Context(), "Не удалось привести даты ответа к поясу", "timezone", loc.String(), "error", err)
+			}
+		}
+
+		w.WriteHeader(rw.status)
+		w.Write(body)
+	})
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"apigw/pkg/config"
+)
+
+// deprecationPolicy - заголовки устаревания версии API, маршрута или параметра
+type deprecationPolicy struct {
+	// name - имя в метрике apigw_deprecated_requests_total
+	name        string
+	deprecation string
+	sunset      string
+	link        string
+}
+
+// newDeprecationPolicy проверяет даты устаревания и готовит значения заголовков
+func newDeprecationPolicy(name, deprecatedAt, sunset, link string) (*deprecationPolicy, error) {
+	policy := &deprecationPolicy{name: name, link: link}
+	if deprecatedAt != "" {
+		t, err := parseDeprecationDate(deprecatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("некорректная дата deprecated_at: %w", err)
+		}
+		// Формат RFC 9745: @<unix time>
+		policy.deprecation = "@" + strconv.FormatInt(t.Unix(), 10)
+	}
+	if sunset != "" {
+		t, err := parseDeprecationDate(sunset)
+		if err != nil {
+			return nil, fmt.Errorf("некорректная дата sunset: %w", err)
+		}
+		policy.sunset = t.UTC().Format(http.TimeFormat)
+	}
+	return policy, nil
+}
+
+// parseDeprecationDate разбирает дату в формате RFC 3339 или ГГГГ-ММ-ДД
+func parseDeprecationDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// apply добавляет заголовки устаревания к ответу
+func (p *deprecationPolicy) apply(h http.Header) {
+	if p.deprecation != "" {
+		h.Set("Deprecation", p.deprecation)
+	}
+	if p.sunset != "" {
+		h.Set("Sunset", p.sunset)
+	}
+	if p.link != "" {
+		h.Add("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", p.link))
+	}
+}
+
+// deprecationRule - устаревший маршрут или параметр из секции deprecations
+type deprecationRule struct {
+	path   string
+	method string
+	param  string
+	policy *deprecationPolicy
+}
+
+// matches проверяет, что запрос относится к устаревшему маршруту или параметру
+func (d *deprecationRule) matches(r *http.Request) bool {
+	if d.method != "" && d.method != r.Method {
+		return false
+	}
+	if !pathMatches(d.path, r.URL.Path) {
+		return false
+	}
+	return d.param == "" || r.URL.Query().Has(d.param)
+}
+
+// pathMatches проверяет, что путь запроса совпадает с pattern. Шаблон,
+// оканчивающийся на /, задает префикс, как в http.ServeMux.
+func pathMatches(pattern, path string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(path, pattern)
+	}
+	return path == pattern
+}
+
+// newDeprecationRules проверяет секцию deprecations
+func newDeprecationRules(cfg []config.DeprecationConfig) ([]*deprecationRule, error) {
+	var rules []*deprecationRule
+	for _, dc := range cfg {
+		if !strings.HasPrefix(dc.Path, "/") {
+			return nil, fmt.Errorf("путь устаревшего маршрута должен начинаться с /: %q", dc.Path)
+		}
+		if dc.DeprecatedAt == "" && dc.Sunset == "" {
+			return nil, fmt.Errorf("для устаревшего маршрута %s не указаны deprecated_at и sunset", dc.Path)
+		}
+
+		name := dc.Name
+		if name == "" {
+			name = dc.Path
+			if dc.Param != "" {
+				name += "?" + dc.Param
+			}
+		}
+		policy, err := newDeprecationPolicy(name, dc.DeprecatedAt, dc.Sunset, dc.Link)
+		if err != nil {
+			return nil, fmt.Errorf("устаревший маршрут %s: %w", name, err)
+		}
+		rules = append(rules, &deprecationRule{
+			path:   dc.Path,
+			method: strings.ToUpper(dc.Method),
+			param:  dc.Param,
+			policy: policy,
+		})
+	}
+	return rules, nil
+}
+
+// deprecate добавляет к ответу заголовки устаревания и учитывает запрос в метрике
+func (s *Server) deprecate(h http.Header, policy *deprecationPolicy) {
+	policy.apply(h)
+	if policy.deprecation == "" && policy.sunset == "" {
+		return
+	}
+	s.shared.metrics.Counter("apigw_deprecated_requests_total",
+		"Запросы к устаревшим версиям API, маршрутам и параметрам", "name").Inc(policy.name)
+}
+
+// deprecationMiddleware отмечает ответы на запросы к устаревшим маршрутам и
+// параметрам из секции deprecations. Учитывается первое подходящее правило.
+func (s *Server) deprecationMiddleware(next http.Handler) http.Handler {
+	if len(s.deprecations) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, rule := range s.deprecations {
+			if rule.matches(r) {
+				s.deprecate(w.Header(), rule.policy)
+				break
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
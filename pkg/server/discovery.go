@@ -0,0 +1,227 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"apigw/pkg/config"
+)
+
+// defaultDiscoveryInterval - интервал повторного запроса SRV записи по умолчанию
+const defaultDiscoveryInterval = 30 * time.Second
+
+// discoveryLookupTimeout ограничивает время запроса SRV записи
+const discoveryLookupTimeout = 5 * time.Second
+
+// srvTransport отправляет запросы к сервису на экземпляры из DNS SRV записи.
+// Экземпляр выбирается для каждого запроса среди записей с наименьшим
+// приоритетом пропорционально их весу. Адреса запрашиваются при первом
+// запросе к сервису, затем обновляются в фоне, когда с прошлого запроса
+// записи прошло больше interval. Если запросить запись не удалось,
+// используются прежние адреса.
+type srvTransport struct {
+	upstream string
+	record   string
+	interval time.Duration
+	resolver *net.Resolver
+	next     *http.Transport
+
+	mu        sync.Mutex
+	targets   []*net.SRV
+	resolved  time.Time
+	resolving bool
+}
+
+// newSRVTransport проверяет секцию discovery сервиса с адресом base и
+// создает транспорт поверх next
+func newSRVTransport(base *url.URL, cfg *config.DiscoveryConfig, next *http.Transport) (*srvTransport, error) {
+	if cfg.SRV == "" {
+		return nil, errors.New("не задано имя SRV записи discovery.srv")
+	}
+	if cfg.Interval < 0 {
+		return nil, fmt.Errorf("некорректный интервал discovery.interval: %s", cfg.Interval.Std())
+	}
+	interval := cfg.Interval.Std()
+	if interval == 0 {
+		interval = defaultDiscoveryInterval
+	}
+
+	resolver := net.DefaultResolver
+	if cfg.Server != "" {
+		if _, _, err := net.SplitHostPort(cfg.Server); err != nil {
+			return nil, fmt.Errorf("некорректный адрес DNS сервера discovery.server: %q", cfg.Server)
+		}
+		dialer := &net.Dialer{Timeout: discoveryLookupTimeout}
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, cfg.Server)
+			},
+		}
+	}
+
+	return &srvTransport{
+		upstream: upstreamName(base),
+		record:   cfg.SRV,
+		interval: interval,
+		resolver: resolver,
+		next:     next,
+	}, nil
+}
+
+// RoundTrip отправляет запрос на один из экземпляров сервиса. Заголовок Host
+// остается именем сервиса из его адреса.
+func (t *srvTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	targets, err := t.endpoints(req.Context())
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	target := pickSRV(targets)
+
+	u := *req.URL
+	u.Host = srvAddr(target)
+	out := req.WithContext(req.Context())
+	out.URL = &u
+	if out.Host == "" {
+		out.Host = req.URL.Host
+	}
+	return t.next.RoundTrip(out)
+}
+
+// CloseIdleConnections закрывает простаивающие соединения с экземплярами сервиса
+func (t *srvTransport) CloseIdleConnections() {
+	t.next.CloseIdleConnections()
+}
+
+// endpoints возвращает экземпляры сервиса. Первый раз запись запрашивается
+// синхронно, устаревшие адреса обновляются в фоне.
+func (t *srvTransport) endpoints(ctx context.Context) ([]*net.SRV, error) {
+	t.mu.Lock()
+	targets := t.targets
+	refresh := targets != nil && !t.resolving && time.Since(t.resolved) >= t.interval
+	if refresh {
+		t.resolving = true
+	}
+	t.mu.Unlock()
+
+	if refresh {
+		go t.resolve(context.Background())
+	}
+	if targets != nil {
+		return targets, nil
+	}
+	return t.resolve(ctx)
+}
+
+// resolve запрашивает SRV запись и сохраняет полученные адреса
+func (t *srvTransport) resolve(ctx context.Context) ([]*net.SRV, error) {
+	ctx, cancel := context.WithTimeout(ctx, discoveryLookupTimeout)
+	defer cancel()
+
+	_, records, err := t.resolver.LookupSRV(ctx, "", "", t.record)
+	if err == nil && len(records) == 0 {
+		err = errors.New("запись не содержит адресов")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.resolving = false
+
+	if err != nil {
+		// Повторный запрос - через interval, до этого работают прежние адреса
+		t.resolved = time.Now()
+		slog.Warn("Не удалось получить адреса сервиса из DNS SRV", "upstream", t.upstream, "srv", t.record, "error", err)
+		if t.targets == nil {
+			return nil, fmt.Errorf("не удалось получить адреса сервиса %s из DNS SRV %s: %w", t.upstream, t.record, err)
+		}
+		return t.targets, nil
+	}
+
+	records = lowestPriority(records)
+	changed := !sameSRV(t.targets, records)
+	t.targets = records
+	t.resolved = time.Now()
+
+	if changed {
+		slog.Info("Адреса сервиса обновлены из DNS SRV", "upstream", t.upstream, "srv", t.record, "targets", srvAddrs(records))
+		// Соединения с удаленными экземплярами не должны оставаться в пуле
+		t.next.CloseIdleConnections()
+	}
+	return records, nil
+}
+
+// lowestPriority оставляет записи с наименьшим приоритетом, отсортированные по адресу
+func lowestPriority(records []*net.SRV) []*net.SRV {
+	result := make([]*net.SRV, 0, len(records))
+	for _, r := range records {
+		switch {
+		case len(result) == 0 || r.Priority == result[0].Priority:
+			result = append(result, r)
+		case r.Priority < result[0].Priority:
+			result = append(result[:0], r)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return srvAddr(result[i]) < srvAddr(result[j]) })
+	return result
+}
+
+// sameSRV сравнивает наборы записей
+func sameSRV(a, b []*net.SRV) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if *a[i] != *b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// srvAddrs возвращает адреса host:port записей
+func srvAddrs(records []*net.SRV) []string {
+	addrs := make([]string, len(records))
+	for i, r := range records {
+		addrs[i] = srvAddr(r)
+	}
+	return addrs
+}
+
+// srvAddr возвращает адрес host:port записи
+func srvAddr(r *net.SRV) string {
+	return net.JoinHostPort(strings.TrimSuffix(r.Target, "."), strconv.Itoa(int(r.Port)))
+}
+
+// pickSRV выбирает запись пропорционально весу; если у всех записей нулевой
+// вес, запись выбирается равновероятно
+func pickSRV(records []*net.SRV) *net.SRV {
+	total := 0
+	for _, r := range records {
+		total += int(r.Weight)
+	}
+	if total == 0 {
+		return records[rand.Intn(len(records))]
+	}
+	n := rand.Intn(total)
+	for _, r := range records {
+		n -= int(r.Weight)
+		if n < 0 {
+			return r
+		}
+	}
+	return records[len(records)-1]
+}
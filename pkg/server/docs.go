@@ -0,0 +1,58 @@
+package server
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+//go:embed assets/docs.html
+var docsPage string
+
+var docsTemplate = template.Must(template.New("docs").Parse(docsPage))
+
+// handleDocs отдает страницу Swagger UI, построенную по /openapi.json
+func (s *Server) handleDocs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Метод не разрешен")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	err := docsTemplate.Execute(w, struct {
+		AssetsURL string
+		SpecURL   string
+	}{
+		AssetsURL: strings.TrimSuffix(s.config.Docs.AssetsURL, "/"),
+		SpecURL:   "/openapi.json",
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка при формировании страницы документации", "error", err)
+	}
+}
+
+// docsAuthMiddleware закрывает документацию Basic-аутентификацией, если она включена
+func (s *Server) docsAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.config.Docs.RequireAuth {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		username, password, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(username), []byte(s.config.Docs.Username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(s.config.Docs.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="API Gateway docs", charset="UTF-8"`)
+			writeError(w, r, http.StatusUnauthorized, codeUnauthorized, "Требуется авторизация")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
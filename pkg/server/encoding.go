@@ -0,0 +1,168 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+
+	"apigw/pkg/pb/apigwv1"
+)
+
+// Поддерживаемые двоичные форматы ответов
+const (
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeMsgpack  = "application/msgpack"
+)
+
+// errUnsupportedEncoding возвращается, если для маршрута нет схемы protobuf
+var errUnsupportedEncoding = errors.New("формат не поддерживается для этого маршрута")
+
+// negotiateEncoding выбирает формат ответа по заголовку Accept.
+// Возвращает пустую строку, если клиенту подходит JSON.
+func negotiateEncoding(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+		switch mediaType {
+		case contentTypeProtobuf, "application/protobuf":
+			return contentTypeProtobuf
+		case contentTypeMsgpack, "application/x-msgpack":
+			return contentTypeMsgpack
+		case "application/json", "*/*", "application/*":
+			return ""
+		}
+	}
+	return ""
+}
+
+// protoMessageFor возвращает сообщение, описывающее ответ обработчика, и имя поля,
+// в которое нужно обернуть JSON ответ (для ответов-массивов)
+func (s *Server) protoMessageFor(r *http.Request) (proto.Message, string) {
+	switch {
+	case r.URL.Path == "/api/news" && r.URL.Query().Get("comm") != "":
+		return &apigwv1.GetNewsResponse{}, ""
+	case r.URL.Path == "/api/news" || r.URL.Path == "/api/fullnews":
+		return &apigwv1.ListNewsResponse{}, ""
+	case r.URL.Path == "/api/news/batch":
+		// Для ответа с несколькими новостями сообщения нет, он остается в JSON
+		return nil, ""
+	case strings.HasPrefix(r.URL.Path, "/api/news/"):
+		return &apigwv1.News{}, ""
+	case r.URL.Path == "/api/comments" && !commentsPaginated(r.URL.Query()) && s.commentsView(r.URL.Query()) == commentsViewFlat:
+		// Страницы и дерево комментариев остаются в JSON
+		return &apigwv1.ListCommentsResponse{}, "comments"
+	}
+	return nil, ""
+}
+
+// encodingMiddleware перекодирует успешные JSON ответы в protobuf или MessagePack,
+// если клиент запросил их в заголовке Accept. Ошибки остаются в JSON.
+// Middleware располагается снаружи кэша, поэтому в кэше хранится только JSON.
+func (s *Server) encodingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept"))
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rw := s.newSpillWriter(w)
+		next.ServeHTTP(rw, r)
+		if rw.spilled {
+			slog.DebugContext(r.Context(), "Ответ больше streaming.max_buffer, отправлен в JSON", "content_type", encoding)
+			return
+		}
+
+		for name, values := range rw.header {
+			w.Header()[name] = values
+		}
+
+		body := rw.body.Bytes()
+		if rw.status == http.StatusOK {
+			var (
+				encoded []byte
+				err     error
+			)
+			switch encoding {
+			case contentTypeProtobuf:
+				encoded, err = s.jsonToProtobuf(r, body)
+			case contentTypeMsgpack:
+				encoded, err = jsonToMsgpack(body)
+			}
+
+			if err == nil {
+				w.Header().Set("Content-Type", encoding)
+				w.Header().Del("Content-Length")
+				body = encoded
+			} else {
+				slog.WarnContext(r.Context(), "Не удалось перекодировать ответ", "content_type", encoding, "error", err)
+			}
+		}
+
+		w.WriteHeader(rw.status)
+		w.Write(body)
+	})
+}
+
+// jsonToProtobuf перекодирует JSON ответ в соответствующее сообщение protobuf
+func (s *Server) jsonToProtobuf(r *http.Request, body []byte) ([]byte, error) {
+	msg, wrapKey := s.protoMessageFor(r)
+	if msg == nil {
+		return nil, errUnsupportedEncoding
+	}
+
+	if wrapKey != "" {
+		body = append(append([]byte(`{"`+wrapKey+`":`), body...), '}')
+	}
+	if err := grpcUnmarshal.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+	return proto.Marshal(msg)
+}
+
+// jsonToMsgpack перекодирует JSON ответ в MessagePack, сохраняя целые числа целыми
+func jsonToMsgpack(body []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return msgpack.Marshal(normalizeNumbers(value))
+}
+
+// normalizeNumbers заменяет json.Number на int64 или float64
+func normalizeNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeNumbers(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeNumbers(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
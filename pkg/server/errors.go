@@ -0,0 +1,172 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Машиночитаемые коды ошибок в ответах шлюза
+const (
+	codeInvalidRequest      = "invalid_request"
+	codeInvalidJSON         = "invalid_json"
+	codeValidationFailed    = "validation_failed"
+	codeInvalidNewsID       = "invalid_news_id"
+	codeEmptyComment        = "empty_comment"
+	codeCommentRejected     = "comment_rejected"
+	codeTooManyItems        = "too_many_items"
+	codeUnauthorized        = "unauthorized"
+	codeUnknownTenant       = "unknown_tenant"
+	codeForbidden           = "forbidden"
+	codeNotFound            = "not_found"
+	codeNewsNotFound        = "news_not_found"
+	codeMethodNotAllowed    = "method_not_allowed"
+	codeRateLimited         = "rate_limited"
+	codeOverloaded          = "overloaded"
+	codeCircuitOpen         = "circuit_open"
+	codeUpstreamUnhealthy   = "upstream_unhealthy"
+	codeTooManyConnections  = "too_many_connections"
+	codeTimeout             = "timeout"
+	codeMaintenance         = "maintenance"
+	codeUpstreamError       = "upstream_error"
+	codeUpstreamUnavailable = "upstream_unavailable"
+	codeInternal            = "internal_error"
+)
+
+// errorResponse - тело ответа с ошибкой
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+// errorBody - описание ошибки
+type errorBody struct {
+	// Code - машиночитаемый код ошибки, например news_not_found
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// RequestID - идентификатор запроса для обращения в поддержку
+	RequestID string `json:"request_id"`
+	// Details - дополнительные сведения об ошибке
+	Details interface{} `json:"details,omitempty"`
+}
+
+// writeError отправляет ответ с ошибкой в едином формате
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	writeErrorDetails(w, r, status, code, message, nil)
+}
+
+// writeErrorDetails отправляет ответ с ошибкой и дополнительными сведениями
+func writeErrorDetails(w http.ResponseWriter, r *http.Request, status int, code, message string, details interface{}) {
+	body := errorResponse{Error: errorBody{
+		Code:      code,
+		Message:   message,
+		RequestID: errorRequestID(w, r),
+		Details:   details,
+	}}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(status)
+	if err := writeJSON(w, body); err != nil {
+		slog.DebugContext(r.Context(), "Ошибка при отправке ответа с ошибкой", "error", err)
+	}
+}
+
+// writeBackendError отправляет ответ при ошибке запроса к backend-сервису
+func writeBackendError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	var unhealthy *upstreamUnhealthyError
+	if errors.As(err, &unhealthy) {
+		writeErrorDetails(w, r, http.StatusServiceUnavailable, codeUpstreamUnhealthy, "Сервис недоступен по результатам проверки доступности", map[string]string{
+			"upstream": unhealthy.upstream,
+			"reason":   unhealthy.reason,
+			"since":    unhealthy.since.UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	var full *bulkheadFullError
+	if errors.As(err, &full) {
+		w.Header().Set("Retry-After", full.retryAfter())
+		writeError(w, r, http.StatusServiceUnavailable, codeOverloaded, "Превышено число одновременных запросов к сервису")
+		return
+	}
+
+	status := backendErrorStatus(err)
+	code := codeUpstreamUnavailable
+	switch {
+	case errors.Is(err, errCircuitOpen):
+		code = codeCircuitOpen
+		message = "Сервис временно недоступен"
+	case status == http.StatusServiceUnavailable:
+		code = codeOverloaded
+	case status == http.StatusGatewayTimeout:
+		code = codeTimeout
+		message = "Превышено время ожидания ответа сервиса"
+	}
+	writeError(w, r, status, code, message)
+}
+
+// upstreamStatusError возвращает ответ клиенту, когда backend-сервис вернул
+// ошибку: статус сервиса передается клиенту, код ошибки определяется по статусу
+func upstreamStatusError(status int, message string) *responseError {
+	code := codeUpstreamError
+	if status < http.StatusInternalServerError {
+		code = codeForStatus(status)
+	}
+	return &responseError{status: status, code: code, message: message}
+}
+
+// newsErrorCode возвращает код ошибки, когда сервис новостей ответил статусом status
+func newsErrorCode(status int) string {
+	switch {
+	case status == http.StatusNotFound:
+		return codeNewsNotFound
+	case status >= http.StatusInternalServerError:
+		return codeUpstreamError
+	}
+	return codeForStatus(status)
+}
+
+// errorRequestID возвращает идентификатор запроса для ответа с ошибкой.
+// Запросы, не прошедшие requestIDMiddleware, получают новый идентификатор.
+func errorRequestID(w http.ResponseWriter, r *http.Request) string {
+	if requestID, ok := r.Context().Value(requestIDKey).(string); ok && requestID != "" {
+		return requestID
+	}
+	if requestID := w.Header().Get("X-Request-ID"); requestID != "" {
+		return requestID
+	}
+	requestID, err := generateRequestID(8)
+	if err != nil {
+		return ""
+	}
+	w.Header().Set("X-Request-ID", requestID)
+	return requestID
+}
+
+// codeForStatus возвращает код ошибки по HTTP статусу, например not_found
+func codeForStatus(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return codeInternal
+	}
+	return strings.ToLower(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text))
+}
+
+// errorMessage извлекает текст ошибки из тела ответа обработчика
+func errorMessage(body []byte) string {
+	_, message := parseError(body)
+	return message
+}
+
+// parseError извлекает код и текст ошибки из тела ответа шлюза.
+// Для ответов в другом формате код пустой, а текстом считается тело целиком.
+func parseError(body []byte) (code, message string) {
+	var payload errorResponse
+	if err := json.Unmarshal(body, &payload); err == nil && payload.Error.Message != "" {
+		return payload.Error.Code, payload.Error.Message
+	}
+	return "", strings.TrimSpace(string(body))
+}
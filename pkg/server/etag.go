@@ -0,0 +1,74 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// etagLength - сколько байт хэша тела ответа входит в ETag
+const etagLength = 16
+
+// responseETag возвращает слабый ETag для тела ответа. ETag слабый, так как
+// сжатие меняет байты ответа, не меняя его содержания.
+func responseETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:etagLength]) + `"`
+}
+
+// etagMatches проверяет, что ETag совпадает с одним из значений заголовка
+// If-None-Match при слабом сравнении (RFC 9110, раздел 13.1.2)
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// etagMiddleware добавляет к успешным ответам на GET заголовок ETag
+// по содержимому ответа и отвечает 304 Not Modified, если клиент передал его
+// в If-None-Match. ETag считается по окончательному телу ответа, поэтому
+// учитывает формат ответа и часовой пояс дат. Ответы больше
+// streaming.max_buffer передаются потоком без ETag.
+func (s *Server) etagMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rw := s.newSpillWriter(w)
+		next.ServeHTTP(rw, r)
+		if rw.spilled {
+			slog.DebugContext(r.Context(), "Ответ больше streaming.max_buffer отправлен без ETag", "path", r.URL.Path)
+			return
+		}
+
+		for name, values := range rw.header {
+			w.Header()[name] = values
+		}
+		if rw.status != http.StatusOK {
+			w.WriteHeader(rw.status)
+			w.Write(rw.body.Bytes())
+			return
+		}
+
+		etag := responseETag(rw.body.Bytes())
+		w.Header().Set("ETag", etag)
+		if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag) {
+			// Ответ 304 не содержит тела и описывающих его заголовков
+			for _, name := range []string{"Content-Type", "Content-Length"} {
+				w.Header().Del(name)
+			}
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(rw.status)
+		w.Write(rw.body.Bytes())
+	})
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"apigw/pkg/config"
+	"apigw/pkg/events"
+)
+
+// requestEvent - данные события request.completed
+type requestEvent struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Status int    `json:"status"`
+	// Bytes - размер тела ответа
+	Bytes      int64   `json:"bytes"`
+	DurationMS float64 `json:"duration_ms"`
+	RemoteIP   string  `json:"remote_ip"`
+	RequestID  string  `json:"request_id"`
+	// Client - клиент, определенный по ключу API
+	Client string `json:"client,omitempty"`
+}
+
+// commentEvent - данные события comment.created
+type commentEvent struct {
+	NewsID    int64  `json:"news_id"`
+	Text      string `json:"text"`
+	UserID    string `json:"user_id,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	// Response - ответ сервиса комментариев, если это JSON
+	Response interface{} `json:"response,omitempty"`
+}
+
+// upstreamEvent - данные событий upstream.unhealthy и upstream.healthy
+type upstreamEvent struct {
+	Upstream string `json:"upstream"`
+	Status   int    `json:"status,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// checkEventsConfig проверяет настройки публикации событий без подключения к брокеру
+func checkEventsConfig(cfg config.EventsConfig) error {
+	switch cfg.Driver {
+	case "":
+	case "nats":
+		if cfg.NATS.URL == "" || cfg.NATS.Subject == "" {
+			return fmt.Errorf("не указан адрес или тема NATS (events.nats.url, events.nats.subject)")
+		}
+	case "kafka":
+		if len(cfg.Kafka.Brokers) == 0 || cfg.Kafka.Topic == "" {
+			return fmt.Errorf("не указаны брокеры или топик Kafka (events.kafka.brokers, events.kafka.topic)")
+		}
+	default:
+		return fmt.Errorf("неизвестный драйвер событий: %q", cfg.Driver)
+	}
+	return nil
+}
+
+// newEventPublisher создает издателя событий согласно events.driver.
+// Возвращает nil, если публикация отключена.
+func newEventPublisher(cfg config.EventsConfig) (*events.Publisher, error) {
+	if err := checkEventsConfig(cfg); err != nil || cfg.Driver == "" {
+		return nil, err
+	}
+
+	var sink events.Sink
+	if cfg.Driver == "nats" {
+		var err error
+		if sink, err = events.NewNATS(cfg.NATS.URL, cfg.NATS.Subject); err != nil {
+			return nil, err
+		}
+	} else {
+		sink = events.NewKafka(cfg.Kafka.Brokers, cfg.Kafka.Topic)
+	}
+
+	return events.NewPublisher(sink, events.Options{
+		Types:      cfg.Types,
+		BufferSize: cfg.BufferSize,
+		Timeout:    cfg.Timeout.Std(),
+		Source:     cfg.Source,
+		TypePrefix: cfg.TypePrefix,
+	}), nil
+}
+
+// publishEvent публикует событие, если публикация включена
+func (s *Server) publishEvent(eventType, subject string, data interface{}) {
+	if s.events == nil {
+		return
+	}
+	s.events.Publish(events.Event{Type: eventType, Subject: subject, Data: data})
+}
+
+// upstreamHealth отслеживает доступность backend-сервисов по результатам запросов
+// и публикует событие только при изменении состояния, а не на каждую ошибку
+type upstreamHealth struct {
+	mu        sync.Mutex
+	unhealthy map[string]bool
+	publish   func(eventType, subject string, data interface{})
+}
+
+// newUpstreamHealth создает отслеживание доступности backend-сервисов
+func newUpstreamHealth(publish func(eventType, subject string, data interface{})) *upstreamHealth {
+	return &upstreamHealth{unhealthy: make(map[string]bool), publish: publish}
+}
+
+// healthy сообщает, что последний запрос к сервису upstream не завершился
+// сетевой ошибкой или ответом 502, 503 и 504
+func (h *upstreamHealth) healthy(upstream string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return !h.unhealthy[upstream]
+}
+
+// observe учитывает результат запроса к backend-сервису.
+// Сервис считается недоступным при сетевой ошибке или ответах 502, 503 и 504.
+func (h *upstreamHealth) observe(target *url.URL, resp *http.Response, err error) {
+	if h == nil || target == nil {
+		return
+	}
+	// Клиент отменил запрос - это ничего не говорит о состоянии сервиса
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	upstream := upstreamName(target)
+	event := upstreamEvent{Upstream: upstream}
+	failed := false
+	switch {
+	case err != nil:
+		failed = true
+		event.Error = err.Error()
+	case resp.StatusCode == http.StatusBadGateway, resp.StatusCode == http.StatusServiceUnavailable, resp.StatusCode == http.StatusGatewayTimeout:
+		failed = true
+		event.Status = resp.StatusCode
+	}
+
+	h.mu.Lock()
+	changed := h.unhealthy[upstream] != failed
+	if failed {
+		h.unhealthy[upstream] = true
+	} else {
+		delete(h.unhealthy, upstream)
+	}
+	h.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if failed {
+		slog.Warn("Backend-сервис недоступен", "backend", upstream)
+		h.publish(events.TypeUpstreamUnhealthy, upstream, event)
+	} else {
+		slog.Info("Backend-сервис снова доступен", "backend", upstream)
+		h.publish(events.TypeUpstreamHealthy, upstream, event)
+	}
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"apigw/pkg/config"
+)
+
+// errInjectedFault возвращается для запросов, прерванных внесением сбоев
+var errInjectedFault = errors.New("внесенный сбой: соединение с сервисом прервано")
+
+// faultInjector вносит задержки и ошибки в запросы к backend-сервисам.
+// nil означает, что внесение сбоев отключено.
+type faultInjector struct {
+	// rules - сбои по имени backend-сервиса (см. upstreamName)
+	rules map[string]config.FaultConfig
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// newFaultInjector создает источник сбоев согласно секции faults
+func newFaultInjector(cfg *config.Config) (*faultInjector, error) {
+	if !cfg.Faults.Enabled {
+		return nil, nil
+	}
+	if cfg.Server.Environment == "" || cfg.Server.Environment == "production" {
+		return nil, fmt.Errorf("внесение сбоев запрещено в окружении production, укажите server.environment")
+	}
+
+	f := &faultInjector{
+		rules: make(map[string]config.FaultConfig),
+		rnd:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for name, rule := range cfg.Faults.Services {
+		if rule.ErrorRate < 0 || rule.AbortRate < 0 || rule.ErrorRate+rule.AbortRate > 1 {
+			return nil, fmt.Errorf("доли сбоев сервиса %s должны быть неотрицательными и в сумме не больше 1", name)
+		}
+		if rule.ErrorStatus == 0 {
+			rule.ErrorStatus = http.StatusServiceUnavailable
+		}
+
+		address := name
+		if service, ok := cfg.Services[name]; ok {
+			address = service.URL
+		}
+		target, err := url.Parse(address)
+		if err != nil || target.Host == "" {
+			return nil, fmt.Errorf("некорректный сервис для внесения сбоев: %q", name)
+		}
+		f.rules[upstreamName(target)] = rule
+	}
+
+	slog.Warn("Внесение сбоев включено", "services", len(f.rules), "environment", cfg.Server.Environment)
+	return f, nil
+}
+
+// inject применяет сбои к запросу: выдерживает задержку и при необходимости
+// возвращает ответ или ошибку вместо сервиса. Если оба результата nil,
+// запрос нужно отправить сервису.
+func (f *faultInjector) inject(req *http.Request) (*http.Response, error) {
+	if f == nil {
+		return nil, nil
+	}
+	rule, ok := f.rules[upstreamName(req.URL)]
+	if !ok {
+		return nil, nil
+	}
+
+	f.mu.Lock()
+	delay := rule.Latency.Std()
+	if rule.Jitter > 0 {
+		delay += time.Duration(f.rnd.Int63n(int64(rule.Jitter)))
+	}
+	roll := f.rnd.Float64()
+	f.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+
+	switch {
+	case roll < rule.AbortRate:
+		return nil, errInjectedFault
+	case roll < rule.AbortRate+rule.ErrorRate:
+		body := fmt.Sprintf("внесенный сбой: %d %s", rule.ErrorStatus, http.StatusText(rule.ErrorStatus))
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", rule.ErrorStatus, http.StatusText(rule.ErrorStatus)),
+			StatusCode:    rule.ErrorStatus,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// fieldsParam - параметр запроса со списком полей новостей, например ?fields=id,title
+const fieldsParam = "fields"
+
+// parseFields разбирает список полей через запятую
+func parseFields(raw string) (map[string]bool, error) {
+	fields := make(map[string]bool)
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, errors.New("пустое имя поля")
+		}
+		fields[name] = true
+	}
+	return fields, nil
+}
+
+// newsContainer возвращает поле ответа маршрута, в котором находятся новости:
+// items - список или страница новостей, news - новость с комментариями,
+// пустая строка - ответ и есть новость. ok = false, если маршрут не
+// поддерживает выбор полей.
+func newsContainer(r *http.Request) (container string, ok bool) {
+	switch {
+	case r.URL.Path == "/api/news" && r.URL.Query().Get("comm") != "":
+		return "news", true
+	case r.URL.Path == "/api/news" || r.URL.Path == "/api/fullnews" || r.URL.Path == "/api/news/batch":
+		return "items", true
+	case strings.HasPrefix(r.URL.Path, "/api/news/") && !strings.Contains(strings.TrimPrefix(r.URL.Path, "/api/news/"), "/"):
+		return "", true
+	}
+	return "", false
+}
+
+// projectFields оставляет в новостях JSON ответа только поля fields.
+// Остальные поля ответа (пагинация, комментарии, not_found) не изменяются.
+func projectFields(body []byte, container string, fields map[string]bool) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if container == "" {
+		projectObject(value, fields)
+	} else if object, ok := value.(map[string]interface{}); ok {
+		switch news := object[container].(type) {
+		case []interface{}:
+			for _, item := range news {
+				projectObject(item, fields)
+			}
+		default:
+			projectObject(news, fields)
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// projectObject удаляет из объекта поля, которых нет в fields
+func projectObject(value interface{}, fields map[string]bool) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for name := range object {
+		if !fields[name] {
+			delete(object, name)
+		}
+	}
+}
+
+// fieldsMiddleware оставляет в новостях успешных JSON ответов только поля,
+// перечисленные в параметре fields, чтобы уменьшить размер ответа для
+// мобильных клиентов. Middleware располагается снаружи кэша, поэтому в кэше
+// хранятся полные ответы, а параметр fields не влияет на ключ кэша.
+func (s *Server) fieldsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		container, supported := newsContainer(r)
+		if !query.Has(fieldsParam) || !supported || r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		fields, err := parseFields(query.Get(fieldsParam))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "Некорректный список полей: "+err.Error())
+			return
+		}
+
+		rw := s.newSpillWriter(w)
+		next.ServeHTTP(rw, r)
+		if rw.spilled {
+			slog.DebugContext(r.Context(), "Ответ больше streaming.max_buffer отправлен со всеми полями", "path", r.URL.Path)
+			return
+		}
+
+		for name, values := range rw.header {
+			w.Header()[name] = values
+		}
+
+		body := rw.body.Bytes()
+		mediaType, _, _ := mime.ParseMediaType(rw.header.Get("Content-Type"))
+		if rw.status == http.StatusOK && mediaType == "application/json" {
+			if projected, err := projectFields(body, container, fields); err == nil {
+				w.Header().Del("Content-Length")
+				body = projected
+			} else {
+				slog.WarnContext(r.Context(), "Не удалось выбрать поля ответа", "path", r.URL.Path, "error", err)
+			}
+		}
+
+		w.WriteHeader(rw.status)
+		w.Write(body)
+	})
+}
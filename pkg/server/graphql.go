@@ -0,0 +1,322 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+
+	"apigw/pkg/clients/comments"
+	"apigw/pkg/clients/news"
+)
+
+// graphQLRequest - тело запроса к /graphql
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// newGraphQLSchema строит GraphQL схему поверх сервисов новостей и комментариев
+func (s *Server) newGraphQLSchema() (graphql.Schema, error) {
+	commentType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Comment",
+		Fields: graphql.Fields{
+			"id":     &graphql.Field{Type: graphql.Int, Resolve: resolveComment(func(c comments.Comment) interface{} { return c.ID })},
+			"newsId": &graphql.Field{Type: graphql.Int, Resolve: resolveComment(func(c comments.Comment) interface{} { return c.NewsID })},
+			"parentId": &graphql.Field{Type: graphql.Int, Resolve: resolveComment(func(c comments.Comment) interface{} {
+				if c.ParentID == nil {
+					return nil
+				}
+				return *c.ParentID
+			})},
+			"text":      &graphql.Field{Type: graphql.String, Resolve: resolveComment(func(c comments.Comment) interface{} { return c.Text })},
+			"createdAt": &graphql.Field{Type: graphql.String, Resolve: resolveComment(func(c comments.Comment) interface{} { return c.CreatedAt })},
+		},
+	})
+
+	// Комментарии к новости запрашиваются параллельно для всех новостей в ответе
+	// (не более graphql.concurrency одновременно): резолвер запускает запрос в
+	// фоне и возвращает отложенный результат
+	resolveComments := func(p graphql.ResolveParams) (interface{}, error) {
+		item, ok := newsSource(p)
+		if !ok {
+			return []comments.Comment{}, nil
+		}
+
+		future := s.loadComments(p.Context, item.ID)
+		return func() (interface{}, error) {
+			<-future.done
+			return future.comments, future.err
+		}, nil
+	}
+
+	newsType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "News",
+		Fields: graphql.Fields{
+			"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: resolveNews(func(n news.Item) interface{} { return n.ID })},
+			"title":       &graphql.Field{Type: graphql.String, Resolve: resolveNews(func(n news.Item) interface{} { return n.Title })},
+			"description": &graphql.Field{Type: graphql.String, Resolve: resolveNews(func(n news.Item) interface{} { return n.Description })},
+			"pubDate":     &graphql.Field{Type: graphql.String, Resolve: resolveNews(func(n news.Item) interface{} { return n.PubDate })},
+			"sourceUrl":   &graphql.Field{Type: graphql.String, Resolve: resolveNews(func(n news.Item) interface{} { return n.SourceURL })},
+			"createdAt":   &graphql.Field{Type: graphql.String, Resolve: resolveNews(func(n news.Item) interface{} { return n.CreatedAt })},
+			"comments": &graphql.Field{
+				Type:    graphql.NewList(commentType),
+				Resolve: resolveComments,
+			},
+			"commentCount": &graphql.Field{
+				Type: graphql.Int,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					item, ok := newsSource(p)
+					if !ok {
+						return 0, nil
+					}
+
+					future := s.loadComments(p.Context, item.ID)
+					return func() (interface{}, error) {
+						<-future.done
+						return len(future.comments), future.err
+					}, nil
+				},
+			},
+		},
+	})
+
+	newsPageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "NewsPage",
+		Fields: graphql.Fields{
+			"items":        &graphql.Field{Type: graphql.NewList(newsType)},
+			"totalPages":   &graphql.Field{Type: graphql.Int},
+			"currentPage":  &graphql.Field{Type: graphql.Int},
+			"itemsPerPage": &graphql.Field{Type: graphql.Int},
+			"totalItems":   &graphql.Field{Type: graphql.Int},
+		},
+	})
+
+	pageArgs := graphql.FieldConfigArgument{
+		"page":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPage},
+		"count": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultCount},
+		"s":     &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: ""},
+	}
+
+	// Списки новостей в кратком и полном формате отличаются только набором
+	// полей, который в GraphQL выбирает клиент
+	resolveNewsPage := func(p graphql.ResolveParams) (interface{}, error) {
+		page, _ := p.Args["page"].(int)
+		count, _ := p.Args["count"].(int)
+		searchTerm, _ := p.Args["s"].(string)
+		if page <= 0 {
+			page = defaultPage
+		}
+		if count <= 0 {
+			count = defaultCount
+		}
+
+		allNews, err := s.fetchAllNews(p.Context)
+		if err != nil {
+			return nil, err
+		}
+
+		filtered := filterNewsByTitle(allNews, searchTerm)
+		start, end, totalPages := pageBounds(len(filtered), page, count)
+
+		return map[string]interface{}{
+			"items":        filtered[start:end],
+			"totalPages":   totalPages,
+			"currentPage":  page,
+			"itemsPerPage": count,
+			"totalItems":   len(filtered),
+		}, nil
+	}
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"news": &graphql.Field{
+				Type:    newsPageType,
+				Args:    pageArgs,
+				Resolve: resolveNewsPage,
+			},
+			"fullNews": &graphql.Field{
+				Type:    newsPageType,
+				Args:    pageArgs,
+				Resolve: resolveNewsPage,
+			},
+			"newsItem": &graphql.Field{
+				Type: newsType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, _ := p.Args["id"].(int)
+					item, err := s.fetchNewsItem(p.Context, int64(id))
+					if errors.Is(err, errNewsNotFound) {
+						return nil, nil
+					}
+					return item, err
+				},
+			},
+			"comments": &graphql.Field{
+				Type: graphql.NewList(commentType),
+				Args: graphql.FieldConfigArgument{
+					"newsID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, _ := p.Args["newsID"].(int)
+					return s.fetchComments(p.Context, int64(id))
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+// Ключ контекста для загрузчика комментариев GraphQL запроса
+const commentsLoaderKey contextKey = "graphqlComments"
+
+// commentsFuture - результат запроса комментариев, который может быть еще не получен
+type commentsFuture struct {
+	done     chan struct{}
+	comments []comments.Comment
+	err      error
+}
+
+// commentsLoader запрашивает комментарии к каждой новости не более одного раза
+// за GraphQL запрос, даже если выбраны и comments, и commentCount
+type commentsLoader struct {
+	mu      sync.Mutex
+	futures map[int64]*commentsFuture
+	// sem ограничивает число одновременных запросов комментариев (graphql.concurrency)
+	sem chan struct{}
+}
+
+// newCommentsLoader создает загрузчик комментариев, выполняющий не более
+// concurrency запросов одновременно; 0 - без ограничения
+func newCommentsLoader(concurrency int) *commentsLoader {
+	loader := &commentsLoader{}
+	if concurrency > 0 {
+		loader.sem = make(chan struct{}, concurrency)
+	}
+	return loader
+}
+
+// loadComments запускает (или переиспользует) фоновый запрос комментариев к новости
+func (s *Server) loadComments(ctx context.Context, newsID int64) *commentsFuture {
+	loader, _ := ctx.Value(commentsLoaderKey).(*commentsLoader)
+	if loader == nil {
+		loader = newCommentsLoader(s.config.GraphQL.Concurrency)
+	}
+
+	loader.mu.Lock()
+	defer loader.mu.Unlock()
+
+	if future, ok := loader.futures[newsID]; ok {
+		return future
+	}
+	if loader.futures == nil {
+		loader.futures = make(map[int64]*commentsFuture)
+	}
+
+	future := &commentsFuture{done: make(chan struct{})}
+	loader.futures[newsID] = future
+	go func() {
+		defer close(future.done)
+		if loader.sem != nil {
+			select {
+			case loader.sem <- struct{}{}:
+				defer func() { <-loader.sem }()
+			case <-ctx.Done():
+				future.err = ctx.Err()
+				return
+			}
+		}
+		future.comments, future.err = s.fetchComments(ctx, newsID)
+	}()
+	return future
+}
+
+// newsSource возвращает новость, для которой вызван резолвер поля
+func newsSource(p graphql.ResolveParams) (news.Item, bool) {
+	switch item := p.Source.(type) {
+	case news.Item:
+		return item, true
+	case *news.Item:
+		if item != nil {
+			return *item, true
+		}
+	}
+	return news.Item{}, false
+}
+
+// resolveNews возвращает резолвер поля новости
+func resolveNews(field func(news.Item) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		item, ok := newsSource(p)
+		if !ok {
+			return nil, nil
+		}
+		return field(item), nil
+	}
+}
+
+// resolveComment возвращает резолвер поля комментария
+func resolveComment(field func(comments.Comment) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		comment, ok := p.Source.(comments.Comment)
+		if !ok {
+			return nil, nil
+		}
+		return field(comment), nil
+	}
+}
+
+// handleGraphQL выполняет GraphQL запрос, переданный в теле POST или в параметрах GET
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphQLRequest
+
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if vars := query.Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "Некорректный параметр variables")
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, codeInvalidJSON, "Неверный формат JSON или отсутствие тела запроса")
+			return
+		}
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Метод не разрешен")
+		return
+	}
+
+	if req.Query == "" {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "Не указан GraphQL запрос")
+		return
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         s.graphQLSchema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        context.WithValue(r.Context(), commentsLoaderKey, newCommentsLoader(s.config.GraphQL.Concurrency)),
+	})
+	if result.HasErrors() {
+		slog.WarnContext(r.Context(), "GraphQL запрос завершился с ошибками", "errors", result.Errors)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,261 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"apigw/pkg/pb/apigwv1"
+)
+
+// Метаданные gRPC, которые передаются в цепочку обработчиков как HTTP заголовки
+var forwardedMetadata = []string{"authorization", "x-api-key", "x-request-id", "x-forwarded-for", "forwarded"}
+
+// Декодер ответов обработчиков: ответы сервисов могут содержать лишние поля
+var grpcUnmarshal = protojson.UnmarshalOptions{DiscardUnknown: true}
+
+// grpcGateway реализует gRPC сервис NewsGateway поверх HTTP обработчиков шлюза
+type grpcGateway struct {
+	apigwv1.UnimplementedNewsGatewayServer
+	s *Server
+}
+
+// newGRPCServer создает gRPC сервер с зарегистрированным сервисом NewsGateway
+func (s *Server) newGRPCServer() *grpc.Server {
+	gs := grpc.NewServer()
+	apigwv1.RegisterNewsGatewayServer(gs, &grpcGateway{s: s})
+	return gs
+}
+
+// ListNews возвращает страницу новостей в кратком формате
+func (g *grpcGateway) ListNews(ctx context.Context, req *apigwv1.ListNewsRequest) (*apigwv1.ListNewsResponse, error) {
+	resp := &apigwv1.ListNewsResponse{}
+	err := g.call(ctx, http.MethodGet, "/api/news?"+listNewsQuery(req).Encode(), nil, resp)
+	return resp, err
+}
+
+// ListFullNews возвращает страницу новостей с описанием
+func (g *grpcGateway) ListFullNews(ctx context.Context, req *apigwv1.ListNewsRequest) (*apigwv1.ListNewsResponse, error) {
+	resp := &apigwv1.ListNewsResponse{}
+	err := g.call(ctx, http.MethodGet, "/api/fullnews?"+listNewsQuery(req).Encode(), nil, resp)
+	return resp, err
+}
+
+// GetNews возвращает новость по ID, при необходимости вместе с комментариями
+func (g *grpcGateway) GetNews(ctx context.Context, req *apigwv1.GetNewsRequest) (*apigwv1.GetNewsResponse, error) {
+	resp := &apigwv1.GetNewsResponse{}
+
+	// Ответ /api/news?comm={id} уже имеет вид {"news": ..., "comments": [...]}
+	if req.GetIncludeComments() {
+		err := g.call(ctx, http.MethodGet, fmt.Sprintf("/api/news?comm=%d", req.GetId()), nil, resp)
+		return resp, err
+	}
+
+	resp.News = &apigwv1.News{}
+	err := g.call(ctx, http.MethodGet, fmt.Sprintf("/api/news/%d", req.GetId()), nil, resp.News)
+	return resp, err
+}
+
+// ListComments возвращает комментарии к новости
+func (g *grpcGateway) ListComments(ctx context.Context, req *apigwv1.ListCommentsRequest) (*apigwv1.ListCommentsResponse, error) {
+	resp := &apigwv1.ListCommentsResponse{}
+
+	// Обработчик возвращает массив комментариев, оборачиваем его в сообщение
+	body, err := g.do(ctx, http.MethodGet, fmt.Sprintf("/api/comments?id=%d", req.GetNewsId()), nil)
+	if err != nil {
+		return nil, err
+	}
+	wrapped := append(append([]byte(`{"comments":`), body...), '}')
+	if err := grpcUnmarshal.Unmarshal(wrapped, resp); err != nil {
+		return nil, status.Errorf(codes.Internal, "ошибка при обработке комментариев: %v", err)
+	}
+	return resp, nil
+}
+
+// AddComment добавляет комментарий к новости
+func (g *grpcGateway) AddComment(ctx context.Context, req *apigwv1.AddCommentRequest) (*apigwv1.AddCommentResponse, error) {
+	body, err := json.Marshal(map[string]string{"text": req.GetText()})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "ошибка при обработке запроса: %v", err)
+	}
+
+	resp := &apigwv1.AddCommentResponse{}
+	err = g.call(ctx, http.MethodPost, fmt.Sprintf("/api/comments/add?news_id=%d", req.GetNewsId()), body, resp)
+	return resp, err
+}
+
+// listNewsQuery формирует параметры запроса списка новостей
+func listNewsQuery(req *apigwv1.ListNewsRequest) url.Values {
+	query := url.Values{}
+	if req.GetPage() > 0 {
+		query.Set("page", strconv.Itoa(int(req.GetPage())))
+	}
+	if req.GetCount() > 0 {
+		query.Set("count", strconv.Itoa(int(req.GetCount())))
+	}
+	if req.GetS() != "" {
+		query.Set("s", req.GetS())
+	}
+	return query
+}
+
+// call выполняет запрос через цепочку обработчиков и декодирует ответ в сообщение
+func (g *grpcGateway) call(ctx context.Context, method, target string, body []byte, out proto.Message) error {
+	respBody, err := g.do(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	if err := grpcUnmarshal.Unmarshal(respBody, out); err != nil {
+		return status.Errorf(codes.Internal, "ошибка при обработке ответа: %v", err)
+	}
+	return nil
+}
+
+// do выполняет запрос через цепочку обработчиков, передавая метаданные вызова
+// как заголовки, и преобразует ошибочный HTTP статус в статус gRPC
+func (g *grpcGateway) do(ctx context.Context, method, target string, body []byte) ([]byte, error) {
+	srv := g.s.current()
+	header := make(http.Header)
+	if body != nil {
+		header.Set("Content-Type", "application/json")
+	}
+
+	var authority string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, name := range forwardedMetadata {
+			for _, value := range md.Get(name) {
+				header.Add(name, value)
+			}
+		}
+		if srv.tenants != nil {
+			for _, value := range md.Get(srv.tenants.header) {
+				header.Add(srv.tenants.header, value)
+			}
+		}
+		if srv.auth != nil {
+			for _, value := range md.Get(srv.auth.header) {
+				header.Add(srv.auth.header, value)
+			}
+		}
+		if values := md.Get(":authority"); len(values) > 0 {
+			authority = values[0]
+		}
+		// request_id передается обработчикам через параметр запроса
+		if ids := md.Get("x-request-id"); len(ids) > 0 && ids[0] != "" {
+			target = appendQueryParam(target, "request_id", ids[0])
+		}
+	}
+
+	// Вызовы gRPC проходят ту же проверку ключа API, что и HTTP API
+	if srv.auth != nil {
+		client, ok := srv.auth.authenticate(header.Get(srv.auth.header))
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "Требуется действительный ключ API")
+		}
+		ctx = withClient(ctx, client)
+	}
+
+	// Токен пользователя проверяется по тем же правилам маршрутов, что и в HTTP API
+	if srv.jwt != nil {
+		path, _, _ := strings.Cut(target, "?")
+		if rule := srv.jwt.rule(method, path); rule != nil {
+			claims, err := srv.jwt.verify(rule, header)
+			if err != nil {
+				return nil, status.Error(codes.Unauthenticated, "Требуется действительный токен доступа")
+			}
+			if claims != nil {
+				ctx = withClaims(ctx, claims)
+			}
+		}
+	}
+
+	// Политики доступа маршрутов действуют так же, как в HTTP API
+	if srv.policies != nil {
+		path, _, _ := strings.Cut(target, "?")
+		var denial *policyDenial
+		if ctx, denial = srv.policies.authorize(ctx, method, path, header); denial != nil {
+			return nil, status.Error(grpcCode(denial.status), denial.message)
+		}
+	}
+
+	// Вызовы gRPC проходят те же определение арендатора и лимит запросов, что и HTTP API
+	if srv.tenants != nil {
+		t, err := srv.tenants.admit(header, authority)
+		var rateErr *tenantRateLimitError
+		switch {
+		case errors.As(err, &rateErr):
+			return nil, status.Error(codes.ResourceExhausted, "Превышен лимит запросов, повторите запрос позже")
+		case err != nil:
+			return nil, status.Error(codes.PermissionDenied, "Не удалось определить арендатора")
+		}
+		ctx = withTenant(ctx, t)
+	}
+
+	remoteAddr := "grpc"
+	if p, ok := peer.FromContext(ctx); ok {
+		remoteAddr = p.Addr.String()
+	}
+
+	rw, err := srv.serveInternal(ctx, method, target, header, body, remoteAddr)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "ошибка при создании запроса: %v", err)
+	}
+
+	if requestID := rw.header.Get("X-Request-ID"); requestID != "" {
+		grpc.SetHeader(ctx, metadata.Pairs("x-request-id", requestID))
+	}
+
+	if rw.status < 200 || rw.status >= 300 {
+		return nil, status.Error(grpcCode(rw.status), errorMessage(rw.body.Bytes()))
+	}
+	return rw.body.Bytes(), nil
+}
+
+// appendQueryParam добавляет параметр к пути с параметрами
+func appendQueryParam(target, name, value string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	query := u.Query()
+	query.Set(name, value)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// grpcCode сопоставляет HTTP статус коду gRPC
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable, http.StatusBadGateway:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	default:
+		return codes.Internal
+	}
+}
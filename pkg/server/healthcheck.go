@@ -0,0 +1,233 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"apigw/pkg/config"
+	"apigw/pkg/httpclient"
+	"apigw/pkg/metrics"
+)
+
+// upstreamUnhealthyError возвращается, если активная проверка признала
+// backend-сервис недоступным и запрос к нему не отправлялся
+type upstreamUnhealthyError struct {
+	upstream string
+	reason   string
+	since    time.Time
+}
+
+func (e *upstreamUnhealthyError) Error() string {
+	return fmt.Sprintf("сервис %s не прошел проверку доступности: %s", e.upstream, e.reason)
+}
+
+// probeService запрашивает адрес target и проверяет статус ответа: при strict
+// нужен статус 2xx, иначе достаточно любого ответа со статусом ниже 500
+func probeService(ctx context.Context, client *http.Client, target *url.URL, strict bool) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	healthy := resp.StatusCode < http.StatusInternalServerError
+	if strict {
+		healthy = resp.StatusCode >= 200 && resp.StatusCode < 300
+	}
+	if !healthy {
+		return fmt.Errorf("статус ответа %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// upstreamCheck - состояние активной проверки одного backend-сервиса
+type upstreamCheck struct {
+	target *url.URL
+	// strict - путь проверки задан явно, нужен статус 2xx
+	strict bool
+	cfg    config.HealthCheckConfig
+
+	mu        sync.Mutex
+	unhealthy bool
+	// failures и successes - число неудачных и успешных проверок подряд
+	failures  int
+	successes int
+	reason    string
+	since     time.Time
+}
+
+// upstreamHealthChecker периодически проверяет backend-сервисы с включенной
+// секцией health_check. nil означает, что активная проверка отключена.
+type upstreamHealthChecker struct {
+	checks      map[string]*upstreamCheck
+	clients     *httpclient.Pool
+	transitions *metrics.CounterVec
+	rejected    *metrics.CounterVec
+}
+
+// newUpstreamHealthChecker проверяет настройки активной проверки сервисов.
+// Сервисы с одним адресом проверяются один раз.
+func newUpstreamHealthChecker(cfg *config.Config, clients *httpclient.Pool, registry *metrics.Registry) (*upstreamHealthChecker, error) {
+	type checkSettings struct {
+		config.HealthCheckConfig
+		Target string
+	}
+	settings, err := upstreamSettings(cfg, "проверки доступности", func(service config.ServiceConfig) checkSettings {
+		hc := service.HealthCheck.WithDefaults(cfg.HealthCheck)
+		if !hc.Enabled {
+			return checkSettings{}
+		}
+		if hc.Path == "" {
+			hc.Path = service.HealthPath
+		}
+		return checkSettings{HealthCheckConfig: hc, Target: strings.TrimSuffix(service.URL, "/") + hc.Path}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &upstreamHealthChecker{
+		checks:  make(map[string]*upstreamCheck),
+		clients: clients,
+		transitions: registry.Counter("apigw_upstream_health_transitions_total",
+			"Смены состояния сервисов по результатам активной проверки", "upstream", "state"),
+		rejected: registry.Counter("apigw_upstream_unhealthy_rejected_total",
+			"Запросы, не отправленные сервису, не прошедшему активную проверку", "upstream"),
+	}
+	for upstream, hc := range settings {
+		if !hc.Enabled {
+			continue
+		}
+		if hc.Interval <= 0 || hc.Timeout <= 0 || hc.UnhealthyThreshold <= 0 || hc.HealthyThreshold <= 0 {
+			return nil, fmt.Errorf("некорректные настройки проверки доступности сервиса %s", upstream)
+		}
+		target, err := url.Parse(hc.Target)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный путь проверки доступности сервиса %s: %q", upstream, hc.Path)
+		}
+		c.checks[upstream] = &upstreamCheck{target: target, strict: hc.Path != "", cfg: hc.HealthCheckConfig}
+	}
+	if len(c.checks) == 0 {
+		return nil, nil
+	}
+	return c, nil
+}
+
+// run проверяет сервисы до остановки поколения
+func (c *upstreamHealthChecker) run(ctx context.Context) {
+	for upstream, check := range c.checks {
+		go c.watch(ctx, upstream, check)
+	}
+}
+
+// watch проверяет сервис upstream с периодом из его настроек. Первая
+// проверка выполняется сразу.
+func (c *upstreamHealthChecker) watch(ctx context.Context, upstream string, check *upstreamCheck) {
+	ticker := time.NewTicker(check.cfg.Interval.Std())
+	defer ticker.Stop()
+	for {
+		probeCtx, cancel := context.WithTimeout(ctx, check.cfg.Timeout.Std())
+		err := probeService(probeCtx, c.clients.For(check.target), check.target, check.strict)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		c.record(upstream, check, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// record учитывает результат проверки. Состояние меняется только после
+// unhealthy_threshold неудачных или healthy_threshold успешных проверок подряд.
+func (c *upstreamHealthChecker) record(upstream string, check *upstreamCheck, err error) {
+	check.mu.Lock()
+	defer check.mu.Unlock()
+
+	if err != nil {
+		check.successes = 0
+		check.failures++
+		check.reason = err.Error()
+		if check.unhealthy || check.failures < check.cfg.UnhealthyThreshold {
+			return
+		}
+		check.unhealthy, check.since = true, time.Now()
+		slog.Warn("Backend-сервис не прошел проверку доступности, запросы к нему отклоняются", "backend", upstream, "error", err)
+		c.transitions.Inc(upstream, "unhealthy")
+		return
+	}
+
+	check.failures = 0
+	check.successes++
+	if !check.unhealthy || check.successes < check.cfg.HealthyThreshold {
+		return
+	}
+	check.unhealthy, check.reason, check.since = false, "", time.Now()
+	slog.Info("Backend-сервис прошел проверку доступности", "backend", upstream)
+	c.transitions.Inc(upstream, "healthy")
+}
+
+// healthCheckStatus - состояние активной проверки сервиса для административного API
+type healthCheckStatus struct {
+	Healthy bool   `json:"healthy"`
+	Reason  string `json:"reason,omitempty"`
+	// Since - время последней смены состояния, пусто - состояние не менялось
+	Since    *time.Time `json:"since,omitempty"`
+	Failures int        `json:"consecutive_failures"`
+}
+
+// status возвращает состояние активной проверки сервиса upstream, nil - сервис не проверяется
+func (c *upstreamHealthChecker) status(upstream string) *healthCheckStatus {
+	if c == nil {
+		return nil
+	}
+	check, ok := c.checks[upstream]
+	if !ok {
+		return nil
+	}
+
+	check.mu.Lock()
+	defer check.mu.Unlock()
+	st := &healthCheckStatus{Healthy: !check.unhealthy, Reason: check.reason, Failures: check.failures}
+	if !check.since.IsZero() {
+		since := check.since
+		st.Since = &since
+	}
+	return st
+}
+
+// allow возвращает ошибку, если активная проверка признала сервис запроса недоступным
+func (c *upstreamHealthChecker) allow(req *http.Request) error {
+	if c == nil {
+		return nil
+	}
+	upstream := upstreamName(req.URL)
+	check, ok := c.checks[upstream]
+	if !ok {
+		return nil
+	}
+
+	check.mu.Lock()
+	defer check.mu.Unlock()
+	if !check.unhealthy {
+		return nil
+	}
+	c.rejected.Inc(upstream)
+	return &upstreamUnhealthyError{upstream: upstream, reason: check.reason, since: check.since}
+}
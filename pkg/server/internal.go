@@ -0,0 +1,71 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Заголовки клиента, которые не передаются внутренним запросам: тело, формат
+// и условие ответа у внутреннего запроса свои
+var internalSkippedHeaders = []string{"Content-Length", "Content-Type", "Accept", "Accept-Encoding", "Connection", "Upgrade", "If-None-Match"}
+
+// bufferWriter - ResponseWriter, накапливающий ответ в памяти
+type bufferWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferWriter() *bufferWriter {
+	return &bufferWriter{header: make(http.Header), status: http.StatusOK}
+}
+
+func (b *bufferWriter) Header() http.Header         { return b.header }
+func (b *bufferWriter) Write(p []byte) (int, error) { return b.body.Write(p) }
+func (b *bufferWriter) WriteHeader(code int)        { b.status = code }
+
+// serveInternal выполняет запрос через общую цепочку обработчиков шлюза без
+// сетевого обращения. Так дополнительные фронтенды (прогрев кэша, gRPC и т.д.)
+// получают то же кэширование, трассировку и логирование, что и HTTP API.
+func (s *Server) serveInternal(ctx context.Context, method, target string, header http.Header, body []byte, remoteAddr string) (*bufferWriter, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.RemoteAddr = remoteAddr
+	req = s.withClientIP(req)
+
+	rw := newBufferWriter()
+	s.validationMiddleware(s.mux).ServeHTTP(rw, req)
+	return rw, nil
+}
+
+// internalHeader возвращает заголовки исходного запроса клиента для внутреннего
+// запроса, чтобы авторизация и трассировка работали так же, как при прямом обращении
+func internalHeader(parent *http.Request) http.Header {
+	header := parent.Header.Clone()
+	for _, name := range internalSkippedHeaders {
+		header.Del(name)
+	}
+	return header
+}
+
+// propagateRequestID добавляет к пути request_id исходного запроса, если он не указан явно
+func propagateRequestID(target string, parent *http.Request) string {
+	requestID, ok := parent.Context().Value(requestIDKey).(string)
+	if !ok || requestID == "" || strings.Contains(target, "request_id=") {
+		return target
+	}
+	return appendQueryParam(target, "request_id", requestID)
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Коды ошибок JSON-RPC 2.0
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	// Коды из диапазона ошибок сервера: обработчик вернул ошибочный HTTP статус
+	rpcServerError = -32000
+	rpcNotFound    = -32004
+)
+
+// rpcRequest - запрос JSON-RPC 2.0
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	// ID отсутствует у уведомлений, на которые не отправляется ответ
+	ID json.RawMessage `json:"id,omitempty"`
+}
+
+// rpcResponse - ответ JSON-RPC 2.0
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// rpcError - ошибка JSON-RPC 2.0
+type rpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// rpcCall - запрос к HTTP обработчику, в который отображается метод JSON-RPC
+type rpcCall struct {
+	method string
+	target string
+	body   []byte
+}
+
+// rpcMethods сопоставляет методам JSON-RPC запросы к обработчикам шлюза.
+// Параметры передаются по имени (объектом).
+var rpcMethods = map[string]func(params json.RawMessage) (rpcCall, error){
+	"news.list": func(params json.RawMessage) (rpcCall, error) {
+		var p struct {
+			Page  int    `json:"page"`
+			Count int    `json:"count"`
+			S     string `json:"s"`
+			// Full - вернуть новости с описанием (/api/fullnews)
+			Full bool `json:"full"`
+		}
+		if err := decodeRPCParams(params, &p); err != nil {
+			return rpcCall{}, err
+		}
+
+		query := url.Values{}
+		if p.Page > 0 {
+			query.Set("page", strconv.Itoa(p.Page))
+		}
+		if p.Count > 0 {
+			query.Set("count", strconv.Itoa(p.Count))
+		}
+		if p.S != "" {
+			query.Set("s", p.S)
+		}
+		path := "/api/news"
+		if p.Full {
+			path = "/api/fullnews"
+		}
+		return rpcCall{method: http.MethodGet, target: path + "?" + query.Encode()}, nil
+	},
+	"news.get": func(params json.RawMessage) (rpcCall, error) {
+		var p struct {
+			ID int64 `json:"id"`
+			// Comments - вернуть новость вместе с комментариями
+			Comments bool `json:"comments"`
+		}
+		if err := decodeRPCParams(params, &p); err != nil {
+			return rpcCall{}, err
+		}
+		if p.ID <= 0 {
+			return rpcCall{}, fmt.Errorf("не указан ID новости (id)")
+		}
+
+		if p.Comments {
+			return rpcCall{method: http.MethodGet, target: fmt.Sprintf("/api/news?comm=%d", p.ID)}, nil
+		}
+		return rpcCall{method: http.MethodGet, target: fmt.Sprintf("/api/news/%d", p.ID)}, nil
+	},
+	"comments.list": func(params json.RawMessage) (rpcCall, error) {
+		var p struct {
+			NewsID int64 `json:"news_id"`
+		}
+		if err := decodeRPCParams(params, &p); err != nil {
+			return rpcCall{}, err
+		}
+		if p.NewsID <= 0 {
+			return rpcCall{}, fmt.Errorf("не указан ID новости (news_id)")
+		}
+		return rpcCall{method: http.MethodGet, target: fmt.Sprintf("/api/comments?id=%d", p.NewsID)}, nil
+	},
+	"comments.add": func(params json.RawMessage) (rpcCall, error) {
+		var p struct {
+			NewsID   int64  `json:"news_id"`
+			Text     string `json:"text"`
+			ParentID int64  `json:"parent_id"`
+		}
+		if err := decodeRPCParams(params, &p); err != nil {
+			return rpcCall{}, err
+		}
+		if p.NewsID <= 0 {
+			return rpcCall{}, fmt.Errorf("не указан ID новости (news_id)")
+		}
+
+		body, err := json.Marshal(addCommentRequest{Text: p.Text, ParentID: p.ParentID})
+		if err != nil {
+			return rpcCall{}, err
+		}
+		return rpcCall{method: http.MethodPost, target: fmt.Sprintf("/api/comments/add?news_id=%d", p.NewsID), body: body}, nil
+	},
+}
+
+// decodeRPCParams декодирует именованные параметры метода
+func decodeRPCParams(params json.RawMessage, v interface{}) error {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if params[0] != '{' {
+		return fmt.Errorf("параметры должны передаваться по имени (объектом)")
+	}
+	return json.Unmarshal(params, v)
+}
+
+// handleJSONRPC обрабатывает запросы JSON-RPC 2.0, в том числе пакетные,
+// выполняя методы через общую цепочку обработчиков шлюза
+func (s *Server) handleJSONRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Метод не разрешен")
+		return
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		slog.DebugContext(r.Context(), "Ошибка при чтении запроса JSON-RPC", "error", err)
+		writeRPC(w, rpcFailure(nil, rpcParseError, "Parse error", nil))
+		return
+	}
+
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(raw, &batch); err != nil || len(batch) == 0 {
+			writeRPC(w, rpcFailure(nil, rpcInvalidRequest, "Invalid Request", nil))
+			return
+		}
+
+		var responses []*rpcResponse
+		for _, item := range batch {
+			if resp := s.executeRPC(r, item); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		// На пакет из одних уведомлений ответ не отправляется
+		if len(responses) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeRPC(w, responses)
+		return
+	}
+
+	resp := s.executeRPC(r, raw)
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeRPC(w, resp)
+}
+
+// executeRPC выполняет один вызов. Для уведомлений возвращает nil.
+func (s *Server) executeRPC(parent *http.Request, raw json.RawMessage) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" {
+		return rpcFailure(req.ID, rpcInvalidRequest, "Invalid Request", nil)
+	}
+	notification := len(req.ID) == 0
+
+	build, ok := rpcMethods[req.Method]
+	if !ok {
+		if notification {
+			return nil
+		}
+		return rpcFailure(req.ID, rpcMethodNotFound, "Method not found", req.Method)
+	}
+
+	call, err := build(req.Params)
+	if err != nil {
+		if notification {
+			return nil
+		}
+		return rpcFailure(req.ID, rpcInvalidParams, "Invalid params", err.Error())
+	}
+
+	header := internalHeader(parent)
+	if call.body != nil {
+		header.Set("Content-Type", "application/json")
+	}
+
+	rw, err := s.serveInternal(parent.Context(), call.method, propagateRequestID(call.target, parent), header, call.body, parent.RemoteAddr)
+	if notification {
+		return nil
+	}
+	if err != nil {
+		return rpcFailure(req.ID, rpcInternalError, "Internal error", err.Error())
+	}
+
+	body := rw.body.Bytes()
+	if rw.status < 200 || rw.status >= 300 {
+		code := rpcServerError
+		switch rw.status {
+		case http.StatusBadRequest:
+			code = rpcInvalidParams
+		case http.StatusNotFound:
+			code = rpcNotFound
+		}
+		return rpcFailure(req.ID, code, errorMessage(body), map[string]int{"status": rw.status})
+	}
+
+	if !json.Valid(body) {
+		return rpcFailure(req.ID, rpcInternalError, "Internal error", "ответ обработчика не в формате JSON")
+	}
+	return &rpcResponse{JSONRPC: "2.0", Result: json.RawMessage(body), ID: req.ID}
+}
+
+// rpcFailure формирует ответ с ошибкой
+func rpcFailure(id json.RawMessage, code int, message string, data interface{}) *rpcResponse {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: code, Message: message, Data: data}, ID: id}
+}
+
+// writeRPC отправляет ответ JSON-RPC; ошибки передаются в теле со статусом 200
+func writeRPC(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Debug("Ошибка при кодировании ответа JSON-RPC", "error", err)
+	}
+}
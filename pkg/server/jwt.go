@@ -0,0 +1,212 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"apigw/pkg/config"
+	"apigw/pkg/jwt"
+	"apigw/pkg/metrics"
+)
+
+// Ключ контекста для хранения утверждений проверенного токена
+const claimsKey contextKey = "jwtClaims"
+
+// jwksTimeout - таймаут загрузки набора ключей издателя
+const jwksTimeout = 5 * time.Second
+
+// jwtRule - маршрут, для которого проверяется токен
+type jwtRule struct {
+	path     string
+	method   string
+	optional bool
+}
+
+// jwtAuth проверяет токены JWT на маршрутах из секции jwt
+type jwtAuth struct {
+	validator  *jwt.Validator
+	userClaim  string
+	rolesClaim string
+	rules      []jwtRule
+	failures   *metrics.CounterVec
+}
+
+// newJWTAuth проверяет секцию jwt и готовит ключи. Возвращает nil, если проверка отключена.
+func newJWTAuth(cfg config.JWTConfig, registry *metrics.Registry) (*jwtAuth, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	keys := &jwt.KeySet{HMAC: []byte(cfg.Secret)}
+	if cfg.PublicKeyFile != "" {
+		data, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать открытый ключ JWT: %w", err)
+		}
+		if keys.RSA, err = jwt.ParseRSAPublicKey(data); err != nil {
+			return nil, fmt.Errorf("некорректный открытый ключ JWT: %w", err)
+		}
+	}
+	issuer := cfg.Issuer
+	switch {
+	case cfg.JWKSURL != "":
+		if u, err := url.Parse(cfg.JWKSURL); err != nil || u.Host == "" {
+			return nil, fmt.Errorf("некорректный адрес JWKS: %q", cfg.JWKSURL)
+		}
+		keys.JWKS = jwt.NewJWKS(cfg.JWKSURL, cfg.JWKSRefresh.Std(), &http.Client{Timeout: jwksTimeout})
+	case cfg.OIDC.IssuerURL != "":
+		// Адрес ключей определяется при первой проверке токена, чтобы
+		// запуск не зависел от доступности издателя
+		keys.JWKS = jwt.NewOIDCJWKS(cfg.OIDC.IssuerURL, cfg.JWKSRefresh.Std(), &http.Client{Timeout: jwksTimeout})
+	}
+	if cfg.OIDC.IssuerURL != "" {
+		if u, err := url.Parse(cfg.OIDC.IssuerURL); err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+			return nil, fmt.Errorf("некорректный адрес издателя OpenID Connect: %q", cfg.OIDC.IssuerURL)
+		}
+		if issuer == "" {
+			issuer = cfg.OIDC.IssuerURL
+		}
+	}
+	if len(keys.HMAC) == 0 && keys.RSA == nil && keys.JWKS == nil {
+		return nil, fmt.Errorf("для проверки JWT нужно указать secret, public_key_file, jwks_url или oidc.issuer_url")
+	}
+
+	a := &jwtAuth{
+		validator: &jwt.Validator{
+			Keys:     keys,
+			Issuer:   issuer,
+			Audience: cfg.Audience,
+			ClientID: cfg.OIDC.ClientID,
+			Leeway:   cfg.Leeway.Std(),
+		},
+		userClaim:  cfg.UserClaim,
+		rolesClaim: cfg.RolesClaim,
+		failures: registry.Counter("apigw_jwt_failures_total",
+			"Запросы, отклоненные проверкой токена JWT", "reason"),
+	}
+	for _, rc := range cfg.Routes {
+		if !strings.HasPrefix(rc.Path, "/") {
+			return nil, fmt.Errorf("путь проверки JWT должен начинаться с /: %q", rc.Path)
+		}
+		a.rules = append(a.rules, jwtRule{path: rc.Path, method: strings.ToUpper(rc.Method), optional: rc.Optional})
+	}
+	return a, nil
+}
+
+// errTokenMissing - токен не указан для маршрута, где он обязателен
+var errTokenMissing = errors.New("токен доступа не указан")
+
+// rule возвращает правило для запроса или nil, если токен не проверяется
+func (a *jwtAuth) rule(method, path string) *jwtRule {
+	for i, rule := range a.rules {
+		if (rule.method == "" || rule.method == method) && pathMatches(rule.path, path) {
+			return &a.rules[i]
+		}
+	}
+	return nil
+}
+
+// verify проверяет токен из заголовков запроса по правилу маршрута. Для
+// необязательного токена без заголовка возвращает nil без ошибки.
+func (a *jwtAuth) verify(rule *jwtRule, header http.Header) (jwt.Claims, error) {
+	token := bearerToken(header)
+	if token == "" {
+		if rule.optional {
+			return nil, nil
+		}
+		a.failures.Inc("missing")
+		return nil, errTokenMissing
+	}
+
+	claims, err := a.validator.Validate(token)
+	if err != nil {
+		reason := "invalid"
+		if errors.Is(err, jwt.ErrExpired) {
+			reason = "expired"
+		}
+		a.failures.Inc(reason)
+		return nil, err
+	}
+	return claims, nil
+}
+
+// bearerToken возвращает токен из заголовка Authorization: Bearer
+func bearerToken(header http.Header) string {
+	scheme, token, ok := strings.Cut(header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// jwtMiddleware проверяет токен пользователя и передает его утверждения
+// обработчикам через контекст
+func (s *Server) jwtMiddleware(next http.Handler) http.Handler {
+	if s.jwt == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule := s.jwt.rule(r.Method, unversionedPath(r.URL.Path))
+		if rule == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		claims, err := s.jwt.verify(rule, r.Header)
+		switch {
+		case err != nil:
+			writeTokenError(w, r, err)
+			return
+		case claims == nil:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(withClaims(r.Context(), claims)))
+	})
+}
+
+// writeTokenError отправляет ответ 401 на запрос без токена или с недействительным токеном
+func writeTokenError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errTokenMissing) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="apigw"`)
+		writeError(w, r, http.StatusUnauthorized, codeUnauthorized, "Требуется токен доступа")
+		return
+	}
+
+	slog.WarnContext(r.Context(), "Запрос отклонен: недействительный токен доступа", "method", r.Method, "path", r.URL.Path, "error", err)
+	w.Header().Set("WWW-Authenticate", `Bearer realm="apigw", error="invalid_token"`)
+	message := "Недействительный токен доступа"
+	if errors.Is(err, jwt.ErrExpired) {
+		message = "Срок действия токена доступа истек"
+	}
+	writeError(w, r, http.StatusUnauthorized, codeUnauthorized, message)
+}
+
+// withClaims сохраняет в контексте утверждения проверенного токена
+func withClaims(ctx context.Context, claims jwt.Claims) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
+}
+
+// claimsFrom возвращает утверждения проверенного токена запроса или nil
+func claimsFrom(ctx context.Context) jwt.Claims {
+	claims, _ := ctx.Value(claimsKey).(jwt.Claims)
+	return claims
+}
+
+// userIDFrom возвращает идентификатор пользователя из проверенного токена
+// запроса или пустую строку
+func (s *Server) userIDFrom(ctx context.Context) string {
+	if s.jwt == nil {
+		return ""
+	}
+	return claimsFrom(ctx).String(s.jwt.userClaim)
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"apigw/pkg/config"
+	"apigw/pkg/limit"
+)
+
+// errUpstreamOverloaded возвращается, если исчерпан лимит одновременных запросов к backend-сервису
+var errUpstreamOverloaded = errors.New("превышен лимит одновременных запросов к сервису")
+
+// upstreamLimits ведет адаптивные лимиты одновременных запросов отдельно
+// для каждого backend-сервиса. nil означает, что ограничение отключено.
+type upstreamLimits struct {
+	opts limit.Options
+
+	mu       sync.Mutex
+	limiters map[string]*limit.Gradient
+}
+
+// newUpstreamLimits создает лимиты согласно секции concurrency
+func newUpstreamLimits(cfg config.ConcurrencyConfig) *upstreamLimits {
+	if !cfg.Adaptive {
+		return nil
+	}
+	return &upstreamLimits{
+		opts: limit.Options{
+			InitialLimit: cfg.InitialLimit,
+			MinLimit:     cfg.MinLimit,
+			MaxLimit:     cfg.MaxLimit,
+			Smoothing:    cfg.Smoothing,
+			Tolerance:    cfg.Tolerance,
+		},
+		limiters: make(map[string]*limit.Gradient),
+	}
+}
+
+// acquire занимает место для запроса к backend-сервису. Запросу доступна
+// доля лимита его класса приоритета. Возвращает false, если эта доля лимита
+// сервиса исчерпана. После получения ответа нужно вызвать release.
+func (l *upstreamLimits) acquire(req *http.Request) (release func(*http.Response, error), ok bool) {
+	if l == nil {
+		return func(*http.Response, error) {}, true
+	}
+
+	upstream := upstreamName(req.URL)
+	l.mu.Lock()
+	limiter, exists := l.limiters[upstream]
+	if !exists {
+		limiter = limit.NewGradient(l.opts)
+		l.limiters[upstream] = limiter
+	}
+	l.mu.Unlock()
+
+	done, ok := limiter.AcquireShare(concurrencyShare(req.Context()))
+	if !ok {
+		return nil, false
+	}
+	return func(resp *http.Response, err error) {
+		done(limitOutcome(resp, err))
+	}, true
+}
+
+// limitOutcome оценивает результат запроса для адаптивного лимита
+func limitOutcome(resp *http.Response, err error) limit.Outcome {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return limit.Ignored
+	case err != nil:
+		return limit.Dropped
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return limit.Dropped
+	}
+	return limit.Success
+}
+
+// upstreamName возвращает имя backend-сервиса по адресу запроса
+func upstreamName(target *url.URL) string {
+	return target.Scheme + "://" + target.Host
+}
+
+// send выполняет одну попытку запроса к backend-сервису клиентом этого
+// сервиса, если сервис не признан недоступным активной проверкой и его
+// выключатель замкнут, с учетом лимитов одновременных запросов и
+// отслеживанием доступности сервиса. Задержкой для адаптивного лимита
+// считается время до получения заголовков ответа.
+func (t *upstreamTransport) send(req *http.Request) (*http.Response, error) {
+	if err := t.checker.allow(req); err != nil {
+		return nil, err
+	}
+	// Место в bulkhead занимается до выключателя, чтобы пробные запросы
+	// разомкнутого выключателя не ждали в очереди
+	leave, err := t.bulkheads.acquire(req)
+	if err != nil {
+		return nil, err
+	}
+	done, ok := t.breakers.acquire(req)
+	if !ok {
+		leave(nil, nil)
+		return nil, errCircuitOpen
+	}
+	release, ok := t.limits.acquire(req)
+	if !ok {
+		slog.WarnContext(req.Context(), "Превышен лимит одновременных запросов", "backend", upstreamName(req.URL))
+		done(nil, nil)
+		leave(nil, nil)
+		return nil, errUpstreamOverloaded
+	}
+
+	// Внесенные сбои учитываются лимитом, выключателем и проверкой доступности как настоящие
+	start := time.Now()
+	req, finish := withServiceTimeout(t.timeouts, req)
+	resp, err := t.faults.inject(req)
+	if resp == nil && err == nil {
+		resp, err = t.clients.For(req.URL).Do(req)
+	}
+	finish(resp, err)
+	leave(resp, err)
+	logUpstreamRequest(req, resp, err, time.Since(start))
+	release(resp, err)
+	done(resp, err)
+	t.health.observe(req.URL, resp, err)
+	return resp, err
+}
+
+// logUpstreamRequest записывает в журнал запрос к backend-сервису с задержкой
+// до получения заголовков ответа и добавляет его в строку журнала запросов
+func logUpstreamRequest(req *http.Request, resp *http.Response, err error, latency time.Duration) {
+	recordBackendCall(req, resp, err, latency)
+
+	attrs := []slog.Attr{
+		slog.String("backend", upstreamName(req.URL)),
+		slog.String("method", req.Method),
+		slog.String("url", req.URL.Redacted()),
+		slog.Duration("latency", latency),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+		slog.LogAttrs(req.Context(), slog.LevelWarn, "Ошибка запроса к backend-сервису", attrs...)
+		return
+	}
+	attrs = append(attrs, slog.Int("status", resp.StatusCode))
+	slog.LogAttrs(req.Context(), slog.LevelDebug, "Запрос к backend-сервису", attrs...)
+}
+
+// backendErrorStatus возвращает статус ответа клиенту при ошибке запроса к backend-сервису
+func backendErrorStatus(err error) int {
+	var unhealthy *upstreamUnhealthyError
+	if errors.Is(err, errUpstreamOverloaded) || errors.Is(err, errCircuitOpen) || errors.As(err, &unhealthy) {
+		return http.StatusServiceUnavailable
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"apigw/pkg/config"
+)
+
+// Ключ контекста для хранения маршрута запроса в журнале
+const routeKey contextKey = "route"
+
+// logLevels - допустимые значения log.level
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// logLevel - текущий уровень журнала процесса. Задается секцией log и может
+// быть изменен через административный API до следующей перезагрузки конфигурации.
+var logLevel = new(slog.LevelVar)
+
+// newLogHandler проверяет секцию log и создает обработчик записей журнала,
+// пишущий в w. Уровень из конфигурации записывается в level.
+func newLogHandler(cfg config.LogConfig, w io.Writer, level *slog.LevelVar) (slog.Handler, error) {
+	l, ok := logLevels[strings.ToLower(cfg.Level)]
+	if !ok {
+		return nil, fmt.Errorf("неизвестный уровень журнала log.level: %q", cfg.Level)
+	}
+	level.Set(l)
+
+	return newFormatHandler(cfg.Format, w, &slog.HandlerOptions{Level: level})
+}
+
+// newFormatHandler создает обработчик записей журнала в формате log.format
+func newFormatHandler(format string, w io.Writer, opts *slog.HandlerOptions) (slog.Handler, error) {
+	switch format {
+	case "", "text":
+		return contextHandler{slog.NewTextHandler(w, opts)}, nil
+	case "json":
+		return contextHandler{slog.NewJSONHandler(w, opts)}, nil
+	default:
+		return nil, fmt.Errorf("неизвестный формат журнала log.format: %q", format)
+	}
+}
+
+// SetupLogging настраивает журнал процесса и журнал запросов по секции log.
+// Записи стандартного пакета log также проходят через журнал процесса с
+// уровнем info. Файл журнала запросов открывается заново при каждом вызове,
+// что позволяет ротацию журнала перезагрузкой конфигурации.
+func SetupLogging(cfg config.LogConfig) error {
+	handler, err := newLogHandler(cfg, os.Stderr, logLevel)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(handler))
+
+	access, err := newAccessLogger(cfg)
+	if err != nil {
+		return err
+	}
+	if err := access.open(); err != nil {
+		return err
+	}
+	if prev := accessLog.Swap(access); prev != nil {
+		prev.close()
+	}
+	return nil
+}
+
+// contextHandler добавляет к записям журнала сведения о запросе из контекста:
+// request_id, маршрут, клиента, арендатора и новые версии сервисов
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle дополняет запись полями запроса и передает ее обработчику
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if ctx != nil {
+		if requestID, ok := ctx.Value(requestIDKey).(string); ok && requestID != "" {
+			record.AddAttrs(slog.String("request_id", requestID))
+		}
+		if route, ok := ctx.Value(routeKey).(string); ok {
+			record.AddAttrs(slog.String("route", route))
+		}
+		if client := clientFrom(ctx); client != "" {
+			record.AddAttrs(slog.String("client", client))
+		}
+		if t := tenantFrom(ctx); t != nil {
+			record.AddAttrs(slog.String("tenant", t.name))
+		}
+		if targets := canaryTargets(ctx); len(targets) > 0 {
+			record.AddAttrs(slog.String("canary", strings.Join(targets, ",")))
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs возвращает обработчик с дополнительными полями
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup возвращает обработчик, добавляющий поля в группу name
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"apigw/pkg/config"
+)
+
+// maxOverrideFormSize - максимальный размер формы, в которой ищется поле _method.
+// Формы большего размера передаются обработчику без подмены метода.
+const maxOverrideFormSize = 64 << 10
+
+// methodOverrider подменяет метод POST запросов согласно секции method_override
+type methodOverrider struct {
+	// methods - разрешенные методы
+	methods map[string]bool
+}
+
+// newMethodOverrider проверяет настройки подмены метода. Возвращает nil, если подмена отключена.
+func newMethodOverrider(cfg config.MethodOverrideConfig) (*methodOverrider, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	o := &methodOverrider{methods: make(map[string]bool)}
+	for _, method := range cfg.Methods {
+		method = strings.ToUpper(method)
+		switch method {
+		case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+			o.methods[method] = true
+		default:
+			return nil, fmt.Errorf("метод %q нельзя использовать для подмены POST", method)
+		}
+	}
+	return o, nil
+}
+
+// requestedMethod возвращает метод, указанный клиентом в заголовке
+// X-HTTP-Method-Override или поле формы _method. Тело формы после чтения
+// восстанавливается, чтобы обработчик получил его целиком.
+func requestedMethod(r *http.Request) string {
+	if method := r.Header.Get("X-HTTP-Method-Override"); method != "" {
+		return method
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/x-www-form-urlencoded" || r.Body == nil {
+		return ""
+	}
+	if r.ContentLength > maxOverrideFormSize {
+		return ""
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxOverrideFormSize+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil || len(body) > maxOverrideFormSize {
+		return ""
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return ""
+	}
+	return form.Get("_method")
+}
+
+// methodOverrideMiddleware заменяет метод POST запроса на указанный клиентом
+// до выбора маршрута. Неразрешенный метод отклоняется со статусом 400.
+func (s *Server) methodOverrideMiddleware(next http.Handler) http.Handler {
+	if s.methodOverride == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		method := strings.ToUpper(strings.TrimSpace(requestedMethod(r)))
+		if method == "" || method == http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !s.methodOverride.methods[method] {
+			slog.WarnContext(r.Context(), "Запрос отклонен: подмена метода запрещена", "path", r.URL.Path, "override", method)
+			writeError(w, r, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("Подмена метода на %s не разрешена", method))
+			return
+		}
+
+		r.Method = method
+		r.Header.Del("X-HTTP-Method-Override")
+		next.ServeHTTP(w, r)
+	})
+}
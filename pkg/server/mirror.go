@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"apigw/pkg/config"
+	"apigw/pkg/httpclient"
+	"apigw/pkg/metrics"
+)
+
+// Значения по умолчанию для незаданных полей секции mirror сервиса
+const (
+	defaultMirrorTimeout     = 10 * time.Second
+	defaultMirrorMaxInFlight = 100
+)
+
+// shadowRequestHeader отмечает копии запросов, чтобы теневой сервис мог
+// отличить их, например не отправлять уведомления
+const shadowRequestHeader = "X-Shadow-Request"
+
+// upstreamMirrors отправляет копии запросов на чтение к backend-сервисам
+// на теневые сервисы. nil означает, что зеркалирование не настроено.
+type upstreamMirrors struct {
+	// client - отдельный пул соединений, чтобы копии не занимали соединения с сервисами
+	client *http.Client
+	// targets - теневые сервисы по имени backend-сервиса (см. upstreamName)
+	targets  map[string]*mirrorTarget
+	requests *metrics.CounterVec
+}
+
+// mirrorTarget - теневой сервис одного backend-сервиса
+type mirrorTarget struct {
+	base    *url.URL
+	percent float64
+	timeout time.Duration
+	// slots ограничивает число одновременно выполняющихся копий
+	slots chan struct{}
+}
+
+// newUpstreamMirrors проверяет секции mirror сервисов. Копии отправляются
+// клиентом с настройками секции http_client.
+func newUpstreamMirrors(cfg *config.Config, registry *metrics.Registry) (*upstreamMirrors, error) {
+	settings, err := upstreamSettings(cfg, "зеркалирования", func(service config.ServiceConfig) *config.MirrorConfig {
+		return service.Mirror
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make(map[string]*mirrorTarget)
+	for upstream, mc := range settings {
+		if mc == nil {
+			continue
+		}
+		base, err := url.Parse(mc.URL)
+		if err != nil || base.Host == "" || (base.Scheme != "http" && base.Scheme != "https") {
+			return nil, fmt.Errorf("некорректный адрес теневого сервиса %s: %q", upstream, mc.URL)
+		}
+		if mc.Percent < 0 || mc.Percent > 100 {
+			return nil, fmt.Errorf("некорректная доля копируемых запросов сервиса %s: %g", upstream, mc.Percent)
+		}
+		if mc.Timeout < 0 || mc.MaxInFlight < 0 {
+			return nil, fmt.Errorf("некорректные timeout или max_in_flight зеркалирования сервиса %s", upstream)
+		}
+
+		target := &mirrorTarget{base: base, percent: mc.Percent, timeout: mc.Timeout.Std()}
+		if target.percent == 0 {
+			target.percent = 100
+		}
+		if target.timeout == 0 {
+			target.timeout = defaultMirrorTimeout
+		}
+		maxInFlight := mc.MaxInFlight
+		if maxInFlight == 0 {
+			maxInFlight = defaultMirrorMaxInFlight
+		}
+		target.slots = make(chan struct{}, maxInFlight)
+		targets[upstream] = target
+	}
+	if len(targets) == 0 {
+		return nil, nil
+	}
+
+	return &upstreamMirrors{
+		client:  httpclient.New(clientOptions(cfg.HTTPClient)),
+		targets: targets,
+		requests: registry.Counter("apigw_mirror_requests_total",
+			"Копии запросов к теневым сервисам по результату (sent, error, skipped)", "upstream", "result"),
+	}, nil
+}
+
+// mirror отправляет копию запроса GET или HEAD на теневой сервис, если он
+// задан для сервиса запроса. Копия выполняется в фоне и не задерживает
+// запрос; если одновременных копий уже максимальное количество, копия
+// пропускается.
+func (m *upstreamMirrors) mirror(req *http.Request) {
+	if m == nil || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+		return
+	}
+	upstream := upstreamName(req.URL)
+	target, ok := m.targets[upstream]
+	if !ok || (target.percent < 100 && rand.Float64()*100 >= target.percent) {
+		return
+	}
+
+	select {
+	case target.slots <- struct{}{}:
+	default:
+		m.requests.Inc(upstream, "skipped")
+		return
+	}
+
+	// Копия не должна прерываться вместе с запросом клиента
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(req.Context()), target.timeout)
+	shadow, err := http.NewRequestWithContext(ctx, req.Method, target.url(req.URL).String(), nil)
+	if err != nil {
+		cancel()
+		<-target.slots
+		return
+	}
+	copyHeaders(shadow.Header, req.Header)
+	shadow.Header.Set(shadowRequestHeader, "1")
+
+	go func() {
+		defer func() { <-target.slots }()
+		defer cancel()
+
+		start := time.Now()
+		resp, err := m.client.Do(shadow)
+		if err != nil {
+			m.requests.Inc(upstream, "error")
+			slog.DebugContext(ctx, "Ошибка запроса к теневому сервису", "backend", upstream, "url", shadow.URL.Redacted(), "error", err)
+			return
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		m.requests.Inc(upstream, "sent")
+		slog.DebugContext(ctx, "Копия запроса отправлена на теневой сервис", "backend", upstream, "url", shadow.URL.Redacted(),
+			"status", resp.StatusCode, "latency", time.Since(start))
+	}()
+}
+
+// url возвращает адрес копии запроса к target на теневом сервисе
+func (t *mirrorTarget) url(target *url.URL) *url.URL {
+	u := *t.base
+	u.Path = strings.TrimSuffix(t.base.Path, "/") + target.Path
+	u.RawPath = ""
+	u.RawQuery = target.RawQuery
+	return &u
+}
+
+// closeIdleConnections закрывает простаивающие соединения с теневыми сервисами
+func (m *upstreamMirrors) closeIdleConnections() {
+	if m != nil {
+		m.client.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"apigw/pkg/config"
+	"apigw/pkg/httpclient"
+	"apigw/pkg/metrics"
+)
+
+// moderationReasonWords - причина отклонения комментария со словами из списка
+const moderationReasonWords = "Комментарий содержит запрещенные слова"
+
+// moderationResponseLimit ограничивает размер ответа сервиса модерации
+const moderationResponseLimit = 64 << 10
+
+// commentModerator проверяет комментарии перед отправкой в сервис
+// комментариев. nil означает, что модерация не настроена.
+type commentModerator struct {
+	// words - запрещенные слова в нормализованном виде (см. normalizeWord)
+	words map[string]struct{}
+
+	// service - адрес внешнего сервиса модерации, nil если не задан
+	service  *url.URL
+	client   *http.Client
+	timeout  time.Duration
+	failOpen bool
+
+	results *metrics.CounterVec
+}
+
+// moderationRequest - комментарий, который проверяет сервис модерации
+type moderationRequest struct {
+	NewsID int64  `json:"news_id"`
+	Text   string `json:"text"`
+	UserID string `json:"user_id,omitempty"`
+}
+
+// moderationVerdict - решение сервиса модерации
+type moderationVerdict struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"`
+}
+
+// newCommentModerator проверяет секцию moderation и загружает список
+// запрещенных слов. Запросы к сервису модерации отправляются клиентом с
+// настройками секции http_client. Возвращает nil, если модерация не настроена.
+func newCommentModerator(cfg *config.Config, registry *metrics.Registry) (*commentModerator, error) {
+	mc := cfg.Moderation
+	words := append([]string(nil), mc.Words...)
+	if mc.WordsFile != "" {
+		fileWords, err := readModerationWords(mc.WordsFile)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, fileWords...)
+	}
+	if len(words) == 0 && mc.URL == "" {
+		return nil, nil
+	}
+
+	m := &commentModerator{
+		words:    make(map[string]struct{}, len(words)),
+		failOpen: mc.FailOpen,
+		results: registry.Counter("apigw_comment_moderation_total",
+			"Комментарии, проверенные модерацией, по результату (accepted, rejected, error)", "result"),
+	}
+	for _, word := range words {
+		normalized := normalizeWord(strings.TrimSpace(word))
+		if normalized == "" {
+			continue
+		}
+		if strings.IndexFunc(normalized, isWordSeparator) >= 0 {
+			return nil, fmt.Errorf("запрещенное слово модерации должно быть одним словом: %q", word)
+		}
+		m.words[normalized] = struct{}{}
+	}
+
+	if mc.URL != "" {
+		service, err := url.Parse(mc.URL)
+		if err != nil || service.Host == "" || (service.Scheme != "http" && service.Scheme != "https") {
+			return nil, fmt.Errorf("некорректный адрес сервиса модерации moderation.url: %q", mc.URL)
+		}
+		if mc.Timeout <= 0 {
+			return nil, fmt.Errorf("некорректный таймаут сервиса модерации moderation.timeout: %s", mc.Timeout.Std())
+		}
+		m.service = service
+		m.client = httpclient.New(clientOptions(cfg.HTTPClient))
+		m.timeout = mc.Timeout.Std()
+	}
+	return m, nil
+}
+
+// readModerationWords читает файл со словами по одному на строке. Пустые
+// строки и строки, начинающиеся с #, пропускаются.
+func readModerationWords(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать список запрещенных слов moderation.words_file: %w", err)
+	}
+	var words []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words = append(words, line)
+		}
+	}
+	return words, scanner.Err()
+}
+
+// check проверяет комментарий. Возвращает причину отклонения или пустую
+// строку, если комментарий принят. Ошибка означает, что сервис модерации
+// недоступен и комментарий нельзя принять без проверки.
+func (m *commentModerator) check(ctx context.Context, req moderationRequest) (string, error) {
+	if m.containsWords(req.Text) {
+		m.results.Inc("rejected")
+		slog.InfoContext(ctx, "Комментарий отклонен модерацией", "news_id", req.NewsID, "reason", moderationReasonWords)
+		return moderationReasonWords, nil
+	}
+	if m.service == nil {
+		m.results.Inc("accepted")
+		return "", nil
+	}
+
+	verdict, err := m.askService(ctx, req)
+	if err != nil {
+		m.results.Inc("error")
+		if m.failOpen {
+			slog.WarnContext(ctx, "Сервис модерации недоступен, комментарий принят без проверки", "url", m.service.Redacted(), "error", err)
+			return "", nil
+		}
+		slog.WarnContext(ctx, "Сервис модерации недоступен", "url", m.service.Redacted(), "error", err)
+		return "", err
+	}
+	if !verdict.Allowed {
+		m.results.Inc("rejected")
+		reason := verdict.Reason
+		if reason == "" {
+			reason = "Комментарий отклонен модерацией"
+		}
+		slog.InfoContext(ctx, "Комментарий отклонен модерацией", "news_id", req.NewsID, "reason", reason)
+		return reason, nil
+	}
+	m.results.Inc("accepted")
+	return "", nil
+}
+
+// containsWords проверяет, есть ли в тексте запрещенные слова
+func (m *commentModerator) containsWords(text string) bool {
+	if len(m.words) == 0 {
+		return false
+	}
+	for _, word := range strings.FieldsFunc(text, isWordSeparator) {
+		if _, ok := m.words[normalizeWord(word)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// askService отправляет комментарий сервису модерации: POST с телом
+// moderationRequest, в ответ ожидается 200 с moderationVerdict
+func (m *commentModerator) askService(ctx context.Context, req moderationRequest) (moderationVerdict, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return moderationVerdict{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, m.service.String(), bytes.NewReader(body))
+	if err != nil {
+		return moderationVerdict{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if requestID, ok := ctx.Value(requestIDKey).(string); ok && requestID != "" {
+		httpReq.Header.Set(requestIDHeaderName, requestID)
+	}
+
+	resp, err := m.client.Do(httpReq)
+	if err != nil {
+		return moderationVerdict{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, moderationResponseLimit))
+		return moderationVerdict{}, fmt.Errorf("сервис модерации вернул статус %d", resp.StatusCode)
+	}
+
+	var verdict moderationVerdict
+	if err := json.NewDecoder(io.LimitReader(resp.Body, moderationResponseLimit)).Decode(&verdict); err != nil {
+		return moderationVerdict{}, fmt.Errorf("некорректный ответ сервиса модерации: %w", err)
+	}
+	return verdict, nil
+}
+
+// isWordSeparator сообщает, что символ разделяет слова
+func isWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// normalizeWord приводит слово к нижнему регистру и заменяет ё на е
+func normalizeWord(word string) string {
+	return strings.ReplaceAll(strings.ToLower(word), "ё", "е")
+}
+
+// withModeratedFlag добавляет "moderated": true в ответ сервиса комментариев,
+// если он не сохранил отметку. Ответ не в виде объекта JSON не изменяется.
+func withModeratedFlag(body []byte) []byte {
+	var comment map[string]json.RawMessage
+	if json.Unmarshal(body, &comment) != nil || comment == nil {
+		return body
+	}
+	if _, ok := comment["moderated"]; ok {
+		return body
+	}
+	comment["moderated"] = json.RawMessage("true")
+	flagged, err := json.Marshal(comment)
+	if err != nil {
+		return body
+	}
+	return append(flagged, '\n')
+}
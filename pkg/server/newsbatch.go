@@ -0,0 +1,110 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Ограничения запроса нескольких новостей /api/news/batch
+const (
+	// newsBatchMaxIDs - максимальное количество ID в запросе
+	newsBatchMaxIDs = 100
+	// newsBatchConcurrency - максимальное число одновременных запросов к сервису новостей
+	newsBatchConcurrency = 10
+)
+
+// NewsBatchResponse представляет ответ с несколькими новостями
+type NewsBatchResponse struct {
+	Items    []json.RawMessage `json:"items"`     // Найденные новости в порядке ids
+	NotFound []int64           `json:"not_found"` // ID отсутствующих новостей
+}
+
+// newsBatchResult - результат запроса одной новости
+type newsBatchResult struct {
+	item json.RawMessage
+	err  error
+}
+
+// handleNewsBatch обрабатывает запросы нескольких новостей по ID
+// (/api/news/batch?ids=1,2,3). Новости запрашиваются у сервиса параллельно и
+// возвращаются в порядке ids; повторяющиеся ID запрашиваются один раз.
+func (s *Server) handleNewsBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Метод не разрешен")
+		return
+	}
+
+	ids, ok := parseNewsIDs(r.URL.Query().Get("ids"))
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, codeInvalidNewsID, "Не указаны или некорректны ID новостей")
+		return
+	}
+	if len(ids) > newsBatchMaxIDs {
+		writeErrorDetails(w, r, http.StatusBadRequest, codeTooManyItems,
+			fmt.Sprintf("Слишком много ID новостей в запросе (максимум %d)", newsBatchMaxIDs),
+			map[string]int{"max_items": newsBatchMaxIDs})
+		return
+	}
+
+	results := make(map[int64]*newsBatchResult, len(ids))
+	for _, id := range ids {
+		results[id] = &newsBatchResult{}
+	}
+
+	sem := make(chan struct{}, newsBatchConcurrency)
+	var wg sync.WaitGroup
+	for id, result := range results {
+		wg.Add(1)
+		go func(id int64, result *newsBatchResult) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			result.item, result.err = s.fetchRawNewsItem(r.Context(), id)
+		}(id, result)
+	}
+	wg.Wait()
+
+	response := NewsBatchResponse{Items: []json.RawMessage{}, NotFound: []int64{}}
+	for _, id := range ids {
+		result := results[id]
+		var respErr *responseError
+		switch {
+		case result.err == nil:
+			response.Items = append(response.Items, result.item)
+		case errors.As(result.err, &respErr) && respErr.code == codeNewsNotFound:
+			response.NotFound = append(response.NotFound, id)
+		case errors.As(result.err, &respErr):
+			// Ответ без части новостей из-за ошибки сервиса выглядел бы как их отсутствие
+			writeError(w, r, respErr.status, respErr.code, respErr.message)
+			return
+		default:
+			writeBackendError(w, r, result.err, "Не удалось получить новости")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, response)
+}
+
+// parseNewsIDs разбирает непустой список ID новостей через запятую
+func parseNewsIDs(value string) ([]int64, bool) {
+	if strings.TrimSpace(value) == "" {
+		return nil, false
+	}
+	parts := strings.Split(value, ",")
+	ids := make([]int64, 0, len(parts))
+	for _, part := range parts {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true
+}
@@ -0,0 +1,274 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"apigw/pkg/config"
+)
+
+// Режимы поиска (параметр match)
+const (
+	searchSubstring = "substring"
+	searchPhrase    = "phrase"
+	searchRegex     = "regex"
+)
+
+// searchFields - поля новости, в которых можно искать (параметр in)
+var searchFields = []string{"title", "description"}
+
+// maxSearchPattern - максимальная длина регулярного выражения поиска
+const maxSearchPattern = 256
+
+// newsFilter - условия отбора новостей списка из параметров s, in, match,
+// from и until
+type newsFilter struct {
+	// search - поисковый запрос, как его передал клиент
+	search string
+	// fields - поля новости, в которых ищется запрос
+	fields []string
+	// mode - режим поиска; term - подстрока или фраза в нижнем регистре,
+	// re - регулярное выражение
+	mode string
+	term string
+	re   *regexp.Regexp
+	// inParam и matchParam - значения параметров in и match
+	inParam, matchParam string
+	// from и until - границы периода публикации pub_date: from включительно,
+	// until не включительно; нулевое значение - граница не задана
+	from, until time.Time
+	// fromParam и untilParam - значения параметров в том виде, в котором их передал клиент
+	fromParam, untilParam string
+	// native - период передается сервису новостей, который отбирает новости сам
+	native bool
+	dates  *dateLocalizer
+}
+
+// newsDateFilter проверяет, что сервис новостей арендатора запроса сам
+// отбирает новости по периоду публикации
+func (s *Server) newsDateFilter(ctx context.Context) bool {
+	return s.services(ctx)[config.ServiceNews].DateFilter
+}
+
+// parseNewsFilter разбирает параметры отбора новостей. Даты from и until
+// распознаются в тех же форматах, что и pub_date; даты без часового пояса
+// считаются датами в поясе dates.source_timezone.
+func (s *Server) parseNewsFilter(ctx context.Context, query url.Values) (newsFilter, error) {
+	f := newsFilter{
+		search:     query.Get("s"),
+		inParam:    query.Get("in"),
+		matchParam: query.Get("match"),
+		fromParam:  query.Get("from"),
+		untilParam: query.Get("until"),
+		native:     s.newsDateFilter(ctx),
+		dates:      s.dates,
+	}
+	if err := f.parseSearch(); err != nil {
+		return newsFilter{}, err
+	}
+
+	var ok bool
+	if f.fromParam != "" {
+		if f.from, ok = s.dates.parseDate(f.fromParam); !ok {
+			return newsFilter{}, fmt.Errorf("некорректная дата from: %s", f.fromParam)
+		}
+	}
+	if f.untilParam != "" {
+		if f.until, ok = s.dates.parseDate(f.untilParam); !ok {
+			return newsFilter{}, fmt.Errorf("некорректная дата until: %s", f.untilParam)
+		}
+	}
+	if f.fromParam != "" && f.untilParam != "" && !f.from.Before(f.until) {
+		return newsFilter{}, fmt.Errorf("дата from должна быть раньше until")
+	}
+	return f, nil
+}
+
+// parseSearch разбирает поля и режим поиска. Запрос в двойных кавычках без
+// параметра match ищется как фраза.
+func (f *newsFilter) parseSearch() error {
+	f.fields = []string{"title"}
+	if f.inParam != "" {
+		requested := make(map[string]bool)
+		for _, name := range strings.Split(f.inParam, ",") {
+			name = strings.TrimSpace(name)
+			if !containsString(searchFields, name) {
+				return fmt.Errorf("неизвестное поле поиска %s", name)
+			}
+			requested[name] = true
+		}
+		f.fields = nil
+		for _, name := range searchFields {
+			if requested[name] {
+				f.fields = append(f.fields, name)
+			}
+		}
+	}
+
+	f.mode = f.matchParam
+	quoted := len(f.search) >= 2 && strings.HasPrefix(f.search, `"`) && strings.HasSuffix(f.search, `"`)
+	if f.mode == "" {
+		f.mode = searchSubstring
+		if quoted {
+			f.mode = searchPhrase
+		}
+	}
+	switch f.mode {
+	case searchSubstring:
+		f.term = strings.ToLower(f.search)
+	case searchPhrase:
+		phrase := f.search
+		if quoted {
+			phrase = phrase[1 : len(phrase)-1]
+		}
+		f.term = strings.ToLower(strings.TrimSpace(phrase))
+		if f.search != "" && f.term == "" {
+			return fmt.Errorf("пустая фраза поиска")
+		}
+	case searchRegex:
+		if len(f.search) > maxSearchPattern {
+			return fmt.Errorf("регулярное выражение длиннее %d символов", maxSearchPattern)
+		}
+		if _, err := regexp.Compile(f.search); err != nil {
+			return fmt.Errorf("некорректное регулярное выражение: %w", err)
+		}
+		f.re = regexp.MustCompile("(?i)" + f.search)
+	default:
+		return fmt.Errorf("неизвестный режим поиска %s", f.mode)
+	}
+	return nil
+}
+
+// searching сообщает, что клиент задал поисковый запрос
+func (f newsFilter) searching() bool {
+	return f.search != ""
+}
+
+// extendedSearch сообщает, что поиск отличается от поиска подстроки в
+// заголовке, который выполняют сервис новостей и индекс поиска
+func (f newsFilter) extendedSearch() bool {
+	return f.searching() && (f.mode != searchSubstring || len(f.fields) != 1 || f.fields[0] != "title")
+}
+
+// dated сообщает, что клиент запросил новости за период
+func (f newsFilter) dated() bool {
+	return f.fromParam != "" || f.untilParam != ""
+}
+
+// gatewayPeriod сообщает, что новости по периоду отбирает шлюз
+func (f newsFilter) gatewayPeriod() bool {
+	return f.dated() && !f.native
+}
+
+// selective сообщает, что шлюз отбирает новости списка сам
+func (f newsFilter) selective() bool {
+	return f.searching() || f.gatewayPeriod()
+}
+
+// fullList сообщает, что отбор может выполнить только шлюз, поэтому у
+// сервиса новостей нужно запрашивать весь список
+func (f newsFilter) fullList() bool {
+	return f.gatewayPeriod() || f.extendedSearch()
+}
+
+// match проверяет, что новость подходит поисковому запросу и, если период
+// отбирает шлюз, опубликована в этот период. Новости с нераспознанной датой
+// в период не попадают.
+func (f newsFilter) match(item upstreamNews) bool {
+	if f.searching() && len(f.matchedIn(item)) == 0 {
+		return false
+	}
+	if !f.gatewayPeriod() {
+		return true
+	}
+
+	published, ok := f.dates.parseDate(item.PubDate)
+	switch {
+	case !ok:
+		return false
+	case f.fromParam != "" && published.Before(f.from):
+		return false
+	case f.untilParam != "" && !published.Before(f.until):
+		return false
+	}
+	return true
+}
+
+// addPeriodTo добавляет период в запрос к сервису новостей, который отбирает новости сам
+func (f newsFilter) addPeriodTo(query url.Values) {
+	if !f.native {
+		return
+	}
+	if f.fromParam != "" {
+		query.Set("from", f.fromParam)
+	}
+	if f.untilParam != "" {
+		query.Set("until", f.untilParam)
+	}
+}
+
+// matchedIn возвращает поля новости, в которых найден поисковый запрос
+func (f newsFilter) matchedIn(item upstreamNews) []string {
+	var matched []string
+	for _, field := range f.fields {
+		text := item.Title
+		if field == "description" {
+			text = item.Description
+		}
+		if f.matchText(text) {
+			matched = append(matched, field)
+		}
+	}
+	return matched
+}
+
+// matchText проверяет, что текст содержит поисковый запрос
+func (f newsFilter) matchText(text string) bool {
+	switch f.mode {
+	case searchRegex:
+		return f.re.MatchString(text)
+	case searchPhrase:
+		return containsPhrase(strings.ToLower(text), f.term)
+	}
+	return strings.Contains(strings.ToLower(text), f.term)
+}
+
+// annotate отмечает у новостей страницы поля, в которых найден поисковый запрос
+func (f newsFilter) annotate(items []upstreamNews) {
+	if !f.searching() {
+		return
+	}
+	for i := range items {
+		items[i].matchedIn = f.matchedIn(items[i])
+	}
+}
+
+// containsPhrase проверяет, что text содержит phrase целыми словами: до и
+// после фразы нет букв и цифр
+func containsPhrase(text, phrase string) bool {
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], phrase)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(phrase)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(text[start:])
+		offset = start + size
+	}
+}
+
+// isWordRune проверяет, что символ - буква или цифра
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
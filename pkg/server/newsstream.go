@@ -0,0 +1,234 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"apigw/pkg/clients/news"
+	"apigw/pkg/config"
+)
+
+// newsStreamPath - путь потока новых новостей
+const newsStreamPath = "/api/news/stream"
+
+// newsStreamBuffer - сколько новостей может ждать отправки клиенту. Клиент,
+// который не успевает читать поток, отключается, чтобы не задерживать остальных.
+const newsStreamBuffer = 64
+
+// newsStream опрашивает сервис новостей и рассылает подписчикам новости,
+// которых не было при прошлом опросе. Первый опрос только запоминает
+// текущие новости. Поток переживает перезагрузку конфигурации, если секция
+// news_stream и сервисы не изменились.
+type newsStream struct {
+	config config.NewsStreamConfig
+
+	mu          sync.Mutex
+	subscribers map[chan news.Item]struct{}
+	// seen - ID новостей последнего успешного опроса, nil - опросов еще не было
+	seen   map[int64]bool
+	closed bool
+}
+
+// newNewsStream проверяет секцию news_stream. Возвращает nil, если поток отключен.
+func newNewsStream(cfg config.NewsStreamConfig) (*newsStream, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.PollInterval <= 0 {
+		return nil, fmt.Errorf("некорректный интервал опроса news_stream.poll_interval: %s", cfg.PollInterval.Std())
+	}
+	if cfg.Heartbeat < 0 {
+		return nil, fmt.Errorf("некорректный интервал пульса news_stream.heartbeat: %s", cfg.Heartbeat.Std())
+	}
+	if cfg.MaxClients < 0 {
+		return nil, fmt.Errorf("некорректное количество клиентов news_stream.max_clients: %d", cfg.MaxClients)
+	}
+	return &newsStream{
+		config:      cfg,
+		subscribers: make(map[chan news.Item]struct{}),
+	}, nil
+}
+
+// run опрашивает сервис новостей с интервалом из конфигурации до отмены ctx
+func (ns *newsStream) run(ctx context.Context, service news.Service) {
+	ns.poll(ctx, service)
+
+	ticker := time.NewTicker(ns.config.PollInterval.Std())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ns.poll(ctx, service)
+		}
+	}
+}
+
+// poll загружает список новостей и рассылает новые. При ошибке список
+// известных новостей не меняется, и новости будут отправлены после
+// следующего успешного опроса.
+func (ns *newsStream) poll(ctx context.Context, service news.Service) {
+	items, err := service.List(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Не удалось опросить сервис новостей для потока новостей", "error", err)
+		}
+		return
+	}
+
+	seen := make(map[int64]bool, len(items))
+	for _, item := range items {
+		seen[item.ID] = true
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	baseline := ns.seen == nil
+	prev := ns.seen
+	ns.seen = seen
+	if baseline {
+		return
+	}
+
+	// Сервис отдает новости от новых к старым, клиентам они отправляются по порядку публикации
+	published := 0
+	for i := len(items) - 1; i >= 0; i-- {
+		if prev[items[i].ID] {
+			continue
+		}
+		published++
+		for ch := range ns.subscribers {
+			select {
+			case ch <- items[i]:
+			default:
+				slog.WarnContext(ctx, "Клиент потока новостей не успевает читать события и отключен")
+				ns.unsubscribeLocked(ch)
+			}
+		}
+	}
+	if published > 0 {
+		slog.DebugContext(ctx, "Новые новости отправлены в поток", "news", published, "clients", len(ns.subscribers))
+	}
+}
+
+// subscribe подключает клиента. Возвращает false, если клиентов уже
+// максимальное количество или поток остановлен.
+func (ns *newsStream) subscribe() (chan news.Item, bool) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if ns.closed || (ns.config.MaxClients > 0 && len(ns.subscribers) >= ns.config.MaxClients) {
+		return nil, false
+	}
+	ch := make(chan news.Item, newsStreamBuffer)
+	ns.subscribers[ch] = struct{}{}
+	return ch, true
+}
+
+// unsubscribe отключает клиента
+func (ns *newsStream) unsubscribe(ch chan news.Item) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.unsubscribeLocked(ch)
+}
+
+// unsubscribeLocked отключает клиента и закрывает его канал; вызывается под ns.mu
+func (ns *newsStream) unsubscribeLocked(ch chan news.Item) {
+	if _, ok := ns.subscribers[ch]; ok {
+		delete(ns.subscribers, ch)
+		close(ch)
+	}
+}
+
+// close отключает всех клиентов. Вызывается, когда новое поколение
+// конфигурации использует другой поток: клиенты переподключатся к нему сами.
+func (ns *newsStream) close() {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	ns.closed = true
+	for ch := range ns.subscribers {
+		ns.unsubscribeLocked(ch)
+	}
+}
+
+// handleNewsStream отправляет клиенту новые новости как события Server-Sent
+// Events. Между событиями передаются комментарии-пульсы.
+func (s *Server) handleNewsStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Метод не поддерживается")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		slog.ErrorContext(r.Context(), "ResponseWriter не поддерживает Flush, поток новостей невозможен")
+		writeError(w, r, http.StatusInternalServerError, codeInternal, "Внутренняя ошибка сервера")
+		return
+	}
+
+	events, ok := s.newsStream.subscribe()
+	if !ok {
+		slog.WarnContext(r.Context(), "Превышен лимит клиентов потока новостей", "max_clients", s.newsStream.config.MaxClients)
+		writeError(w, r, http.StatusServiceUnavailable, codeTooManyConnections, "Превышено количество соединений")
+		return
+	}
+	defer s.newsStream.unsubscribe(events)
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	// Отключаем буферизацию ответа в nginx
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	// Комментарий в начале потока сразу отдает клиенту заголовки
+	w.Write(sseHeartbeat)
+	flusher.Flush()
+
+	var heartbeat <-chan time.Time
+	if interval := s.newsStream.config.Heartbeat.Std(); interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case item, ok := <-events:
+			if !ok {
+				return
+			}
+			if _, err := w.Write(newsEvent(item)); err != nil {
+				return
+			}
+		case <-heartbeat:
+			if _, err := w.Write(sseHeartbeat); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// newsEvent форматирует новость как событие news с ID новости
+func newsEvent(item news.Item) []byte {
+	data, _ := json.Marshal(item)
+	var buf bytes.Buffer
+	buf.WriteString("event: news\nid: ")
+	buf.WriteString(strconv.FormatInt(item.ID, 10))
+	buf.WriteString("\ndata: ")
+	buf.Write(data)
+	buf.WriteString("\n\n")
+	return buf.Bytes()
+}
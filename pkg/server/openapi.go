@@ -0,0 +1,474 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"apigw/pkg/config"
+	"apigw/pkg/openapi"
+)
+
+// Версия описываемого API
+const apiVersion = "1.0.0"
+
+// addCommentRequest - тело запроса на добавление комментария
+type addCommentRequest struct {
+	Text     string `json:"text"`
+	ParentID int64  `json:"parent_id,omitempty"`
+}
+
+// newsWithComments - ответ /api/news?comm={newsId}
+type newsWithComments struct {
+	News     FullNewsItem `json:"news"`
+	Comments []Comment    `json:"comments"`
+}
+
+// paginatedSchema возвращает схему PaginatedResponse со списком элементов указанной схемы
+func paginatedSchema(items *openapi.Schema) *openapi.Schema {
+	schema := openapi.SchemaFor(PaginatedResponse{})
+	schema.Properties["items"] = openapi.ArrayOf(items)
+	return schema
+}
+
+// cursorPageSchema возвращает схему CursorResponse со списком элементов указанной схемы
+func cursorPageSchema(items *openapi.Schema) *openapi.Schema {
+	schema := openapi.SchemaFor(CursorResponse{})
+	schema.Properties["items"] = openapi.ArrayOf(items)
+	return schema
+}
+
+// Общие параметры и ответы
+var (
+	pageParams = []openapi.Parameter{
+		{Name: "page", In: "query", Description: "Номер страницы", Schema: &openapi.Schema{Type: "integer", Default: defaultPage, Minimum: minimum(1)}},
+		{Name: "count", In: "query", Description: "Количество элементов на страницу", Schema: &openapi.Schema{Type: "integer", Default: defaultCount, Minimum: minimum(1)}},
+		{Name: "s", In: "query", Description: "Поисковый запрос (фильтрует новости по заголовку); запрос в двойных кавычках ищется как фраза. Найденные новости получают поле matched_in", Schema: &openapi.Schema{Type: "string"}},
+		{Name: "in", In: "query", Description: "Поля, в которых ищется запрос s, через запятую: title, description", Schema: &openapi.Schema{Type: "string", Default: "title"}},
+		{Name: "match", In: "query", Description: "Режим поиска: подстрока, фраза целыми словами или регулярное выражение RE2 (без учета регистра)", Schema: &openapi.Schema{Type: "string", Enum: []interface{}{searchSubstring, searchPhrase, searchRegex}, Default: searchSubstring}},
+		{Name: "from", In: "query", Description: "Начало периода публикации (включительно), например 2024-01-01 или 2024-01-01T00:00:00Z", Schema: &openapi.Schema{Type: "string"}},
+		{Name: "until", In: "query", Description: "Конец периода публикации (не включительно)", Schema: &openapi.Schema{Type: "string"}},
+		{Name: "sort", In: "query", Description: "Поле сортировки новостей; равные значения упорядочиваются по ID", Schema: &openapi.Schema{Type: "string", Enum: []interface{}{"pub_date", "title"}}},
+		{Name: "order", In: "query", Description: "Порядок сортировки; учитывается вместе с sort", Schema: &openapi.Schema{Type: "string", Enum: []interface{}{"asc", "desc"}, Default: "asc"}},
+		{Name: "cursor", In: "query", Description: "Курсор страницы из next_cursor или prev_cursor; вместе с limit включает пагинацию курсорами вместо page и count", Schema: &openapi.Schema{Type: "string"}},
+		{Name: "limit", In: "query", Description: "Количество элементов на страницу при пагинации курсорами", Schema: &openapi.Schema{Type: "integer", Default: defaultCount, Minimum: minimum(1)}},
+	}
+	requestIDParam = openapi.Parameter{
+		Name: "request_id", In: "query", Description: "Идентификатор запроса для трассировки (устаревший способ, используйте заголовок X-Request-ID)",
+		Schema: &openapi.Schema{Type: "string"},
+	}
+	requestIDHeaderParam = openapi.Parameter{
+		Name: "X-Request-ID", In: "header", Description: "Идентификатор запроса для трассировки; если не указан, генерируется шлюзом",
+		Schema: &openapi.Schema{Type: "string"},
+	}
+	ifNoneMatchParam = openapi.Parameter{
+		Name: "If-None-Match", In: "header", Description: "ETag ранее полученного ответа; если ответ не изменился, возвращается 304 без тела",
+		Schema: &openapi.Schema{Type: "string"},
+	}
+	etagHeader = openapi.Header{
+		Description: "Слабый ETag содержимого ответа; не передается для ответов больше streaming.max_buffer",
+		Schema:      &openapi.Schema{Type: "string"},
+	}
+	fieldsQueryParam = openapi.Parameter{
+		Name: fieldsParam, In: "query", Description: "Поля новостей через запятую, которые нужно вернуть, например id,title; остальные поля не передаются",
+		Schema: &openapi.Schema{Type: "string"},
+	}
+	newsIDSchema    = &openapi.Schema{Type: "integer", Format: "int64", Minimum: minimum(1)}
+	requestIDHeader = map[string]openapi.Header{
+		"X-Request-ID": {Description: "Идентификатор запроса", Schema: &openapi.Schema{Type: "string"}},
+	}
+)
+
+// minimum возвращает указатель на минимальное значение для схемы
+func minimum(value float64) *float64 {
+	return &value
+}
+
+// addCommentSchema возвращает схему тела запроса на добавление комментария
+// с ограничением длины из validation.comment_max_length
+func (s *Server) addCommentSchema() *openapi.Schema {
+	schema := openapi.SchemaFor(addCommentRequest{})
+	minLength := 1
+	schema.Properties["text"].MinLength = &minLength
+	if maxLength := s.config.Validation.CommentMaxLength; s.config.Validation.Enabled && maxLength > 0 {
+		schema.Properties["text"].MaxLength = &maxLength
+	}
+	schema.Properties["parent_id"].Minimum = minimum(0)
+	return schema
+}
+
+// errorResponseSpec возвращает описание ответа с ошибкой
+func errorResponseSpec(description string) openapi.Response {
+	return openapi.Response{Description: description, Content: openapi.JSON(openapi.Ref("Error"))}
+}
+
+// okResponseSpec возвращает описание успешного ответа
+func okResponseSpec(description string, schema *openapi.Schema) openapi.Response {
+	return openapi.Response{Description: description, Headers: requestIDHeader, Content: openapi.JSON(schema)}
+}
+
+// withConditionalGet добавляет к операции заголовок If-None-Match, заголовок
+// ETag успешного ответа и ответ 304
+func withConditionalGet(op *openapi.Operation) {
+	op.Parameters = append(op.Parameters, ifNoneMatchParam)
+	ok := op.Responses["200"]
+	headers := map[string]openapi.Header{"ETag": etagHeader}
+	for name, header := range ok.Headers {
+		headers[name] = header
+	}
+	ok.Headers = headers
+	op.Responses["200"] = ok
+	op.Responses["304"] = openapi.Response{Description: "Ответ не изменился с версии из If-None-Match", Headers: map[string]openapi.Header{"ETag": etagHeader}}
+}
+
+// withRequestID добавляет к параметрам операции заголовок X-Request-ID и параметр request_id
+func withRequestID(params ...openapi.Parameter) []openapi.Parameter {
+	return append(params, requestIDHeaderParam, requestIDParam)
+}
+
+// OpenAPIDocument строит описание API шлюза для конфигурации cfg без запуска
+// сервера, например для генерации клиентов
+func OpenAPIDocument(cfg *config.Config) (*openapi.Document, error) {
+	srv, err := newDetachedGeneration(cfg)
+	if err != nil {
+		return nil, err
+	}
+	srv.background.stop()
+	return srv.openAPIDocument(), nil
+}
+
+// openAPIDocument строит описание всех маршрутов шлюза
+func (s *Server) openAPIDocument() *openapi.Document {
+	doc := &openapi.Document{
+		OpenAPI: "3.0.3",
+		Info: openapi.Info{
+			Title:       "API Gateway новостного сервиса",
+			Description: "Унифицированный доступ к сервисам новостей и комментариев",
+			Version:     apiVersion,
+		},
+		Tags: []openapi.Tag{
+			{Name: "news", Description: "Новости"},
+			{Name: "comments", Description: "Комментарии"},
+			{Name: "meta", Description: "Служебные эндпоинты"},
+			{Name: "proxy", Description: "Маршруты из конфигурации, проксируемые на backend-сервисы"},
+		},
+		Components: openapi.Components{
+			Schemas: map[string]*openapi.Schema{
+				"NewsItem":           openapi.SchemaFor(NewsItem{}),
+				"FullNewsItem":       openapi.SchemaFor(FullNewsItem{}),
+				"Comment":            openapi.SchemaFor(Comment{}),
+				"NewsPage":           paginatedSchema(openapi.Ref("NewsItem")),
+				"FullNewsPage":       paginatedSchema(openapi.Ref("FullNewsItem")),
+				"NewsCursorPage":     cursorPageSchema(openapi.Ref("NewsItem")),
+				"FullNewsCursorPage": cursorPageSchema(openapi.Ref("FullNewsItem")),
+				"CommentsPage":       paginatedSchema(openapi.Ref("Comment")),
+				"CommentThread":      openapi.SchemaFor(Comment{}),
+				"CommentThreadsPage": paginatedSchema(openapi.Ref("CommentThread")),
+				"NewsWithComments":   openapi.SchemaFor(newsWithComments{}),
+				"NewsBatch":          openapi.SchemaFor(NewsBatchResponse{}),
+				"QueuedComment":      openapi.SchemaFor(QueuedCommentResponse{}),
+				"Error":              openapi.SchemaFor(errorResponse{}),
+			},
+		},
+		Paths: map[string]openapi.PathItem{},
+	}
+	// Вложенные структуры заменяем ссылками на общие схемы
+	doc.Components.Schemas["NewsWithComments"].Properties["news"] = openapi.Ref("FullNewsItem")
+	doc.Components.Schemas["NewsWithComments"].Properties["comments"] = openapi.ArrayOf(openapi.Ref("Comment"))
+	doc.Components.Schemas["CommentThread"].Properties["replies"] = openapi.ArrayOf(openapi.Ref("CommentThread"))
+	doc.Components.Schemas["NewsBatch"].Properties["items"] = openapi.ArrayOf(openapi.Ref("FullNewsItem"))
+
+	doc.Paths["/api/news"] = openapi.PathItem{
+		"get": {
+			Tags:        []string{"news"},
+			OperationID: "listNews",
+			Summary:     "Список новостей (краткий формат)",
+			Description: "Если указан параметр comm, возвращает новость с этим ID вместе с комментариями к ней.",
+			Parameters: withRequestID(append(pageParams,
+				openapi.Parameter{Name: "comm", In: "query", Description: "ID новости, которую нужно вернуть вместе с комментариями", Schema: newsIDSchema},
+				openapi.Parameter{Name: "with_comment_counts", In: "query", Description: "Добавить к новостям количество комментариев comment_count", Schema: &openapi.Schema{Type: "boolean"}},
+			)...),
+			Responses: map[string]openapi.Response{
+				"200": okResponseSpec("Страница новостей (при cursor или limit - страница с курсорами) или новость с комментариями (при comm)", &openapi.Schema{
+					OneOf: []*openapi.Schema{openapi.Ref("NewsPage"), openapi.Ref("NewsCursorPage"), openapi.Ref("NewsWithComments")},
+				}),
+				"400": errorResponseSpec("Некорректный ID новости"),
+				"404": errorResponseSpec("Новость не найдена"),
+				"500": errorResponseSpec("Не удалось получить новости"),
+			},
+		},
+	}
+
+	doc.Paths["/api/fullnews"] = openapi.PathItem{
+		"get": {
+			Tags:        []string{"news"},
+			OperationID: "listFullNews",
+			Summary:     "Список новостей с описанием",
+			Parameters:  withRequestID(pageParams...),
+			Responses: map[string]openapi.Response{
+				"200": okResponseSpec("Страница новостей (при cursor или limit - страница с курсорами)", &openapi.Schema{
+					OneOf: []*openapi.Schema{openapi.Ref("FullNewsPage"), openapi.Ref("FullNewsCursorPage")},
+				}),
+				"500": errorResponseSpec("Не удалось получить новости"),
+			},
+		},
+	}
+
+	doc.Paths["/api/news/batch"] = openapi.PathItem{
+		"get": {
+			Tags:        []string{"news"},
+			OperationID: "getNewsBatch",
+			Summary:     "Несколько новостей по ID",
+			Description: fmt.Sprintf("Новости возвращаются в порядке ids, отсутствующие перечисляются в not_found. Не больше %d ID в запросе.", newsBatchMaxIDs),
+			Parameters: withRequestID(
+				openapi.Parameter{Name: "ids", In: "query", Required: true, Description: "ID новостей через запятую", Schema: &openapi.Schema{Type: "string"}},
+			),
+			Responses: map[string]openapi.Response{
+				"200": okResponseSpec("Новости", openapi.Ref("NewsBatch")),
+				"400": errorResponseSpec("Не указаны или некорректны ID новостей, слишком много ID"),
+				"500": errorResponseSpec("Не удалось получить новости"),
+			},
+		},
+	}
+
+	doc.Paths["/api/news/{newsId}"] = openapi.PathItem{
+		"get": {
+			Tags:        []string{"news"},
+			OperationID: "getNews",
+			Summary:     "Новость по ID",
+			Parameters: withRequestID(
+				openapi.Parameter{Name: "newsId", In: "path", Required: true, Description: "ID новости", Schema: newsIDSchema},
+			),
+			Responses: map[string]openapi.Response{
+				"200": okResponseSpec("Новость", openapi.Ref("FullNewsItem")),
+				"400": {Description: "Некорректный ID новости"},
+				"404": {Description: "Новость не найдена"},
+			},
+		},
+	}
+
+	if s.config.NewsStream.Enabled {
+		doc.Paths[newsStreamPath] = openapi.PathItem{
+			"get": {
+				Tags:        []string{"news"},
+				OperationID: "streamNews",
+				Summary:     "Поток новых новостей (Server-Sent Events)",
+				Description: "Каждая новая новость передается событием news, id события - ID новости, data - новость в JSON. Между событиями передаются комментарии-пульсы.",
+				Parameters:  withRequestID(),
+				Responses: map[string]openapi.Response{
+					"200": {Description: "Поток событий", Content: map[string]openapi.MediaType{"text/event-stream": {Schema: openapi.Ref("FullNewsItem")}}},
+					"503": errorResponseSpec("Превышено количество подключенных клиентов"),
+				},
+			},
+		}
+	}
+
+	doc.Paths["/api/comments"] = openapi.PathItem{
+		"get": {
+			Tags:        []string{"comments"},
+			OperationID: "listComments",
+			Summary:     "Комментарии к новости",
+			Parameters: withRequestID(
+				openapi.Parameter{Name: "id", In: "query", Required: true, Description: "ID новости", Schema: newsIDSchema},
+				openapi.Parameter{Name: "page", In: "query", Description: "Номер страницы; с page, count или sort возвращается страница комментариев", Schema: &openapi.Schema{Type: "integer", Default: defaultPage, Minimum: minimum(1)}},
+				openapi.Parameter{Name: "count", In: "query", Description: "Количество комментариев на страницу", Schema: &openapi.Schema{Type: "integer", Default: defaultCount, Minimum: minimum(1)}},
+				openapi.Parameter{Name: "sort", In: "query", Description: "Порядок комментариев: id, created_at, -id или -created_at (обратный порядок)", Schema: &openapi.Schema{Type: "string", Enum: []interface{}{"id", "-id", "created_at", "-created_at"}}},
+				openapi.Parameter{Name: "view", In: "query", Description: "Вид списка: flat (список) или tree (ответы вложены в поле replies); по умолчанию comments.view", Schema: &openapi.Schema{Type: "string", Enum: []interface{}{commentsViewFlat, commentsViewTree}}},
+			),
+			Responses: map[string]openapi.Response{
+				"200": okResponseSpec("Комментарии или страница комментариев (при page, count или sort)", &openapi.Schema{
+					OneOf: []*openapi.Schema{
+						openapi.ArrayOf(openapi.Ref("Comment")), openapi.Ref("CommentsPage"),
+						openapi.ArrayOf(openapi.Ref("CommentThread")), openapi.Ref("CommentThreadsPage"),
+					},
+				}),
+				"400": errorResponseSpec("Не указан или некорректен ID новости, некорректный порядок сортировки или вид списка"),
+				"500": errorResponseSpec("Не удалось получить комментарии"),
+			},
+		},
+	}
+
+	doc.Paths["/api/comments/add"] = openapi.PathItem{
+		"post": {
+			Tags:        []string{"comments"},
+			OperationID: "addComment",
+			Summary:     "Добавление комментария к новости",
+			Parameters: withRequestID(
+				openapi.Parameter{Name: "news_id", In: "query", Description: "ID новости; обязателен, если не указан id", Schema: newsIDSchema},
+				openapi.Parameter{Name: "id", In: "query", Description: "ID новости, если не указан news_id", Schema: newsIDSchema},
+			),
+			RequestBody: &openapi.RequestBody{
+				Required: true,
+				Content:  openapi.JSON(s.addCommentSchema()),
+			},
+			Responses: map[string]openapi.Response{
+				"200": okResponseSpec("Комментарий добавлен; тело - ответ сервиса комментариев", &openapi.Schema{
+					Type:       "object",
+					Properties: map[string]*openapi.Schema{"id": {Type: "integer", Format: "int64"}},
+				}),
+				"202": okResponseSpec("Сервис комментариев недоступен, комментарий сохранен в очередь (comment_outbox)", openapi.Ref("QueuedComment")),
+				"400": errorResponseSpec("Некорректный ID новости или пустой комментарий"),
+				"500": errorResponseSpec("Не удалось добавить комментарий"),
+			},
+		},
+	}
+
+	// Запросы к эндпоинтам новостей и комментариев проверяются по этим схемам (validationMiddleware)
+	if s.config.Validation.Enabled {
+		for _, item := range doc.Paths {
+			for _, op := range item {
+				if _, ok := op.Responses["400"]; !ok && validatedOperation(op) {
+					op.Responses["400"] = errorResponseSpec("Параметры запроса не прошли проверку")
+				}
+			}
+		}
+	}
+
+	// Новости в ответах этих маршрутов можно сократить до нужных полей (fieldsMiddleware)
+	for _, path := range []string{"/api/news", "/api/fullnews", "/api/news/batch", "/api/news/{newsId}"} {
+		op := doc.Paths[path]["get"]
+		op.Parameters = append(op.Parameters, fieldsQueryParam)
+	}
+
+	// Ответы на GET этих маршрутов получают ETag (etagMiddleware)
+	for _, path := range []string{"/api/news", "/api/fullnews", "/api/news/batch", "/api/news/{newsId}", "/api/comments"} {
+		withConditionalGet(doc.Paths[path]["get"])
+	}
+
+	batchRequestSchema := openapi.SchemaFor(batchRequest{})
+	batchRequestSchema.Properties["body"] = &openapi.Schema{Description: "Тело запроса в формате JSON"}
+	batchRequestSchema.Required = []string{"path"}
+	batchResponseSchema := openapi.SchemaFor(batchResponse{})
+	batchResponseSchema.Properties["body"] = &openapi.Schema{Description: "Ответ в формате JSON или строка"}
+	doc.Paths["/api/batch"] = openapi.PathItem{
+		"post": {
+			Tags:        []string{"meta"},
+			OperationID: "batch",
+			Summary:     "Выполнение нескольких запросов за одно обращение",
+			Description: "Запросы выполняются параллельно, ответы возвращаются в том же порядке.",
+			RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(openapi.ArrayOf(batchRequestSchema))},
+			Responses: map[string]openapi.Response{
+				"200": okResponseSpec("Ответы на запросы пакета", openapi.ArrayOf(batchResponseSchema)),
+				"400": errorResponseSpec("Некорректный пакет или слишком много запросов"),
+			},
+		},
+	}
+
+	doc.Paths["/rpc"] = openapi.PathItem{
+		"post": {
+			Tags:        []string{"meta"},
+			OperationID: "jsonRPC",
+			Summary:     "JSON-RPC 2.0",
+			Description: "Методы news.list, news.get, comments.list и comments.add с параметрами по имени. Поддерживаются пакетные вызовы и уведомления.",
+			RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(&openapi.Schema{
+				OneOf: []*openapi.Schema{openapi.SchemaFor(rpcRequest{}), openapi.ArrayOf(openapi.SchemaFor(rpcRequest{}))},
+			})},
+			Responses: map[string]openapi.Response{
+				"200": okResponseSpec("Результат вызова или ошибка JSON-RPC", &openapi.Schema{
+					OneOf: []*openapi.Schema{openapi.SchemaFor(rpcResponse{}), openapi.ArrayOf(openapi.SchemaFor(rpcResponse{}))},
+				}),
+				"204": {Description: "Запрос состоял только из уведомлений"},
+			},
+		},
+	}
+
+	graphQLOp := func(method string) *openapi.Operation {
+		op := &openapi.Operation{
+			Tags:        []string{"meta"},
+			OperationID: method + "GraphQL",
+			Summary:     "GraphQL запрос к новостям и комментариям",
+			Responses: map[string]openapi.Response{
+				"200": okResponseSpec("Результат GraphQL запроса", &openapi.Schema{Type: "object"}),
+				"400": errorResponseSpec("Не указан запрос"),
+			},
+		}
+		if method == "get" {
+			op.Parameters = []openapi.Parameter{
+				{Name: "query", In: "query", Required: true, Schema: &openapi.Schema{Type: "string"}},
+				{Name: "variables", In: "query", Description: "Переменные в формате JSON", Schema: &openapi.Schema{Type: "string"}},
+				{Name: "operationName", In: "query", Schema: &openapi.Schema{Type: "string"}},
+			}
+		} else {
+			op.RequestBody = &openapi.RequestBody{Required: true, Content: openapi.JSON(openapi.SchemaFor(graphQLRequest{}))}
+		}
+		return op
+	}
+	doc.Paths["/graphql"] = openapi.PathItem{"get": graphQLOp("get"), "post": graphQLOp("post")}
+
+	doc.Paths["/openapi.json"] = openapi.PathItem{
+		"get": {
+			Tags:        []string{"meta"},
+			OperationID: "getOpenAPI",
+			Summary:     "Этот документ",
+			Responses: map[string]openapi.Response{
+				"200": {Description: "Описание API в формате OpenAPI 3", Content: openapi.JSON(&openapi.Schema{Type: "object"})},
+			},
+		},
+	}
+
+	if s.config.Docs.Enabled {
+		doc.Paths["/docs"] = openapi.PathItem{
+			"get": {
+				Tags:        []string{"meta"},
+				OperationID: "getDocs",
+				Summary:     "Интерактивная документация (Swagger UI)",
+				Responses: map[string]openapi.Response{
+					"200": {Description: "HTML страница Swagger UI"},
+					"401": {Description: "Требуется авторизация (если включена docs.require_auth)"},
+				},
+			},
+		}
+	}
+
+	// Маршруты из конфигурации проксируются как есть, схема ответа определяется сервисом
+	for _, route := range s.config.Routes {
+		path := route.Path
+		var params []openapi.Parameter
+		if strings.HasSuffix(path, "/") {
+			path += "{path}"
+			params = append(params, openapi.Parameter{Name: "path", In: "path", Required: true, Description: "Путь, передаваемый сервису", Schema: &openapi.Schema{Type: "string"}})
+		}
+
+		upstream := route.Upstream
+		if proxy := s.proxies[route.Path]; proxy != nil {
+			upstream = proxy.upstream.String()
+		}
+		description := "Проксируется на " + upstream
+		if strings.HasPrefix(upstream, "ws") {
+			description = "WebSocket соединение с " + upstream + " (требуется Upgrade: websocket)"
+		}
+
+		doc.Paths[path] = openapi.PathItem{
+			"get": {
+				Tags:        []string{"proxy"},
+				Summary:     "Проксируемый маршрут",
+				Description: description,
+				Parameters:  params,
+				Responses: map[string]openapi.Response{
+					"default": {Description: "Ответ backend-сервиса"},
+				},
+			},
+		}
+	}
+
+	return doc
+}
+
+// handleOpenAPI отдает описание API в формате OpenAPI 3
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Метод не разрешен")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(s.openAPIDocument()); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка при кодировании OpenAPI документа", "error", err)
+	}
+}
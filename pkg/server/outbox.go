@@ -0,0 +1,289 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"apigw/pkg/config"
+	"apigw/pkg/events"
+	"apigw/pkg/metrics"
+	"apigw/pkg/outbox"
+)
+
+// outboxBatchSize - сколько комментариев доставляется за одну проверку очереди
+const outboxBatchSize = 100
+
+// outboxStatusQueued - статус ответа на комментарий, принятый в очередь
+const outboxStatusQueued = "queued"
+
+// QueuedCommentResponse представляет ответ 202 на комментарий, который
+// сохранен в очередь и будет доставлен в сервис комментариев позже
+type QueuedCommentResponse struct {
+	Status  string `json:"status"`   // Всегда "queued"
+	QueueID string `json:"queue_id"` // ID комментария в очереди
+}
+
+// commentOutbox - очередь комментариев, которые не удалось доставить в
+// сервис комментариев. Общая для всех поколений; nil означает, что очередь
+// отключена.
+type commentOutbox struct {
+	store          outbox.Store
+	interval       time.Duration
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	maxAttempts    int
+
+	results *metrics.CounterVec
+}
+
+// queuedComment - комментарий в очереди. Адрес сервиса комментариев
+// определяется при доставке, поэтому учитывает перезагрузку конфигурации.
+type queuedComment struct {
+	NewsID int64 `json:"news_id"`
+	// Body - тело запроса к сервису после преобразования из секции transforms
+	Body        []byte `json:"body"`
+	ContentType string `json:"content_type"`
+	// Text и UserID - комментарий для события comment.created
+	Text      string `json:"text"`
+	UserID    string `json:"user_id,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	Tenant    string `json:"tenant,omitempty"`
+}
+
+// checkCommentOutboxConfig проверяет секцию comment_outbox без подключения к хранилищу
+func checkCommentOutboxConfig(cfg config.CommentOutboxConfig) error {
+	switch cfg.Driver {
+	case "":
+		return nil
+	case "file":
+		if cfg.Dir == "" {
+			return fmt.Errorf("не указан каталог очереди комментариев (comment_outbox.dir)")
+		}
+	case "redis":
+		if cfg.Redis.Addr == "" {
+			return fmt.Errorf("не указан адрес Redis (comment_outbox.redis.addr)")
+		}
+	default:
+		return fmt.Errorf("неизвестный драйвер очереди комментариев: %q", cfg.Driver)
+	}
+	if cfg.Interval <= 0 || cfg.InitialBackoff <= 0 || cfg.MaxBackoff < cfg.InitialBackoff {
+		return fmt.Errorf("некорректные interval, initial_backoff или max_backoff очереди комментариев")
+	}
+	if cfg.MaxAttempts < 0 {
+		return fmt.Errorf("отрицательное число попыток comment_outbox.max_attempts: %d", cfg.MaxAttempts)
+	}
+	return nil
+}
+
+// newCommentOutbox создает очередь комментариев согласно comment_outbox.driver.
+// Возвращает nil, если очередь отключена.
+func newCommentOutbox(cfg config.CommentOutboxConfig, registry *metrics.Registry) (*commentOutbox, error) {
+	if err := checkCommentOutboxConfig(cfg); err != nil || cfg.Driver == "" {
+		return nil, err
+	}
+
+	o := &commentOutbox{
+		interval:       cfg.Interval.Std(),
+		initialBackoff: cfg.InitialBackoff.Std(),
+		maxBackoff:     cfg.MaxBackoff.Std(),
+		maxAttempts:    cfg.MaxAttempts,
+		results: registry.Counter("apigw_comment_outbox_total",
+			"Комментарии очереди по результату (queued, delivered, retried, dropped)", "result"),
+	}
+	switch cfg.Driver {
+	case "file":
+		store, err := outbox.NewDir(cfg.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось открыть каталог очереди комментариев: %w", err)
+		}
+		o.store = store
+	case "redis":
+		o.store = outbox.NewRedis(outbox.RedisOptions{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+			Prefix:   cfg.Redis.Prefix,
+			Timeout:  cfg.Redis.Timeout.Std(),
+		})
+	}
+	return o, nil
+}
+
+// enqueue сохраняет комментарий в очередь для доставки при следующей
+// проверке. Возвращает ответ клиенту или false, если очередь отключена или
+// сохранить комментарий не удалось.
+func (o *commentOutbox) enqueue(ctx context.Context, comment queuedComment) (QueuedCommentResponse, bool) {
+	if o == nil {
+		return QueuedCommentResponse{}, false
+	}
+	comment.RequestID, _ = ctx.Value(requestIDKey).(string)
+	if t := tenantFrom(ctx); t != nil {
+		comment.Tenant = t.name
+	}
+	payload, err := json.Marshal(comment)
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка при создании JSON", "error", err)
+		return QueuedCommentResponse{}, false
+	}
+	id, err := outbox.NewID()
+	if err != nil {
+		slog.ErrorContext(ctx, "Не удалось создать ID комментария в очереди", "error", err)
+		return QueuedCommentResponse{}, false
+	}
+
+	now := time.Now()
+	msg := outbox.Message{ID: id, Payload: payload, NextAttempt: now.Add(o.initialBackoff), Created: now}
+	if err := o.store.Put(ctx, msg); err != nil {
+		slog.ErrorContext(ctx, "Не удалось сохранить комментарий в очередь", "news_id", comment.NewsID, "error", err)
+		return QueuedCommentResponse{}, false
+	}
+	o.results.Inc("queued")
+	slog.WarnContext(ctx, "Сервис комментариев недоступен, комментарий сохранен в очередь", "news_id", comment.NewsID, "queue_id", id)
+	return QueuedCommentResponse{Status: outboxStatusQueued, QueueID: id}, true
+}
+
+// backoff возвращает паузу перед следующей попыткой после attempts неудачных
+func (o *commentOutbox) backoff(attempts int) time.Duration {
+	d := o.initialBackoff
+	for i := 1; i < attempts && d < o.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > o.maxBackoff {
+		d = o.maxBackoff
+	}
+	return d
+}
+
+// runCommentOutbox доставляет комментарии из очереди каждые
+// comment_outbox.interval, пока ctx не отменен
+func (s *Server) runCommentOutbox(ctx context.Context) {
+	ticker := time.NewTicker(s.outbox.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		due, err := s.outbox.store.Due(ctx, time.Now(), outboxBatchSize)
+		if err != nil {
+			slog.Error("Не удалось прочитать очередь комментариев", "error", err)
+			continue
+		}
+		for _, msg := range due {
+			s.deliverQueuedComment(ctx, msg)
+		}
+	}
+}
+
+// deliverQueuedComment отправляет комментарий из очереди в сервис
+// комментариев текущего поколения. При недоступности сервиса попытка
+// повторяется позже; комментарий, отклоненный сервисом, удаляется из очереди.
+func (s *Server) deliverQueuedComment(ctx context.Context, msg outbox.Message) {
+	o := s.outbox
+	var comment queuedComment
+	if err := json.Unmarshal(msg.Payload, &comment); err != nil {
+		slog.Error("Некорректный комментарий в очереди", "queue_id", msg.ID, "error", err)
+		o.drop(ctx, msg)
+		return
+	}
+
+	gen := s.current()
+	if comment.RequestID != "" {
+		ctx = context.WithValue(ctx, requestIDKey, comment.RequestID)
+	}
+	if t := gen.tenants.lookup(comment.Tenant); t != nil {
+		ctx = withTenant(ctx, t)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, gen.commentAddURL(ctx, comment.NewsID), bytes.NewReader(comment.Body))
+	if err != nil {
+		slog.ErrorContext(ctx, "Некорректный адрес backend-сервиса", "error", err)
+		o.retry(ctx, msg, err.Error())
+		return
+	}
+	req.Header.Set("Content-Type", comment.ContentType)
+	gen.requestIDs.apply(req)
+
+	resp, err := gen.transport().RoundTrip(req)
+	if err != nil {
+		o.retry(ctx, msg, err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		o.retry(ctx, msg, err.Error())
+		return
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated:
+	case resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests:
+		o.retry(ctx, msg, fmt.Sprintf("сервис вернул статус %d", resp.StatusCode))
+		return
+	default:
+		slog.ErrorContext(ctx, "Сервис комментариев отклонил комментарий из очереди", "queue_id", msg.ID, "news_id", comment.NewsID, "status", resp.StatusCode, "body", string(respBody))
+		o.drop(ctx, msg)
+		return
+	}
+
+	if err := o.store.Delete(ctx, msg.ID); err != nil {
+		// Комментарий будет доставлен повторно; сервис получит его дважды
+		slog.ErrorContext(ctx, "Не удалось удалить комментарий из очереди", "queue_id", msg.ID, "error", err)
+	}
+	o.results.Inc("delivered")
+	slog.InfoContext(ctx, "Комментарий из очереди добавлен", "queue_id", msg.ID, "news_id", comment.NewsID, "attempts", msg.Attempts+1)
+
+	event := commentEvent{NewsID: comment.NewsID, Text: comment.Text, UserID: comment.UserID, RequestID: comment.RequestID}
+	if json.Valid(respBody) {
+		event.Response = json.RawMessage(respBody)
+	}
+	s.publishEvent(events.TypeCommentCreated, strconv.FormatInt(comment.NewsID, 10), event)
+	gen.invalidateCache(ctx, commentsTag(comment.NewsID), commentCountsTag)
+}
+
+// retry планирует следующую попытку доставки или удаляет комментарий, если
+// исчерпаны comment_outbox.max_attempts
+func (o *commentOutbox) retry(ctx context.Context, msg outbox.Message, reason string) {
+	msg.Attempts++
+	if o.maxAttempts > 0 && msg.Attempts >= o.maxAttempts {
+		slog.ErrorContext(ctx, "Исчерпаны попытки доставки комментария из очереди", "queue_id", msg.ID, "attempts", msg.Attempts, "error", reason)
+		o.drop(ctx, msg)
+		return
+	}
+
+	delay := o.backoff(msg.Attempts)
+	msg.NextAttempt = time.Now().Add(delay)
+	if err := o.store.Put(ctx, msg); err != nil {
+		slog.ErrorContext(ctx, "Не удалось сохранить комментарий в очередь", "queue_id", msg.ID, "error", err)
+		return
+	}
+	o.results.Inc("retried")
+	slog.WarnContext(ctx, "Не удалось доставить комментарий из очереди", "queue_id", msg.ID, "attempts", msg.Attempts, "retry_in", delay, "error", reason)
+}
+
+// drop удаляет комментарий из очереди без доставки
+func (o *commentOutbox) drop(ctx context.Context, msg outbox.Message) {
+	if err := o.store.Delete(ctx, msg.ID); err != nil {
+		slog.ErrorContext(ctx, "Не удалось удалить комментарий из очереди", "queue_id", msg.ID, "error", err)
+		return
+	}
+	o.results.Inc("dropped")
+}
+
+// close закрывает хранилище очереди
+func (o *commentOutbox) close() {
+	if o != nil {
+		o.store.Close()
+	}
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"apigw/pkg/config"
+	"apigw/pkg/metrics"
+)
+
+// Способы аутентификации политик доступа (policies[].auth)
+const (
+	policyAuthNone   = "none"
+	policyAuthAPIKey = "api_key"
+	policyAuthJWT    = "jwt"
+)
+
+// accessPolicy - политика доступа маршрута
+type accessPolicy struct {
+	path    string
+	methods []string
+	auth    string
+	scopes  []string
+	roles   []string
+}
+
+// accessPolicies проверяет запросы по политикам доступа из секции policies.
+// Ключ API и токен проверяются теми же auth и jwt, что и в их секциях.
+type accessPolicies struct {
+	policies []*accessPolicy
+	auth     *apiKeyAuth
+	jwt      *jwtAuth
+	denials  *metrics.CounterVec
+}
+
+// policyDenial - отказ в доступе по политике
+type policyDenial struct {
+	status  int
+	code    string
+	message string
+	// err - ошибка проверки токена для ответа с WWW-Authenticate
+	err error
+}
+
+// newAccessPolicies проверяет секцию policies. Возвращает nil, если политик нет.
+func newAccessPolicies(cfg []config.PolicyConfig, auth *apiKeyAuth, jwt *jwtAuth, registry *metrics.Registry) (*accessPolicies, error) {
+	if len(cfg) == 0 {
+		return nil, nil
+	}
+
+	p := &accessPolicies{
+		auth: auth,
+		jwt:  jwt,
+		denials: registry.Counter("apigw_policy_denials_total",
+			"Запросы, отклоненные политиками доступа маршрутов", "reason"),
+	}
+	for _, pc := range cfg {
+		if !strings.HasPrefix(pc.Path, "/") {
+			return nil, fmt.Errorf("путь политики доступа должен начинаться с /: %q", pc.Path)
+		}
+		policy := &accessPolicy{path: pc.Path, auth: pc.Auth, scopes: pc.Scopes, roles: pc.Roles}
+		if policy.auth == "" {
+			policy.auth = policyAuthNone
+		}
+		for _, method := range pc.Methods {
+			policy.methods = append(policy.methods, strings.ToUpper(method))
+		}
+
+		switch policy.auth {
+		case policyAuthNone:
+		case policyAuthAPIKey:
+			if auth == nil {
+				return nil, fmt.Errorf("политика доступа %s требует ключ API, но секция auth отключена", pc.Path)
+			}
+		case policyAuthJWT:
+			if jwt == nil {
+				return nil, fmt.Errorf("политика доступа %s требует токен, но секция jwt отключена", pc.Path)
+			}
+		default:
+			return nil, fmt.Errorf("неизвестный способ аутентификации политики доступа %s: %q", pc.Path, pc.Auth)
+		}
+		if (len(policy.scopes) > 0 || len(policy.roles) > 0) && policy.auth != policyAuthJWT {
+			return nil, fmt.Errorf("области доступа и роли политики %s проверяются только с auth: jwt", pc.Path)
+		}
+		p.policies = append(p.policies, policy)
+	}
+	return p, nil
+}
+
+// match возвращает первую политику, путь которой подходит запросу, или nil
+func (p *accessPolicies) match(path string) *accessPolicy {
+	for _, policy := range p.policies {
+		if pathMatches(policy.path, path) {
+			return policy
+		}
+	}
+	return nil
+}
+
+// uses проверяет, что хотя бы одна политика использует способ аутентификации auth
+func (p *accessPolicies) uses(auth string) bool {
+	if p == nil {
+		return false
+	}
+	for _, policy := range p.policies {
+		if policy.auth == auth {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize проверяет запрос по политике его маршрута и возвращает контекст
+// с клиентом или утверждениями токена, проверенными политикой
+func (p *accessPolicies) authorize(ctx context.Context, method, path string, header http.Header) (context.Context, *policyDenial) {
+	policy := p.match(path)
+	if policy == nil {
+		return ctx, nil
+	}
+
+	if len(policy.methods) > 0 && !containsString(policy.methods, method) {
+		p.denials.Inc("method")
+		return ctx, &policyDenial{status: http.StatusMethodNotAllowed, code: codeMethodNotAllowed, message: "Метод не разрешен"}
+	}
+
+	switch policy.auth {
+	case policyAuthAPIKey:
+		// Ключ уже проверен authMiddleware, если путь указан в auth.paths
+		if clientFrom(ctx) != "" {
+			break
+		}
+		key := header.Get(p.auth.header)
+		client, ok := p.auth.authenticate(key)
+		if !ok {
+			reason := "invalid"
+			if key == "" {
+				reason = "missing"
+			}
+			p.auth.failures.Inc(reason)
+			p.denials.Inc("unauthenticated")
+			return ctx, &policyDenial{status: http.StatusUnauthorized, code: codeUnauthorized, message: "Требуется действительный ключ API"}
+		}
+		p.auth.requests.Inc(client)
+		ctx = withClient(ctx, client)
+	case policyAuthJWT:
+		// Токен уже проверен jwtMiddleware, если путь указан в jwt.routes
+		if claimsFrom(ctx) != nil {
+			break
+		}
+		claims, err := p.jwt.verify(&jwtRule{}, header)
+		if err != nil {
+			p.denials.Inc("unauthenticated")
+			return ctx, &policyDenial{status: http.StatusUnauthorized, code: codeUnauthorized, message: "Требуется действительный токен доступа", err: err}
+		}
+		ctx = withClaims(ctx, claims)
+	}
+
+	if policy.auth == policyAuthJWT {
+		claims := claimsFrom(ctx)
+		granted := append(claims.Strings("scope"), claims.Strings("scp")...)
+		for _, scope := range policy.scopes {
+			if !containsString(granted, scope) {
+				p.denials.Inc("forbidden")
+				return ctx, &policyDenial{status: http.StatusForbidden, code: codeForbidden, message: fmt.Sprintf("Нет области доступа %s", scope)}
+			}
+		}
+		if len(policy.roles) > 0 && !containsAny(claims.Strings(p.jwt.rolesClaim), policy.roles) {
+			p.denials.Inc("forbidden")
+			return ctx, &policyDenial{status: http.StatusForbidden, code: codeForbidden, message: "Недостаточно прав"}
+		}
+	}
+	return ctx, nil
+}
+
+// containsString проверяет, что values содержит value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAny проверяет, что values содержит хотя бы одно значение из wanted
+func containsAny(values, wanted []string) bool {
+	for _, value := range wanted {
+		if containsString(values, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// policyMiddleware проверяет запросы по политикам доступа маршрутов после
+// проверки ключа API и токена, чтобы обработчикам не нужны были свои проверки
+func (s *Server) policyMiddleware(next http.Handler) http.Handler {
+	if s.policies == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := unversionedPath(r.URL.Path)
+		ctx, denial := s.policies.authorize(r.Context(), r.Method, path, r.Header)
+		if denial == nil {
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		switch {
+		case denial.err != nil:
+			writeTokenError(w, r, denial.err)
+		case denial.status == http.StatusMethodNotAllowed:
+			policy := s.policies.match(path)
+			w.Header().Set("Allow", strings.Join(policy.methods, ", "))
+			writeError(w, r, denial.status, denial.code, denial.message)
+		default:
+			slog.WarnContext(r.Context(), "Запрос отклонен политикой доступа", "method", r.Method, "path", r.URL.Path, "ip", clientIP(r), "error", denial.message)
+			writeError(w, r, denial.status, denial.code, denial.message)
+		}
+	})
+}
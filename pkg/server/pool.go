@@ -0,0 +1,99 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Буферы больше этого размера не возвращаются в пул, чтобы редкие большие
+// ответы не удерживали память
+const maxPooledBufferSize = 1 << 20
+
+// Пул буферов для чтения ответов сервисов и кодирования ответов клиентам
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// getBuffer берет пустой буфер из пула
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer возвращает буфер в пул. После вызова буфер и его содержимое
+// использовать нельзя.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// readPooled читает тело полностью в буфер из пула. Буфер нужно вернуть через putBuffer.
+func readPooled(r io.Reader) (*bytes.Buffer, error) {
+	buf := getBuffer()
+	if _, err := buf.ReadFrom(r); err != nil {
+		putBuffer(buf)
+		return nil, err
+	}
+	return buf, nil
+}
+
+// copyBufferSize - размер буферов, через которые обратный прокси передает тела ответов
+const copyBufferSize = 32 * 1024
+
+// copyBufferPool - пул буферов копирования для httputil.ReverseProxy
+type copyBufferPool struct {
+	pool sync.Pool
+}
+
+// Get берет буфер из пула
+func (p *copyBufferPool) Get() []byte {
+	if buf, ok := p.pool.Get().(*[]byte); ok {
+		return *buf
+	}
+	return make([]byte, copyBufferSize)
+}
+
+// Put возвращает буфер в пул
+func (p *copyBufferPool) Put(buf []byte) {
+	p.pool.Put(&buf)
+}
+
+// Пул буферов копирования тел ответов, общий для всех обратных прокси
+var copyBuffers = &copyBufferPool{}
+
+// jsonEncoder - кодировщик JSON, связанный с собственным буфером
+type jsonEncoder struct {
+	buf *bytes.Buffer
+	enc *json.Encoder
+}
+
+// Пул кодировщиков JSON, чтобы не создавать кодировщик на каждый ответ
+var encoderPool = sync.Pool{
+	New: func() interface{} {
+		buf := new(bytes.Buffer)
+		return &jsonEncoder{buf: buf, enc: json.NewEncoder(buf)}
+	},
+}
+
+// writeJSON кодирует значение и отправляет его одним вызовом Write.
+// Формат совпадает с json.NewEncoder(w).Encode(v), включая перевод строки в конце.
+func writeJSON(w http.ResponseWriter, v interface{}) error {
+	e := encoderPool.Get().(*jsonEncoder)
+	defer func() {
+		if e.buf.Cap() <= maxPooledBufferSize {
+			e.buf.Reset()
+			encoderPool.Put(e)
+		}
+	}()
+
+	if err := e.enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := w.Write(e.buf.Bytes())
+	return err
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"apigw/pkg/config"
+)
+
+// Hop-by-hop заголовки, которые не передаются через прокси (RFC 7230, раздел 6.1)
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// proxyRoute - маршрут из таблицы маршрутов конфигурации
+type proxyRoute struct {
+	config   config.RouteConfig
+	upstream *url.URL
+	// Семафор, ограничивающий число одновременных WebSocket соединений
+	conns chan struct{}
+	// Отправка запросов backend-сервису
+	transport *upstreamTransport
+	// Преобразования тела запросов к backend-сервису
+	transforms []*bodyTransform
+	// Способ передачи request_id backend-сервису
+	requestIDs requestIDPropagation
+	// Заголовки с утверждениями токена клиента
+	claimHeaders claimHeaders
+	// Сервисы, среди которых правила canary выбирают версию сервиса маршрута
+	services config.ServicesConfig
+	// Инвалидация кэша после изменяющих запросов (routes[].invalidates)
+	invalidate func(ctx context.Context, tags ...string)
+}
+
+// newProxyRoute проверяет конфигурацию маршрута и создает его. Адрес сервиса,
+// указанного по имени, берется из services.
+func newProxyRoute(cfg config.RouteConfig, services config.ServicesConfig) (*proxyRoute, error) {
+	if !strings.HasPrefix(cfg.Path, "/") {
+		return nil, fmt.Errorf("путь маршрута должен начинаться с /: %q", cfg.Path)
+	}
+	if cfg.Service != "" {
+		if cfg.Upstream != "" {
+			return nil, fmt.Errorf("для маршрута %s указаны и upstream, и service", cfg.Path)
+		}
+		service, ok := services[cfg.Service]
+		if !ok {
+			return nil, fmt.Errorf("сервис %q маршрута %s отсутствует в секции services", cfg.Service, cfg.Path)
+		}
+		cfg.Upstream = service.URL
+	}
+
+	upstream, err := url.Parse(cfg.Upstream)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный адрес backend-сервиса маршрута %s: %w", cfg.Path, err)
+	}
+	switch upstream.Scheme {
+	case "http", "https", "ws", "wss":
+	default:
+		return nil, fmt.Errorf("неподдерживаемая схема backend-сервиса маршрута %s: %q", cfg.Path, upstream.Scheme)
+	}
+	if upstream.Host == "" {
+		return nil, fmt.Errorf("не указан хост backend-сервиса маршрута %s", cfg.Path)
+	}
+
+	for _, kind := range cfg.Invalidates {
+		if kind != config.ServiceNews && kind != config.ServiceComments {
+			return nil, fmt.Errorf("неизвестный вид данных %q в invalidates маршрута %s", kind, cfg.Path)
+		}
+	}
+
+	route := &proxyRoute{config: cfg, upstream: upstream, services: services}
+	if cfg.MaxConnections > 0 {
+		route.conns = make(chan struct{}, cfg.MaxConnections)
+	}
+	return route, nil
+}
+
+// isWebSocket сообщает, что маршрут ведет на WebSocket backend
+func (p *proxyRoute) isWebSocket() bool {
+	return p.upstream.Scheme == "ws" || p.upstream.Scheme == "wss"
+}
+
+// targetURL формирует адрес запроса к backend-сервису
+func (p *proxyRoute) targetURL(r *http.Request) *url.URL {
+	path := r.URL.Path
+	if p.config.StripPrefix {
+		path = "/" + strings.TrimPrefix(strings.TrimPrefix(path, strings.TrimSuffix(p.config.Path, "/")), "/")
+	}
+
+	upstream := p.upstream
+	if p.config.Service != "" {
+		if name := canaryService(r.Context(), p.config.Service); name != p.config.Service {
+			if u, err := url.Parse(p.services[name].URL); err == nil {
+				upstream = u
+			}
+		}
+	}
+
+	target := *upstream
+	target.Path = strings.TrimSuffix(upstream.Path, "/") + path
+	target.RawPath = ""
+	target.RawQuery = r.URL.RawQuery
+	return &target
+}
+
+// mutationTags возвращает теги кэша, которые устаревают после успешного
+// изменяющего запроса к маршруту. ID новости берется из последнего сегмента
+// пути, а если он не число - из параметра news_id.
+func (p *proxyRoute) mutationTags(r *http.Request) []string {
+	segment := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	id, err := strconv.ParseInt(segment, 10, 64)
+	if err != nil {
+		id, err = strconv.ParseInt(r.URL.Query().Get("news_id"), 10, 64)
+	}
+	hasID := err == nil
+
+	var tags []string
+	for _, kind := range p.config.Invalidates {
+		switch kind {
+		case config.ServiceNews:
+			tags = append(tags, newsListTag)
+			if hasID {
+				tags = append(tags, newsTag(id))
+			}
+		case config.ServiceComments:
+			tags = append(tags, commentCountsTag)
+			if hasID {
+				tags = append(tags, commentsTag(id))
+			}
+		}
+	}
+	return tags
+}
+
+// copyHeaders копирует заголовки, пропуская hop-by-hop заголовки
+func copyHeaders(dst, src http.Header) {
+	for name, values := range src {
+		dst[name] = append([]string(nil), values...)
+	}
+	for _, name := range hopHeaders {
+		dst.Del(name)
+	}
+}
+
+// setForwardedHeaders добавляет заголовки X-Forwarded-* для backend-сервиса
+func setForwardedHeaders(dst http.Header, r *http.Request) {
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if prior := r.Header.Get("X-Forwarded-For"); prior != "" {
+			ip = prior + ", " + ip
+		}
+		dst.Set("X-Forwarded-For", ip)
+	}
+	dst.Set("X-Forwarded-Host", r.Host)
+	if r.TLS != nil {
+		dst.Set("X-Forwarded-Proto", "https")
+	} else {
+		dst.Set("X-Forwarded-Proto", "http")
+	}
+}
+
+// ServeHTTP проксирует запрос на backend-сервис маршрута
+func (p *proxyRoute) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p.isWebSocket() {
+		p.serveWebSocket(w, r)
+		return
+	}
+
+	if t := findBodyTransform(p.transforms, r); t != nil {
+		transformed, err := t.transformRequestBody(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Не удалось преобразовать тело запроса", "path", r.URL.Path, "error", err)
+			writeError(w, r, http.StatusBadRequest, codeInvalidJSON, "Неверный формат JSON в теле запроса")
+			return
+		}
+		r = transformed
+	}
+
+	proxy := newReverseProxy(p.transport, proxyHooks{
+		rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL = p.targetURL(pr.In)
+			p.requestIDs.apply(pr.Out)
+			p.claimHeaders.apply(pr.Out)
+			pr.Out.Host = ""
+			setForwardedHeaders(pr.Out.Header, pr.In)
+		},
+		modifyResponse: func(resp *http.Response) error {
+			// Поток событий передается клиенту сразу, без буферизации промежуточными прокси
+			if isEventStream(resp.Header) && resp.StatusCode == http.StatusOK {
+				resp.Header.Del("Content-Length")
+				resp.Header.Set("Cache-Control", "no-cache")
+				resp.Header.Set("X-Accel-Buffering", "no")
+				resp.Body = withHeartbeat(resp.Body, p.config.HeartbeatInterval.Std())
+			}
+			method := resp.Request.Method
+			if p.invalidate != nil && method != http.MethodGet && method != http.MethodHead && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				p.invalidate(resp.Request.Context(), p.mutationTags(r)...)
+			}
+			return nil
+		},
+		onError: func(w http.ResponseWriter, r *http.Request, err error) {
+			if status := backendErrorStatus(err); status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout {
+				writeBackendError(w, r, err, "Сервис перегружен")
+				return
+			}
+			slog.ErrorContext(r.Context(), "Ошибка при обращении к backend-сервису", "backend", p.config.Upstream, "url", p.targetURL(r).Redacted(), "error", err)
+			writeError(w, r, http.StatusBadGateway, codeUpstreamUnavailable, "Сервис недоступен")
+		},
+	})
+	proxy.ServeHTTP(w, r)
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+
+	"apigw/pkg/cache"
+)
+
+// CachePurge описывает записи кэша ответов, которые нужно сбросить
+type CachePurge struct {
+	// Routes - кэшируемые маршруты: /api/news, /api/fullnews, /api/comments,
+	// /api/news/batch или /api/news/ (страницы отдельных новостей)
+	Routes []string `json:"routes,omitempty"`
+	// NewsIDs - новости, которые изменились: сбрасываются их страницы,
+	// комментарии, списки новостей и отметки об отсутствии новостей
+	NewsIDs []int64 `json:"news_ids,omitempty"`
+	// Pattern - шаблон кэшируемых маршрутов в синтаксисе path.Match, например /api/news*
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// tags возвращает теги кэша, которые нужно инвалидировать
+func (p CachePurge) tags() ([]string, error) {
+	if len(p.Routes) == 0 && len(p.NewsIDs) == 0 && p.Pattern == "" {
+		return nil, errors.New("не указаны маршруты, ID новостей или шаблон")
+	}
+
+	var tags []string
+	for _, route := range p.Routes {
+		if !containsString(cachedRoutes, route) {
+			return nil, fmt.Errorf("маршрут %s не кэшируется", route)
+		}
+		tags = append(tags, routeTag(route))
+	}
+	for _, id := range p.NewsIDs {
+		if id <= 0 {
+			return nil, fmt.Errorf("некорректный ID новости: %d", id)
+		}
+		tags = append(tags, newsTag(id), commentsTag(id))
+	}
+	if len(p.NewsIDs) > 0 {
+		tags = append(tags, newsListTag)
+	}
+	if p.Pattern != "" {
+		matched := false
+		for _, route := range cachedRoutes {
+			ok, err := path.Match(p.Pattern, route)
+			if err != nil {
+				return nil, fmt.Errorf("некорректный шаблон маршрутов %q: %w", p.Pattern, err)
+			}
+			if ok {
+				tags = append(tags, routeTag(route))
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("шаблон %q не соответствует ни одному кэшируемому маршруту", p.Pattern)
+		}
+	}
+	return tags, nil
+}
+
+// PurgeCache сбрасывает записи кэша ответов по маршрутам, ID новостей или
+// шаблону маршрутов во всех разделах арендаторов и возвращает
+// инвалидированные теги. Позволяет сбрасывать кэш при встраивании шлюза
+// через Handler; сервис новостей может делать то же через административный API.
+func (s *Server) PurgeCache(ctx context.Context, purge CachePurge) ([]string, error) {
+	tags, err := purge.tags()
+	if err != nil {
+		return nil, err
+	}
+	srv := s.current()
+	if err := srv.invalidateEverywhere(ctx, tags); err != nil {
+		return nil, err
+	}
+
+	// Новость могла появиться после того, как сервис новостей ответил "не найдено"
+	for _, store := range srv.cacheStores() {
+		for _, id := range purge.NewsIDs {
+			if err := store.Delete(ctx, srv.missingNewsKey(id)); err != nil && !errors.Is(err, cache.ErrNotFound) {
+				return nil, err
+			}
+		}
+	}
+	slog.InfoContext(ctx, "Записи кэша ответов сброшены", "tags", tags)
+	return tags, nil
+}
+
+// cacheStores возвращает хранилище кэша и разделы всех арендаторов
+func (s *Server) cacheStores() []cache.Cache {
+	stores := []cache.Cache{s.store}
+	if s.tenants != nil {
+		for _, t := range s.tenants.byName {
+			stores = append(stores, t.store)
+		}
+	}
+	return stores
+}
+
+// invalidateEverywhere инвалидирует теги в кэше и разделах всех арендаторов
+func (s *Server) invalidateEverywhere(ctx context.Context, tags []string) error {
+	versions := []*cache.Tags{s.tags}
+	if s.tenants != nil {
+		for _, t := range s.tenants.byName {
+			versions = append(versions, t.tags)
+		}
+	}
+	for _, v := range versions {
+		if err := v.Invalidate(ctx, tags...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleAdminCachePurge сбрасывает записи кэша ответов, описанные в теле
+// запроса (CachePurge). Используется сервисом новостей для уведомления
+// шлюза об изменении содержимого.
+func (s *Server) handleAdminCachePurge(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	var purge CachePurge
+	if err := json.NewDecoder(r.Body).Decode(&purge); err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidJSON, "Некорректный JSON в теле запроса")
+		return
+	}
+	if _, err := purge.tags(); err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	tags, err := s.PurgeCache(r.Context(), purge)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка при сбросе кэша", "error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, "Не удалось сбросить кэш")
+		return
+	}
+	writeJSON(w, map[string]interface{}{"purged": tags})
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"apigw/pkg/config"
+)
+
+// Ключ контекста для хранения класса приоритета запроса
+const qosClassKey contextKey = "qos_class"
+
+// qosClass - класс приоритета запроса
+type qosClass struct {
+	name string
+	// share - доля лимита одновременных запросов к backend-сервису
+	share float64
+	// shedThreshold - доля бюджета памяти, начиная с которой запросы
+	// класса отклоняются, 0 - не отклонять
+	shedThreshold float64
+}
+
+// qosRoute - правило отнесения запросов к классу
+type qosRoute struct {
+	pathPrefix string
+	method     string
+	class      *qosClass
+}
+
+// qosPolicy относит запросы к классам приоритета согласно секции qos
+type qosPolicy struct {
+	classes map[string]*qosClass
+	routes  []qosRoute
+	// lowPriorityPaths - префиксы путей из shedding.low_priority_paths, запросы к ним относятся к export
+	lowPriorityPaths []string
+}
+
+// newQoSPolicy проверяет настройки классов приоритета. Возвращает nil, если они отключены.
+func newQoSPolicy(cfg *config.Config) (*qosPolicy, error) {
+	if !cfg.QoS.Enabled {
+		return nil, nil
+	}
+
+	q := &qosPolicy{
+		classes:          make(map[string]*qosClass),
+		lowPriorityPaths: cfg.Shedding.LowPriorityPaths,
+	}
+	for _, name := range []string{config.QoSInteractive, config.QoSWrite, config.QoSExport} {
+		classCfg, ok := cfg.QoS.Classes[name]
+		if !ok {
+			return nil, fmt.Errorf("не заданы настройки класса приоритета %s", name)
+		}
+		if classCfg.ConcurrencyShare <= 0 || classCfg.ConcurrencyShare > 1 {
+			return nil, fmt.Errorf("доля лимита класса %s должна быть в диапазоне (0, 1]: %v", name, classCfg.ConcurrencyShare)
+		}
+		if classCfg.ShedThreshold < 0 || classCfg.ShedThreshold > 1 {
+			return nil, fmt.Errorf("порог сброса нагрузки класса %s должен быть в диапазоне [0, 1]: %v", name, classCfg.ShedThreshold)
+		}
+		q.classes[name] = &qosClass{name: name, share: classCfg.ConcurrencyShare, shedThreshold: classCfg.ShedThreshold}
+	}
+	for name := range cfg.QoS.Classes {
+		if q.classes[name] == nil {
+			return nil, fmt.Errorf("неизвестный класс приоритета: %q", name)
+		}
+	}
+
+	for _, route := range cfg.QoS.Routes {
+		class := q.classes[route.Class]
+		if class == nil {
+			return nil, fmt.Errorf("неизвестный класс приоритета маршрута %s: %q", route.PathPrefix, route.Class)
+		}
+		if !strings.HasPrefix(route.PathPrefix, "/") {
+			return nil, fmt.Errorf("префикс пути класса приоритета должен начинаться с /: %q", route.PathPrefix)
+		}
+		q.routes = append(q.routes, qosRoute{
+			pathPrefix: route.PathPrefix,
+			method:     strings.ToUpper(route.Method),
+			class:      class,
+		})
+	}
+	return q, nil
+}
+
+// classify определяет класс приоритета запроса
+func (q *qosPolicy) classify(r *http.Request) *qosClass {
+	for _, route := range q.routes {
+		if (route.method == "" || route.method == r.Method) && strings.HasPrefix(r.URL.Path, route.pathPrefix) {
+			return route.class
+		}
+	}
+
+	if r.URL.Query().Get("s") != "" {
+		return q.classes[config.QoSExport]
+	}
+	for _, prefix := range q.lowPriorityPaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return q.classes[config.QoSExport]
+		}
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return q.classes[config.QoSInteractive]
+	}
+	return q.classes[config.QoSWrite]
+}
+
+// qosMiddleware определяет класс приоритета запроса и передает его через
+// контекст сбросу нагрузки и лимитам запросов к backend-сервисам
+func (s *Server) qosMiddleware(next http.Handler) http.Handler {
+	if s.qos == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class := s.qos.classify(r)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), qosClassKey, class)))
+	})
+}
+
+// qosClassFrom возвращает класс приоритета запроса или nil, если классы не настроены
+func qosClassFrom(ctx context.Context) *qosClass {
+	class, _ := ctx.Value(qosClassKey).(*qosClass)
+	return class
+}
+
+// concurrencyShare возвращает долю лимита одновременных запросов, доступную запросу
+func concurrencyShare(ctx context.Context) float64 {
+	if class := qosClassFrom(ctx); class != nil {
+		return class.share
+	}
+	return 1
+}
@@ -0,0 +1,268 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"apigw/pkg/config"
+	"apigw/pkg/limit"
+)
+
+// rateSweepInterval - как часто удаляются корзины клиентов, которые давно не обращались
+const rateSweepInterval = time.Minute
+
+// rateRedisRetryInterval - сколько после ошибки Redis лимиты проверяются по
+// локальным корзинам, прежде чем шлюз снова обратится к Redis
+const rateRedisRetryInterval = 5 * time.Second
+
+// Ключи клиентов для лимитов (rate_limit.key)
+const (
+	rateKeyIP     = "ip"
+	rateKeyAPIKey = "api_key"
+)
+
+// clientRateRule - лимит запросов с одного IP-адреса. Пустой path -
+// лимит по умолчанию для запросов, не подходящих правилам маршрутов.
+type clientRateRule struct {
+	path      string
+	method    string
+	perSecond float64
+	burst     int
+}
+
+// matches проверяет, что правило относится к запросу
+func (rule *clientRateRule) matches(r *http.Request) bool {
+	if rule.path == "" {
+		return true
+	}
+	return (rule.method == "" || rule.method == r.Method) && pathMatches(rule.path, r.URL.Path)
+}
+
+// clientBucket - корзина жетонов клиента для одного правила
+type clientBucket struct {
+	rate     *limit.Rate
+	lastSeen time.Time
+	// idle - через сколько после последнего запроса корзина снова полная
+	idle time.Duration
+}
+
+// clientRateLimiter ограничивает частоту запросов с одного IP-адреса или
+// ключа API клиента. Корзины хранятся в памяти или в Redis; при недоступности
+// Redis используются корзины в памяти.
+type clientRateLimiter struct {
+	rules      []*clientRateRule
+	maxClients int
+	// byAPIKey - у клиентов с действительным ключом API общий лимит по имени клиента
+	byAPIKey bool
+	// redis - корзины, общие для экземпляров шлюза, nil - только в памяти
+	redis *limit.RedisRates
+
+	mu        sync.Mutex
+	buckets   map[string]*clientBucket
+	lastSweep time.Time
+	// redisDownUntil - до какого времени Redis не используется после ошибки
+	redisDownUntil time.Time
+}
+
+// newClientRateLimiter проверяет секцию rate_limit. Возвращает nil, если ограничение отключено.
+func newClientRateLimiter(cfg config.ClientRateLimitConfig) (*clientRateLimiter, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	l := &clientRateLimiter{
+		maxClients: cfg.MaxClients,
+		buckets:    make(map[string]*clientBucket),
+		lastSweep:  time.Now(),
+	}
+	switch cfg.Key {
+	case "", rateKeyIP:
+	case rateKeyAPIKey:
+		l.byAPIKey = true
+	default:
+		return nil, fmt.Errorf("неизвестный ключ клиента rate_limit.key: %q", cfg.Key)
+	}
+	switch cfg.Store {
+	case "", "memory":
+	case "redis":
+		if cfg.Redis.Addr == "" {
+			return nil, fmt.Errorf("не указан адрес Redis (rate_limit.redis.addr)")
+		}
+	default:
+		return nil, fmt.Errorf("неизвестное хранилище лимитов rate_limit.store: %q", cfg.Store)
+	}
+	for _, rc := range cfg.Routes {
+		if !strings.HasPrefix(rc.Path, "/") {
+			return nil, fmt.Errorf("путь лимита запросов должен начинаться с /: %q", rc.Path)
+		}
+		if rc.RequestsPerSecond <= 0 || rc.Burst < 0 {
+			return nil, fmt.Errorf("некорректный лимит запросов для %s", rc.Path)
+		}
+		l.rules = append(l.rules, &clientRateRule{
+			path:      rc.Path,
+			method:    strings.ToUpper(rc.Method),
+			perSecond: rc.RequestsPerSecond,
+			burst:     rc.Burst,
+		})
+	}
+	if cfg.RequestsPerSecond < 0 || cfg.Burst < 0 {
+		return nil, fmt.Errorf("некорректный лимит запросов rate_limit")
+	}
+	if cfg.RequestsPerSecond > 0 {
+		l.rules = append(l.rules, &clientRateRule{perSecond: cfg.RequestsPerSecond, burst: cfg.Burst})
+	}
+	if len(l.rules) == 0 {
+		return nil, fmt.Errorf("в секции rate_limit не задан ни один лимит")
+	}
+
+	// Подключение создается последним, чтобы не оставлять его открытым при
+	// ошибке в настройках
+	if cfg.Store == "redis" {
+		l.redis = limit.NewRedisRates(limit.RedisOptions{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+			Prefix:   cfg.Redis.Prefix,
+			Timeout:  cfg.Redis.Timeout.Std(),
+		})
+	}
+	return l, nil
+}
+
+// take забирает жетон из корзины клиента для запроса r. Возвращает
+// false, если к запросу не относится ни одно правило.
+func (l *clientRateLimiter) take(r *http.Request, client string) (limit.Decision, bool) {
+	index := -1
+	for i, rule := range l.rules {
+		if rule.matches(r) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return limit.Decision{}, false
+	}
+	rule := l.rules[index]
+	key := strconv.Itoa(index) + "|" + client
+	now := time.Now()
+
+	if l.redis != nil && l.redisAvailable(now) {
+		decision, err := l.redis.Take(r.Context(), key, rule.perSecond, rule.burst)
+		if err == nil {
+			return decision, true
+		}
+		if r.Context().Err() != nil {
+			// Клиент отменил запрос, Redis тут ни при чем
+			return limit.Decision{}, false
+		}
+		l.redisFailed(now, err)
+	}
+
+	l.mu.Lock()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= l.maxClients || now.Sub(l.lastSweep) >= rateSweepInterval {
+			l.sweep(now)
+		}
+		if len(l.buckets) >= l.maxClients {
+			l.mu.Unlock()
+			slog.Warn("Достигнуто максимальное число отслеживаемых клиентов, запрос не ограничивается", "max_clients", l.maxClients, "client", client)
+			return limit.Decision{}, false
+		}
+		rate := limit.NewRate(rule.perSecond, rule.burst)
+		bucket = &clientBucket{rate: rate, idle: time.Duration(float64(rate.Burst()) / rule.perSecond * float64(time.Second))}
+		l.buckets[key] = bucket
+	}
+	bucket.lastSeen = now
+	l.mu.Unlock()
+
+	return bucket.rate.Take(), true
+}
+
+// redisAvailable сообщает, что лимиты можно проверять в Redis
+func (l *clientRateLimiter) redisAvailable(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return !now.Before(l.redisDownUntil)
+}
+
+// redisFailed переводит лимиты на корзины в памяти на rateRedisRetryInterval
+func (l *clientRateLimiter) redisFailed(now time.Time, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Before(l.redisDownUntil) {
+		return
+	}
+	l.redisDownUntil = now.Add(rateRedisRetryInterval)
+	slog.Warn("Redis недоступен, лимиты запросов проверяются локально", "retry_in", rateRedisRetryInterval, "error", err)
+}
+
+// close закрывает подключение к Redis
+func (l *clientRateLimiter) close() {
+	if l != nil && l.redis != nil {
+		l.redis.Close()
+	}
+}
+
+// sweep удаляет корзины клиентов, которые успели заполниться после
+// последнего запроса: такая корзина не отличается от новой. Вызывается под l.mu.
+func (l *clientRateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.lastSeen) >= bucket.idle {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// rateClient возвращает ключ корзины клиента: имя клиента для запроса с
+// действительным ключом API при rate_limit.key = api_key, иначе IP-адрес.
+// Ключ проверяется до authMiddleware, поэтому запросы с недействительными
+// ключами ограничиваются по IP-адресу.
+func (s *Server) rateClient(r *http.Request) string {
+	if s.rateLimiter.byAPIKey && s.auth != nil {
+		if client, ok := s.auth.authenticate(r.Header.Get(s.auth.header)); ok {
+			return "client:" + client
+		}
+	}
+	return clientIP(r)
+}
+
+// rateLimitMiddleware ограничивает частоту запросов с одного IP-адреса или
+// ключа API клиента и сообщает клиенту состояние лимита в заголовках X-RateLimit-*
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	if s.rateLimiter == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := s.rateClient(r)
+		decision, ok := s.rateLimiter.take(r, client)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+		if !decision.Allowed {
+			slog.WarnContext(r.Context(), "Запрос отклонен: превышен лимит запросов", "method", r.Method, "path", r.URL.Path, "ip", clientIP(r), "client", client)
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(decision.RetryAfter)))
+			writeError(w, r, http.StatusTooManyRequests, codeRateLimited, "Превышен лимит запросов, повторите запрос позже")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ceilSeconds округляет интервал вверх до целых секунд, но не меньше 1
+func ceilSeconds(d time.Duration) int {
+	return int(math.Max(1, math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"apigw/pkg/config"
+)
+
+// serviceCheck - результат проверки доступности backend-сервиса
+type serviceCheck struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// readinessResponse - ответ проверки готовности
+type readinessResponse struct {
+	Status   string                  `json:"status"`
+	Services map[string]serviceCheck `json:"services"`
+}
+
+// readinessProbe проверяет доступность backend-сервисов для /readyz.
+// Результат используется в течение ttl, чтобы частые проверки оркестратора
+// не создавали нагрузку на сервисы.
+type readinessProbe struct {
+	services []string
+	timeout  time.Duration
+	ttl      time.Duration
+
+	mu       sync.Mutex
+	checked  time.Time
+	response readinessResponse
+}
+
+// newReadinessProbe проверяет секцию health
+func newReadinessProbe(cfg config.HealthConfig, services config.ServicesConfig) (*readinessProbe, error) {
+	for _, path := range []string{cfg.LivenessPath, cfg.ReadinessPath} {
+		if path == "" {
+			continue
+		}
+		if _, taken := builtinRoutes[path]; taken || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("некорректный путь проверки работоспособности: %q", path)
+		}
+	}
+	if cfg.LivenessPath != "" && cfg.LivenessPath == cfg.ReadinessPath {
+		return nil, fmt.Errorf("пути health.liveness_path и health.readiness_path совпадают")
+	}
+	for _, name := range cfg.Services {
+		if _, ok := services[name]; !ok {
+			return nil, fmt.Errorf("неизвестный сервис в health.services: %q", name)
+		}
+	}
+	return &readinessProbe{services: cfg.Services, timeout: cfg.Timeout.Std(), ttl: cfg.CacheTTL.Std()}, nil
+}
+
+// isHealthPath проверяет, что по пути path отдается проверка работоспособности или готовности
+func (s *Server) isHealthPath(path string) bool {
+	return path != "" && (path == s.config.Health.LivenessPath || path == s.config.Health.ReadinessPath)
+}
+
+// check возвращает результат проверки сервисов, выполняя ее заново, если
+// сохраненный результат устарел. Одновременные запросы ждут одну проверку.
+func (p *readinessProbe) check(s *Server) readinessResponse {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.checked.IsZero() && time.Since(p.checked) < p.ttl {
+		return p.response
+	}
+
+	response := readinessResponse{Status: "ok", Services: make(map[string]serviceCheck, len(p.services))}
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, name := range p.services {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := p.probe(s, name)
+			mu.Lock()
+			response.Services[name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	for name, result := range response.Services {
+		if result.Status != "ok" {
+			response.Status = "unavailable"
+			slog.Warn("Backend-сервис не прошел проверку готовности", "backend", name, "error", result.Error)
+		}
+	}
+	p.response, p.checked = response, time.Now()
+	return response
+}
+
+// probe проверяет доступность сервиса name запросом к его health_path или,
+// если путь не задан, к адресу сервиса
+func (p *readinessProbe) probe(s *Server, name string) serviceCheck {
+	service := s.config.Services[name]
+	target, err := url.Parse(service.URL + service.HealthPath)
+	if err != nil {
+		return serviceCheck{Status: "error", Error: err.Error()}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	start := time.Now()
+	err = probeService(ctx, s.clients.For(target), target, service.HealthPath != "")
+	latency := float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		return serviceCheck{Status: "error", LatencyMS: latency, Error: err.Error()}
+	}
+	return serviceCheck{Status: "ok", LatencyMS: latency}
+}
+
+// handleLiveness отвечает, что процесс шлюза работает и обслуживает запросы
+func (s *Server) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+// handleReadiness отвечает 200, если проверяемые backend-сервисы доступны,
+// и 503, чтобы оркестратор не направлял запросы на шлюз, когда они недоступны
+func (s *Server) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	response := s.readiness.check(s)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if response.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	writeJSON(w, response)
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// clientIPKey - ключ контекста с IP-адресом клиента
+const clientIPKey contextKey = "clientIP"
+
+// ipResolver определяет IP-адрес клиента с учетом заголовков прокси.
+// Заголовки Forwarded и X-Forwarded-For учитываются, только если запрос
+// пришел от доверенного прокси из trusted_proxies.
+type ipResolver struct {
+	trusted []*net.IPNet
+}
+
+// newIPResolver проверяет список trusted_proxies: адреса IP или подсети CIDR
+func newIPResolver(proxies []string) (*ipResolver, error) {
+	resolver := &ipResolver{}
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("некорректный адрес доверенного прокси: %q", proxy)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			resolver.trusted = append(resolver.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("некорректная подсеть доверенных прокси: %q", proxy)
+		}
+		resolver.trusted = append(resolver.trusted, network)
+	}
+	return resolver, nil
+}
+
+// trusts проверяет, что адрес принадлежит доверенному прокси
+func (ir *ipResolver) trusts(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range ir.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve возвращает IP-адрес клиента. Цепочка адресов из Forwarded (а без
+// него - из X-Forwarded-For) просматривается справа налево, пока адреса
+// принадлежат доверенным прокси; первый недоверенный адрес считается адресом
+// клиента. Если в цепочке встречается не IP-адрес (например, скрытый
+// идентификатор RFC 7239), клиентом считается последний доверенный прокси.
+func (ir *ipResolver) resolve(r *http.Request) string {
+	ip := remoteIP(r)
+	if !ir.trusts(ip) {
+		return ip
+	}
+
+	hops := forwardedFor(r.Header)
+	if hops == nil {
+		hops = xForwardedFor(r.Header)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := hops[i]
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !ir.trusts(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// remoteIP возвращает IP-адрес соединения
+func remoteIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// xForwardedFor возвращает адреса из заголовков X-Forwarded-For по порядку
+func xForwardedFor(header http.Header) []string {
+	var hops []string
+	for _, value := range header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	return hops
+}
+
+// forwardedFor возвращает адреса параметров for из заголовков Forwarded
+// (RFC 7239) по порядку, без портов и скобок IPv6. Элемент без for дает
+// пустой адрес. Возвращает nil, если заголовка нет.
+func forwardedFor(header http.Header) []string {
+	var hops []string
+	for _, value := range header.Values("Forwarded") {
+		for _, element := range splitQuoted(value, ',') {
+			hop := ""
+			for _, pair := range splitQuoted(element, ';') {
+				name, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(name, "for") {
+					hop = forwardedNode(val)
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// forwardedNode извлекает адрес из значения параметра for: "192.0.2.1:8080",
+// "[2001:db8::1]:4711" или неизменный идентификатор вроде unknown
+func forwardedNode(value string) string {
+	value = strings.Trim(strings.TrimSpace(value), `"`)
+	if strings.HasPrefix(value, "[") {
+		if end := strings.IndexByte(value, ']'); end > 0 {
+			return value[1:end]
+		}
+		return value
+	}
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		return host
+	}
+	return value
+}
+
+// splitQuoted разбивает s по sep вне строк в кавычках
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// withClientIP сохраняет в контексте запроса IP-адрес клиента, если он еще не определен
+func (s *Server) withClientIP(r *http.Request) *http.Request {
+	if _, ok := r.Context().Value(clientIPKey).(string); ok {
+		return r
+	}
+	ctx := context.WithValue(r.Context(), clientIPKey, s.ipResolver.resolve(r))
+	return r.WithContext(ctx)
+}
+
+// realIPMiddleware определяет IP-адрес клиента до остальных обработчиков
+func (s *Server) realIPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, s.withClientIP(r))
+	})
+}
+
+// clientIP возвращает IP-адрес клиента, определенный realIPMiddleware, а для
+// запросов вне цепочки обработчиков - адрес соединения
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
@@ -0,0 +1,466 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"apigw/pkg/config"
+	"apigw/pkg/metrics"
+)
+
+// sharedState - состояние, общее для всех поколений сервера
+type sharedState struct {
+	// current - поколение, которое обслуживает новые запросы
+	current atomic.Pointer[Server]
+	// mu сериализует перезагрузки конфигурации
+	mu sync.Mutex
+	// metrics - метрики, которые накапливаются независимо от перезагрузок
+	metrics *metrics.Registry
+	// maintenance - режим обслуживания, включенный через административный API
+	maintenance atomic.Pointer[maintenanceMode]
+	// keysMu сериализует изменения файла ключей API через административный API
+	keysMu sync.Mutex
+}
+
+// newSharedState создает общее состояние поколений
+func newSharedState() *sharedState {
+	return &sharedState{metrics: metrics.NewRegistry()}
+}
+
+// generationState - фоновые задачи поколения (прогрев кэша, поисковый индекс,
+// контроль памяти), которые останавливаются при его замене
+type generationState struct {
+	ctx  context.Context
+	stop context.CancelFunc
+}
+
+// configure строит поколение для конфигурации cfg. prev - заменяемое поколение
+// (nil при запуске): от него наследуется состояние, которое не должно
+// сбрасываться при перезагрузке, если его настройки не изменились.
+func (s *Server) configure(cfg *config.Config, prev *Server) error {
+	s.config = cfg
+	s.mux = http.NewServeMux()
+	s.proxies = make(map[string]*proxyRoute)
+	s.keys = newCacheKeyBuilder(cfg.Cache)
+
+	ctx, stop := context.WithCancel(context.Background())
+	s.background = &generationState{ctx: ctx, stop: stop}
+
+	if err := checkCacheRoutes(cfg.Cache.Routes); err != nil {
+		return err
+	}
+	if _, err := newLogHandler(cfg.Log, io.Discard, new(slog.LevelVar)); err != nil {
+		return err
+	}
+	if _, err := newAccessLogger(cfg.Log); err != nil {
+		return err
+	}
+	if cfg.Streaming.MaxBuffer < 0 {
+		return fmt.Errorf("отрицательный размер буфера ответа streaming.max_buffer: %d", cfg.Streaming.MaxBuffer)
+	}
+	if !validCacheSerialization(cfg.Cache.Serialization) {
+		return fmt.Errorf("неизвестный формат записей кэша cache.serialization: %q", cfg.Cache.Serialization)
+	}
+	if cfg.Comments.View != "" && !validCommentsView(cfg.Comments.View) {
+		return fmt.Errorf("неизвестный вид списка комментариев comments.view: %q", cfg.Comments.View)
+	}
+
+	// Адаптивные лимиты накапливают сведения о задержке сервисов,
+	// поэтому сохраняются, пока настройки ограничения те же
+	if prev != nil && reflect.DeepEqual(prev.config.Concurrency, cfg.Concurrency) {
+		s.limits = prev.limits
+	} else {
+		s.limits = newUpstreamLimits(cfg.Concurrency)
+	}
+
+	if err := checkServices(cfg.Services); err != nil {
+		return err
+	}
+
+	// Пулы соединений и состояние выключателей сохраняются, пока не
+	// изменились сервисы и их настройки
+	sameServices := prev != nil && reflect.DeepEqual(prev.config.Services, cfg.Services) &&
+		reflect.DeepEqual(prev.config.Tenants, cfg.Tenants)
+	if sameServices && reflect.DeepEqual(prev.config.HTTPClient, cfg.HTTPClient) {
+		s.clients = prev.clients
+		s.mirrors = prev.mirrors
+	} else {
+		clients, err := newUpstreamClients(cfg)
+		if err != nil {
+			return err
+		}
+		s.clients = clients
+		mirrors, err := newUpstreamMirrors(cfg, s.shared.metrics)
+		if err != nil {
+			return err
+		}
+		s.mirrors = mirrors
+	}
+	if sameServices && reflect.DeepEqual(prev.config.CircuitBreaker, cfg.CircuitBreaker) {
+		s.breakers = prev.breakers
+	} else {
+		breakers, err := newUpstreamBreakers(cfg, s.shared.metrics)
+		if err != nil {
+			return err
+		}
+		s.breakers = breakers
+	}
+	// Лимиты сохраняются вместе с числом выполняющихся запросов
+	if sameServices && reflect.DeepEqual(prev.config.Bulkhead, cfg.Bulkhead) {
+		s.bulkheads = prev.bulkheads
+	} else {
+		bulkheads, err := newUpstreamBulkheads(cfg, s.shared.metrics)
+		if err != nil {
+			return err
+		}
+		s.bulkheads = bulkheads
+	}
+	// Состояние сервисов сохраняется, чтобы после перезагрузки запросы не
+	// отправлялись сервису, который уже признан недоступным
+	if sameServices && s.clients == prev.clients && reflect.DeepEqual(prev.config.HealthCheck, cfg.HealthCheck) {
+		s.checker = prev.checker
+	} else {
+		checker, err := newUpstreamHealthChecker(cfg, s.clients, s.shared.metrics)
+		if err != nil {
+			return err
+		}
+		s.checker = checker
+	}
+	if sameServices && reflect.DeepEqual(prev.config.Retries, cfg.Retries) {
+		s.retries = prev.retries
+	} else {
+		retries, err := newUpstreamRetries(cfg, s.shared.metrics)
+		if err != nil {
+			return err
+		}
+		s.retries = retries
+	}
+
+	var err error
+	s.requestIDs, err = newRequestIDPropagation(cfg)
+	if err != nil {
+		return err
+	}
+
+	s.claimHeaders, err = newClaimHeaders(cfg.JWT)
+	if err != nil {
+		return err
+	}
+
+	s.faults, err = newFaultInjector(cfg)
+	if err != nil {
+		return err
+	}
+
+	var prevTenants *tenantRegistry
+	if prev != nil {
+		prevTenants = prev.tenants
+	}
+	s.tenants, err = newTenantRegistry(cfg, s.store, prevTenants)
+	if err != nil {
+		return err
+	}
+
+	s.qos, err = newQoSPolicy(cfg)
+	if err != nil {
+		return err
+	}
+
+	s.shedder, err = newLoadShedder(cfg.Shedding, s.qos)
+	if err != nil {
+		return err
+	}
+
+	s.compressor, err = newCompressor(cfg.Compression)
+	if err != nil {
+		return err
+	}
+
+	s.dates, err = newDateLocalizer(cfg.Dates)
+	if err != nil {
+		return err
+	}
+
+	s.methodOverride, err = newMethodOverrider(cfg.MethodOverride)
+	if err != nil {
+		return err
+	}
+
+	if err := checkAdmin(cfg.Admin); err != nil {
+		return err
+	}
+
+	s.cors, err = newCORSPolicies(cfg.CORS)
+	if err != nil {
+		return err
+	}
+
+	s.timeouts, err = newRequestTimeouts(cfg.Timeouts)
+	if err != nil {
+		return err
+	}
+	s.serviceTimeouts, err = newServiceTimeouts(cfg)
+	if err != nil {
+		return err
+	}
+	s.canaries, err = newCanaryRules(cfg.Canary, cfg.Services)
+	if err != nil {
+		return err
+	}
+	// Запросы, которые уже ждут ответа, завершаются в прежнем поколении
+	s.coalescer, err = newRequestCoalescer(cfg.Coalescing, s.shared.metrics)
+	if err != nil {
+		return err
+	}
+	s.moderator, err = newCommentModerator(cfg, s.shared.metrics)
+	if err != nil {
+		return err
+	}
+	// Поток сохраняется, чтобы после перезагрузки клиенты оставались
+	// подключенными, а уже известные новости не отправлялись повторно
+	if sameServices && prev.newsStream != nil && reflect.DeepEqual(prev.config.NewsStream, cfg.NewsStream) {
+		s.newsStream = prev.newsStream
+	} else {
+		s.newsStream, err = newNewsStream(cfg.NewsStream)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.versions, err = newVersionPolicies(cfg.Versions)
+	if err != nil {
+		return err
+	}
+
+	s.rewrites, err = newRewriteRules(cfg.Rewrites)
+	if err != nil {
+		return err
+	}
+
+	s.transforms, err = newBodyTransforms(cfg.Transforms)
+	if err != nil {
+		return err
+	}
+
+	s.deprecations, err = newDeprecationRules(cfg.Deprecations)
+	if err != nil {
+		return err
+	}
+
+	s.auth, err = newAPIKeyAuth(cfg.Auth, s.shared.metrics)
+	if err != nil {
+		return err
+	}
+
+	s.jwt, err = newJWTAuth(cfg.JWT, s.shared.metrics)
+	if err != nil {
+		return err
+	}
+
+	s.policies, err = newAccessPolicies(cfg.Policies, s.auth, s.jwt, s.shared.metrics)
+	if err != nil {
+		return err
+	}
+	if s.jwt != nil && len(s.jwt.rules) == 0 && !s.policies.uses(policyAuthJWT) {
+		return fmt.Errorf("в секции jwt не указаны маршруты и нет политик доступа с auth: jwt")
+	}
+
+	s.ipResolver, err = newIPResolver(cfg.TrustedProxies)
+	if err != nil {
+		return err
+	}
+
+	s.readiness, err = newReadinessProbe(cfg.Health, cfg.Services)
+	if err != nil {
+		return err
+	}
+
+	if cfg.RateLimit.Enabled && cfg.RateLimit.Key == rateKeyAPIKey && s.auth == nil {
+		return fmt.Errorf("лимиты по ключу API (rate_limit.key) требуют включенной секции auth")
+	}
+	// Корзины клиентов сохраняются, пока не изменились лимиты
+	if prev != nil && reflect.DeepEqual(prev.config.RateLimit, cfg.RateLimit) {
+		s.rateLimiter = prev.rateLimiter
+	} else if s.rateLimiter, err = newClientRateLimiter(cfg.RateLimit); err != nil {
+		return err
+	}
+
+	s.graphQLSchema, err = s.newGraphQLSchema()
+	if err != nil {
+		return fmt.Errorf("не удалось построить GraphQL схему: %w", err)
+	}
+
+	if err := s.setupRoutes(); err != nil {
+		return err
+	}
+	// Схемы проверки берутся из описания API, которое зависит от маршрутов
+	s.validator, err = newRequestValidator(cfg.Validation, s.openAPIDocument(), s.shared.metrics)
+	if err != nil {
+		return err
+	}
+	s.handler = s.realIPMiddleware(s.corsMiddleware(s.maintenanceMiddleware(s.rewriteMiddleware(s.methodOverrideMiddleware(s.deprecationMiddleware(s.rateLimitMiddleware(s.authMiddleware(s.jwtMiddleware(s.policyMiddleware(s.apiVersionMiddleware(s.validationMiddleware(s.qosMiddleware(s.sheddingMiddleware(s.tenantMiddleware(s.canaryMiddleware(s.compressionMiddleware(s.timeoutMiddleware(s.mux))))))))))))))))))
+
+	// До построения собственного индекса поиск идет по индексу предыдущего поколения
+	if prev != nil && cfg.Search.Index {
+		if index := prev.newsIndex.Load(); index != nil {
+			s.newsIndex.Store(index)
+		}
+	}
+	return nil
+}
+
+// Validate проверяет конфигурацию так же, как при запуске сервера, но без
+// подключения к хранилищу кэша и брокеру событий и без запуска фоновых задач
+func Validate(cfg *config.Config) error {
+	if _, err := newCacheStore(cfg.Cache); err != nil {
+		return fmt.Errorf("не удалось создать хранилище кэша: %w", err)
+	}
+	if err := checkEventsConfig(cfg.Events); err != nil {
+		return fmt.Errorf("не удалось настроить публикацию событий: %w", err)
+	}
+	if err := checkCommentOutboxConfig(cfg.CommentOutbox); err != nil {
+		return fmt.Errorf("не удалось настроить очередь комментариев: %w", err)
+	}
+	if _, err := newTLSConfig(cfg.Server.TLS); err != nil {
+		return err
+	}
+
+	srv, err := newDetachedGeneration(cfg)
+	if err != nil {
+		return err
+	}
+	srv.background.stop()
+	return nil
+}
+
+// newDetachedGeneration строит поколение без общих ресурсов (хранилища кэша,
+// публикации событий) для проверки конфигурации и разбора маршрутов.
+// Фоновые задачи поколения не запускаются, вызывающий должен остановить их контекст.
+func newDetachedGeneration(cfg *config.Config) (*Server, error) {
+	srv := &Server{shared: newSharedState()}
+	if err := srv.configure(cfg, nil); err != nil {
+		srv.background.stop()
+		return nil, err
+	}
+	return srv, nil
+}
+
+// current возвращает поколение, которое обслуживает новые запросы
+func (s *Server) current() *Server {
+	return s.shared.current.Load()
+}
+
+// serveCurrent передает запрос текущему поколению. Поколение выбирается
+// один раз на запрос, поэтому запрос целиком видит одну конфигурацию.
+func (s *Server) serveCurrent(w http.ResponseWriter, r *http.Request) {
+	s.current().handler.ServeHTTP(w, r)
+}
+
+// startBackground запускает фоновые задачи поколения
+func (s *Server) startBackground() {
+	ctx := s.background.ctx
+
+	// Прогреваем кэш в фоне, чтобы не задерживать запуск сервера
+	if s.cacheEnabled() && len(s.config.Cache.Warmup.Paths) > 0 {
+		go s.warmCache(ctx)
+	}
+
+	// Поисковый индекс строится в фоне, до его готовности поиск идет через сервис
+	if s.config.Search.Index {
+		go s.runSearchIndex(ctx)
+	}
+
+	// Доступность backend-сервисов проверяется в фоне
+	if s.checker != nil {
+		s.checker.run(ctx)
+	}
+
+	// Потребление памяти проверяется в фоне для сброса нагрузки
+	if s.shedder != nil {
+		go s.shedder.run(ctx)
+	}
+
+	// Сервис новостей опрашивается в фоне для потока новых новостей
+	if s.newsStream != nil {
+		go s.newsStream.run(ctx, s.newsService(ctx))
+	}
+}
+
+// Reload применяет новую конфигурацию без остановки сервера. Новое поколение
+// строится целиком до замены, поэтому при ошибке продолжает работать текущее.
+// Настройки, требующие перезапуска (порты, хранилище кэша, публикация
+// событий), изменять нельзя.
+func (s *Server) Reload(cfg *config.Config) error {
+	s.shared.mu.Lock()
+	defer s.shared.mu.Unlock()
+
+	prev := s.current()
+	if err := checkReloadable(prev.config, cfg); err != nil {
+		return err
+	}
+
+	next := &Server{
+		store:  prev.store,
+		tags:   prev.tags,
+		events: prev.events,
+		health: prev.health,
+		outbox: prev.outbox,
+		shared: prev.shared,
+	}
+	if err := next.configure(cfg, prev); err != nil {
+		next.background.stop()
+		return err
+	}
+
+	s.shared.current.Store(next)
+	prev.background.stop()
+	// Секция log уже проверена при построении поколения, но файл журнала
+	// запросов может не открыться; тогда журнал пишется в прежний файл
+	if err := SetupLogging(cfg.Log); err != nil {
+		slog.Error("Не удалось настроить журнал", "error", err)
+	}
+	if next.clients != prev.clients {
+		prev.clients.CloseIdleConnections()
+		prev.mirrors.closeIdleConnections()
+	}
+	if prev.newsStream != nil && next.newsStream != prev.newsStream {
+		prev.newsStream.close()
+	}
+	if next.rateLimiter != prev.rateLimiter {
+		prev.rateLimiter.close()
+	}
+	next.startBackground()
+
+	slog.Info("Конфигурация перезагружена")
+	return nil
+}
+
+// checkReloadable проверяет, что изменены только настройки, которые можно применить на лету
+func checkReloadable(old, next *config.Config) error {
+	fixed := []struct {
+		name      string
+		old, next interface{}
+	}{
+		{"server", old.Server, next.Server},
+		{"cache.driver", old.Cache.Driver, next.Cache.Driver},
+		{"cache.max_entries", old.Cache.MaxEntries, next.Cache.MaxEntries},
+		{"cache.memcached", old.Cache.Memcached, next.Cache.Memcached},
+		{"cache.redis", old.Cache.Redis, next.Cache.Redis},
+		{"cache.local", old.Cache.Local, next.Cache.Local},
+		{"events", old.Events, next.Events},
+		{"comment_outbox", old.CommentOutbox, next.CommentOutbox},
+		{"admin.port", old.Admin.Port, next.Admin.Port},
+	}
+	for _, f := range fixed {
+		if !reflect.DeepEqual(f.old, f.next) {
+			return fmt.Errorf("изменение секции %s требует перезапуска", f.name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package server_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"apigw/pkg/config"
+	"apigw/pkg/testbackends"
+)
+
+// reloadTargets - запросы клиентов во время перезагрузки конфигурации
+var reloadTargets = []string{
+	"/api/news?page=1",
+	"/api/news?page=2&count=5&s=Новость",
+	"/api/fullnews?page=3&count=5",
+	"/api/news/7",
+	"/api/news?comm=7",
+	"/api/comments?id=7",
+}
+
+// reloadConfig создает конфигурацию шлюза g. cacheTTL 0 отключает кэш
+// ответов; withRoute добавляет проксируемый маршрут /ext/.
+func reloadConfig(g *testbackends.Gateway, cacheTTL time.Duration, withRoute bool) *config.Config {
+	cfg := config.NewConfig()
+	cfg.Log.Level = "error"
+	cfg.Cache.TTL = config.Duration(cacheTTL)
+	cfg.Services = config.ServicesConfig{
+		config.ServiceNews:     {URL: g.News.URL},
+		config.ServiceComments: {URL: g.Comments.URL},
+	}
+	if withRoute {
+		cfg.Routes = []config.RouteConfig{{Path: "/ext/", Upstream: g.News.URL, StripPrefix: true}}
+	}
+	return cfg
+}
+
+// newReloadGateway запускает шлюз поверх фейковых сервисов
+func newReloadGateway(t *testing.T) *testbackends.Gateway {
+	t.Helper()
+	cfg := config.NewConfig()
+	cfg.Log.Level = "error"
+	g, err := testbackends.NewGateway(cfg, testbackends.GenerateNews(50), benchComments(50, 3))
+	if err != nil {
+		t.Fatalf("не удалось запустить шлюз: %v", err)
+	}
+	t.Cleanup(g.Close)
+	return g
+}
+
+// get выполняет GET запрос к шлюзу и возвращает статус и заголовки ответа
+func get(t *testing.T, g *testbackends.Gateway, target string) (int, http.Header) {
+	t.Helper()
+	resp, err := g.Client().Get(g.URL + target)
+	if err != nil {
+		t.Fatalf("GET %s: %v", target, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, resp.Header
+}
+
+// TestReloadUnderLoad перезагружает конфигурацию, пока клиенты отправляют
+// запросы. Ни один запрос не должен завершиться ошибкой; гонки данных между
+// поколениями сервера находит go test -race.
+func TestReloadUnderLoad(t *testing.T) {
+	g := newReloadGateway(t)
+
+	const clients = 8
+	var (
+		stop     atomic.Bool
+		requests atomic.Int64
+		failures = make(chan string, clients)
+		wg       sync.WaitGroup
+	)
+	for c := 0; c < clients; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			client := g.Client()
+			for i := c; !stop.Load(); i++ {
+				var (
+					resp *http.Response
+					err  error
+				)
+				target := reloadTargets[i%len(reloadTargets)]
+				if i%10 == 0 {
+					target = "/api/comments/add?news_id=7"
+					resp, err = client.Post(g.URL+target, "application/json", strings.NewReader(`{"text":"Комментарий во время перезагрузки"}`))
+				} else {
+					resp, err = client.Get(g.URL + target)
+				}
+				if err != nil {
+					failures <- fmt.Sprintf("%s: %v", target, err)
+					return
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+					failures <- fmt.Sprintf("%s: статус %d", target, resp.StatusCode)
+					return
+				}
+				requests.Add(1)
+			}
+		}(c)
+	}
+
+	for i := 0; i < 50; i++ {
+		cfg := reloadConfig(g, 0, false)
+		if i%2 == 1 {
+			cfg = reloadConfig(g, time.Minute, true)
+		}
+		if err := g.Gateway.Reload(cfg); err != nil {
+			t.Errorf("перезагрузка %d: %v", i, err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	stop.Store(true)
+	wg.Wait()
+	close(failures)
+
+	for failure := range failures {
+		t.Error(failure)
+	}
+	if requests.Load() == 0 {
+		t.Fatal("клиенты не выполнили ни одного запроса")
+	}
+}
+
+// TestReloadAppliesConfig проверяет, что новые запросы обслуживает новое поколение
+func TestReloadAppliesConfig(t *testing.T) {
+	g := newReloadGateway(t)
+
+	if status, _ := get(t, g, "/ext/api/news/1"); status != http.StatusNotFound {
+		t.Fatalf("маршрут /ext/ до перезагрузки: статус %d, ожидался 404", status)
+	}
+
+	if err := g.Gateway.Reload(reloadConfig(g, time.Minute, true)); err != nil {
+		t.Fatalf("перезагрузка: %v", err)
+	}
+	if status, _ := get(t, g, "/ext/api/news/1"); status != http.StatusOK {
+		t.Errorf("маршрут /ext/ после перезагрузки: статус %d, ожидался 200", status)
+	}
+	get(t, g, "/api/news/3")
+	if _, header := get(t, g, "/api/news/3"); header.Get("X-Cache") != "HIT" {
+		t.Errorf("X-Cache после включения кэша: %q, ожидался HIT", header.Get("X-Cache"))
+	}
+}
+
+// TestReloadKeepsGenerationOnError проверяет, что при ошибке продолжает
+// работать текущая конфигурация
+func TestReloadKeepsGenerationOnError(t *testing.T) {
+	g := newReloadGateway(t)
+	if err := g.Gateway.Reload(reloadConfig(g, 0, true)); err != nil {
+		t.Fatalf("перезагрузка: %v", err)
+	}
+
+	invalid := reloadConfig(g, 0, false)
+	invalid.Routes = []config.RouteConfig{{Path: "ext", Upstream: g.News.URL}}
+	if err := g.Gateway.Reload(invalid); err == nil {
+		t.Error("перезагрузка с некорректным маршрутом завершилась без ошибки")
+	}
+
+	restart := reloadConfig(g, 0, false)
+	restart.Server.Port++
+	if err := g.Gateway.Reload(restart); err == nil {
+		t.Error("изменение секции server применено без перезапуска")
+	}
+
+	if status, _ := get(t, g, "/ext/api/news/1"); status != http.StatusOK {
+		t.Errorf("маршрут /ext/ после неудачной перезагрузки: статус %d, ожидался 200", status)
+	}
+	if status, _ := get(t, g, "/api/news/1"); status != http.StatusOK {
+		t.Errorf("/api/news/1 после неудачной перезагрузки: статус %d, ожидался 200", status)
+	}
+}
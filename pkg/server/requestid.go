@@ -0,0 +1,87 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"apigw/pkg/config"
+)
+
+// requestIDHeaderName - заголовок, в котором клиенты и backend-сервисы передают request_id
+const requestIDHeaderName = "X-Request-ID"
+
+// maxRequestIDLength - максимальная длина request_id, принимаемого от клиента
+const maxRequestIDLength = 128
+
+// validRequestID проверяет request_id клиента: непустая строка из видимых
+// ASCII символов, чтобы его можно было передать в заголовке и записать в журнал
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// incomingRequestID возвращает request_id из заголовка X-Request-ID или, для
+// совместимости, из параметра request_id. Некорректные значения игнорируются.
+func incomingRequestID(r *http.Request) string {
+	if id := r.Header.Get(requestIDHeaderName); validRequestID(id) {
+		return id
+	}
+	if id := r.URL.Query().Get("request_id"); validRequestID(id) {
+		return id
+	}
+	return ""
+}
+
+// requestIDPropagation - способ передачи request_id backend-сервисам по
+// адресу сервиса. Сервисы без настройки получают request_id в параметрах.
+type requestIDPropagation map[string]string
+
+// newRequestIDPropagation проверяет поле request_id сервисов
+func newRequestIDPropagation(cfg *config.Config) (requestIDPropagation, error) {
+	settings, err := upstreamSettings(cfg, "передачи request_id", func(service config.ServiceConfig) string {
+		if service.RequestID == "" {
+			return config.RequestIDQuery
+		}
+		return service.RequestID
+	})
+	if err != nil {
+		return nil, err
+	}
+	for upstream, mode := range settings {
+		switch mode {
+		case config.RequestIDQuery, config.RequestIDHeader, config.RequestIDBoth:
+		default:
+			return nil, fmt.Errorf("неизвестный способ передачи request_id сервису %s: %q", upstream, mode)
+		}
+	}
+	return settings, nil
+}
+
+// apply добавляет request_id запроса клиента к запросу req к backend-сервису
+// в параметрах и/или заголовке X-Request-ID согласно настройке сервиса
+func (p requestIDPropagation) apply(req *http.Request) {
+	requestID, ok := req.Context().Value(requestIDKey).(string)
+	if !ok || requestID == "" {
+		return
+	}
+
+	mode := p[upstreamName(req.URL)]
+	if mode == "" {
+		mode = config.RequestIDQuery
+	}
+	if mode == config.RequestIDQuery || mode == config.RequestIDBoth {
+		q := req.URL.Query()
+		q.Set("request_id", requestID)
+		req.URL.RawQuery = q.Encode()
+	}
+	if mode == config.RequestIDHeader || mode == config.RequestIDBoth {
+		req.Header.Set(requestIDHeaderName, requestID)
+	}
+}
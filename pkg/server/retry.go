@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"apigw/pkg/config"
+	"apigw/pkg/limit"
+	"apigw/pkg/metrics"
+)
+
+// maxRetryDrainSize - сколько байт тела ответа дочитывается перед повтором,
+// чтобы соединение вернулось в пул
+const maxRetryDrainSize = 64 << 10
+
+// retryPolicy - правила повторов запросов к одному backend-сервису
+type retryPolicy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	multiplier     float64
+	statuses       map[int]bool
+	budget         *limit.Budget
+}
+
+// upstreamRetries - повторы идемпотентных запросов по адресу сервиса.
+// nil означает, что повторы отключены.
+type upstreamRetries struct {
+	policies  map[string]*retryPolicy
+	retries   *metrics.CounterVec
+	exhausted *metrics.CounterVec
+}
+
+// newUpstreamRetries создает правила повторов для сервисов, у которых они включены
+func newUpstreamRetries(cfg *config.Config, registry *metrics.Registry) (*upstreamRetries, error) {
+	settings, err := upstreamSettings(cfg, "повторов", func(service config.ServiceConfig) config.RetryConfig {
+		return service.Retries.WithDefaults(cfg.Retries)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r := &upstreamRetries{
+		policies: make(map[string]*retryPolicy),
+		retries: registry.Counter("apigw_upstream_retries_total",
+			"Повторы запросов к backend-сервисам", "upstream"),
+		exhausted: registry.Counter("apigw_upstream_retry_budget_exhausted_total",
+			"Повторы, не выполненные из-за исчерпания бюджета", "upstream"),
+	}
+	for upstream, rc := range settings {
+		if !rc.Enabled {
+			continue
+		}
+		if rc.MaxAttempts < 1 {
+			return nil, fmt.Errorf("число попыток запроса к %s должно быть не меньше 1", upstream)
+		}
+		if rc.InitialBackoff <= 0 || rc.MaxBackoff < rc.InitialBackoff || rc.BackoffMultiplier < 1 {
+			return nil, fmt.Errorf("некорректные паузы между повторами запросов к %s", upstream)
+		}
+		if rc.BudgetRatio < 0 || rc.MinRetriesPerSecond < 0 {
+			return nil, fmt.Errorf("бюджет повторов запросов к %s не может быть отрицательным", upstream)
+		}
+
+		policy := &retryPolicy{
+			maxAttempts:    rc.MaxAttempts,
+			initialBackoff: rc.InitialBackoff.Std(),
+			maxBackoff:     rc.MaxBackoff.Std(),
+			multiplier:     rc.BackoffMultiplier,
+			statuses:       make(map[int]bool),
+			budget:         limit.NewBudget(rc.BudgetRatio, rc.MinRetriesPerSecond),
+		}
+		for _, status := range rc.RetryableStatuses {
+			policy.statuses[status] = true
+		}
+		r.policies[upstream] = policy
+	}
+	if len(r.policies) == 0 {
+		return nil, nil
+	}
+	return r, nil
+}
+
+// policy возвращает правила повторов для запроса или nil, если запрос не
+// повторяется. Повторяются только GET и HEAD запросы без тела.
+func (r *upstreamRetries) policy(req *http.Request) *retryPolicy {
+	if r == nil || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+		return nil
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		return nil
+	}
+	return r.policies[upstreamName(req.URL)]
+}
+
+// do выполняет запрос функцией send, повторяя его при сетевых ошибках и
+// статусах из retryable_statuses, пока не исчерпаны попытки и бюджет повторов
+func (r *upstreamRetries) do(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	policy := r.policy(req)
+	if policy == nil {
+		return send(req)
+	}
+	policy.budget.Deposit()
+	upstream := upstreamName(req.URL)
+
+	for attempt := 1; ; attempt++ {
+		resp, err := send(req)
+		reason, retryable := policy.retryable(req, resp, err)
+		if !retryable || attempt >= policy.maxAttempts {
+			return resp, err
+		}
+		if !policy.budget.Withdraw() {
+			r.exhausted.Inc(upstream)
+			slog.WarnContext(req.Context(), "Бюджет повторов запросов исчерпан", "backend", upstream)
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxRetryDrainSize))
+			resp.Body.Close()
+		}
+		delay := policy.backoff(attempt)
+		slog.InfoContext(req.Context(), "Повтор запроса к backend-сервису", "backend", upstream, "url", req.URL.Redacted(), "attempt", attempt, "delay", delay, "reason", reason)
+		r.retries.Inc(upstream)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable проверяет, нужно ли повторить запрос, и возвращает причину повтора.
+// Отказы выключателя, лимита и активной проверки не повторяются, чтобы не
+// нагружать сервис.
+func (p *retryPolicy) retryable(req *http.Request, resp *http.Response, err error) (string, bool) {
+	if req.Context().Err() != nil {
+		return "", false
+	}
+	var unhealthy *upstreamUnhealthyError
+	switch {
+	case errors.Is(err, errCircuitOpen), errors.Is(err, errUpstreamOverloaded), errors.Is(err, context.Canceled), errors.As(err, &unhealthy):
+		return "", false
+	case err != nil:
+		return err.Error(), true
+	case p.statuses[resp.StatusCode]:
+		return "статус " + strconv.Itoa(resp.StatusCode), true
+	}
+	return "", false
+}
+
+// backoff возвращает паузу перед повтором attempt: пауза растет
+// экспоненциально, а случайная половина разводит повторы разных клиентов
+func (p *retryPolicy) backoff(attempt int) time.Duration {
+	d := float64(p.initialBackoff) * math.Pow(p.multiplier, float64(attempt-1))
+	d = math.Min(d, float64(p.maxBackoff))
+	return time.Duration(d/2 + rand.Float64()*d/2)
+}
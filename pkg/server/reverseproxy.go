@@ -0,0 +1,222 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"time"
+
+	"apigw/pkg/httpclient"
+)
+
+// upstreamTransport - http.RoundTripper для запросов к backend-сервисам:
+// запросы отправляются с учетом повторов, выключателей, лимита одновременных
+// запросов, отслеживания доступности сервисов и внесения сбоев
+type upstreamTransport struct {
+	clients   *httpclient.Pool
+	retries   *upstreamRetries
+	breakers  *upstreamBreakers
+	bulkheads *upstreamBulkheads
+	limits    *upstreamLimits
+	health    *upstreamHealth
+	checker   *upstreamHealthChecker
+	faults    *faultInjector
+	// timeouts - таймауты запросов по адресу сервиса
+	timeouts  map[string]time.Duration
+	mirrors   *upstreamMirrors
+	coalescer *requestCoalescer
+}
+
+// RoundTrip отправляет запрос backend-сервису. Одновременные одинаковые
+// запросы GET объединяются согласно секции coalescing. Идемпотентные
+// запросы повторяются согласно секции retries, а их копии отправляются на
+// теневой сервис, если он задан.
+func (t *upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.coalescer.do(req, func(req *http.Request) (*http.Response, error) {
+		t.mirrors.mirror(req)
+		return t.retries.do(req, t.send)
+	})
+}
+
+// responseError - ответ с ошибкой, который отправляется клиенту вместо ответа
+// backend-сервиса, если хук обработки ответа отклонил его
+type responseError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *responseError) Error() string {
+	return e.message
+}
+
+// proxyHooks - хуки потокового обратного прокси
+type proxyHooks struct {
+	// rewrite формирует запрос к backend-сервису из запроса клиента
+	rewrite func(*httputil.ProxyRequest)
+	// modifyResponse проверяет и преобразует ответ сервиса до передачи клиенту.
+	// Ошибка *responseError отправляется клиенту, остальные передаются onError.
+	modifyResponse func(*http.Response) error
+	// onError отправляет ответ клиенту, если сервис недоступен
+	onError func(http.ResponseWriter, *http.Request, error)
+}
+
+// newReverseProxy создает обратный прокси на базе httputil.ReverseProxy.
+// Тело ответа сервиса передается клиенту по мере чтения, без буферизации
+// всего ответа в памяти; потоки событий отправляются клиенту сразу.
+func newReverseProxy(transport http.RoundTripper, hooks proxyHooks) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			// http.Client отклоняет запросы с заполненным RequestURI
+			pr.Out.RequestURI = ""
+			hooks.rewrite(pr)
+		},
+		Transport:      transport,
+		ModifyResponse: hooks.modifyResponse,
+		BufferPool:     copyBuffers,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			var respErr *responseError
+			if errors.As(err, &respErr) {
+				writeError(w, r, respErr.status, respErr.code, respErr.message)
+				return
+			}
+			hooks.onError(w, r, err)
+		},
+	}
+}
+
+// upstreamCall - запрос обработчика шлюза к backend-сервису
+type upstreamCall struct {
+	// method - метод запроса к сервису, по умолчанию GET
+	method string
+	// target - адрес запроса к сервису
+	target string
+	// body - JSON тело запроса, nil - запрос без тела. Перед отправкой к нему
+	// применяется преобразование из секции transforms.
+	body []byte
+	// response проверяет ответ сервиса и при необходимости заменяет его тело
+	// результатом агрегации (см. replaceBody). Вызывается при любом статусе ответа.
+	response func(*http.Response) error
+	// message - текст ошибки для клиента, если сервис недоступен
+	message string
+	// unavailable вызывается, если сервис недоступен или ответил 502, 503
+	// или 504, с телом запроса после преобразования. Возвращает ответ 202
+	// клиенту или false, если клиенту отправляется ошибка.
+	unavailable func(body []byte, contentType string) (interface{}, bool)
+}
+
+// proxyUpstream выполняет запрос обработчика к backend-сервису через
+// обратный прокси и передает ответ клиенту потоком. Обработчики шлюза не
+// передают сервисам заголовки клиента, а клиенту - заголовки сервиса:
+// ответ всегда JSON.
+func (s *Server) proxyUpstream(w http.ResponseWriter, r *http.Request, call upstreamCall) {
+	target, err := url.Parse(call.target)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Некорректный адрес backend-сервиса", "url", call.target, "error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, "Внутренняя ошибка сервера")
+		return
+	}
+	method := call.method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	body, contentType := call.body, "application/json"
+	if body != nil {
+		// Тело приводится к схеме сервиса согласно секции transforms
+		if body, contentType, err = s.transformUpstreamBody(r, body); err != nil {
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Внутренняя ошибка сервера")
+			return
+		}
+	}
+
+	proxy := newReverseProxy(s.transport(), proxyHooks{
+		rewrite: func(pr *httputil.ProxyRequest) {
+			out := pr.Out
+			out.Method = method
+			u := *target
+			out.URL = &u
+			out.Host = ""
+			out.Header = make(http.Header)
+			s.requestIDs.apply(out)
+			s.claimHeaders.apply(out)
+			out.Body, out.GetBody, out.ContentLength = nil, nil, 0
+			if body != nil {
+				out.Header.Set("Content-Type", contentType)
+				out.Body = io.NopCloser(bytes.NewReader(body))
+				out.ContentLength = int64(len(body))
+			}
+		},
+		modifyResponse: func(resp *http.Response) error {
+			status, deferred := resp.StatusCode, false
+			if call.unavailable != nil && unavailableStatus(resp.StatusCode) {
+				if accepted, ok := call.unavailable(body, contentType); ok {
+					if err := replaceJSON(resp, http.StatusAccepted, accepted); err != nil {
+						return err
+					}
+					deferred = true
+				}
+			}
+			if call.response != nil && !deferred {
+				if err := call.response(resp); err != nil {
+					return err
+				}
+			}
+			// Отложенный запрос инвалидирует кэш при доставке из очереди
+			if method != http.MethodGet && method != http.MethodHead && !deferred && status >= 200 && status < 300 {
+				markUpstreamWrite(r.Context())
+			}
+
+			resp.Header = http.Header{"Content-Type": {"application/json"}}
+			if resp.ContentLength >= 0 {
+				resp.Header.Set("Content-Length", strconv.FormatInt(resp.ContentLength, 10))
+			}
+			return nil
+		},
+		onError: func(w http.ResponseWriter, r *http.Request, err error) {
+			slog.ErrorContext(r.Context(), call.message, "backend", upstreamName(target), "error", err)
+			// Запрос, прерванный клиентом, не откладывается: клиент не узнает о нем
+			if call.unavailable != nil && r.Context().Err() == nil {
+				if accepted, ok := call.unavailable(body, contentType); ok {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusAccepted)
+					writeJSON(w, accepted)
+					return
+				}
+			}
+			writeBackendError(w, r, err, call.message)
+		},
+	})
+	proxy.FlushInterval = s.config.Streaming.FlushInterval.Std()
+	proxy.ServeHTTP(w, r)
+}
+
+// unavailableStatus сообщает, что статус ответа означает временную
+// недоступность сервиса
+func unavailableStatus(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+// replaceBody заменяет тело ответа сервиса, например результатом агрегации
+func replaceBody(resp *http.Response, body []byte) {
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+}
+
+// replaceJSON заменяет тело ответа сервиса значением v в формате JSON
+func replaceJSON(resp *http.Response, status int, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	resp.StatusCode = status
+	replaceBody(resp, append(data, '\n'))
+	return nil
+}
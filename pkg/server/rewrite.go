@@ -0,0 +1,101 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"apigw/pkg/config"
+)
+
+// rewriteRule - правило перенаправления или внутренней перезаписи пути
+type rewriteRule struct {
+	match  *regexp.Regexp
+	target string
+	// status - статус перенаправления, 0 - внутренняя перезапись
+	status int
+}
+
+// newRewriteRules проверяет секцию rewrites
+func newRewriteRules(cfg []config.RewriteConfig) ([]*rewriteRule, error) {
+	var rules []*rewriteRule
+	for _, rc := range cfg {
+		re, err := regexp.Compile(rc.Match)
+		if err != nil {
+			return nil, fmt.Errorf("некорректное выражение правила перезаписи %q: %w", rc.Match, err)
+		}
+		if rc.Target == "" {
+			return nil, fmt.Errorf("не указан target правила перезаписи %q", rc.Match)
+		}
+
+		switch rc.Status {
+		case 0:
+			if !strings.HasPrefix(rc.Target, "/") {
+				return nil, fmt.Errorf("путь внутренней перезаписи должен начинаться с /: %q", rc.Target)
+			}
+		case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		default:
+			return nil, fmt.Errorf("неподдерживаемый статус перенаправления правила %q: %d", rc.Match, rc.Status)
+		}
+		rules = append(rules, &rewriteRule{match: re, target: rc.Target, status: rc.Status})
+	}
+	return rules, nil
+}
+
+// apply подставляет группы выражения в target. Параметры исходного запроса
+// добавляются к параметрам из target. Возвращает false, если путь не подходит.
+func (rule *rewriteRule) apply(u *url.URL) (*url.URL, bool) {
+	match := rule.match.FindStringSubmatchIndex(u.Path)
+	if match == nil {
+		return nil, false
+	}
+	expanded := string(rule.match.ExpandString(nil, rule.target, u.Path, match))
+
+	target, err := url.Parse(expanded)
+	if err != nil {
+		slog.Warn("Некорректный адрес после перезаписи", "path", u.Path, "error", err)
+		return nil, false
+	}
+	if u.RawQuery != "" {
+		if target.RawQuery != "" {
+			target.RawQuery += "&" + u.RawQuery
+		} else {
+			target.RawQuery = u.RawQuery
+		}
+	}
+	return target, true
+}
+
+// rewriteMiddleware применяет правила перенаправления и перезаписи до выбора
+// маршрута, чтобы старые публичные адреса продолжали работать
+func (s *Server) rewriteMiddleware(next http.Handler) http.Handler {
+	if len(s.rewrites) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, rule := range s.rewrites {
+			target, ok := rule.apply(r.URL)
+			if !ok {
+				continue
+			}
+
+			if rule.status != 0 {
+				http.Redirect(w, r, target.String(), rule.status)
+				return
+			}
+
+			slog.DebugContext(r.Context(), "Путь перезаписан", "path", r.URL.Path, "target", target.RequestURI())
+			inner := r.Clone(r.Context())
+			inner.URL.Path = target.Path
+			inner.URL.RawPath = ""
+			inner.URL.RawQuery = target.RawQuery
+			next.ServeHTTP(w, inner)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
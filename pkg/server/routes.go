@@ -0,0 +1,228 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"apigw/pkg/config"
+)
+
+// routeInfo - описание встроенного маршрута для apigw routes match
+type routeInfo struct {
+	// Handler - обработчик маршрута
+	Handler string
+	// Middleware - цепочка middleware маршрута от внешнего к внутреннему
+	Middleware []string
+	// Backends - сервисы, к которым обращается обработчик: "news", "comments"
+	Backends []string
+}
+
+// builtinRoutes - маршруты, которые шлюз обрабатывает сам. Маршруты из
+// конфигурации не могут занимать эти пути. Цепочки middleware из таблицы
+// собирает builtinHandler, поэтому apigw routes match показывает ту же
+// цепочку, через которую проходят запросы.
+var builtinRoutes = map[string]routeInfo{
+	"/api/news":         {"handleNews", []string{"request_id", "logging", "etag", "encoding", "dates", "fields", "cache"}, []string{"news", "comments"}},
+	"/api/fullnews":     {"handleFullNews", []string{"request_id", "logging", "etag", "encoding", "dates", "fields", "cache"}, []string{"news"}},
+	"/api/comments":     {"handleComments", []string{"request_id", "logging", "etag", "encoding", "dates", "cache"}, []string{"comments"}},
+	"/api/comments/add": {"handleAddComment", []string{"request_id", "logging", "cache"}, []string{"comments"}},
+	"/api/news/":        {"handleNewsWithID", []string{"request_id", "logging", "etag", "encoding", "dates", "fields", "cache"}, []string{"news", "comments"}},
+	"/api/news/batch":   {"handleNewsBatch", []string{"request_id", "logging", "etag", "encoding", "dates", "fields", "cache"}, []string{"news"}},
+	"/api/news/stream":  {"handleNewsStream", []string{"request_id", "logging"}, []string{"news"}},
+	"/api/batch":        {"handleBatch", []string{"request_id", "logging"}, nil},
+	"/api/v1/":          {"versionHandler(v1)", nil, nil},
+	"/api/v2/":          {"versionHandler(v2)", nil, nil},
+	"/rpc":              {"handleJSONRPC", []string{"request_id", "logging"}, []string{"news", "comments"}},
+	"/graphql":          {"handleGraphQL", []string{"request_id", "logging"}, []string{"news", "comments"}},
+	"/openapi.json":     {"handleOpenAPI", []string{"request_id", "logging"}, nil},
+	"/docs":             {"handleDocs", []string{"request_id", "logging", "docs_auth"}, nil},
+}
+
+// builtinHandler оборачивает обработчик встроенного маршрута pattern в
+// цепочку middleware из builtinRoutes
+func (s *Server) builtinHandler(pattern string, handler http.HandlerFunc) http.Handler {
+	chain := builtinRoutes[pattern].Middleware
+	var h http.Handler = handler
+	for i := len(chain) - 1; i >= 0; i-- {
+		h = s.routeMiddleware(chain[i], pattern, h)
+	}
+	return h
+}
+
+// routeMiddleware оборачивает next в middleware с именем name
+func (s *Server) routeMiddleware(name, pattern string, next http.Handler) http.Handler {
+	switch name {
+	case "request_id":
+		return s.requestIDMiddleware(next)
+	case "logging":
+		return s.loggingMiddleware(pattern, next)
+	case "etag":
+		return s.etagMiddleware(next)
+	case "encoding":
+		return s.encodingMiddleware(next)
+	case "dates":
+		return s.datesMiddleware(next)
+	case "fields":
+		return s.fieldsMiddleware(next)
+	case "cache":
+		return s.cacheMiddleware(next)
+	case "docs_auth":
+		return s.docsAuthMiddleware(next)
+	}
+	panic(fmt.Sprintf("неизвестный middleware %q маршрута %s", name, pattern))
+}
+
+// RouteMatch - маршрут, на который попадет запрос
+type RouteMatch struct {
+	// Pattern - шаблон маршрута в http.ServeMux, пустой - маршрут не найден (404)
+	Pattern string `json:"pattern"`
+	// Kind - вид маршрута: "builtin", "version", "proxy" или "static"
+	Kind    string `json:"kind,omitempty"`
+	Handler string `json:"handler,omitempty"`
+	// Middleware - полная цепочка middleware, включая общие для всех запросов
+	Middleware []string `json:"middleware,omitempty"`
+	// Backends - адреса backend-сервисов, к которым обратится шлюз
+	Backends []string `json:"backends,omitempty"`
+	// Inner - маршрут, которым обрабатывается запрос версионированного API
+	Inner *RouteMatch `json:"inner,omitempty"`
+	// Rewritten - путь после внутренней перезаписи (секция rewrites)
+	Rewritten string `json:"rewritten,omitempty"`
+	// Redirect и RedirectStatus - адрес и статус перенаправления; маршрут в этом случае не выбирается
+	Redirect       string `json:"redirect,omitempty"`
+	RedirectStatus int    `json:"redirect_status,omitempty"`
+}
+
+// MatchRoute определяет, какой маршрут, цепочка middleware и backend-сервис
+// обработают запрос при конфигурации cfg. target - путь с параметрами.
+func MatchRoute(cfg *config.Config, method, target string) (*RouteMatch, error) {
+	if !strings.HasPrefix(target, "/") {
+		return nil, fmt.Errorf("путь должен начинаться с /: %q", target)
+	}
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный запрос: %w", err)
+	}
+
+	srv, err := newDetachedGeneration(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer srv.background.stop()
+
+	var global []string
+	if srv.cors != nil {
+		global = append(global, "cors")
+	}
+	if len(srv.rewrites) > 0 {
+		global = append(global, "rewrite")
+	}
+
+	var rewritten string
+	for _, rule := range srv.rewrites {
+		target, ok := rule.apply(req.URL)
+		if !ok {
+			continue
+		}
+		if rule.status != 0 {
+			return &RouteMatch{Middleware: global, Redirect: target.String(), RedirectStatus: rule.status}, nil
+		}
+		rewritten = target.RequestURI()
+		req.URL.Path = target.Path
+		req.URL.RawQuery = target.RawQuery
+		break
+	}
+
+	if srv.methodOverride != nil {
+		global = append(global, "method_override")
+	}
+	if len(srv.deprecations) > 0 {
+		global = append(global, "deprecation")
+	}
+	if srv.rateLimiter != nil {
+		global = append(global, "rate_limit")
+	}
+	if srv.auth != nil && srv.auth.protects(unversionedPath(req.URL.Path)) {
+		global = append(global, "auth")
+	}
+	if srv.jwt != nil && srv.jwt.rule(req.Method, unversionedPath(req.URL.Path)) != nil {
+		global = append(global, "jwt")
+	}
+	if srv.qos != nil {
+		global = append(global, "qos")
+	}
+	if srv.shedder != nil {
+		global = append(global, "shedding")
+	}
+	if srv.compressor != nil {
+		global = append(global, "compression")
+	}
+	if srv.timeouts != nil && srv.timeouts.timeout(req) > 0 {
+		global = append(global, "timeout")
+	}
+	match := srv.matchRoute(req, global)
+	match.Rewritten = rewritten
+	return match, nil
+}
+
+// matchRoute сопоставляет запрос с маршрутами поколения
+func (s *Server) matchRoute(r *http.Request, middleware []string) *RouteMatch {
+	_, pattern := s.mux.Handler(r)
+	match := &RouteMatch{Pattern: pattern, Middleware: middleware}
+	if pattern == "" {
+		return match
+	}
+
+	if info, ok := builtinRoutes[pattern]; ok {
+		match.Kind = "builtin"
+		match.Handler = info.Handler
+		match.Middleware = append(match.Middleware, info.Middleware...)
+		for _, backend := range info.Backends {
+			match.Backends = append(match.Backends, s.config.Services.URL(backend))
+		}
+
+		// Запросы версионированного API передаются маршрутам без версии
+		if version := strings.TrimSuffix(strings.TrimPrefix(pattern, "/api/"), "/"); version == apiV1 || version == apiV2 {
+			match.Kind = "version"
+			inner := r.Clone(r.Context())
+			inner.URL.Path = "/api" + strings.TrimPrefix(r.URL.Path, "/api/"+version)
+			match.Inner = s.matchRoute(inner, nil)
+		}
+		return match
+	}
+
+	if s.isMetricsPath(pattern) {
+		match.Kind = "builtin"
+		match.Handler = "metrics"
+		return match
+	}
+
+	if s.isHealthPath(pattern) {
+		match.Kind = "builtin"
+		match.Handler = "liveness"
+		if pattern == s.config.Health.ReadinessPath {
+			match.Handler = "readiness"
+			for _, name := range s.config.Health.Services {
+				match.Backends = append(match.Backends, s.config.Services.URL(name))
+			}
+		}
+		return match
+	}
+
+	if route, ok := s.proxies[pattern]; ok {
+		match.Kind = "proxy"
+		match.Handler = "proxyRoute"
+		match.Middleware = append(match.Middleware, "request_id", "logging")
+		match.Backends = []string{route.targetURL(r).String()}
+		return match
+	}
+
+	if pattern == "/" && s.config.Static.Enabled {
+		match.Kind = "static"
+		match.Handler = "static"
+		match.Middleware = append(match.Middleware, "logging")
+		return match
+	}
+	// Остальные пути обрабатывает handleNotFound
+	return &RouteMatch{Middleware: middleware}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"apigw/pkg/config"
+	"apigw/pkg/search"
+)
+
+// newsIndex - снимок списка новостей с поисковым индексом по заголовкам
+type newsIndex struct {
+	// items - новости в порядке ответа сервиса, позиции совпадают с позициями в index
+	items []upstreamNews
+	index *search.Index
+}
+
+// runSearchIndex строит индекс при запуске и обновляет его с интервалом из конфигурации
+func (s *Server) runSearchIndex(ctx context.Context) {
+	s.refreshSearchIndex(ctx)
+
+	interval := s.config.Search.RefreshInterval.Std()
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.refreshSearchIndex(ctx)
+		}
+	}
+}
+
+// refreshSearchIndex загружает список новостей и заменяет индекс. При ошибке
+// продолжает использоваться предыдущий индекс.
+func (s *Server) refreshSearchIndex(ctx context.Context) {
+	items, err := s.loadIndexedNews(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Не удалось обновить поисковый индекс", "error", err)
+		return
+	}
+
+	titles := make([]string, len(items))
+	for i, item := range items {
+		titles[i] = item.Title
+	}
+	s.newsIndex.Store(&newsIndex{
+		items: items,
+		index: search.NewIndex(titles),
+	})
+	slog.InfoContext(ctx, "Поисковый индекс обновлен", "news", len(items))
+}
+
+// loadIndexedNews получает список новостей для индекса. Записи, которые не
+// удалось декодировать, пропускаются так же, как при поиске по ответу сервиса.
+func (s *Server) loadIndexedNews(ctx context.Context) ([]upstreamNews, error) {
+	resp, err := s.makeBackendRequest(http.MethodGet, fmt.Sprintf("%s/api/news/", s.config.Services.URL(config.ServiceNews)), ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("сервис вернул статус %d", resp.StatusCode)
+	}
+
+	var raw []json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("ошибка при декодировании ответа: %w", err)
+	}
+
+	maxItems := s.config.Search.MaxItems
+	if maxItems > 0 && len(raw) > maxItems {
+		raw = raw[:maxItems]
+	}
+
+	items := make([]upstreamNews, 0, len(raw))
+	for _, data := range raw {
+		var item upstreamNews
+		if json.Unmarshal(data, &item) != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// searchNewsIndex возвращает страницу результатов поиска из индекса.
+// false означает, что запрос нужно выполнить через сервис новостей:
+// поиск не задан, индекс отключен или еще не построен, либо у арендатора
+// запроса свой сервис новостей.
+func (s *Server) searchNewsIndex(ctx context.Context, searchTerm string, page, count int) ([]upstreamNews, int, bool) {
+	if searchTerm == "" || s.serviceURL(ctx, config.ServiceNews) != s.config.Services.URL(config.ServiceNews) {
+		return nil, 0, false
+	}
+	snapshot := s.newsIndex.Load()
+	if snapshot == nil {
+		return nil, 0, false
+	}
+
+	matches := snapshot.index.Search(searchTerm)
+	start, end, _ := pageBounds(len(matches), page, count)
+
+	items := make([]upstreamNews, 0, end-start)
+	for _, i := range matches[start:end] {
+		items = append(items, snapshot.items[i])
+	}
+	return items, len(matches), true
+}
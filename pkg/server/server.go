@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/rand"
@@ -8,13 +9,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"apigw/pkg/cache"
 	"apigw/pkg/config"
+	"apigw/pkg/events"
+	"apigw/pkg/httpclient"
+
+	"github.com/graphql-go/graphql"
 )
 
 // Ключ контекста для хранения request_id
@@ -22,12 +30,22 @@ type contextKey string
 
 const requestIDKey contextKey = "requestID"
 
+// Параметры пагинации по умолчанию
+const (
+	defaultPage  = 1
+	defaultCount = 10
+)
+
 // NewsItem представляет краткую информацию о новости (без описания)
 type NewsItem struct {
 	ID        int64  `json:"id"`
 	Title     string `json:"title"`
 	PubDate   string `json:"pub_date"`
 	SourceURL string `json:"source_url"`
+	// CommentCount - количество комментариев; заполняется при with_comment_counts
+	CommentCount *int `json:"comment_count,omitempty"`
+	// MatchedIn - поля, в которых найден поисковый запрос s
+	MatchedIn []string `json:"matched_in,omitempty"`
 }
 
 // FullNewsItem представляет полную информацию о новости (с описанием)
@@ -38,14 +56,18 @@ type FullNewsItem struct {
 	PubDate     string `json:"pub_date"`
 	SourceURL   string `json:"source_url"`
 	CreatedAt   string `json:"created_at,omitempty"`
+	// MatchedIn - поля, в которых найден поисковый запрос s
+	MatchedIn []string `json:"matched_in,omitempty"`
 }
 
 // Comment представляет информацию о комментарии к новости
 type Comment struct {
 	ID        int64  `json:"id"`
 	NewsID    int64  `json:"news_id"`
-	Message   string `json:"message"`
+	Text      string `json:"text"`
 	CreatedAt string `json:"created_at"`
+	// ParentID - комментарий, ответом на который является этот комментарий
+	ParentID int64 `json:"parent_id,omitempty"`
 }
 
 // CommentResponse представляет ответ со списком комментариев
@@ -54,6 +76,13 @@ type CommentResponse struct {
 	NewsID   int64     `json:"news_id"`
 }
 
+// rawNewsWithComments - ответ /api/news?comm={newsId} с новостью и комментариями
+// в том виде, в котором их вернули сервисы
+type rawNewsWithComments struct {
+	News     json.RawMessage   `json:"news"`
+	Comments []json.RawMessage `json:"comments"`
+}
+
 // PaginatedResponse представляет ответ с пагинацией
 type PaginatedResponse struct {
 	Items        interface{} `json:"items"`          // Содержимое (новости)
@@ -63,138 +92,408 @@ type PaginatedResponse struct {
 	TotalItems   int         `json:"total_items"`    // Всего элементов
 }
 
+// Server - API Gateway. Значение Server - неизменяемое поколение: конфигурация,
+// таблица маршрутов и зависящее от них состояние. При перезагрузке конфигурации
+// строится новое поколение, которое атомарно заменяет текущее (см. Reload);
+// запросы, начатые на старом поколении, дорабатывают на нем.
 type Server struct {
 	config *config.Config
 	mux    *http.ServeMux
+	keys   *cache.KeyBuilder
+	// handler - mux с middleware уровня сервера (сжатие, сброс нагрузки)
+	handler http.Handler
+	// proxies - маршруты из конфигурации по шаблону пути
+	proxies map[string]*proxyRoute
+
+	// Общие для всех поколений хранилище кэша, публикация событий,
+	// отслеживание доступности сервисов, очередь комментариев и указатель на
+	// текущее поколение
+	store  cache.Cache
+	tags   *cache.Tags
+	events *events.Publisher
+	health *upstreamHealth
+	outbox *commentOutbox
+	shared *sharedState
+
+	limits *upstreamLimits
+	// clients - HTTP клиенты backend-сервисов с отдельными пулами соединений
+	clients *httpclient.Pool
+	// breakers - автоматические выключатели backend-сервисов, nil если отключены
+	breakers *upstreamBreakers
+	// requestIDs - способ передачи request_id backend-сервисам
+	requestIDs requestIDPropagation
+	// claimHeaders - заголовки backend-сервисам с утверждениями токена клиента
+	claimHeaders claimHeaders
+	// checker - активная проверка доступности backend-сервисов, nil если отключена
+	checker *upstreamHealthChecker
+	// auth - проверка ключей API, nil если отключена
+	auth *apiKeyAuth
+	// jwt - проверка токенов пользователей, nil если отключена
+	jwt *jwtAuth
+	// policies - политики доступа маршрутов, nil если не заданы
+	policies *accessPolicies
+	// validator - проверка запросов по описанию API, nil если отключена
+	validator *requestValidator
+	// readiness - проверка доступности backend-сервисов для пути готовности
+	readiness *readinessProbe
+	// rateLimiter - ограничение частоты запросов с одного IP-адреса, nil если отключено
+	rateLimiter *clientRateLimiter
+	// ipResolver - определение IP-адреса клиента с учетом доверенных прокси
+	ipResolver *ipResolver
+	// retries - повторы запросов к backend-сервисам, nil если отключены
+	retries *upstreamRetries
+	// faults - внесение сбоев в запросы к сервисам, nil если отключено
+	faults *faultInjector
+	// tenants - арендаторы, nil если шлюз обслуживает один портал
+	tenants *tenantRegistry
+	// background - фоновые задачи поколения
+	background *generationState
+	// qos - классы приоритета запросов, nil если отключены
+	qos *qosPolicy
+	// shedder - сброс нагрузки при нехватке памяти, nil если отключен
+	shedder *loadShedder
+	// compressor - сжатие ответов, nil если отключено
+	compressor *compressor
+	// dates - приведение дат в ответах к часовому поясу клиента
+	dates *dateLocalizer
+	// methodOverride - подмена метода POST запросов, nil если отключена
+	methodOverride *methodOverrider
+	// cors - политики CORS, nil если отключены
+	cors *corsPolicies
+	// timeouts - ограничения времени обработки запросов, nil если не заданы
+	timeouts *requestTimeouts
+	// serviceTimeouts - таймауты запросов к backend-сервисам по адресу сервиса
+	serviceTimeouts map[string]time.Duration
+	// newsStream - поток новых новостей, nil если отключен
+	newsStream *newsStream
+	// canaries - правила канареечного выпуска
+	canaries []canaryRule
+	// mirrors - теневые сервисы для копий запросов, nil если не заданы
+	mirrors *upstreamMirrors
+	// bulkheads - лимиты одновременных запросов к backend-сервисам
+	bulkheads *upstreamBulkheads
+	// coalescer - объединение одинаковых запросов к backend-сервисам, nil если отключено
+	coalescer *requestCoalescer
+	// moderator - модерация комментариев, nil если не настроена
+	moderator *commentModerator
+
+	versions map[string]*deprecationPolicy
+	// rewrites - правила перенаправления и перезаписи путей
+	rewrites []*rewriteRule
+	// transforms - преобразования тела запросов к backend-сервисам
+	transforms []*bodyTransform
+	// deprecations - устаревшие маршруты и параметры
+	deprecations []*deprecationRule
+
+	// newsIndex - поисковый индекс новостей, nil пока индекс не построен
+	newsIndex atomic.Pointer[newsIndex]
+
+	graphQLSchema graphql.Schema
 }
 
-// responseWriter - обертка над http.ResponseWriter для захвата статуса ответа
+// responseWriter - обертка над http.ResponseWriter для захвата статуса и размера
+// ответа. Flusher, Hijacker и Pusher передаются исходному ResponseWriter, поэтому
+// потоковые ответы и WebSocket работают и за middleware.
 type responseWriter struct {
 	http.ResponseWriter
-	statusCode int
+	statusCode  int
+	bytes       int64
+	wroteHeader bool
+}
+
+// newResponseWriter создает обертку со статусом 200 по умолчанию
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 }
 
-// WriteHeader перехватывает статус-код ответа
+// WriteHeader перехватывает статус-код ответа. Повторные вызовы игнорируются,
+// как и в net/http, кроме информационных ответов 1xx.
 func (rw *responseWriter) WriteHeader(code int) {
+	if rw.wroteHeader {
+		return
+	}
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		rw.ResponseWriter.WriteHeader(code)
+		return
+	}
 	rw.statusCode = code
+	rw.wroteHeader = true
 	rw.ResponseWriter.WriteHeader(code)
 }
 
-func NewServer(cfg *config.Config) *Server {
+// Write передает тело ответа и учитывает количество записанных байт
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Flush отправляет клиенту буферизованные данные (нужно для потоковых ответов)
+func (rw *responseWriter) Flush() {
+	rw.wroteHeader = true
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack передает управление соединением обработчику (нужно для WebSocket)
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("ResponseWriter не поддерживает Hijack")
+	}
+	rw.statusCode = http.StatusSwitchingProtocols
+	rw.wroteHeader = true
+	return hijacker.Hijack()
+}
+
+// Push инициирует HTTP/2 server push, если соединение его поддерживает
+func (rw *responseWriter) Push(target string, opts *http.PushOptions) error {
+	pusher, ok := rw.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return pusher.Push(target, opts)
+}
+
+// Unwrap возвращает исходный ResponseWriter для http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// NewServer создает сервер и первое поколение его конфигурации
+func NewServer(cfg *config.Config) (*Server, error) {
+	store, err := newCacheStore(cfg.Cache)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать хранилище кэша: %w", err)
+	}
+
 	srv := &Server{
-		config: cfg,
-		mux:    http.NewServeMux(),
+		store:  store,
+		tags:   cache.NewTags(store),
+		shared: newSharedState(),
+	}
+
+	srv.events, err = newEventPublisher(cfg.Events)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось настроить публикацию событий: %w", err)
+	}
+	srv.health = newUpstreamHealth(srv.publishEvent)
+
+	srv.outbox, err = newCommentOutbox(cfg.CommentOutbox, srv.shared.metrics)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось настроить очередь комментариев: %w", err)
+	}
+
+	if err := srv.configure(cfg, nil); err != nil {
+		return nil, err
 	}
-	srv.setupRoutes()
-	return srv
+	srv.shared.current.Store(srv)
+	return srv, nil
 }
 
-func (s *Server) setupRoutes() {
+// newCacheKeyBuilder создает построитель ключей кэша с учетом
+// параметров по умолчанию, которые используют обработчики
+func newCacheKeyBuilder(cfg config.CacheConfig) *cache.KeyBuilder {
+	return cache.NewKeyBuilder(cache.KeyOptions{
+		// Часовой пояс tz и выбор полей fields применяются к ответу снаружи кэша
+		Ignored: append([]string{"tz", fieldsParam}, cfg.IgnoredParams...),
+		Defaults: map[string]string{
+			"page":  strconv.Itoa(defaultPage),
+			"count": strconv.Itoa(defaultCount),
+		},
+		// Поиск подстроки и фразы регистронезависимый; регулярное выражение
+		// добавляется в ключ как есть (см. regexSearchKey)
+		CaseInsensitive: []string{"s"},
+	})
+}
+
+// isMetricsPath проверяет, что по пути path отдаются метрики
+func (s *Server) isMetricsPath(path string) bool {
+	return s.config.Metrics.Enabled && path == s.config.Metrics.Path
+}
+
+func (s *Server) setupRoutes() error {
 	// Маршруты с применением  middleware
-	s.mux.Handle("/api/news", s.requestIDMiddleware(s.loggingMiddleware(http.HandlerFunc(s.handleNews))))
-	s.mux.Handle("/api/fullnews", s.requestIDMiddleware(s.loggingMiddleware(http.HandlerFunc(s.handleFullNews))))
+	s.mux.Handle("/api/news", s.builtinHandler("/api/news", s.handleNews))
+	s.mux.Handle("/api/fullnews", s.builtinHandler("/api/fullnews", s.handleFullNews))
 
 	// Маршруты для комментариев
-	s.mux.Handle("/api/comments", s.requestIDMiddleware(s.loggingMiddleware(http.HandlerFunc(s.handleComments))))
+	s.mux.Handle("/api/comments", s.builtinHandler("/api/comments", s.handleComments))
 	// Новый маршрут для добавления комментариев через POST
-	s.mux.Handle("/api/comments/add", s.requestIDMiddleware(s.loggingMiddleware(http.HandlerFunc(s.handleAddComment))))
+	s.mux.Handle("/api/comments/add", s.builtinHandler("/api/comments/add", s.handleAddComment))
+
+	// Поток новых новостей; без кэша и преобразования дат, которые буферизуют ответ
+	if s.newsStream != nil {
+		s.mux.Handle(newsStreamPath, s.builtinHandler(newsStreamPath, s.handleNewsStream))
+	}
+
+	// Несколько новостей по ID за один запрос
+	s.mux.Handle("/api/news/batch", s.builtinHandler("/api/news/batch", s.handleNewsBatch))
 
 	// REST-стиль URL для работы с комментариями (принимает ID новости в пути)
-	s.mux.Handle("/api/news/", s.requestIDMiddleware(s.loggingMiddleware(http.HandlerFunc(s.handleNewsWithID))))
+	s.mux.Handle("/api/news/", s.builtinHandler("/api/news/", s.handleNewsWithID))
+
+	// Пакетное выполнение нескольких запросов за одно обращение
+	s.mux.Handle("/api/batch", s.builtinHandler("/api/batch", s.handleBatch))
+
+	// Версионированные пути /api/v1/... и /api/v2/... обслуживаются теми же обработчиками
+	s.mux.Handle("/api/"+apiV1+"/", s.versionHandler(apiV1))
+	s.mux.Handle("/api/"+apiV2+"/", s.versionHandler(apiV2))
+
+	// JSON-RPC 2.0 для партнерских интеграций
+	s.mux.Handle("/rpc", s.builtinHandler("/rpc", s.handleJSONRPC))
+
+	// GraphQL поверх сервисов новостей и комментариев
+	s.mux.Handle("/graphql", s.builtinHandler("/graphql", s.handleGraphQL))
+
+	// Описание API в формате OpenAPI 3
+	s.mux.Handle("/openapi.json", s.builtinHandler("/openapi.json", s.handleOpenAPI))
+
+	// Интерактивная документация (Swagger UI)
+	if s.config.Docs.Enabled {
+		if s.config.Docs.RequireAuth && (s.config.Docs.Username == "" || s.config.Docs.Password == "") {
+			return fmt.Errorf("для docs.require_auth нужно указать docs.username и docs.password")
+		}
+		s.mux.Handle("/docs", s.builtinHandler("/docs", s.handleDocs))
+	}
+
+	// Метрики в формате Prometheus
+	if s.config.Metrics.Enabled {
+		if _, taken := builtinRoutes[s.config.Metrics.Path]; taken || !strings.HasPrefix(s.config.Metrics.Path, "/") {
+			return fmt.Errorf("некорректный путь метрик: %q", s.config.Metrics.Path)
+		}
+		s.mux.Handle(s.config.Metrics.Path, s.shared.metrics.Handler())
+	}
+
+	// Проверки работоспособности и готовности для оркестратора
+	for path, handler := range map[string]http.HandlerFunc{
+		s.config.Health.LivenessPath:  s.handleLiveness,
+		s.config.Health.ReadinessPath: s.handleReadiness,
+	} {
+		if path == "" {
+			continue
+		}
+		if s.isMetricsPath(path) {
+			return fmt.Errorf("путь проверки %s совпадает с путем метрик", path)
+		}
+		s.mux.Handle(path, handler)
+	}
+
+	// Маршруты из конфигурации, проксируемые на произвольные backend-сервисы
+	for _, routeCfg := range s.config.Routes {
+		if _, taken := builtinRoutes[routeCfg.Path]; taken || s.proxies[routeCfg.Path] != nil || s.isMetricsPath(routeCfg.Path) || s.isHealthPath(routeCfg.Path) {
+			return fmt.Errorf("маршрут %s уже обрабатывается шлюзом", routeCfg.Path)
+		}
+		route, err := newProxyRoute(routeCfg, s.config.Services)
+		if err != nil {
+			return err
+		}
+		route.transport = s.transport()
+		route.transforms = s.transforms
+		route.requestIDs = s.requestIDs
+		route.claimHeaders = s.claimHeaders
+		if len(routeCfg.Invalidates) > 0 {
+			route.invalidate = func(ctx context.Context, tags ...string) {
+				if s.cacheEnabled() {
+					s.invalidateCache(ctx, tags...)
+				}
+			}
+		}
+		s.proxies[routeCfg.Path] = route
+		s.mux.Handle(routeCfg.Path, s.requestIDMiddleware(s.loggingMiddleware(routeCfg.Path, route)))
+	}
+
+	// Фронтенд обслуживает все пути, не занятые API и маршрутами из конфигурации
+	if s.config.Static.Enabled {
+		if s.proxies["/"] != nil {
+			return fmt.Errorf("маршрут / конфликтует с раздачей статических файлов (static.enabled)")
+		}
+		static, err := newStaticHandler(s.config.Static)
+		if err != nil {
+			return fmt.Errorf("не удалось настроить раздачу статических файлов: %w", err)
+		}
+		s.mux.Handle("/", s.loggingMiddleware("/", static))
+	} else if s.proxies["/"] == nil {
+		// Неизвестные пути получают ошибку в том же формате, что и остальные
+		s.mux.HandleFunc("/", handleNotFound)
+	}
+	return nil
+}
+
+// handleNotFound отвечает на запросы к неизвестным маршрутам
+func handleNotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusNotFound, codeNotFound, "Маршрут не найден")
 }
 
 // Middleware для обработки request_id
 func (s *Server) requestIDMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Получаем request_id из query-параметров
-		requestID := r.URL.Query().Get("request_id")
+		// Получаем request_id из заголовка X-Request-ID или query-параметров
+		requestID := incomingRequestID(r)
 
 		// Если request_id не передан, генерируем его
 		if requestID == "" {
 			var err error
 			requestID, err = generateRequestID(8) // Генерируем строку из 8 символов
 			if err != nil {
-				log.Printf("Ошибка при генерации request_id: %v", err)
-				http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+				slog.ErrorContext(r.Context(), "Ошибка при генерации request_id", "error", err)
+				writeError(w, r, http.StatusInternalServerError, codeInternal, "Внутренняя ошибка сервера")
 				return
 			}
-			log.Printf("Сгенерирован новый request_id: %s", requestID)
-		} else {
-			log.Printf("Получен request_id из параметров: %s", requestID)
 		}
 
 		// Добавляем request_id в заголовок ответа для отладки
-		w.Header().Set("X-Request-ID", requestID)
+		w.Header().Set(requestIDHeaderName, requestID)
 
-		// Добавляем request_id в контекст запроса
+		// Добавляем request_id в контекст запроса, записи журнала получают его оттуда
 		ctx := context.WithValue(r.Context(), requestIDKey, requestID)
 
-		// Проверяем, что request_id успешно добавлен в контекст
-		checkID, ok := ctx.Value(requestIDKey).(string)
-		if !ok || checkID == "" {
-			log.Printf("ОШИБКА: request_id не добавлен в контекст")
-		} else {
-			log.Printf("request_id успешно добавлен в контекст: %s", checkID)
-		}
-
 		// Вызываем следующий обработчик с обновленным контекстом
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// loggingMiddleware логирует информацию о запросе после его обработки
-func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
+// loggingMiddleware записывает строку журнала запросов к маршруту route после его обработки
+func (s *Server) loggingMiddleware(route string, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Создаем обертку, чтобы перехватить статус-код ответа
-		rw := &responseWriter{w, http.StatusOK}
-
-		// Получаем request_id из контекста
-		requestID := "unknown"
-		if id, ok := r.Context().Value(requestIDKey).(string); ok && id != "" {
-			requestID = id
-			log.Printf("loggingMiddleware: получен request_id из контекста: %s", id)
-		} else {
-			log.Printf("loggingMiddleware: request_id не найден в контексте")
-
-			// Попробуем получить его из заголовка, который должен был установить requestIDMiddleware
-			headerID := w.Header().Get("X-Request-ID")
-			if headerID != "" {
-				log.Printf("loggingMiddleware: нашли request_id в заголовке: %s", headerID)
-				requestID = headerID
-			}
-		}
+		rw := newResponseWriter(w)
 
-		// Получаем IP-адрес запроса
-		ipAddress := r.RemoteAddr
-		// Проверяем X-Forwarded-For заголовок, который может содержать реальный IP за прокси
-		if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
-			// Берем первый IP из списка (клиентский)
-			ips := strings.Split(forwardedFor, ",")
-			if len(ips) > 0 {
-				ipAddress = strings.TrimSpace(ips[0])
-			}
-		}
+		// request_id добавляется в контекст requestIDMiddleware
+		requestID, _ := r.Context().Value(requestIDKey).(string)
+
+		// Получаем IP-адрес клиента с учетом доверенных прокси
+		ipAddress := clientIP(r)
 
 		// Время начала обработки запроса
 		start := time.Now()
 
-		// Вызываем следующий обработчик с нашей оберткой вместо оригинального ResponseWriter
-		next.ServeHTTP(rw, r)
+		// Маршрут добавляется к записям журнала, сделанным при обработке запроса
+		ctx := context.WithValue(r.Context(), routeKey, route)
+		logger := accessLog.Load()
+		if logger.collectBackend() {
+			ctx = withBackendCalls(ctx)
+		}
+		next.ServeHTTP(rw, r.WithContext(ctx))
 
 		// Время завершения обработки запроса
 		duration := time.Since(start)
 
-		// Логируем информацию после обработки запроса
-		log.Printf(
-			"[%s] Request: %s %s | IP: %s | Status: %d | Duration: %v | ID: %s",
-			time.Now().Format(time.RFC3339),
-			r.Method,
-			r.URL.Path,
-			ipAddress,
-			rw.statusCode,
-			duration,
-			requestID,
-		)
+		logger.log(ctx, newAccessRecord(ctx, r, route, start, rw.statusCode, rw.bytes, duration))
+
+		client := clientFrom(r.Context())
+		s.publishEvent(events.TypeRequestCompleted, r.URL.Path, requestEvent{
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Status:     rw.statusCode,
+			Bytes:      rw.bytes,
+			DurationMS: float64(duration.Microseconds()) / 1000,
+			RemoteIP:   ipAddress,
+			RequestID:  requestID,
+			Client:     client,
+		})
 	})
 }
 
@@ -208,9 +507,82 @@ func generateRequestID(length int) (string, error) {
 }
 
 func (s *Server) Start() error {
+	tlsCfg, err := newTLSConfig(s.config.Server.TLS)
+	if err != nil {
+		return err
+	}
+
 	addr := fmt.Sprintf(":%d", s.config.Server.Port)
-	log.Printf("API Gateway доступен по адресу http://localhost:%d", s.config.Server.Port)
-	return http.ListenAndServe(addr, s.mux)
+	scheme := "http"
+	if tlsCfg != nil {
+		scheme = "https"
+	}
+	slog.Info("API Gateway запущен", "address", fmt.Sprintf("%s://localhost:%d", scheme, s.config.Server.Port))
+
+	s.current().startBackground()
+
+	// Очередь комментариев общая для всех поколений и работает до остановки сервера
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	if s.outbox != nil {
+		go s.runCommentOutbox(ctx)
+	}
+
+	errCh := make(chan error, 4)
+
+	if tlsCfg != nil && s.config.Server.TLS.RedirectPort > 0 {
+		go func() {
+			errCh <- s.serveRedirect(s.config.Server.TLS.RedirectPort)
+		}()
+	}
+
+	// gRPC сервис работает на отдельном порту
+	if s.config.Server.GRPCPort > 0 {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.config.Server.GRPCPort))
+		if err != nil {
+			return fmt.Errorf("не удалось открыть порт gRPC: %w", err)
+		}
+		slog.Info("gRPC сервис запущен", "port", s.config.Server.GRPCPort)
+		go func() {
+			errCh <- s.newGRPCServer().Serve(lis)
+		}()
+	}
+
+	// Административный API работает на отдельном порту
+	if s.config.Admin.Port > 0 {
+		go func() {
+			errCh <- s.serveAdmin(s.config.Admin.Port)
+		}()
+	}
+
+	go func() {
+		srv := &http.Server{Addr: addr, Handler: s.Handler(), TLSConfig: tlsCfg}
+		if tlsCfg != nil {
+			// Сертификат уже загружен в TLSConfig
+			errCh <- srv.ListenAndServeTLS("", "")
+			return
+		}
+		errCh <- srv.ListenAndServe()
+	}()
+
+	err = <-errCh
+	stop()
+	s.outbox.close()
+	if s.events != nil {
+		s.events.Close()
+	}
+	return err
+}
+
+// Handler возвращает обработчик HTTP запросов шлюза для встраивания в другой
+// HTTP сервер. Фоновые задачи (прогрев кэша, поисковый индекс) запускает только Start.
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(s.serveCurrent)
+}
+
+// transport возвращает транспорт запросов поколения к backend-сервисам
+func (s *Server) transport() *upstreamTransport {
+	return &upstreamTransport{clients: s.clients, retries: s.retries, breakers: s.breakers, bulkheads: s.bulkheads, limits: s.limits, health: s.health, checker: s.checker, faults: s.faults, timeouts: s.serviceTimeouts, mirrors: s.mirrors, coalescer: s.coalescer}
 }
 
 // Модифицируем функцию запроса к backend-сервису для передачи request_id
@@ -226,21 +598,22 @@ func (s *Server) makeBackendRequest(method, url string, ctx context.Context, bod
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	}
 
-	// Получаем request_id из контекста
-	requestID, ok := ctx.Value(requestIDKey).(string)
-	if ok && requestID != "" {
-		// Добавляем request_id в параметры запроса к backend-сервису
-		q := req.URL.Query()
-		q.Add("request_id", requestID)
-		req.URL.RawQuery = q.Encode()
-	}
+	// Передаем request_id в параметрах или заголовке согласно настройке сервиса
+	s.requestIDs.apply(req)
+	s.claimHeaders.apply(req)
 
-	// Выполняем запрос с использованием http.DefaultClient
-	return http.DefaultClient.Do(req)
+	// Выполняем запрос с учетом лимита одновременных запросов к сервису
+	return s.transport().RoundTrip(req)
 }
 
 // handleNews обрабатывает запросы на получение списка новостей без описания
 func (s *Server) handleNews(w http.ResponseWriter, r *http.Request) {
+	// Обрабатываем только GET запросы, в том числе с параметром comm
+	if r.Method != http.MethodGet {
+		writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Метод не разрешен")
+		return
+	}
+
 	// Проверяем параметр comm - только для получения новости с комментариями
 	query := r.URL.Query()
 	commentNewsID := query.Get("comm")
@@ -249,735 +622,613 @@ func (s *Server) handleNews(w http.ResponseWriter, r *http.Request) {
 	if commentNewsID != "" {
 
 		// Получаем новость и комментарии к ней
-		log.Printf("Получение новости ID: %s с комментариями", commentNewsID)
+		slog.DebugContext(r.Context(), "Получение новости с комментариями", "news_id", commentNewsID)
 
 		// Формируем URL для получения новости
 		newsID, err := strconv.ParseInt(commentNewsID, 10, 64)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Некорректный ID новости"})
+			writeError(w, r, http.StatusBadRequest, codeInvalidNewsID, "Некорректный ID новости")
 			return
 		}
 
-		// Получаем одну новость с сервиса новостей
-		newsURL := fmt.Sprintf("%s/api/news/%d", s.config.Services.News.URL, newsID)
-		newsResp, err := s.makeBackendRequest(http.MethodGet, newsURL, r.Context(), nil)
-		if err != nil {
-			log.Printf("Ошибка при получении новости: %v", err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Не удалось получить новость"})
+		// Не обращаемся к сервису новостей, если недавно узнали, что новости нет
+		if s.isNewsMissing(r.Context(), newsID) {
+			writeError(w, r, http.StatusNotFound, codeNewsNotFound, "Новость не найдена")
 			return
 		}
-		defer newsResp.Body.Close()
 
-		// Проверяем статус ответа от сервиса новостей
-		if newsResp.StatusCode != http.StatusOK {
-			log.Printf("Сервис новостей вернул статус: %d", newsResp.StatusCode)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(newsResp.StatusCode)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Новость не найдена"})
-			return
-		}
-
-		// Читаем ответ от сервиса новостей
-		newsBody, err := io.ReadAll(newsResp.Body)
-		if err != nil {
-			log.Printf("Ошибка при чтении ответа: %v", err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Ошибка при обработке ответа от сервиса новостей"})
-			return
-		}
-
-		// Декодируем новость - сервис возвращает массив с одним элементом
-		var newsItems []map[string]interface{}
-		if err := json.Unmarshal(newsBody, &newsItems); err != nil {
-			log.Printf("Ошибка при декодировании новости: %v, тело: %s", err, string(newsBody))
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Ошибка при обработке новости"})
-			return
-		}
-
-		// Проверяем, что в массиве есть хотя бы один элемент
-		if len(newsItems) == 0 {
-			log.Printf("Новость не найдена")
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Новость не найдена"})
-			return
-		}
-
-		// Берем первую новость из массива
-		newsItem := newsItems[0]
-
-		// Получаем комментарии к новости
-		commURL := fmt.Sprintf("%s/api/comm_news?id=%d", s.config.Services.Comments.URL, newsID)
-		commResp, err := s.makeBackendRequest(http.MethodGet, commURL, r.Context(), nil)
-		if err != nil {
-			log.Printf("Ошибка при получении комментариев: %v", err)
-			// В случае ошибки, возвращаем только новость без комментариев
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"news":     newsItem,
-				"comments": []interface{}{},
-			})
-			return
-		}
-		defer commResp.Body.Close()
-
-		// Читаем ответ от сервиса комментариев
-		commBody, err := io.ReadAll(commResp.Body)
-		if err != nil {
-			log.Printf("Ошибка при чтении ответа комментариев: %v", err)
-			// В случае ошибки, возвращаем только новость без комментариев
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"news":     newsItem,
-				"comments": []interface{}{},
-			})
-			return
-		}
-
-		// Декодируем комментарии
-		var commResponse []interface{}
-		if err := json.Unmarshal(commBody, &commResponse); err != nil {
-			log.Printf("Ошибка при декодировании комментариев: %v, тело: %s", err, string(commBody))
-			// В случае ошибки, возвращаем только новость без комментариев
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"news":     newsItem,
-				"comments": []interface{}{},
-			})
-			return
-		}
-
-		// Формируем и отправляем ответ с новостью и комментариями
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"news":     newsItem,
-			"comments": commResponse,
+		// Получаем новость с сервиса новостей и дополняем ее комментариями.
+		// Новость и комментарии передаются клиенту как есть, поэтому не разбираются на поля.
+		s.proxyUpstream(w, r, upstreamCall{
+			target:  fmt.Sprintf("%s/api/news/%d", s.serviceURL(r.Context(), config.ServiceNews), newsID),
+			message: "Не удалось получить новость",
+			response: func(resp *http.Response) error {
+				newsItem, err := s.readNewsItem(r.Context(), resp, newsID)
+				if err != nil {
+					return err
+				}
+				return replaceJSON(resp, http.StatusOK, rawNewsWithComments{
+					News:     newsItem,
+					Comments: s.fetchRawComments(r.Context(), newsID),
+				})
+			},
 		})
 		return
 	}
 
 	// Если не указан параметр comm, обрабатываем как обычный запрос новостей
-	// Обрабатываем только GET запросы
-	if r.Method != http.MethodGet {
-		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Получаем и обрабатываем параметры запроса
-	pageStr := query.Get("page")
-	countStr := query.Get("count")
-	searchTerm := query.Get("s")
-
-	// Параметры пагинации по умолчанию
-	page := 1
-	count := 10
-
-	// Парсим параметр страницы
-	if pageStr != "" {
-		parsedPage, err := strconv.Atoi(pageStr)
-		if err == nil && parsedPage > 0 {
-			page = parsedPage
-		}
-	}
-
-	// Парсим параметр количества элементов на страницу
-	if countStr != "" {
-		parsedCount, err := strconv.Atoi(countStr)
-		if err == nil && parsedCount > 0 {
-			count = parsedCount
-		}
-	}
-
-	// Формируем URL для сервиса новостей - без указания количества, получим все новости
-	newsURL := fmt.Sprintf("%s/api/news/", s.config.Services.News.URL)
-
-	// Используем модифицированную функцию для запроса к backend, передавая context с request_id
-	resp, err := s.makeBackendRequest(http.MethodGet, newsURL, r.Context(), nil)
-	if err != nil {
-		log.Printf("Ошибка при получении новостей: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Не удалось получить новости"})
-		return
-	}
-	defer resp.Body.Close()
-
-	// Устанавливаем тип содержимого JSON для всех ответов
-	w.Header().Set("Content-Type", "application/json")
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Бэкенд вернул статус: %d", resp.StatusCode)
-		sendEmptyPaginatedResponse(w, page, count)
-		return
-	}
-
-	// Читаем тело ответа
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Ошибка при чтении ответа: %v", err)
-		sendEmptyPaginatedResponse(w, page, count)
-		return
-	}
-
-	// Обрабатываем пустой ответ
-	if len(body) == 0 {
-		sendEmptyPaginatedResponse(w, page, count)
-		return
-	}
-
-	// Декодируем полные новости из бэкенда
-	var allNews []map[string]interface{}
-	if err := json.Unmarshal(body, &allNews); err != nil {
-		log.Printf("Ошибка при декодировании новостей: %v", err)
-		sendEmptyPaginatedResponse(w, page, count)
-		return
-	}
-
-	// Фильтруем новости по поисковому запросу, если он указан
-	var filteredNews []map[string]interface{}
-	if searchTerm != "" {
-		searchTerm = strings.ToLower(searchTerm)
-		for _, item := range allNews {
-			title, ok := item["title"].(string)
-			if !ok {
+	s.serveNewsPage(w, r, func(pagedNews []upstreamNews) interface{} {
+		// Конвертируем полные новости в краткий формат
+		news := make([]NewsItem, 0, len(pagedNews))
+		for _, item := range pagedNews {
+			if item.ID == nil {
 				continue
 			}
 
-			if strings.Contains(strings.ToLower(title), searchTerm) {
-				filteredNews = append(filteredNews, item)
+			newsItem := NewsItem{
+				ID:        *item.ID,
+				Title:     item.Title,
+				PubDate:   item.PubDate,
+				SourceURL: item.SourceURL,
+				MatchedIn: item.matchedIn,
 			}
+			news = append(news, newsItem)
 		}
-	} else {
-		filteredNews = allNews
-	}
-
-	// Применяем пагинацию к отфильтрованным новостям
-	totalItems := len(filteredNews)
-	totalPages := (totalItems + count - 1) / count // Округление вверх
-
-	// Проверяем, что запрошенная страница существует
-	if totalItems == 0 {
-		sendEmptyPaginatedResponse(w, page, count)
-		return
-	}
-
-	// Вычисляем индексы для текущей страницы согласно требованиям
-	// Для page=2, count=5 должны получить элементы с 10 по 15
-	// т.е. для page=2 начинаем с индекса 5*2=10-1=9
-	startIndex := (page - 1) * count
-	endIndex := startIndex + count
-
-	// Проверяем валидность индексов
-	if startIndex >= totalItems {
-		// Запрошенная страница выходит за пределы доступных данных
-		sendEmptyPaginatedResponse(w, page, count)
-		return
-	}
-
-	// Обрезаем endIndex, если он выходит за пределы массива
-	if endIndex > totalItems {
-		endIndex = totalItems
-	}
-
-	// Получаем новости для текущей страницы
-	pagedNews := filteredNews[startIndex:endIndex]
-
-	// Конвертируем полные новости в краткий формат
-	news := make([]NewsItem, 0, len(pagedNews))
-	for _, item := range pagedNews {
-		id, ok := item["id"].(float64)
-		if !ok {
-			continue
+		if withCommentCounts(query) {
+			s.fillCommentCounts(r.Context(), news)
 		}
-
-		newsItem := NewsItem{
-			ID:        int64(id),
-			Title:     getStringValue(item, "title"),
-			PubDate:   getStringValue(item, "pub_date"),
-			SourceURL: getStringValue(item, "source_url"),
-		}
-		news = append(news, newsItem)
-	}
-
-	// Формируем и отправляем ответ с пагинацией
-	response := PaginatedResponse{
-		Items:        news,
-		TotalPages:   totalPages,
-		CurrentPage:  page,
-		ItemsPerPage: count,
-		TotalItems:   totalItems,
-	}
-
-	json.NewEncoder(w).Encode(response)
+		return news
+	})
 }
 
 // handleFullNews обрабатывает запросы на получение полных новостей с описанием
 func (s *Server) handleFullNews(w http.ResponseWriter, r *http.Request) {
 	// Только GET запросы
 	if r.Method != http.MethodGet {
-		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Метод не разрешен")
 		return
 	}
 
-	// Получаем и обрабатываем параметры запроса
-	query := r.URL.Query()
-	pageStr := query.Get("page")
-	countStr := query.Get("count")
-	searchTerm := query.Get("s")
-
-	// Параметры пагинации по умолчанию
-	page := 1
-	count := 10
-
-	// Парсим параметр страницы
-	if pageStr != "" {
-		parsedPage, err := strconv.Atoi(pageStr)
-		if err == nil && parsedPage > 0 {
-			page = parsedPage
-		}
-	}
-
-	// Парсим параметр количества элементов на страницу
-	if countStr != "" {
-		parsedCount, err := strconv.Atoi(countStr)
-		if err == nil && parsedCount > 0 {
-			count = parsedCount
-		}
-	}
-
-	// Формируем URL для сервиса новостей - без указания количества, получим все новости
-	newsURL := fmt.Sprintf("%s/api/news/", s.config.Services.News.URL)
-
-	// Используем модифицированную функцию для запроса к backend, передавая context с request_id
-	resp, err := s.makeBackendRequest(http.MethodGet, newsURL, r.Context(), nil)
-	if err != nil {
-		log.Printf("Ошибка при получении новостей: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Не удалось получить новости"})
-		return
-	}
-	defer resp.Body.Close()
-
-	// Устанавливаем тип содержимого JSON для всех ответов
-	w.Header().Set("Content-Type", "application/json")
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Бэкенд вернул статус: %d", resp.StatusCode)
-		sendEmptyPaginatedResponseFull(w, page, count)
-		return
-	}
-
-	// Читаем тело ответа
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Ошибка при чтении ответа: %v", err)
-		sendEmptyPaginatedResponseFull(w, page, count)
-		return
-	}
-
-	// Обрабатываем пустой ответ
-	if len(body) == 0 {
-		sendEmptyPaginatedResponseFull(w, page, count)
-		return
-	}
-
-	// Декодируем полные новости из бэкенда
-	var allNews []map[string]interface{}
-	if err := json.Unmarshal(body, &allNews); err != nil {
-		log.Printf("Ошибка при декодировании новостей: %v", err)
-		sendEmptyPaginatedResponseFull(w, page, count)
-		return
-	}
-
-	// Фильтруем новости по поисковому запросу, если он указан
-	var filteredNews []map[string]interface{}
-	if searchTerm != "" {
-		searchTerm = strings.ToLower(searchTerm)
-		for _, item := range allNews {
-			title, ok := item["title"].(string)
-			if !ok {
+	s.serveNewsPage(w, r, func(pagedNews []upstreamNews) interface{} {
+		// Конвертируем в полный формат новостей
+		fullNews := make([]FullNewsItem, 0, len(pagedNews))
+		for _, item := range pagedNews {
+			if item.ID == nil {
 				continue
 			}
 
-			if strings.Contains(strings.ToLower(title), searchTerm) {
-				filteredNews = append(filteredNews, item)
+			fullNewsItem := FullNewsItem{
+				ID:          *item.ID,
+				Title:       item.Title,
+				Description: item.Description,
+				PubDate:     item.PubDate,
+				SourceURL:   item.SourceURL,
+				CreatedAt:   item.CreatedAt,
+				MatchedIn:   item.matchedIn,
 			}
-		}
-	} else {
-		filteredNews = allNews
-	}
 
-	// Применяем пагинацию к отфильтрованным новостям
-	totalItems := len(filteredNews)
-	totalPages := (totalItems + count - 1) / count // Округление вверх
-
-	// Проверяем, что запрошенная страница существует
-	if totalItems == 0 {
-		sendEmptyPaginatedResponseFull(w, page, count)
-		return
-	}
-
-	// Вычисляем индексы для текущей страницы согласно требованиям
-	// Для page=2, count=5 должны получить элементы с 10 по 15
-	// т.е. для page=2 начинаем с индекса 5*2=10-1=9
-	startIndex := (page - 1) * count
-	endIndex := startIndex + count
-
-	// Проверяем валидность индексов
-	if startIndex >= totalItems {
-		// Запрошенная страница выходит за пределы доступных данных
-		sendEmptyPaginatedResponseFull(w, page, count)
-		return
-	}
-
-	// Обрезаем endIndex, если он выходит за пределы массива
-	if endIndex > totalItems {
-		endIndex = totalItems
-	}
-
-	// Получаем новости для текущей страницы
-	pagedNews := filteredNews[startIndex:endIndex]
-
-	// Конвертируем в полный формат новостей
-	fullNews := make([]FullNewsItem, 0, len(pagedNews))
-	for _, item := range pagedNews {
-		id, ok := item["id"].(float64)
-		if !ok {
-			continue
+			fullNews = append(fullNews, fullNewsItem)
 		}
-
-		fullNewsItem := FullNewsItem{
-			ID:          int64(id),
-			Title:       getStringValue(item, "title"),
-			Description: getStringValue(item, "description"),
-			PubDate:     getStringValue(item, "pub_date"),
-			SourceURL:   getStringValue(item, "source_url"),
-		}
-
-		// Добавляем created_at, если имеется
-		if createdAt, ok := item["created_at"].(string); ok {
-			fullNewsItem.CreatedAt = createdAt
-		}
-
-		fullNews = append(fullNews, fullNewsItem)
-	}
-
-	// Формируем и отправляем ответ с пагинацией
-	response := PaginatedResponse{
-		Items:        fullNews,
-		TotalPages:   totalPages,
-		CurrentPage:  page,
-		ItemsPerPage: count,
-		TotalItems:   totalItems,
-	}
-
-	json.NewEncoder(w).Encode(response)
+		return fullNews
+	})
 }
 
 // handleAddComment обрабатывает запросы на добавление комментария к новости через POST запрос
 func (s *Server) handleAddComment(w http.ResponseWriter, r *http.Request) {
 	// Проверяем, что запрос POST
 	if r.Method != http.MethodPost {
-		http.Error(w, "Метод не разрешен. Используйте POST", http.StatusMethodNotAllowed)
+		writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Метод не разрешен. Используйте POST")
 		return
 	}
 
-	// Устанавливаем тип содержимого JSON для всех ответов
-	w.Header().Set("Content-Type", "application/json")
-
-	// Логируем заголовки запроса для диагностики
-	log.Printf("Получен запрос на добавление комментария. Headers: %v", r.Header)
-
 	// Получение ID новости из URL параметров
 	newsIDStr := r.URL.Query().Get("news_id")
 	if newsIDStr == "" {
 		newsIDStr = r.URL.Query().Get("id")
 	}
-	log.Printf("ID новости из URL параметров: %s", newsIDStr)
 
 	// Проверяем, что newsID это число
 	newsID, err := strconv.ParseInt(newsIDStr, 10, 64)
 	if err != nil || newsIDStr == "" {
-		log.Printf("Некорректный ID новости: '%s', ошибка: %v", newsIDStr, err)
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Некорректный ID новости. Укажите числовой ID в параметре news_id или id."})
+		slog.DebugContext(r.Context(), "Некорректный ID новости", "news_id", newsIDStr)
+		writeError(w, r, http.StatusBadRequest, codeInvalidNewsID, "Некорректный ID новости. Укажите числовой ID в параметре news_id или id.")
 		return
 	}
 
 	// Чтение JSON-данных из тела запроса
 	var requestData struct {
 		Text string `json:"text"`
+		// ParentID - комментарий, на который отвечает пользователь
+		ParentID int64 `json:"parent_id"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
-		log.Printf("Ошибка при чтении JSON: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Неверный формат JSON или отсутствие тела запроса"})
+		slog.DebugContext(r.Context(), "Ошибка при чтении JSON", "error", err)
+		writeError(w, r, http.StatusBadRequest, codeInvalidJSON, "Неверный формат JSON или отсутствие тела запроса")
 		return
 	}
 	defer r.Body.Close()
 
-	// Логируем полученные данные
-	log.Printf("Получен текст комментария: %s", requestData.Text)
-
 	// Проверяем, что комментарий не пустой
 	if requestData.Text == "" {
-		log.Printf("Получен пустой комментарий")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Комментарий не может быть пустым. Укажите текст в поле text."})
+		writeError(w, r, http.StatusBadRequest, codeEmptyComment, "Комментарий не может быть пустым. Укажите текст в поле text.")
 		return
 	}
 
-	// Формируем URL для сервиса комментариев
-	commURL := fmt.Sprintf("%s/api/comm_add_news?id=%d", s.config.Services.Comments.URL, newsID)
-	log.Printf("Отправка запроса на URL: %s", commURL)
-
-	// Пересылаем JSON как есть на сервис комментариев
-	jsonData := map[string]string{"text": requestData.Text}
-	jsonBody, err := json.Marshal(jsonData)
-	if err != nil {
-		log.Printf("Ошибка при создании JSON: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Ошибка при обработке запроса"})
+	if requestData.ParentID < 0 {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "Некорректный ID родительского комментария в поле parent_id")
 		return
 	}
 
-	// Логируем тело запроса
-	log.Printf("Тело запроса: %s", string(jsonBody))
+	// Пользователь из проверенного токена передается сервису как автор комментария
+	userID := s.userIDFrom(r.Context())
 
-	// Создаем новый запрос с JSON-телом
-	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, commURL, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		log.Printf("Ошибка при создании запроса: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Ошибка при создании запроса к сервису комментариев"})
-		return
+	// Комментарий проверяется модерацией до отправки в сервис комментариев
+	moderated := false
+	if s.moderator != nil {
+		reason, err := s.moderator.check(r.Context(), moderationRequest{NewsID: newsID, Text: requestData.Text, UserID: userID})
+		if err != nil {
+			writeError(w, r, http.StatusServiceUnavailable, codeUpstreamUnavailable, "Сервис модерации недоступен")
+			return
+		}
+		if reason != "" {
+			writeErrorDetails(w, r, http.StatusUnprocessableEntity, codeCommentRejected, "Комментарий отклонен модерацией", map[string]string{"reason": reason})
+			return
+		}
+		moderated = true
 	}
 
-	// Устанавливаем заголовок Content-Type для JSON
-	req.Header.Set("Content-Type", "application/json")
+	// Формируем URL для сервиса комментариев
+	commURL := s.commentAddURL(r.Context(), newsID)
 
-	// Получаем request_id из контекста и добавляем в URL
-	if requestID, ok := r.Context().Value(requestIDKey).(string); ok && requestID != "" {
-		q := req.URL.Query()
-		q.Add("request_id", requestID)
-		req.URL.RawQuery = q.Encode()
+	// Пересылаем JSON как есть на сервис комментариев
+	jsonData := map[string]interface{}{"text": requestData.Text}
+	if userID != "" {
+		jsonData["user_id"] = userID
 	}
-
-	// Отправляем запрос
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		log.Printf("Ошибка при добавлении комментария: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Не удалось добавить комментарий: " + err.Error()})
-		return
+	if requestData.ParentID > 0 {
+		jsonData["parent_id"] = requestData.ParentID
 	}
-	defer resp.Body.Close()
-
-	// Проверяем статус ответа
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		respBody, _ := io.ReadAll(resp.Body)
-		log.Printf("Сервис комментариев вернул статус: %d, тело: %s", resp.StatusCode, string(respBody))
-		w.WriteHeader(resp.StatusCode)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Ошибка при добавлении комментария"})
-		return
+	// Отметка модерации сохраняется сервисом и передается клиенту
+	if moderated {
+		jsonData["moderated"] = true
 	}
-
-	// Читаем ответ от сервиса комментариев
-	respBody, err := io.ReadAll(resp.Body)
+	jsonBody, err := json.Marshal(jsonData)
 	if err != nil {
-		log.Printf("Ошибка при чтении ответа: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Ошибка при обработке ответа от сервиса комментариев"})
+		slog.ErrorContext(r.Context(), "Ошибка при создании JSON", "error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обработке запроса")
 		return
 	}
 
-	// Логируем успешный ответ
-	log.Printf("Комментарий успешно добавлен: %s", string(respBody))
+	slog.DebugContext(r.Context(), "Добавление комментария", "news_id", newsID, "body", string(jsonBody))
+
+	s.proxyUpstream(w, r, upstreamCall{
+		method:  http.MethodPost,
+		target:  commURL,
+		body:    jsonBody,
+		message: "Не удалось добавить комментарий",
+		// Если сервис недоступен, комментарий сохраняется в очередь (comment_outbox)
+		unavailable: func(body []byte, contentType string) (interface{}, bool) {
+			return s.outbox.enqueue(r.Context(), queuedComment{NewsID: newsID, Body: body, ContentType: contentType, Text: requestData.Text, UserID: userID})
+		},
+		response: func(resp *http.Response) error {
+			// Проверяем статус ответа
+			if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+				respBody, _ := io.ReadAll(resp.Body)
+				slog.WarnContext(r.Context(), "Сервис комментариев вернул ошибку", "backend", config.ServiceComments, "status", resp.StatusCode, "body", string(respBody))
+				return upstreamStatusError(resp.StatusCode, "Ошибка при добавлении комментария")
+			}
+
+			// Ответ сервиса небольшой и нужен целиком для события comment.created
+			respBody, err := io.ReadAll(resp.Body)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Ошибка при чтении ответа", "backend", config.ServiceComments, "error", err)
+				return &responseError{http.StatusInternalServerError, codeUpstreamError, "Ошибка при обработке ответа от сервиса комментариев"}
+			}
+
+			slog.InfoContext(r.Context(), "Комментарий добавлен", "news_id", newsID, "response", string(respBody))
+
+			event := commentEvent{NewsID: newsID, Text: requestData.Text, UserID: userID}
+			event.RequestID, _ = r.Context().Value(requestIDKey).(string)
+			if json.Valid(respBody) {
+				event.Response = json.RawMessage(respBody)
+			}
+			s.publishEvent(events.TypeCommentCreated, strconv.FormatInt(newsID, 10), event)
+
+			if moderated {
+				respBody = withModeratedFlag(respBody)
+			}
+			resp.StatusCode = http.StatusOK
+			replaceBody(resp, respBody)
+			return nil
+		},
+	})
+}
 
-	// Устанавливаем тип содержимого JSON для ответа
-	w.WriteHeader(http.StatusOK)
-	w.Write(respBody)
+// commentAddURL возвращает адрес добавления комментария к новости newsID
+func (s *Server) commentAddURL(ctx context.Context, newsID int64) string {
+	return fmt.Sprintf("%s/api/comm_add_news?id=%d", s.serviceURL(ctx, config.ServiceComments), newsID)
 }
 
 // handleComments переименован в handleComments для соответствия конвенции других обработчиков
 func (s *Server) handleComments(w http.ResponseWriter, r *http.Request) {
 	// Только GET запросы
 	if r.Method != http.MethodGet {
-		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Метод не разрешен")
 		return
 	}
 
-	// Устанавливаем тип содержимого JSON для всех ответов
-	w.Header().Set("Content-Type", "application/json")
-
 	// Получаем ID новости из параметров запроса
 	newsIDStr := r.URL.Query().Get("id")
 	if newsIDStr == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Не указан ID новости"})
+		writeError(w, r, http.StatusBadRequest, codeInvalidNewsID, "Не указан ID новости")
 		return
 	}
 
 	// Проверяем, что newsID это число
 	newsID, err := strconv.ParseInt(newsIDStr, 10, 64)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Некорректный ID новости"})
+		writeError(w, r, http.StatusBadRequest, codeInvalidNewsID, "Некорректный ID новости")
 		return
 	}
 
-	// Формируем URL для получения комментариев от сервиса комментариев
-	commURL := fmt.Sprintf("%s/api/comm_news?id=%d", s.config.Services.Comments.URL, newsID)
-	log.Printf("Отправка запроса на сервис комментариев: %s", commURL)
-
-	// Отправляем GET запрос к сервису комментариев
-	resp, err := s.makeBackendRequest(http.MethodGet, commURL, r.Context(), nil)
-	if err != nil {
-		log.Printf("Ошибка при получении комментариев: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Не удалось получить комментарии: " + err.Error()})
+	query := r.URL.Query()
+	view := s.commentsView(query)
+	if !validCommentsView(view) {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "Некорректный вид списка комментариев. Используйте view=flat или view=tree.")
 		return
 	}
-	defer resp.Body.Close()
+	tree := view == commentsViewTree
 
-	// Проверяем статус ответа
-	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		log.Printf("Сервис комментариев вернул статус: %d, тело: %s", resp.StatusCode, string(respBody))
-		w.WriteHeader(resp.StatusCode)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Ошибка при получении комментариев"})
+	// С параметрами page, count или sort отправляется страница комментариев
+	if commentsPaginated(query) {
+		sortBy := query.Get("sort")
+		if sortBy != "" && !commentSorts[sortBy] {
+			writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "Некорректный порядок сортировки комментариев")
+			return
+		}
+		s.serveCommentsPage(w, r, newsID, sortBy, positiveParam(query.Get("page"), defaultPage), positiveParam(query.Get("count"), defaultCount), tree)
 		return
 	}
 
-	// Читаем ответ от сервиса комментариев
-	body, err := io.ReadAll(resp.Body)
+	// Формируем URL для получения комментариев от сервиса комментариев
+	commURL := fmt.Sprintf("%s/api/comm_news?id=%d", s.serviceURL(r.Context(), config.ServiceComments), newsID)
+	slog.DebugContext(r.Context(), "Запрос комментариев", "backend", config.ServiceComments, "url", commURL)
+
+	s.proxyUpstream(w, r, upstreamCall{
+		target:  commURL,
+		message: "Не удалось получить комментарии",
+		response: func(resp *http.Response) error {
+			if resp.StatusCode != http.StatusOK {
+				respBody, _ := io.ReadAll(resp.Body)
+				slog.WarnContext(r.Context(), "Сервис комментариев вернул ошибку", "backend", config.ServiceComments, "status", resp.StatusCode, "body", string(respBody))
+				return upstreamStatusError(resp.StatusCode, "Ошибка при получении комментариев")
+			}
+
+			// Дерево комментариев строится по всему списку
+			if tree {
+				var items []json.RawMessage
+				if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+					slog.ErrorContext(r.Context(), "Ошибка при декодировании комментариев", "backend", config.ServiceComments, "error", err)
+					return &responseError{http.StatusInternalServerError, codeUpstreamError, "Ошибка при обработке комментариев"}
+				}
+				threads, err := buildCommentTree(items)
+				if err != nil {
+					slog.ErrorContext(r.Context(), "Ошибка при построении дерева комментариев", "backend", config.ServiceComments, "error", err)
+					return &responseError{http.StatusInternalServerError, codeUpstreamError, "Ошибка при обработке комментариев"}
+				}
+				return replaceJSON(resp, http.StatusOK, threads)
+			}
+
+			// Ответ в формате JSON передаем клиенту по мере чтения, без промежуточного буфера
+			if isJSONContent(resp.Header.Get("Content-Type")) {
+				return nil
+			}
+
+			// Тип ответа не указан - проверяем, что сервис вернул JSON. Ответ
+			// больше streaming.max_buffer передается без проверки.
+			body, complete, err := readUpTo(resp.Body, int64(s.config.Streaming.MaxBuffer))
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Ошибка при чтении ответа", "backend", config.ServiceComments, "error", err)
+				return &responseError{http.StatusInternalServerError, codeUpstreamError, "Ошибка при обработке комментариев"}
+			}
+			if !complete {
+				resp.Body = readCloser{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+				resp.ContentLength = -1
+				return nil
+			}
+			if !json.Valid(body) {
+				slog.ErrorContext(r.Context(), "Сервис вернул некорректный JSON", "backend", config.ServiceComments, "body", string(body))
+				return &responseError{http.StatusInternalServerError, codeUpstreamError, "Ошибка при обработке комментариев"}
+			}
+			replaceBody(resp, body)
+			return nil
+		},
+	})
+}
+
+// serveCommentsPage отправляет страницу комментариев к новости в том же
+// формате, что и страницы новостей. Комментарии передаются в том виде, в
+// котором их вернул сервис; с tree страница состоит из веток комментариев.
+func (s *Server) serveCommentsPage(w http.ResponseWriter, r *http.Request, newsID int64, sortBy string, page, count int, tree bool) {
+	commURL := s.commentsPageURL(r.Context(), newsID, sortBy, page, count)
+	slog.DebugContext(r.Context(), "Запрос комментариев", "backend", config.ServiceComments, "url", commURL)
+
+	s.proxyUpstream(w, r, upstreamCall{
+		target:  commURL,
+		message: "Не удалось получить комментарии",
+		response: func(resp *http.Response) error {
+			if resp.StatusCode != http.StatusOK {
+				respBody, _ := io.ReadAll(resp.Body)
+				slog.WarnContext(r.Context(), "Сервис комментариев вернул ошибку", "backend", config.ServiceComments, "status", resp.StatusCode, "body", string(respBody))
+				return upstreamStatusError(resp.StatusCode, "Ошибка при получении комментариев")
+			}
+
+			items, totalItems, err := s.readCommentsPage(resp, sortBy, page, count, tree)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Ошибка при декодировании комментариев", "backend", config.ServiceComments, "error", err)
+				return &responseError{http.StatusInternalServerError, codeUpstreamError, "Ошибка при обработке комментариев"}
+			}
+			// Для несуществующей страницы отправляется пустой список
+			if len(items) == 0 {
+				return replaceJSON(resp, http.StatusOK, PaginatedResponse{Items: []json.RawMessage{}, CurrentPage: page, ItemsPerPage: count})
+			}
+			return replaceJSON(resp, http.StatusOK, PaginatedResponse{
+				Items:        items,
+				TotalPages:   (totalItems + count - 1) / count,
+				CurrentPage:  page,
+				ItemsPerPage: count,
+				TotalItems:   totalItems,
+			})
+		},
+	})
+}
+
+// serveNewsPage отправляет страницу списка новостей с пагинацией. Поисковые
+// запросы обслуживаются из индекса в памяти, если он построен, остальные -
+// по ответу сервиса новостей. Сервису с пагинацией передаются параметры
+// страницы и поиска, у остальных запрашивается весь список. convert преобразует новости страницы в элементы
+// ответа и для пустой страницы должен вернуть пустой список.
+func (s *Server) serveNewsPage(w http.ResponseWriter, r *http.Request, convert func([]upstreamNews) interface{}) {
+	// Получаем и обрабатываем параметры запроса
+	query := r.URL.Query()
+	order, err := parseNewsSort(query)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "Некорректная сортировка новостей: "+err.Error())
+		return
+	}
+	filter, err := s.parseNewsFilter(r.Context(), query)
 	if err != nil {
-		log.Printf("Ошибка при чтении ответа от сервиса комментариев: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Ошибка при обработке комментариев"})
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "Некорректные параметры отбора новостей: "+err.Error())
 		return
 	}
+	if query.Has("cursor") || query.Has("limit") {
+		s.serveNewsCursor(w, r, filter, order, convert)
+		return
+	}
+	page := positiveParam(query.Get("page"), defaultPage)
+	count := positiveParam(query.Get("count"), defaultCount)
+
+	pageResponse := func(pagedNews []upstreamNews, totalItems int) PaginatedResponse {
+		// Для несуществующей страницы отправляется пустой ответ
+		if totalItems == 0 || len(pagedNews) == 0 {
+			return PaginatedResponse{Items: convert(nil), CurrentPage: page, ItemsPerPage: count}
+		}
+		filter.annotate(pagedNews)
+		return PaginatedResponse{
+			Items:        convert(pagedNews),
+			TotalPages:   (totalItems + count - 1) / count, // Округление вверх
+			CurrentPage:  page,
+			ItemsPerPage: count,
+			TotalItems:   totalItems,
+		}
+	}
 
-	// Проверяем, что ответ от сервиса комментариев является валидным JSON
-	var commResp any
-	if err := json.Unmarshal(body, &commResp); err != nil {
-		log.Printf("Ошибка при разборе JSON: %v, тело: %s", err, string(body))
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Ошибка при обработке комментариев"})
+	// Сервис новостей не сортирует список: шлюз читает его целиком и сортирует сам
+	if order.field != "" && !s.newsSorting(r.Context()) {
+		s.proxyUpstream(w, r, upstreamCall{
+			target:  s.newsListURL(r.Context(), filter, newsSort{}),
+			message: "Не удалось получить новости",
+			response: func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return newsListError(r.Context(), resp, pageResponse)
+				}
+
+				var matched []upstreamNews
+				err := scanNewsList(resp.Body, filter, func(item upstreamNews) bool {
+					matched = append(matched, item)
+					return false
+				})
+				if err != nil {
+					slog.ErrorContext(r.Context(), "Ошибка при декодировании новостей", "backend", config.ServiceNews, "error", err)
+					return &responseError{http.StatusBadGateway, codeUpstreamError, "Ошибка при обработке новостей"}
+				}
+				s.sortNews(matched, order)
+
+				total := len(matched)
+				if page > (total+count-1)/count {
+					return replaceJSON(resp, http.StatusOK, pageResponse(nil, total))
+				}
+				start := (page - 1) * count
+				return replaceJSON(resp, http.StatusOK, pageResponse(matched[start:min(start+count, total)], total))
+			},
+		})
 		return
 	}
 
-	// Передаем ответ в исходном виде клиенту
-	w.WriteHeader(http.StatusOK)
-	w.Write(body)
-}
+	// Индекс поиска хранит новости в порядке сервиса без дат публикации и
+	// ищет подстроку в заголовке, поэтому не подходит для сортировки, отбора
+	// по периоду и расширенного поиска
+	if order.field == "" && !filter.dated() && !filter.extendedSearch() {
+		if pagedNews, totalItems, indexed := s.searchNewsIndex(r.Context(), filter.search, page, count); indexed {
+			w.Header().Set("Content-Type", "application/json")
+			writeJSON(w, pageResponse(pagedNews, totalItems))
+			return
+		}
+	}
 
-// Вспомогательная функция для возврата пустого пагинированного ответа для NewsItem
-func sendEmptyPaginatedResponse(w http.ResponseWriter, page, count int) {
-	response := PaginatedResponse{
-		Items:        []NewsItem{},
-		TotalPages:   0,
-		CurrentPage:  page,
-		ItemsPerPage: count,
-		TotalItems:   0,
+	// Сервис с пагинацией возвращает только запрошенную страницу; если новости
+	// отбирает шлюз, нужен весь список
+	if s.newsPagination(r.Context()) && !filter.fullList() {
+		s.proxyUpstream(w, r, upstreamCall{
+			target:  s.newsPageURL(r.Context(), filter, order, page, count),
+			message: "Не удалось получить новости",
+			response: func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return newsListError(r.Context(), resp, pageResponse)
+				}
+
+				pagedNews, totalItems, err := readNewsPage(resp, filter, page, count)
+				if err != nil {
+					slog.ErrorContext(r.Context(), "Ошибка при декодировании новостей", "backend", config.ServiceNews, "error", err)
+					return &responseError{http.StatusBadGateway, codeUpstreamError, "Ошибка при обработке новостей"}
+				}
+				return replaceJSON(resp, http.StatusOK, pageResponse(pagedNews, totalItems))
+			},
+		})
+		return
 	}
-	json.NewEncoder(w).Encode(response)
+
+	// Формируем URL для сервиса новостей - без указания количества, получим все новости
+	s.proxyUpstream(w, r, upstreamCall{
+		target:  s.newsListURL(r.Context(), filter, order),
+		message: "Не удалось получить новости",
+		response: func(resp *http.Response) error {
+			if resp.StatusCode != http.StatusOK {
+				return newsListError(r.Context(), resp, pageResponse)
+			}
+
+			// Читаем массив новостей потоком: полностью декодируются только новости
+			// запрошенной страницы, остальные лишь учитываются в общем количестве.
+			// Если общее количество известно из кэша, остаток списка не читается.
+			knownTotal, cached := s.cachedNewsTotal(r.Context(), filter)
+			pagedNews, totalItems, err := streamNewsPage(resp.Body, filter, page, count, knownTotal)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Ошибка при декодировании новостей", "backend", config.ServiceNews, "error", err)
+				return &responseError{http.StatusBadGateway, codeUpstreamError, "Ошибка при обработке новостей"}
+			}
+			if !cached {
+				s.rememberNewsTotal(r.Context(), filter, totalItems)
+			}
+			return replaceJSON(resp, http.StatusOK, pageResponse(pagedNews, totalItems))
+		},
+	})
 }
 
-// Вспомогательная функция для возврата пустого пагинированного ответа для FullNewsItem
-func sendEmptyPaginatedResponseFull(w http.ResponseWriter, page, count int) {
-	response := PaginatedResponse{
-		Items:        []FullNewsItem{},
-		TotalPages:   0,
-		CurrentPage:  page,
-		ItemsPerPage: count,
-		TotalItems:   0,
+// newsListError обрабатывает ошибку сервиса новостей при запросе списка.
+// Ошибка 5xx передается клиенту, чтобы cacheMiddleware мог отправить
+// сохраненную копию ответа, а на остальные ошибки (например, 404 для
+// неизвестного раздела) клиент получает пустую страницу.
+func newsListError(ctx context.Context, resp *http.Response, pageResponse func([]upstreamNews, int) PaginatedResponse) error {
+	slog.WarnContext(ctx, "Сервис новостей вернул ошибку", "backend", config.ServiceNews, "status", resp.StatusCode)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return upstreamStatusError(resp.StatusCode, "Ошибка при получении новостей")
 	}
-	json.NewEncoder(w).Encode(response)
+	return replaceJSON(resp, http.StatusOK, pageResponse(nil, 0))
 }
 
-// Вспомогательная функция для безопасного получения строковых значений из карты
-func getStringValue(item map[string]interface{}, key string) string {
-	if value, ok := item[key].(string); ok {
-		return value
+// positiveParam разбирает положительное целое значение параметра запроса.
+// Для отсутствующего или некорректного значения возвращается def.
+func positiveParam(value string, def int) int {
+	if n, err := strconv.Atoi(value); err == nil && n > 0 {
+		return n
 	}
-	return ""
+	return def
 }
 
 // handleNewsWithID обрабатывает запросы на получение новости по её ID
 func (s *Server) handleNewsWithID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Метод не разрешен")
+		return
+	}
+
 	// Получаем ID новости из пути запроса
 	newsIDStr := strings.TrimPrefix(r.URL.Path, "/api/news/")
 	newsID, err := strconv.ParseInt(newsIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Некорректный ID новости", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, codeInvalidNewsID, "Некорректный ID новости")
 		return
 	}
 
-	// Получаем новость с сервиса новостей
-	newsURL := fmt.Sprintf("%s/api/news/%d", s.config.Services.News.URL, newsID)
-	newsResp, err := s.makeBackendRequest(http.MethodGet, newsURL, r.Context(), nil)
-	if err != nil {
-		log.Printf("Ошибка при получении новости: %v", err)
-		http.Error(w, "Не удалось получить новость", http.StatusInternalServerError)
+	// Не обращаемся к сервису новостей, если недавно узнали, что новости нет
+	if s.isNewsMissing(r.Context(), newsID) {
+		writeError(w, r, http.StatusNotFound, codeNewsNotFound, "Новость не найдена")
 		return
 	}
-	defer newsResp.Body.Close()
 
-	// Проверяем статус ответа от сервиса новостей
-	if newsResp.StatusCode != http.StatusOK {
-		log.Printf("Сервис новостей вернул статус: %d", newsResp.StatusCode)
-		http.Error(w, "Новость не найдена", newsResp.StatusCode)
-		return
-	}
+	// Получаем новость с сервиса новостей. Сервис возвращает массив с одним
+	// элементом: читаем только первый элемент и передаем его клиенту как есть,
+	// не разбирая на поля
+	s.proxyUpstream(w, r, upstreamCall{
+		target:  fmt.Sprintf("%s/api/news/%d", s.serviceURL(r.Context(), config.ServiceNews), newsID),
+		message: "Не удалось получить новость",
+		response: func(resp *http.Response) error {
+			// Ответ больше streaming.max_buffer передается клиенту по мере чтения
+			if limit := int64(s.config.Streaming.MaxBuffer); limit > 0 && resp.StatusCode == http.StatusOK {
+				prefix, complete, err := readUpTo(resp.Body, limit)
+				if err != nil {
+					slog.ErrorContext(r.Context(), "Ошибка при чтении ответа", "backend", config.ServiceNews, "news_id", newsID, "error", err)
+					return &responseError{http.StatusInternalServerError, codeUpstreamError, "Ошибка при обработке новости"}
+				}
+				body := io.MultiReader(bytes.NewReader(prefix), resp.Body)
+				if !complete {
+					return s.streamNewsItem(r.Context(), resp, body, newsID)
+				}
+				resp.Body = readCloser{body, resp.Body}
+			}
+
+			newsItem, err := s.readNewsItem(r.Context(), resp, newsID)
+			if err != nil {
+				return err
+			}
+			replaceBody(resp, append(newsItem, '\n'))
+			return nil
+		},
+	})
+}
 
-	// Читаем ответ от сервиса новостей
-	newsBody, err := io.ReadAll(newsResp.Body)
+// streamNewsItem заменяет тело ответа сервиса новостей потоком первого
+// элемента массива body. Элемент не проверяется: ошибка в середине потока
+// обрывает ответ клиенту.
+func (s *Server) streamNewsItem(ctx context.Context, resp *http.Response, body io.Reader, newsID int64) error {
+	item, err := newFirstElementReader(body)
 	if err != nil {
-		log.Printf("Ошибка при чтении ответа: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Ошибка при обработке ответа от сервиса новостей"})
-		return
+		resp.Body.Close()
+		slog.ErrorContext(ctx, "Ошибка при декодировании новости", "backend", config.ServiceNews, "news_id", newsID, "error", err)
+		return &responseError{http.StatusInternalServerError, codeUpstreamError, "Ошибка при обработке новости"}
 	}
-
-	// Декодируем новость - сервис возвращает массив с одним элементом
-	var newsItems []map[string]interface{}
-	if err := json.Unmarshal(newsBody, &newsItems); err != nil {
-		log.Printf("Ошибка при декодировании новости: %v, тело: %s", err, string(newsBody))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Ошибка при обработке новости"})
-		return
+	if item == nil {
+		resp.Body.Close()
+		s.rememberNewsMissing(ctx, newsID)
+		return &responseError{http.StatusNotFound, codeNewsNotFound, "Новость не найдена"}
 	}
+	slog.DebugContext(ctx, "Новость больше streaming.max_buffer передается потоком", "news_id", newsID)
+	resp.Body = readCloser{io.MultiReader(item, strings.NewReader("\n")), resp.Body}
+	resp.ContentLength = -1
+	return nil
+}
 
-	// Проверяем, что в массиве есть хотя бы один элемент
-	if len(newsItems) == 0 {
-		log.Printf("Новость не найдена")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Новость не найдена"})
-		return
+// readNewsItem проверяет ответ сервиса новостей на запрос новости по ID и
+// читает из него новость. Отсутствие новости запоминается в кэше.
+func (s *Server) readNewsItem(ctx context.Context, resp *http.Response, newsID int64) (json.RawMessage, error) {
+	if resp.StatusCode != http.StatusOK {
+		slog.WarnContext(ctx, "Сервис новостей вернул ошибку", "backend", config.ServiceNews, "status", resp.StatusCode, "news_id", newsID)
+		if resp.StatusCode == http.StatusNotFound {
+			s.rememberNewsMissing(ctx, newsID)
+		}
+		return nil, &responseError{resp.StatusCode, newsErrorCode(resp.StatusCode), "Новость не найдена"}
 	}
 
-	// Берем первую новость из массива
-	newsItem := newsItems[0]
-
-	// Отправляем новость клиенту
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(newsItem)
+	newsItem, err := firstArrayElement(resp.Body)
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка при декодировании новости", "backend", config.ServiceNews, "news_id", newsID, "error", err)
+		return nil, &responseError{http.StatusInternalServerError, codeUpstreamError, "Ошибка при обработке новости"}
+	}
+	if newsItem == nil {
+		slog.DebugContext(ctx, "Новость не найдена", "news_id", newsID)
+		s.rememberNewsMissing(ctx, newsID)
+		return nil, &responseError{http.StatusNotFound, codeNewsNotFound, "Новость не найдена"}
+	}
+	return newsItem, nil
 }
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"runtime/debug"
+	"runtime/metrics"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"apigw/pkg/config"
+)
+
+// sheddingHysteresis - насколько потребление должно опуститься ниже порога,
+// чтобы прием запросов возобновился; исключает переключения на каждой проверке
+const sheddingHysteresis = 0.05
+
+// Метрики среды выполнения, по которым считается занятая процессом память
+var memoryMetrics = []string{
+	"/memory/classes/total:bytes",
+	"/memory/classes/heap/released:bytes",
+}
+
+// loadShedder отслеживает потребление памяти и сообщает, когда нужно
+// отклонять запросы с низким приоритетом
+type loadShedder struct {
+	cfg    config.SheddingConfig
+	budget uint64
+	// levels - пороги потребления памяти по возрастанию. Без классов
+	// приоритета порог один - shedding.threshold.
+	levels []*shedLevel
+}
+
+// shedLevel - порог потребления памяти, при превышении которого отклоняются
+// запросы, для которых он задан
+type shedLevel struct {
+	threshold float64
+	active    atomic.Bool
+}
+
+// newLoadShedder создает сброс нагрузки согласно секции shedding. Если заданы
+// классы приоритета qos, запросы каждого класса отклоняются при своем пороге.
+// Возвращает nil, если сброс нагрузки отключен.
+func newLoadShedder(cfg config.SheddingConfig, qos *qosPolicy) (*loadShedder, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.Threshold <= 0 || cfg.Threshold > 1 {
+		return nil, fmt.Errorf("порог сброса нагрузки должен быть в диапазоне (0, 1]: %v", cfg.Threshold)
+	}
+
+	budget := uint64(cfg.MemoryBudgetMB) << 20
+	if cfg.MemoryBudgetMB <= 0 {
+		// Отрицательное значение только возвращает текущий GOMEMLIMIT
+		limit := debug.SetMemoryLimit(-1)
+		if limit == math.MaxInt64 {
+			return nil, fmt.Errorf("для сброса нагрузки задайте memory_budget_mb или переменную окружения GOMEMLIMIT")
+		}
+		budget = uint64(limit)
+	}
+
+	thresholds := []float64{cfg.Threshold}
+	if qos != nil {
+		thresholds = thresholds[:0]
+		for _, class := range qos.classes {
+			if class.shedThreshold > 0 {
+				thresholds = append(thresholds, class.shedThreshold)
+			}
+		}
+		sort.Float64s(thresholds)
+	}
+
+	l := &loadShedder{cfg: cfg, budget: budget}
+	for i, threshold := range thresholds {
+		if i == 0 || threshold != thresholds[i-1] {
+			l.levels = append(l.levels, &shedLevel{threshold: threshold})
+		}
+	}
+	return l, nil
+}
+
+// run периодически проверяет потребление памяти
+func (l *loadShedder) run(ctx context.Context) {
+	interval := l.cfg.CheckInterval.Std()
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	samples := make([]metrics.Sample, len(memoryMetrics))
+	for i, name := range memoryMetrics {
+		samples[i].Name = name
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		l.check(samples)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check сравнивает занятую память с бюджетом и переключает пороги сброса нагрузки
+func (l *loadShedder) check(samples []metrics.Sample) {
+	metrics.Read(samples)
+	used := samples[0].Value.Uint64() - samples[1].Value.Uint64()
+	usage := float64(used) / float64(l.budget)
+
+	for _, level := range l.levels {
+		threshold := level.threshold
+		if level.active.Load() {
+			threshold -= sheddingHysteresis
+		}
+		shed := usage >= threshold
+
+		if level.active.Swap(shed) != shed {
+			if shed {
+				slog.Warn("Не хватает памяти, запросы с низким приоритетом отклоняются", "used_mb", used>>20, "budget_mb", l.budget>>20, "threshold", level.threshold)
+			} else {
+				slog.Info("Прием запросов возобновлен", "used_mb", used>>20, "budget_mb", l.budget>>20, "threshold", level.threshold)
+			}
+		}
+	}
+}
+
+// shouldShed сообщает, что запрос нужно отклонить из-за нехватки памяти. С
+// классами приоритета запрос отклоняется, если превышен порог его класса,
+// иначе - если превышен shedding.threshold и запрос имеет низкий приоритет.
+func (l *loadShedder) shouldShed(r *http.Request) bool {
+	threshold := l.cfg.Threshold
+	if class := qosClassFrom(r.Context()); class != nil {
+		threshold = class.shedThreshold
+	} else if !l.lowPriority(r) {
+		return false
+	}
+
+	for _, level := range l.levels {
+		if level.threshold == threshold {
+			return level.active.Load()
+		}
+	}
+	return false
+}
+
+// lowPriority сообщает, что запрос можно отклонить при нехватке памяти:
+// поисковые запросы и запросы к путям из low_priority_paths
+func (l *loadShedder) lowPriority(r *http.Request) bool {
+	if r.URL.Query().Get("s") != "" {
+		return true
+	}
+	for _, prefix := range l.cfg.LowPriorityPaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// sheddingMiddleware отклоняет запросы с низким приоритетом, пока памяти не хватает
+func (s *Server) sheddingMiddleware(next http.Handler) http.Handler {
+	if s.shedder == nil {
+		return next
+	}
+
+	retryAfter := int(math.Ceil(s.shedder.cfg.CheckInterval.Std().Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.shedder.shouldShed(r) {
+			slog.WarnContext(r.Context(), "Запрос отклонен: не хватает памяти", "method", r.Method, "path", r.URL.Path)
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			writeError(w, r, http.StatusServiceUnavailable, codeOverloaded, "Сервер перегружен, повторите запрос позже")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
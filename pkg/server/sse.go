@@ -0,0 +1,107 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Комментарий SSE, который отправляется клиенту как heartbeat
+var sseHeartbeat = []byte(":\n\n")
+
+// isEventStream проверяет, что ответ является потоком Server-Sent Events
+func isEventStream(h http.Header) bool {
+	return strings.HasPrefix(strings.ToLower(h.Get("Content-Type")), "text/event-stream")
+}
+
+// heartbeatBody - тело потока событий, в которое между событиями
+// вставляются heartbeat-комментарии, пока сервис молчит
+type heartbeatBody struct {
+	*io.PipeReader
+	upstream io.ReadCloser
+}
+
+// Close закрывает поток и соединение с сервисом
+func (b *heartbeatBody) Close() error {
+	b.PipeReader.Close()
+	return b.upstream.Close()
+}
+
+// withHeartbeat возвращает тело потока событий, которое начинается с
+// комментария: так клиент сразу получает заголовки и понимает, что поток
+// открыт. Если heartbeat > 0 и сервис молчит, между событиями передаются
+// комментарии, чтобы промежуточные прокси не закрывали простаивающее соединение.
+func withHeartbeat(body io.ReadCloser, heartbeat time.Duration) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go pumpEvents(pw, body, heartbeat)
+	return &heartbeatBody{PipeReader: pr, upstream: body}
+}
+
+// pumpEvents передает поток событий из body в pw по мере поступления
+func pumpEvents(pw *io.PipeWriter, body io.Reader, heartbeat time.Duration) {
+	chunks := make(chan []byte)
+	readErr := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := body.Read(buf)
+			if n > 0 {
+				select {
+				case chunks <- append([]byte(nil), buf[:n]...):
+				case <-done:
+					return
+				}
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	if _, err := pw.Write(sseHeartbeat); err != nil {
+		return
+	}
+
+	var tick <-chan time.Time
+	if heartbeat > 0 {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	// atBoundary - последние переданные данные завершили событие,
+	// heartbeat можно отправить, не разрывая событие на части
+	atBoundary := true
+	active := false
+
+	for {
+		select {
+		case chunk := <-chunks:
+			if _, err := pw.Write(chunk); err != nil {
+				return
+			}
+			atBoundary = bytes.HasSuffix(chunk, []byte("\n\n")) || bytes.HasSuffix(chunk, []byte("\r\n\r\n"))
+			active = true
+		case <-tick:
+			if !active && atBoundary {
+				if _, err := pw.Write(sseHeartbeat); err != nil {
+					return
+				}
+			}
+			active = false
+		case err := <-readErr:
+			if err != io.EOF {
+				slog.Warn("Поток событий прерван", "error", err)
+			}
+			pw.CloseWithError(err)
+			return
+		}
+	}
+}
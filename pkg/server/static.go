@@ -0,0 +1,123 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"apigw/pkg/config"
+	"apigw/web"
+)
+
+// staticHandler раздает файлы фронтенда из каталога или встроенной файловой системы
+type staticHandler struct {
+	fsys        fs.FS
+	index       string
+	spaFallback bool
+}
+
+// newStaticHandler создает обработчик статических файлов согласно секции static
+func newStaticHandler(cfg config.StaticConfig) (*staticHandler, error) {
+	var fsys fs.FS
+	if cfg.Dir != "" {
+		info, err := os.Stat(cfg.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("каталог статических файлов недоступен: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("%s не является каталогом", cfg.Dir)
+		}
+		fsys = os.DirFS(cfg.Dir)
+	} else {
+		sub, err := fs.Sub(web.Dist, "dist")
+		if err != nil {
+			return nil, err
+		}
+		fsys = sub
+	}
+
+	index := cfg.Index
+	if index == "" {
+		index = "index.html"
+	}
+	return &staticHandler{fsys: fsys, index: index, spaFallback: cfg.SPAFallback}, nil
+}
+
+// ServeHTTP отдает запрошенный файл. Для неизвестных путей вне /api/ при
+// включенном spa_fallback отдается index, чтобы маршрутизацией занималось
+// клиентское приложение.
+func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Неизвестные пути API не должны превращаться в HTML страницу
+	if r.URL.Path == "/api" || strings.HasPrefix(r.URL.Path, "/api/") {
+		writeError(w, r, http.StatusNotFound, codeNotFound, "Маршрут не найден")
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Метод не разрешен")
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+	if name == "" {
+		name = "."
+	}
+
+	if info, err := fs.Stat(h.fsys, name); err == nil {
+		if !info.IsDir() {
+			h.serveFile(w, r, name)
+			return
+		}
+		if indexName := path.Join(name, h.index); h.exists(indexName) {
+			h.serveFile(w, r, indexName)
+			return
+		}
+	}
+
+	if h.spaFallback && h.exists(h.index) {
+		h.serveFile(w, r, h.index)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// exists проверяет, что файл существует и не является каталогом
+func (h *staticHandler) exists(name string) bool {
+	info, err := fs.Stat(h.fsys, name)
+	return err == nil && !info.IsDir()
+}
+
+// serveFile отдает файл с поддержкой условных запросов и Range
+func (h *staticHandler) serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	file, err := h.fsys.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка при чтении статического файла", "file", name, "error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, "Внутренняя ошибка сервера")
+		return
+	}
+
+	// index должен перепроверяться, иначе клиенты не увидят новую версию фронтенда
+	if path.Base(name) == h.index {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		slog.ErrorContext(r.Context(), "Файловая система не поддерживает чтение с произвольной позиции", "file", name)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, "Внутренняя ошибка сервера")
+		return
+	}
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// spillWriter буферизует ответ обработчика для преобразования, пока его
+// размер не превышает limit. Больший ответ передается клиенту без
+// преобразования: накопленная часть отправляется сразу, остальное - по мере
+// записи. limit 0 означает буферизацию без ограничения. Потоки событий
+// (text/event-stream) и соединения, переданные через Hijack (WebSocket),
+// не буферизуются никогда: они передаются клиенту напрямую.
+type spillWriter struct {
+	bufferWriter
+	w     http.ResponseWriter
+	limit int64
+	// spilled - ответ превысил limit или является потоком и передается клиенту напрямую
+	spilled bool
+}
+
+// newSpillWriter создает буфер ответа с ограничением streaming.max_buffer
+func (s *Server) newSpillWriter(w http.ResponseWriter) *spillWriter {
+	return &spillWriter{
+		// Заголовки, уже заданные внешними обработчиками (например, Vary),
+		// сохраняются при копировании буферизованных заголовков в ответ
+		bufferWriter: bufferWriter{header: w.Header().Clone(), status: http.StatusOK},
+		w:            w,
+		limit:        int64(s.config.Streaming.MaxBuffer),
+	}
+}
+
+// Header возвращает заголовки ответа: до превышения limit - буферизованные
+func (sw *spillWriter) Header() http.Header {
+	if sw.spilled {
+		return sw.w.Header()
+	}
+	return sw.header
+}
+
+// Write буферизует тело ответа, а при превышении limit передает его клиенту
+func (sw *spillWriter) Write(p []byte) (int, error) {
+	if !sw.spilled && isEventStream(sw.header) {
+		if err := sw.spill(); err != nil {
+			return 0, err
+		}
+	}
+	if sw.spilled {
+		return sw.w.Write(p)
+	}
+	if sw.limit <= 0 || int64(sw.body.Len()+len(p)) <= sw.limit {
+		return sw.body.Write(p)
+	}
+
+	if err := sw.spill(); err != nil {
+		return 0, err
+	}
+	return sw.w.Write(p)
+}
+
+// spill отправляет клиенту заголовки и накопленную часть ответа и переводит
+// запись ответа напрямую клиенту
+func (sw *spillWriter) spill() error {
+	sw.spilled = true
+	for name, values := range sw.header {
+		sw.w.Header()[name] = values
+	}
+	sw.w.WriteHeader(sw.status)
+	if _, err := sw.w.Write(sw.body.Bytes()); err != nil {
+		return err
+	}
+	sw.body = bytes.Buffer{}
+	return nil
+}
+
+// WriteHeader запоминает статус-код ответа. Заголовки потока событий
+// отправляются клиенту сразу.
+func (sw *spillWriter) WriteHeader(code int) {
+	if sw.spilled {
+		return
+	}
+	sw.status = code
+	if isEventStream(sw.header) {
+		sw.spill()
+	}
+}
+
+// Flush отправляет клиенту данные ответа, который уже передается напрямую.
+// Буферизованный ответ отправляется целиком после преобразования.
+func (sw *spillWriter) Flush() {
+	if !sw.spilled {
+		return
+	}
+	if flusher, ok := sw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack передает соединение обработчику (WebSocket); ответ после этого
+// считается переданным клиенту напрямую
+func (sw *spillWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := sw.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("ResponseWriter не поддерживает Hijack")
+	}
+	sw.spilled = true
+	return hijacker.Hijack()
+}
+
+// readUpTo читает из r не больше limit байт (0 - без ограничения). complete
+// сообщает, что r прочитан до конца; иначе прочитанные данные - только
+// начало потока.
+func readUpTo(r io.Reader, limit int64) (data []byte, complete bool, err error) {
+	if limit <= 0 {
+		data, err = io.ReadAll(r)
+		return data, err == nil, err
+	}
+	data, err = io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(data)) > limit {
+		return data, false, nil
+	}
+	return data, true, nil
+}
+
+// firstElementReader передает первый элемент JSON массива из потока, не
+// читая массив в память. Элемент должен быть объектом или массивом; его
+// содержимое не проверяется, остаток массива не читается.
+type firstElementReader struct {
+	r        *bufio.Reader
+	depth    int
+	inString bool
+	escaped  bool
+	done     bool
+}
+
+// newFirstElementReader пропускает начало массива в r. Возвращает nil, если
+// массив пустой.
+func newFirstElementReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	c, err := skipJSONSpace(br)
+	if err != nil {
+		return nil, err
+	}
+	if c != '[' {
+		return nil, fmt.Errorf("ожидается массив")
+	}
+
+	c, err = skipJSONSpace(br)
+	if err != nil {
+		return nil, err
+	}
+	switch c {
+	case ']':
+		return nil, nil
+	case '{', '[':
+		br.UnreadByte()
+		return &firstElementReader{r: br}, nil
+	}
+	return nil, fmt.Errorf("ожидается объект")
+}
+
+// skipJSONSpace возвращает первый символ после пробелов JSON
+func skipJSONSpace(br *bufio.Reader) (byte, error) {
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			return c, nil
+		}
+	}
+}
+
+// Read передает очередную часть элемента и останавливается на его конце
+func (e *firstElementReader) Read(p []byte) (int, error) {
+	if e.done {
+		return 0, io.EOF
+	}
+	n, err := e.r.Read(p)
+	for i := 0; i < n; i++ {
+		c := p[i]
+		switch {
+		case e.inString:
+			switch {
+			case e.escaped:
+				e.escaped = false
+			case c == '\\':
+				e.escaped = true
+			case c == '"':
+				e.inString = false
+			}
+		case c == '"':
+			e.inString = true
+		case c == '{' || c == '[':
+			e.depth++
+		case c == '}' || c == ']':
+			e.depth--
+			if e.depth == 0 {
+				e.done = true
+				return i + 1, nil
+			}
+		}
+	}
+	if err == io.EOF {
+		// Поток закончился раньше элемента
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
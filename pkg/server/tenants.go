@@ -0,0 +1,304 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"apigw/pkg/cache"
+	"apigw/pkg/config"
+	"apigw/pkg/limit"
+)
+
+// Ключ контекста для хранения арендатора запроса
+const tenantKey contextKey = "tenant"
+
+// errUnknownTenant возвращается, если запрос не удалось отнести ни к одному арендатору
+var errUnknownTenant = errors.New("арендатор не определен")
+
+// tenantRateLimitError возвращается, если арендатор превысил лимит запросов
+type tenantRateLimitError struct {
+	tenant string
+	wait   time.Duration
+}
+
+func (e *tenantRateLimitError) Error() string {
+	return fmt.Sprintf("арендатор %s превысил лимит запросов", e.tenant)
+}
+
+// retryAfter возвращает значение заголовка Retry-After в секундах
+func (e *tenantRateLimitError) retryAfter() string {
+	return strconv.Itoa(int(math.Ceil(e.wait.Seconds())))
+}
+
+// tenant - арендатор: портал со своими backend-сервисами, лимитом запросов и разделом кэша
+type tenant struct {
+	name     string
+	config   config.TenantConfig
+	services config.ServicesConfig
+	// store и tags - раздел кэша арендатора
+	store cache.Cache
+	tags  *cache.Tags
+	// limiter - ограничение частоты запросов, nil если не задано
+	limiter *limit.Rate
+}
+
+// tenantRegistry определяет арендатора запроса. nil означает, что арендаторы не настроены.
+type tenantRegistry struct {
+	identifyBy []string
+	header     string
+	byName     map[string]*tenant
+	byAPIKey   map[string]*tenant
+	byHost     map[string]*tenant
+	// fallback - арендатор запросов, которые не удалось определить, nil - такие запросы отклоняются
+	fallback *tenant
+}
+
+// newTenantRegistry проверяет настройки арендаторов и создает их разделы в
+// хранилище кэша store. Лимиты запросов арендаторов из prev сохраняются,
+// если их настройки не изменились.
+func newTenantRegistry(cfg *config.Config, store cache.Cache, prev *tenantRegistry) (*tenantRegistry, error) {
+	tc := cfg.Tenants
+	if len(tc.List) == 0 {
+		return nil, nil
+	}
+
+	for _, method := range tc.IdentifyBy {
+		switch method {
+		case "api_key", "host":
+		case "header":
+			if tc.Header == "" {
+				return nil, fmt.Errorf("не указан заголовок арендатора (tenants.header)")
+			}
+		default:
+			return nil, fmt.Errorf("неизвестный способ определения арендатора: %q", method)
+		}
+	}
+
+	registry := &tenantRegistry{
+		identifyBy: tc.IdentifyBy,
+		header:     tc.Header,
+		byName:     make(map[string]*tenant),
+		byAPIKey:   make(map[string]*tenant),
+		byHost:     make(map[string]*tenant),
+	}
+
+	for name, tenantCfg := range tc.List {
+		if name == "" || strings.ContainsAny(name, ": ") {
+			return nil, fmt.Errorf("некорректное имя арендатора: %q", name)
+		}
+
+		services := make(config.ServicesConfig, len(cfg.Services))
+		for service, serviceCfg := range cfg.Services {
+			services[service] = serviceCfg
+		}
+		for service, serviceCfg := range tenantCfg.Services {
+			services[service] = serviceCfg
+		}
+		if err := checkServices(services); err != nil {
+			return nil, fmt.Errorf("арендатор %s: %w", name, err)
+		}
+
+		if tenantCfg.RateLimit.RequestsPerSecond < 0 || tenantCfg.RateLimit.Burst < 0 {
+			return nil, fmt.Errorf("некорректный лимит запросов арендатора %s", name)
+		}
+
+		// Префикс отделяет ключи и версии тегов кэша арендатора от остальных
+		store := cache.NewPrefixed(store, "tenant:"+name+":")
+		t := &tenant{
+			name:     name,
+			config:   tenantCfg,
+			services: services,
+			store:    store,
+			tags:     cache.NewTags(store),
+		}
+
+		// Накопленные жетоны лимита не сбрасываются при перезагрузке конфигурации
+		if old := prev.lookup(name); old != nil && reflect.DeepEqual(old.config.RateLimit, tenantCfg.RateLimit) {
+			t.limiter = old.limiter
+		} else if tenantCfg.RateLimit.RequestsPerSecond > 0 {
+			t.limiter = limit.NewRate(tenantCfg.RateLimit.RequestsPerSecond, tenantCfg.RateLimit.Burst)
+		}
+		registry.byName[name] = t
+
+		for _, key := range tenantCfg.APIKeys {
+			if other, exists := registry.byAPIKey[key]; exists {
+				return nil, fmt.Errorf("ключ API арендатора %s уже используется арендатором %s", name, other.name)
+			}
+			registry.byAPIKey[key] = t
+		}
+		for _, host := range tenantCfg.Hosts {
+			host = strings.ToLower(host)
+			if other, exists := registry.byHost[host]; exists {
+				return nil, fmt.Errorf("хост %s арендатора %s уже используется арендатором %s", host, name, other.name)
+			}
+			registry.byHost[host] = t
+		}
+	}
+
+	if tc.Default != "" {
+		registry.fallback = registry.byName[tc.Default]
+		if registry.fallback == nil {
+			return nil, fmt.Errorf("арендатор по умолчанию %q отсутствует в tenants.list", tc.Default)
+		}
+	}
+	return registry, nil
+}
+
+// lookup возвращает арендатора по имени
+func (r *tenantRegistry) lookup(name string) *tenant {
+	if r == nil {
+		return nil
+	}
+	return r.byName[name]
+}
+
+// identify определяет арендатора по заголовкам запроса. Явно переданные
+// неизвестные ключ API или имя арендатора считаются ошибкой, а не
+// найденный хост - нет: такие запросы получает арендатор по умолчанию.
+func (r *tenantRegistry) identify(header http.Header, host string) (*tenant, error) {
+	for _, method := range r.identifyBy {
+		switch method {
+		case "api_key":
+			if key := header.Get("X-API-Key"); key != "" {
+				if t := r.byAPIKey[key]; t != nil {
+					return t, nil
+				}
+				return nil, errUnknownTenant
+			}
+		case "header":
+			if name := header.Get(r.header); name != "" {
+				if t := r.byName[name]; t != nil {
+					return t, nil
+				}
+				return nil, errUnknownTenant
+			}
+		case "host":
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			if t := r.byHost[strings.ToLower(host)]; t != nil {
+				return t, nil
+			}
+		}
+	}
+	if r.fallback != nil {
+		return r.fallback, nil
+	}
+	return nil, errUnknownTenant
+}
+
+// admit определяет арендатора запроса и проверяет его лимит запросов
+func (r *tenantRegistry) admit(header http.Header, host string) (*tenant, error) {
+	t, err := r.identify(header, host)
+	if err != nil {
+		return nil, err
+	}
+	if t.limiter != nil {
+		if ok, wait := t.limiter.Allow(); !ok {
+			return nil, &tenantRateLimitError{tenant: t.name, wait: wait}
+		}
+	}
+	return t, nil
+}
+
+// tenantScoped проверяет, относится ли путь к API, которое обслуживается
+// отдельно для каждого арендатора. Документация, фронтенд и маршруты из
+// конфигурации общие для всех.
+func tenantScoped(path string) bool {
+	return path == "/api" || strings.HasPrefix(path, "/api/") || path == "/rpc" || path == "/graphql"
+}
+
+// tenantMiddleware определяет арендатора запроса, применяет его лимит
+// запросов и передает арендатора обработчикам через контекст
+func (s *Server) tenantMiddleware(next http.Handler) http.Handler {
+	if s.tenants == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !tenantScoped(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		t, err := s.tenants.admit(r.Header, r.Host)
+		var rateErr *tenantRateLimitError
+		switch {
+		case errors.As(err, &rateErr):
+			slog.WarnContext(r.Context(), "Запрос отклонен: превышен лимит запросов арендатора", "method", r.Method, "path", r.URL.Path, "error", err)
+			w.Header().Set("Retry-After", rateErr.retryAfter())
+			writeError(w, r, http.StatusTooManyRequests, codeRateLimited, "Превышен лимит запросов, повторите запрос позже")
+			return
+		case err != nil:
+			slog.WarnContext(r.Context(), "Запрос отклонен: не удалось определить арендатора", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, r, http.StatusForbidden, codeUnknownTenant, "Не удалось определить арендатора")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(withTenant(r.Context(), t)))
+	})
+}
+
+// withTenant возвращает контекст с арендатором t
+func withTenant(ctx context.Context, t *tenant) context.Context {
+	if t == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, tenantKey, t)
+}
+
+// tenantFrom возвращает арендатора запроса или nil, если арендаторы не настроены
+func tenantFrom(ctx context.Context) *tenant {
+	t, _ := ctx.Value(tenantKey).(*tenant)
+	return t
+}
+
+// services возвращает backend-сервисы арендатора запроса с версиями,
+// выбранными для запроса правилами canary
+func (s *Server) services(ctx context.Context) config.ServicesConfig {
+	services := s.config.Services
+	if t := tenantFrom(ctx); t != nil {
+		services = t.services
+	}
+	chosen, _ := ctx.Value(canaryKey).(map[string]string)
+	if len(chosen) == 0 {
+		return services
+	}
+
+	result := make(config.ServicesConfig, len(services))
+	for name, service := range services {
+		result[name] = service
+	}
+	for name, target := range chosen {
+		// У арендатора может не быть новой версии сервиса
+		if service, ok := services[target]; ok {
+			result[name] = service
+		}
+	}
+	return result
+}
+
+// cacheStore возвращает раздел кэша арендатора запроса
+func (s *Server) cacheStore(ctx context.Context) cache.Cache {
+	if t := tenantFrom(ctx); t != nil {
+		return t.store
+	}
+	return s.store
+}
+
+// cacheTagVersions возвращает теги кэша в разделе арендатора запроса
+func (s *Server) cacheTagVersions(ctx context.Context) *cache.Tags {
+	if t := tenantFrom(ctx); t != nil {
+		return t.tags
+	}
+	return s.tags
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"encoding/json"
+	"net/url"
+)
+
+// Виды списка комментариев (comments.view и параметр view)
+const (
+	commentsViewFlat = "flat"
+	commentsViewTree = "tree"
+)
+
+// commentRepliesField - поле комментария со списком ответов в виде дерева
+const commentRepliesField = "replies"
+
+// validCommentsView проверяет вид списка комментариев
+func validCommentsView(view string) bool {
+	return view == commentsViewFlat || view == commentsViewTree
+}
+
+// commentsView возвращает вид списка комментариев из параметра view или из
+// comments.view, если параметр не указан
+func (s *Server) commentsView(query url.Values) string {
+	if query.Has("view") {
+		return query.Get("view")
+	}
+	if s.config.Comments.View == "" {
+		return commentsViewFlat
+	}
+	return s.config.Comments.View
+}
+
+// commentNode - комментарий при построении дерева
+type commentNode struct {
+	fields   map[string]json.RawMessage
+	id       int64
+	parentID int64
+	replies  []*commentNode
+	visited  bool
+}
+
+// buildCommentTree вкладывает ответы в родительские комментарии по полю
+// parent_id; каждый комментарий получает поле replies. Порядок комментариев
+// одного уровня сохраняется. Комментарии без parent_id, а также ответы на
+// комментарии, которых нет в списке, остаются на верхнем уровне.
+func buildCommentTree(items []json.RawMessage) ([]json.RawMessage, error) {
+	nodes := make([]*commentNode, len(items))
+	byID := make(map[int64]*commentNode, len(items))
+	for i, item := range items {
+		var comment struct {
+			ID       int64  `json:"id"`
+			ParentID *int64 `json:"parent_id"`
+		}
+		if err := json.Unmarshal(item, &comment); err != nil {
+			return nil, err
+		}
+		node := &commentNode{id: comment.ID}
+		if err := json.Unmarshal(item, &node.fields); err != nil {
+			return nil, err
+		}
+		if comment.ParentID != nil {
+			node.parentID = *comment.ParentID
+		}
+		nodes[i] = node
+		if _, ok := byID[node.id]; !ok {
+			byID[node.id] = node
+		}
+	}
+
+	var roots []*commentNode
+	for _, node := range nodes {
+		parent, ok := byID[node.parentID]
+		if node.parentID == 0 || !ok || parent == node {
+			roots = append(roots, node)
+			continue
+		}
+		parent.replies = append(parent.replies, node)
+	}
+
+	tree := make([]json.RawMessage, 0, len(roots))
+	for _, root := range roots {
+		item, err := root.marshal()
+		if err != nil {
+			return nil, err
+		}
+		tree = append(tree, item)
+	}
+	// Комментарии, ссылающиеся друг на друга по кругу, не достижимы от
+	// верхнего уровня и выводятся на нем, чтобы не пропасть из ответа
+	for _, node := range nodes {
+		if node.visited {
+			continue
+		}
+		item, err := node.marshal()
+		if err != nil {
+			return nil, err
+		}
+		tree = append(tree, item)
+	}
+	return tree, nil
+}
+
+// marshal кодирует комментарий вместе с вложенными ответами
+func (n *commentNode) marshal() (json.RawMessage, error) {
+	n.visited = true
+	replies := make([]json.RawMessage, 0, len(n.replies))
+	for _, reply := range n.replies {
+		if reply.visited {
+			continue
+		}
+		item, err := reply.marshal()
+		if err != nil {
+			return nil, err
+		}
+		replies = append(replies, item)
+	}
+
+	encoded, err := json.Marshal(replies)
+	if err != nil {
+		return nil, err
+	}
+	n.fields[commentRepliesField] = encoded
+	return json.Marshal(n.fields)
+}
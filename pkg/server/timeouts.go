@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"apigw/pkg/config"
+)
+
+// routeTimeout - ограничение времени обработки запросов к маршруту
+type routeTimeout struct {
+	path    string
+	method  string
+	timeout time.Duration
+}
+
+// requestTimeouts - ограничения времени обработки запросов из секции timeouts
+type requestTimeouts struct {
+	routes []routeTimeout
+	// fallback - ограничение для остальных запросов, 0 - без ограничения
+	fallback time.Duration
+}
+
+// newRequestTimeouts проверяет секцию timeouts. Возвращает nil, если
+// ограничения не заданы.
+func newRequestTimeouts(cfg config.TimeoutsConfig) (*requestTimeouts, error) {
+	if cfg.Default < 0 {
+		return nil, fmt.Errorf("некорректное время обработки запроса timeouts.default: %s", cfg.Default.Std())
+	}
+	t := &requestTimeouts{fallback: cfg.Default.Std()}
+	for _, rc := range cfg.Routes {
+		if !strings.HasPrefix(rc.Path, "/") {
+			return nil, fmt.Errorf("путь ограничения времени обработки должен начинаться с /: %q", rc.Path)
+		}
+		if rc.Timeout < 0 {
+			return nil, fmt.Errorf("некорректное время обработки запросов к %s: %s", rc.Path, rc.Timeout.Std())
+		}
+		t.routes = append(t.routes, routeTimeout{path: rc.Path, method: strings.ToUpper(rc.Method), timeout: rc.Timeout.Std()})
+	}
+	if t.fallback == 0 && len(t.routes) == 0 {
+		return nil, nil
+	}
+	return t, nil
+}
+
+// timeout возвращает ограничение времени обработки запроса, 0 - без ограничения
+func (t *requestTimeouts) timeout(r *http.Request) time.Duration {
+	for _, rt := range t.routes {
+		if (rt.method == "" || rt.method == r.Method) && pathMatches(rt.path, r.URL.Path) {
+			return rt.timeout
+		}
+	}
+	// Поток событий не завершается сам, общее ограничение к нему не применяется
+	if r.URL.Path == newsStreamPath {
+		return 0
+	}
+	return t.fallback
+}
+
+// timeoutMiddleware ограничивает время обработки запроса: по истечении
+// времени запросы к backend-сервисам прерываются, и клиент получает ответ
+// 504 с кодом timeout
+func (s *Server) timeoutMiddleware(next http.Handler) http.Handler {
+	if s.timeouts == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := s.timeouts.timeout(r)
+		if timeout == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// newServiceTimeouts проверяет поле timeout сервисов и возвращает таймауты
+// запросов по адресу сервиса. Сервисы без таймаута в результат не входят.
+func newServiceTimeouts(cfg *config.Config) (map[string]time.Duration, error) {
+	settings, err := upstreamSettings(cfg, "таймаута", func(service config.ServiceConfig) config.Duration {
+		return service.Timeout
+	})
+	if err != nil {
+		return nil, err
+	}
+	timeouts := make(map[string]time.Duration)
+	for upstream, timeout := range settings {
+		if timeout < 0 {
+			return nil, fmt.Errorf("некорректный таймаут сервиса %s: %s", upstream, timeout.Std())
+		}
+		if timeout > 0 {
+			timeouts[upstream] = timeout.Std()
+		}
+	}
+	return timeouts, nil
+}
+
+// withServiceTimeout ограничивает время запроса к backend-сервису таймаутом
+// сервиса. Функцию finish нужно вызвать с результатом запроса: при успехе
+// таймаут действует до закрытия тела ответа.
+func withServiceTimeout(timeouts map[string]time.Duration, req *http.Request) (*http.Request, func(*http.Response, error)) {
+	timeout := timeouts[upstreamName(req.URL)]
+	if timeout == 0 {
+		return req, func(*http.Response, error) {}
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	return req.WithContext(ctx), func(resp *http.Response, err error) {
+		if err != nil || resp == nil {
+			cancel()
+			return
+		}
+		resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	}
+}
+
+// cancelOnClose отменяет контекст запроса после закрытия тела ответа
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close закрывает тело ответа и отменяет контекст запроса
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
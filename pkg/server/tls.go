@@ -0,0 +1,83 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"apigw/pkg/config"
+)
+
+// tlsVersions - допустимые значения server.tls.min_version
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newTLSConfig проверяет настройки HTTPS и загружает сертификат.
+// Возвращает nil, если HTTPS не настроен.
+func newTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, fmt.Errorf("для HTTPS нужно указать server.tls.cert_file и server.tls.key_file")
+	}
+
+	minVersion, ok := tlsVersions[cfg.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("неподдерживаемая версия TLS server.tls.min_version: %q", cfg.MinVersion)
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось загрузить сертификат: %w", err)
+	}
+
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   minVersion,
+	}
+	if len(cfg.CipherSuites) > 0 {
+		// Небезопасные наборы шифров намеренно не принимаются
+		byName := make(map[string]uint16)
+		for _, suite := range tls.CipherSuites() {
+			byName[suite.Name] = suite.ID
+		}
+		for _, name := range cfg.CipherSuites {
+			id, ok := byName[name]
+			if !ok {
+				return nil, fmt.Errorf("неизвестный или небезопасный набор шифров: %q", name)
+			}
+			tlsCfg.CipherSuites = append(tlsCfg.CipherSuites, id)
+		}
+	}
+	return tlsCfg, nil
+}
+
+// httpsRedirect перенаправляет запросы по HTTP на тот же адрес по HTTPS.
+// Статус 308 сохраняет метод и тело запроса.
+func httpsRedirect(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// serveRedirect принимает запросы по HTTP на порту port и перенаправляет их на HTTPS
+func (s *Server) serveRedirect(port int) error {
+	slog.Info("Запросы по HTTP перенаправляются на HTTPS", "port", port)
+	return http.ListenAndServe(fmt.Sprintf(":%d", port), httpsRedirect(s.config.Server.Port))
+}
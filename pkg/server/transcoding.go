@@ -0,0 +1,562 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"apigw/pkg/config"
+)
+
+// Декодер тела запроса: клиенты шлюза могут передавать поля, которых нет в сообщении
+var transcodingUnmarshal = protojson.UnmarshalOptions{DiscardUnknown: true}
+
+// Заголовки запроса, которые не передаются gRPC сервису как метаданные:
+// их задает сам gRPC или они относятся к HTTP соединению
+var skippedMetadata = map[string]bool{
+	"Accept-Encoding":   true,
+	"Connection":        true,
+	"Content-Length":    true,
+	"Content-Type":      true,
+	"Host":              true,
+	"Keep-Alive":        true,
+	"Proxy-Connection":  true,
+	"Te":                true,
+	"Trailer":           true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+	"User-Agent":        true,
+}
+
+// grpcPathParam - сегмент пути, который заполняет поле сообщения запроса
+type grpcPathParam struct {
+	index int
+	field protoreflect.FieldDescriptor
+}
+
+// grpcRule - правило перекодирования запроса в вызов метода gRPC
+type grpcRule struct {
+	method   string
+	segments []string
+	params   []grpcPathParam
+	// query - поля сообщения для параметров запроса с другими именами
+	query map[string]protoreflect.FieldDescriptor
+	rpc   protoreflect.MethodDescriptor
+	// name - имя метода для вызова: /пакет.Сервис/Метод
+	name string
+	// response - поле ответа, которое передается вместо всего ответа, nil - весь ответ
+	response  protoreflect.FieldDescriptor
+	wrapArray bool
+}
+
+// grpcTranscoder - транспорт gRPC backend-сервиса: находит правило по методу
+// и пути HTTP запроса, вызывает метод gRPC и возвращает ответ как HTTP ответ
+// с JSON телом
+type grpcTranscoder struct {
+	upstream string
+	conn     *grpc.ClientConn
+	rules    []*grpcRule
+
+	// calls - выполняющиеся вызовы; после CloseIdleConnections соединение
+	// закрывается, когда они завершатся
+	mu      sync.Mutex
+	calls   int
+	closing bool
+}
+
+// newGRPCTranscoder загружает описание методов из файла дескрипторов,
+// проверяет правила и создает соединение с сервисом по адресу base.
+// Соединение устанавливается при первом вызове.
+func newGRPCTranscoder(base *url.URL, cfg *config.GRPCConfig) (*grpcTranscoder, error) {
+	files, err := loadDescriptors(cfg.Descriptors)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Methods) == 0 {
+		return nil, fmt.Errorf("не заданы методы в секции grpc.methods")
+	}
+
+	t := &grpcTranscoder{upstream: upstreamName(base)}
+	for _, mc := range cfg.Methods {
+		rule, err := newGRPCRule(files, mc)
+		if err != nil {
+			return nil, fmt.Errorf("правило %s %s: %w", mc.Method, mc.Path, err)
+		}
+		t.rules = append(t.rules, rule)
+	}
+
+	var creds credentials.TransportCredentials
+	switch base.Scheme {
+	case "http":
+		creds = insecure.NewCredentials()
+	case "https":
+		creds = credentials.NewTLS(&tls.Config{ServerName: base.Hostname()})
+	default:
+		return nil, fmt.Errorf("адрес gRPC сервиса должен иметь схему http или https: %s", t.upstream)
+	}
+	t.conn, err = grpc.NewClient(base.Host, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать соединение: %w", err)
+	}
+	return t, nil
+}
+
+// loadDescriptors читает файл FileDescriptorSet
+func loadDescriptors(path string) (*protoregistry.Files, error) {
+	if path == "" {
+		return nil, fmt.Errorf("не задан файл дескрипторов grpc.descriptors")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл дескрипторов: %w", err)
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("некорректный файл дескрипторов %s: %w", path, err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный файл дескрипторов %s: %w", path, err)
+	}
+	return files, nil
+}
+
+// newGRPCRule находит метод правила в дескрипторах и проверяет поля шаблона пути и ответа
+func newGRPCRule(files *protoregistry.Files, cfg config.GRPCMethodConfig) (*grpcRule, error) {
+	if !strings.HasPrefix(cfg.Path, "/") {
+		return nil, fmt.Errorf("путь должен начинаться с /")
+	}
+	serviceName, methodName, ok := strings.Cut(cfg.RPC, "/")
+	if !ok {
+		return nil, fmt.Errorf("метод gRPC нужно указать как пакет.Сервис/Метод: %q", cfg.RPC)
+	}
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil, fmt.Errorf("сервис %s не найден в дескрипторах", serviceName)
+	}
+	service, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s не является сервисом", serviceName)
+	}
+	rpc := service.Methods().ByName(protoreflect.Name(methodName))
+	if rpc == nil {
+		return nil, fmt.Errorf("метод %s не найден в сервисе %s", methodName, serviceName)
+	}
+	if rpc.IsStreamingClient() || rpc.IsStreamingServer() {
+		return nil, fmt.Errorf("потоковый метод %s не поддерживается", cfg.RPC)
+	}
+
+	rule := &grpcRule{
+		method:    strings.ToUpper(cfg.Method),
+		segments:  strings.Split(cfg.Path, "/"),
+		rpc:       rpc,
+		name:      "/" + serviceName + "/" + methodName,
+		wrapArray: cfg.WrapArray,
+	}
+	for i, segment := range rule.segments {
+		if !isPathParam(segment) {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(segment, "{"), "}")
+		field := rpc.Input().Fields().ByName(protoreflect.Name(name))
+		if field == nil || field.Message() != nil || field.IsList() || field.IsMap() {
+			return nil, fmt.Errorf("в запросе %s нет скалярного поля %s", rpc.Input().FullName(), name)
+		}
+		rule.params = append(rule.params, grpcPathParam{index: i, field: field})
+	}
+	for param, name := range cfg.Params {
+		field := rpc.Input().Fields().ByName(protoreflect.Name(name))
+		if field == nil || field.Message() != nil || field.IsMap() {
+			return nil, fmt.Errorf("в запросе %s нет скалярного поля %s для параметра %s", rpc.Input().FullName(), name, param)
+		}
+		if rule.query == nil {
+			rule.query = make(map[string]protoreflect.FieldDescriptor)
+		}
+		rule.query[param] = field
+	}
+	if cfg.Response != "" {
+		field := rpc.Output().Fields().ByName(protoreflect.Name(cfg.Response))
+		if field == nil || field.IsMap() {
+			return nil, fmt.Errorf("в ответе %s нет поля %s", rpc.Output().FullName(), cfg.Response)
+		}
+		rule.response = field
+	}
+	return rule, nil
+}
+
+// isPathParam проверяет, что сегмент шаблона пути заполняет поле: {поле}
+func isPathParam(segment string) bool {
+	return len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// match проверяет, что запрос подходит под правило, и возвращает сегменты пути
+func (r *grpcRule) match(req *http.Request) ([]string, bool) {
+	if r.method != "" && r.method != req.Method {
+		return nil, false
+	}
+	segments := strings.Split(req.URL.Path, "/")
+	if len(segments) != len(r.segments) {
+		return nil, false
+	}
+	for i, segment := range r.segments {
+		if isPathParam(segment) {
+			if segments[i] == "" {
+				return nil, false
+			}
+			continue
+		}
+		if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return segments, true
+}
+
+// request заполняет сообщение запроса из тела JSON, параметров запроса и
+// сегментов пути; при совпадении имен значение из пути важнее
+func (r *grpcRule) request(req *http.Request, segments []string) (*dynamicpb.Message, error) {
+	msg := dynamicpb.NewMessage(r.rpc.Input())
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(body)) > 0 {
+			if err := transcodingUnmarshal.Unmarshal(body, msg); err != nil {
+				return nil, fmt.Errorf("некорректное тело запроса: %w", err)
+			}
+		}
+	}
+
+	fields := r.rpc.Input().Fields()
+	for name, values := range req.URL.Query() {
+		field := r.query[name]
+		if field == nil {
+			field = fields.ByName(protoreflect.Name(name))
+		}
+		if field == nil {
+			field = fields.ByJSONName(name)
+		}
+		// Параметры, которым нет поля, сервис не принимает
+		if field == nil || field.Message() != nil || field.IsMap() {
+			continue
+		}
+		if !field.IsList() {
+			values = values[:1]
+		}
+		for _, value := range values {
+			v, err := scalarValue(field, value)
+			if err != nil {
+				return nil, fmt.Errorf("некорректное значение параметра %s: %w", name, err)
+			}
+			if field.IsList() {
+				msg.Mutable(field).List().Append(v)
+			} else {
+				msg.Set(field, v)
+			}
+		}
+	}
+
+	for _, param := range r.params {
+		v, err := scalarValue(param.field, segments[param.index])
+		if err != nil {
+			return nil, fmt.Errorf("некорректное значение %s в пути: %w", param.field.Name(), err)
+		}
+		msg.Set(param.field, v)
+	}
+	return msg, nil
+}
+
+// responseBody возвращает ответ метода в формате JSON
+func (r *grpcRule) responseBody(out protoreflect.Message) ([]byte, error) {
+	var v interface{}
+	switch {
+	case r.response == nil:
+		v = messageJSON(out)
+	case r.response.Message() != nil && !r.response.IsList() && !out.Has(r.response):
+		// Незаполненное сообщение передается как отсутствие значения
+	default:
+		v = fieldJSON(r.response, out.Get(r.response))
+	}
+	if r.wrapArray {
+		if v == nil {
+			v = []interface{}{}
+		} else {
+			v = []interface{}{v}
+		}
+	}
+	return json.Marshal(v)
+}
+
+// RoundTrip перекодирует запрос в вызов метода gRPC. Ошибки gRPC возвращаются
+// как ответ с соответствующим HTTP статусом, кроме недоступности сервиса и
+// отмены запроса: они возвращаются как ошибки транспорта.
+func (t *grpcTranscoder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+
+	var rule *grpcRule
+	var segments []string
+	for _, candidate := range t.rules {
+		if s, ok := candidate.match(req); ok {
+			rule, segments = candidate, s
+			break
+		}
+	}
+	if rule == nil {
+		slog.WarnContext(req.Context(), "Нет метода gRPC для запроса к сервису", "backend", t.upstream, "method", req.Method, "path", req.URL.Path)
+		return transcodedError(req, http.StatusNotFound, "метод не найден"), nil
+	}
+
+	in, err := rule.request(req, segments)
+	if err != nil {
+		return transcodedError(req, http.StatusBadRequest, err.Error()), nil
+	}
+	out := dynamicpb.NewMessage(rule.rpc.Output())
+	var header metadata.MD
+	ctx := metadata.NewOutgoingContext(req.Context(), outgoingMetadata(req.Header))
+
+	t.begin()
+	err = t.conn.Invoke(ctx, rule.name, in, out, grpc.Header(&header))
+	t.end()
+	if err != nil {
+		st := status.Convert(err)
+		switch st.Code() {
+		case codes.Canceled, codes.DeadlineExceeded:
+			if ctxErr := req.Context().Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+		case codes.Unavailable:
+			return nil, fmt.Errorf("gRPC сервис недоступен: %s", st.Message())
+		}
+		return transcodedError(req, grpcHTTPStatus(st.Code()), st.Message()), nil
+	}
+
+	body, err := rule.responseBody(out)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось перекодировать ответ %s: %w", rule.name, err)
+	}
+	resp := transcodedResponse(req, http.StatusOK, body)
+	for key, values := range header {
+		if strings.HasPrefix(key, ":") || key == "content-type" || strings.HasSuffix(key, "-bin") {
+			continue
+		}
+		for _, value := range values {
+			resp.Header.Add(key, value)
+		}
+	}
+	return resp, nil
+}
+
+// begin учитывает начало вызова
+func (t *grpcTranscoder) begin() {
+	t.mu.Lock()
+	t.calls++
+	t.mu.Unlock()
+}
+
+// end учитывает завершение вызова и закрывает соединение, если оно больше не нужно
+func (t *grpcTranscoder) end() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.calls--
+	if t.closing && t.calls == 0 {
+		t.conn.Close()
+	}
+}
+
+// CloseIdleConnections закрывает соединение с сервисом после завершения
+// выполняющихся вызовов. Вызывается, когда набор клиентов заменен при
+// перезагрузке конфигурации.
+func (t *grpcTranscoder) CloseIdleConnections() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closing {
+		return
+	}
+	t.closing = true
+	if t.calls == 0 {
+		t.conn.Close()
+	}
+}
+
+// outgoingMetadata переводит заголовки запроса в метаданные вызова gRPC
+func outgoingMetadata(h http.Header) metadata.MD {
+	md := metadata.MD{}
+	for key, values := range h {
+		if skippedMetadata[textproto.CanonicalMIMEHeaderKey(key)] || strings.HasSuffix(strings.ToLower(key), "-bin") {
+			continue
+		}
+		md.Append(key, values...)
+	}
+	return md
+}
+
+// transcodedResponse создает HTTP ответ с JSON телом
+func transcodedResponse(req *http.Request, statusCode int, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// transcodedError создает HTTP ответ с описанием ошибки вызова
+func transcodedError(req *http.Request, statusCode int, message string) *http.Response {
+	body, _ := json.Marshal(map[string]string{"error": message})
+	return transcodedResponse(req, statusCode, body)
+}
+
+// grpcHTTPStatus сопоставляет код gRPC HTTP статусу ответа
+func grpcHTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// scalarValue разбирает строковое значение поля сообщения
+func scalarValue(field protoreflect.FieldDescriptor, s string) (protoreflect.Value, error) {
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte(s)), nil
+	case protoreflect.BoolKind:
+		v, err := strconv.ParseBool(s)
+		return protoreflect.ValueOfBool(v), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		v, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfInt32(int32(v)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		v, err := strconv.ParseInt(s, 10, 64)
+		return protoreflect.ValueOfInt64(v), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		v, err := strconv.ParseUint(s, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(v)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		v, err := strconv.ParseUint(s, 10, 64)
+		return protoreflect.ValueOfUint64(v), err
+	case protoreflect.FloatKind:
+		v, err := strconv.ParseFloat(s, 32)
+		return protoreflect.ValueOfFloat32(float32(v)), err
+	case protoreflect.DoubleKind:
+		v, err := strconv.ParseFloat(s, 64)
+		return protoreflect.ValueOfFloat64(v), err
+	case protoreflect.EnumKind:
+		if value := field.Enum().Values().ByName(protoreflect.Name(s)); value != nil {
+			return protoreflect.ValueOfEnum(value.Number()), nil
+		}
+		v, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(v)), err
+	}
+	return protoreflect.Value{}, fmt.Errorf("неподдерживаемый тип поля %s", field.Kind())
+}
+
+// messageJSON переводит сообщение в значение для encoding/json. В отличие
+// от protojson, целые 64-битные числа передаются числами, как их передают
+// HTTP сервисы, а имена полей - как в описании сообщения.
+func messageJSON(m protoreflect.Message) interface{} {
+	// Стандартные типы (Timestamp, Duration, обертки) имеют свое представление в JSON
+	if m.Descriptor().FullName().Parent() == "google.protobuf" {
+		if data, err := protojson.Marshal(m.Interface()); err == nil {
+			return json.RawMessage(data)
+		}
+	}
+
+	obj := make(map[string]interface{})
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		// Незаполненные вложенные сообщения и поля oneof не передаются
+		if !m.Has(field) && (field.ContainingOneof() != nil || (field.Message() != nil && !field.IsList() && !field.IsMap())) {
+			continue
+		}
+		obj[string(field.Name())] = fieldJSON(field, m.Get(field))
+	}
+	return obj
+}
+
+// fieldJSON переводит значение поля в значение для encoding/json
+func fieldJSON(field protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch {
+	case field.IsList():
+		list := v.List()
+		items := make([]interface{}, list.Len())
+		for i := range items {
+			items[i] = singularJSON(field, list.Get(i))
+		}
+		return items
+	case field.IsMap():
+		obj := make(map[string]interface{})
+		v.Map().Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+			obj[key.String()] = singularJSON(field.MapValue(), value)
+			return true
+		})
+		return obj
+	}
+	return singularJSON(field, v)
+}
+
+// singularJSON переводит одиночное значение поля в значение для encoding/json
+func singularJSON(field protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch field.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return messageJSON(v.Message())
+	case protoreflect.EnumKind:
+		if value := field.Enum().Values().ByNumber(v.Enum()); value != nil {
+			return string(value.Name())
+		}
+		return int32(v.Enum())
+	}
+	return v.Interface()
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"apigw/pkg/config"
+)
+
+// maxTransformBodySize - максимальный размер тела запроса, которое преобразуется
+// для маршрутов из конфигурации
+const maxTransformBodySize = 1 << 20
+
+// bodyTransform - преобразование JSON тела запроса к backend-сервису
+type bodyTransform struct {
+	path   string
+	method string
+	remove []string
+	rename map[string]string
+	set    map[string]interface{}
+	// form - передавать тело в формате application/x-www-form-urlencoded
+	form bool
+}
+
+// newBodyTransforms проверяет секцию transforms
+func newBodyTransforms(cfg []config.TransformConfig) ([]*bodyTransform, error) {
+	var transforms []*bodyTransform
+	for _, tc := range cfg {
+		if !strings.HasPrefix(tc.Path, "/") {
+			return nil, fmt.Errorf("путь преобразования тела запроса должен начинаться с /: %q", tc.Path)
+		}
+
+		t := &bodyTransform{
+			path:   tc.Path,
+			method: strings.ToUpper(tc.Method),
+			remove: tc.Remove,
+			rename: tc.Rename,
+			set:    tc.Set,
+		}
+		switch tc.Encoding {
+		case "", "json":
+		case "form":
+			t.form = true
+		default:
+			return nil, fmt.Errorf("неизвестный формат тела запроса для %s: %q", tc.Path, tc.Encoding)
+		}
+		transforms = append(transforms, t)
+	}
+	return transforms, nil
+}
+
+// findBodyTransform возвращает первое преобразование, подходящее запросу, или nil
+func findBodyTransform(transforms []*bodyTransform, r *http.Request) *bodyTransform {
+	for _, t := range transforms {
+		if (t.method == "" || t.method == r.Method) && pathMatches(t.path, r.URL.Path) {
+			return t
+		}
+	}
+	return nil
+}
+
+// apply преобразует JSON объект body и возвращает новое тело и его тип содержимого
+func (t *bodyTransform) apply(body []byte) ([]byte, string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, "", fmt.Errorf("тело запроса не является JSON объектом: %w", err)
+	}
+	if fields == nil {
+		fields = make(map[string]interface{})
+	}
+
+	for _, name := range t.remove {
+		delete(fields, name)
+	}
+	for from, to := range t.rename {
+		if value, ok := fields[from]; ok {
+			delete(fields, from)
+			fields[to] = value
+		}
+	}
+	for name, value := range t.set {
+		fields[name] = value
+	}
+
+	if t.form {
+		form := url.Values{}
+		for name, value := range fields {
+			form.Set(name, formValue(value))
+		}
+		return []byte(form.Encode()), "application/x-www-form-urlencoded", nil
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, "application/json", nil
+}
+
+// formValue представляет значение JSON поля в виде значения формы:
+// строки передаются как есть, остальные значения - в формате JSON
+func formValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// transformRequestBody преобразует JSON тело запроса клиента для маршрута из
+// конфигурации. Запросы с телом в другом формате передаются без изменений.
+func (t *bodyTransform) transformRequestBody(r *http.Request) (*http.Request, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if r.Body == nil || r.Body == http.NoBody || mediaType != "application/json" {
+		return r, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxTransformBodySize+1))
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxTransformBodySize {
+		return nil, fmt.Errorf("тело запроса больше %d байт", maxTransformBodySize)
+	}
+
+	transformed, contentType, err := t.apply(body)
+	if err != nil {
+		return nil, err
+	}
+
+	out := r.Clone(r.Context())
+	out.Body = io.NopCloser(bytes.NewReader(transformed))
+	out.ContentLength = int64(len(transformed))
+	out.Header.Set("Content-Type", contentType)
+	out.Header.Del("Content-Length")
+	return out, nil
+}
+
+// transformUpstreamBody применяет к телу запроса обработчика шлюза к
+// backend-сервису преобразование для запроса клиента r
+func (s *Server) transformUpstreamBody(r *http.Request, body []byte) ([]byte, string, error) {
+	t := findBodyTransform(s.transforms, r)
+	if t == nil {
+		return body, "application/json", nil
+	}
+	transformed, contentType, err := t.apply(body)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Не удалось преобразовать тело запроса", "path", r.URL.Path, "error", err)
+		return nil, "", err
+	}
+	return transformed, contentType, nil
+}
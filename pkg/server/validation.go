@@ -0,0 +1,204 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"apigw/pkg/config"
+	"apigw/pkg/metrics"
+	"apigw/pkg/openapi"
+)
+
+// requestValidator проверяет параметры и тела запросов к эндпоинтам новостей
+// и комментариев по схемам из описания API
+type requestValidator struct {
+	// operations - сначала операции путей без параметров, чтобы
+	// /api/news/batch не проверялся как /api/news/{newsId}
+	operations  []*validationRoute
+	maxBodySize int64
+	failures    *metrics.CounterVec
+}
+
+// validationRoute - проверяемая операция описания API
+type validationRoute struct {
+	method   string
+	path     string
+	segments []string
+	op       *openapi.Operation
+}
+
+// validationFailure - отказ в обработке некорректного запроса
+type validationFailure struct {
+	status  int
+	code    string
+	message string
+	fields  []openapi.FieldError
+}
+
+// validatedOperation проверяет, что операция относится к эндпоинтам новостей
+// и комментариев; маршруты из routes и служебные эндпоинты не проверяются
+func validatedOperation(op *openapi.Operation) bool {
+	for _, tag := range op.Tags {
+		if tag == "news" || tag == "comments" {
+			return true
+		}
+	}
+	return false
+}
+
+// newRequestValidator собирает проверяемые операции из описания API.
+// Возвращает nil, если проверка отключена.
+func newRequestValidator(cfg config.ValidationConfig, doc *openapi.Document, registry *metrics.Registry) (*requestValidator, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.MaxBodySize <= 0 {
+		return nil, fmt.Errorf("некорректный размер тела запроса validation.max_body_size: %d", cfg.MaxBodySize)
+	}
+	if cfg.CommentMaxLength < 0 {
+		return nil, fmt.Errorf("некорректная длина комментария validation.comment_max_length: %d", cfg.CommentMaxLength)
+	}
+
+	v := &requestValidator{
+		maxBodySize: int64(cfg.MaxBodySize),
+		failures: registry.Counter("apigw_validation_failures_total",
+			"Запросы, отклоненные проверкой по описанию API", "route"),
+	}
+	var templated []*validationRoute
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			if !validatedOperation(op) {
+				continue
+			}
+			route := &validationRoute{method: strings.ToUpper(method), path: path, segments: strings.Split(path, "/"), op: op}
+			if strings.Contains(path, "{") {
+				templated = append(templated, route)
+			} else {
+				v.operations = append(v.operations, route)
+			}
+		}
+	}
+	v.operations = append(v.operations, templated...)
+	return v, nil
+}
+
+// match возвращает операцию запроса и значения параметров пути
+func (v *requestValidator) match(method, path string) (*validationRoute, map[string]string) {
+	segments := strings.Split(path, "/")
+	for _, route := range v.operations {
+		if route.method != method || len(route.segments) != len(segments) {
+			continue
+		}
+		params := map[string]string{}
+		matched := true
+		for i, segment := range route.segments {
+			if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+				params[segment[1:len(segment)-1]] = segments[i]
+				continue
+			}
+			if segment != segments[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return route, params
+		}
+	}
+	return nil, nil
+}
+
+// validate проверяет параметры и тело JSON запроса. Прочитанное тело
+// возвращается в запрос, чтобы его получил обработчик.
+func (v *requestValidator) validate(r *http.Request, route *validationRoute, pathParams map[string]string) *validationFailure {
+	var fields []openapi.FieldError
+	query := r.URL.Query()
+	for _, param := range route.op.Parameters {
+		var value string
+		var ok bool
+		switch param.In {
+		case "path":
+			value, ok = pathParams[param.Name]
+		case "query":
+			ok = query.Has(param.Name)
+			value = query.Get(param.Name)
+		default:
+			continue
+		}
+		if !ok {
+			if param.Required {
+				fields = append(fields, openapi.FieldError{Field: param.Name, Message: "обязательный параметр"})
+			}
+			continue
+		}
+		fields = append(fields, param.Schema.ValidateParam(param.Name, value)...)
+	}
+
+	if body := route.op.RequestBody; body != nil && body.Content["application/json"].Schema != nil {
+		data, err := io.ReadAll(io.LimitReader(r.Body, v.maxBodySize+1))
+		r.Body.Close()
+		if err != nil {
+			return &validationFailure{status: http.StatusBadRequest, code: codeInvalidRequest, message: "Не удалось прочитать тело запроса"}
+		}
+		if int64(len(data)) > v.maxBodySize {
+			return &validationFailure{status: http.StatusRequestEntityTooLarge, code: codeInvalidRequest,
+				message: fmt.Sprintf("Тело запроса больше %d байт", v.maxBodySize)}
+		}
+		r.Body = io.NopCloser(bytes.NewReader(data))
+
+		switch {
+		case len(bytes.TrimSpace(data)) == 0:
+			if body.Required {
+				fields = append(fields, openapi.FieldError{Field: "body", Message: "обязательное тело запроса"})
+			}
+		default:
+			decoder := json.NewDecoder(bytes.NewReader(data))
+			decoder.UseNumber()
+			var value interface{}
+			if err := decoder.Decode(&value); err != nil {
+				return &validationFailure{status: http.StatusBadRequest, code: codeInvalidJSON, message: "Некорректный JSON в теле запроса"}
+			}
+			fields = append(fields, body.Content["application/json"].Schema.Validate("", value)...)
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return &validationFailure{status: http.StatusBadRequest, code: codeValidationFailed, message: "Запрос не прошел проверку", fields: fields}
+}
+
+// validationMiddleware проверяет запросы к эндпоинтам новостей и комментариев
+// по описанию API до обращения к backend-сервисам и отвечает 400 со списком
+// ошибок по полям
+func (s *Server) validationMiddleware(next http.Handler) http.Handler {
+	if s.validator == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, params := s.validator.match(r.Method, r.URL.Path)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		failure := s.validator.validate(r, route, params)
+		if failure == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		s.validator.failures.Inc(route.path)
+		slog.DebugContext(r.Context(), "Запрос не прошел проверку", "method", r.Method, "path", r.URL.Path, "errors", failure.fields)
+		if len(failure.fields) == 0 {
+			writeError(w, r, failure.status, failure.code, failure.message)
+			return
+		}
+		writeErrorDetails(w, r, failure.status, failure.code, failure.message, map[string]interface{}{"fields": failure.fields})
+	})
+}
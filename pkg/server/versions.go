@@ -0,0 +1,336 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"apigw/pkg/config"
+)
+
+// Версии API, доступные по префиксу /api/{версия}/ и заголовку Accept-Version.
+// v1 сохраняет форматы ответов без изменений, v2 использует конверт ошибок,
+// заголовок X-Request-ID и пагинацию курсорами через заголовок Link.
+const (
+	apiV1 = "v1"
+	apiV2 = "v2"
+)
+
+// apiVersionHeader - заголовок, которым клиент выбирает версию API для путей без версии
+const apiVersionHeader = "Accept-Version"
+
+// parseAPIVersion возвращает версию API из значения заголовка Accept-Version:
+// v1, v2 или номер версии без префикса
+func parseAPIVersion(value string) (string, bool) {
+	version := strings.ToLower(strings.TrimSpace(value))
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	if version != apiV1 && version != apiV2 {
+		return "", false
+	}
+	return version, true
+}
+
+// versionedPath проверяет, что путь содержит версию API
+func versionedPath(path string) bool {
+	return strings.HasPrefix(path, "/api/"+apiV1+"/") || strings.HasPrefix(path, "/api/"+apiV2+"/")
+}
+
+// apiVersionMiddleware передает запросы к путям /api/... без версии
+// обработчику версии из заголовка Accept-Version. Выполняется после проверок
+// доступа и лимитов, поэтому они применяются к пути без версии.
+func (s *Server) apiVersionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") || versionedPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Ответ на путь без версии зависит от заголовка
+		w.Header().Add("Vary", apiVersionHeader)
+
+		value := r.Header.Get(apiVersionHeader)
+		if value == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		version, ok := parseAPIVersion(value)
+		if !ok {
+			writeError(w, r, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("Неизвестная версия API в заголовке %s: %q", apiVersionHeader, value))
+			return
+		}
+
+		versioned := r.Clone(r.Context())
+		versioned.URL.Path = "/api/" + version + strings.TrimPrefix(r.URL.Path, "/api")
+		versioned.URL.RawPath = ""
+		s.versionHandler(version).ServeHTTP(w, versioned)
+	})
+}
+
+// encodeCursor возвращает непрозрачный курсор страницы списка в API v2
+func encodeCursor(page, count int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", page, count)))
+}
+
+// decodeCursor возвращает страницу и размер страницы из курсора
+func decodeCursor(cursor string) (page, count int, ok bool) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, false
+	}
+	pageStr, countStr, found := strings.Cut(string(data), ":")
+	if !found {
+		return 0, 0, false
+	}
+	page, errPage := strconv.Atoi(pageStr)
+	count, errCount := strconv.Atoi(countStr)
+	if errPage != nil || errCount != nil || page < 1 || count < 1 {
+		return 0, 0, false
+	}
+	return page, count, true
+}
+
+// v2Error - ответ с ошибкой в API v2
+type v2Error struct {
+	Error v2ErrorBody `json:"error"`
+}
+
+// v2ErrorBody - описание ошибки в API v2
+type v2ErrorBody struct {
+	// Code - машиночитаемый код ошибки, например not_found
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Status    int    `json:"status"`
+	RequestID string `json:"request_id,omitempty"`
+	// Details - дополнительные сведения из ответа v1, например ошибки по полям
+	Details interface{} `json:"details,omitempty"`
+}
+
+// newVersionPolicies проверяет настройки версий и готовит значения заголовков
+func newVersionPolicies(cfg map[string]config.APIVersionConfig) (map[string]*deprecationPolicy, error) {
+	policies := map[string]*deprecationPolicy{}
+	for version, vc := range cfg {
+		if version != apiV1 && version != apiV2 {
+			return nil, fmt.Errorf("неизвестная версия API: %q", version)
+		}
+
+		policy, err := newDeprecationPolicy("api/"+version, vc.DeprecatedAt, vc.Sunset, vc.Link)
+		if err != nil {
+			return nil, fmt.Errorf("версия %s: %w", version, err)
+		}
+		policies[version] = policy
+	}
+	return policies, nil
+}
+
+// versionHandler обслуживает пути /api/{version}/..., передавая их
+// обработчикам /api/... и приводя ответ к формату версии
+func (s *Server) versionHandler(version string) http.Handler {
+	prefix := "/api/" + version
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if policy := s.versions[version]; policy != nil {
+			s.deprecate(w.Header(), policy)
+		}
+
+		inner := r.Clone(r.Context())
+		inner.URL.Path = "/api" + strings.TrimPrefix(r.URL.Path, prefix)
+		inner.URL.RawPath = ""
+
+		if version == apiV1 {
+			s.validationMiddleware(s.mux).ServeHTTP(w, inner)
+			return
+		}
+		s.serveV2(w, r, inner)
+	})
+}
+
+// unversionedPath возвращает путь без префикса версии API, например
+// /api/v1/comments/add -> /api/comments/add. По этому пути проверяются ключи
+// API, токены и политики доступа: версионированные пути обслуживаются теми
+// же обработчиками, и префикс версии не должен позволять обойти проверки.
+func unversionedPath(path string) string {
+	for _, version := range []string{apiV1, apiV2} {
+		if rest, ok := strings.CutPrefix(path, "/api/"+version); ok && strings.HasPrefix(rest, "/") {
+			return "/api" + rest
+		}
+	}
+	return path
+}
+
+// serveV2 выполняет запрос и приводит ответ к формату API v2
+func (s *Server) serveV2(w http.ResponseWriter, r, inner *http.Request) {
+	// Идентификатор запроса в v2 передается только заголовком X-Request-ID
+	query := inner.URL.Query()
+	query.Del("request_id")
+	if requestID := r.Header.Get("X-Request-ID"); requestID != "" {
+		query.Set("request_id", requestID)
+	}
+	// Курсор страницы заменяет параметры page и count; остальные курсоры
+	// (пагинация курсорами списка новостей) проверяет обработчик
+	if page, count, ok := decodeCursor(query.Get("cursor")); ok {
+		query.Del("cursor")
+		query.Set("page", strconv.Itoa(page))
+		query.Set("count", strconv.Itoa(count))
+	}
+	inner.URL.RawQuery = query.Encode()
+
+	// Ответ v2 преобразуется целиком, поэтому буферизуется без ограничения
+	// streaming.max_buffer. Поток новостей, потоки событий маршрутов и
+	// WebSocket передаются клиенту напрямую, без преобразования.
+	rw := s.newSpillWriter(w)
+	rw.limit = 0
+	s.validationMiddleware(s.mux).ServeHTTP(rw, inner)
+	if rw.spilled {
+		return
+	}
+
+	for name, values := range rw.header {
+		w.Header()[name] = values
+	}
+
+	body := rw.body.Bytes()
+	switch {
+	case rw.status >= 400:
+		code, message := parseError(body)
+		var payload errorResponse
+		json.Unmarshal(body, &payload)
+		body = v2ErrorResponse(rw.status, code, message, rw.header.Get("X-Request-ID"), payload.Error.Details)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Del("Content-Length")
+		w.Header().Del("X-Content-Type-Options")
+	case rw.status == http.StatusOK && isJSONContent(rw.header.Get("Content-Type")):
+		if items, ok := paginateV2(w.Header(), r.URL, body); ok {
+			body = items
+			w.Header().Del("Content-Length")
+		} else if items, ok := cursorPageV2(w.Header(), r.URL, body); ok {
+			body = items
+			w.Header().Del("Content-Length")
+		}
+	}
+
+	w.WriteHeader(rw.status)
+	w.Write(body)
+}
+
+// v2ErrorResponse кодирует ошибку в формате API v2. Если код ошибки
+// не указан, он определяется по статусу.
+func v2ErrorResponse(status int, code, message, requestID string, details interface{}) []byte {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	if code == "" {
+		code = codeForStatus(status)
+	}
+	body, _ := json.Marshal(v2Error{Error: v2ErrorBody{
+		Code:      code,
+		Message:   message,
+		Status:    status,
+		RequestID: requestID,
+		Details:   details,
+	}})
+	return append(body, '\n')
+}
+
+// isJSONContent проверяет, что ответ в формате JSON
+func isJSONContent(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
+
+// paginateV2 заменяет ответ с пагинацией списком элементов, а сведения о
+// страницах переносит в заголовки Link (first, prev, next, last) с курсорами
+// страниц, X-Next-Cursor и X-Total-Count
+func paginateV2(h http.Header, requestURL *url.URL, body []byte) ([]byte, bool) {
+	var page struct {
+		Items        json.RawMessage `json:"items"`
+		TotalPages   *int            `json:"total_pages"`
+		CurrentPage  int             `json:"current_page"`
+		ItemsPerPage int             `json:"items_per_page"`
+		TotalItems   int             `json:"total_items"`
+	}
+	if err := json.Unmarshal(body, &page); err != nil || page.Items == nil || page.TotalPages == nil {
+		return nil, false
+	}
+
+	pageURL := func(n int) string {
+		u := *requestURL
+		query := u.Query()
+		query.Del("request_id")
+		query.Del("page")
+		query.Del("count")
+		query.Set("cursor", encodeCursor(n, page.ItemsPerPage))
+		u.RawQuery = query.Encode()
+		return u.RequestURI()
+	}
+
+	var links []string
+	addLink := func(n int, rel string) {
+		links = append(links, fmt.Sprintf("<%s>; rel=\"%s\"", pageURL(n), rel))
+	}
+	totalPages := *page.TotalPages
+	if totalPages > 0 {
+		addLink(1, "first")
+	}
+	if page.CurrentPage > 1 && totalPages > 0 {
+		prev := page.CurrentPage - 1
+		if prev > totalPages {
+			prev = totalPages
+		}
+		addLink(prev, "prev")
+	}
+	if page.CurrentPage < totalPages {
+		addLink(page.CurrentPage+1, "next")
+		h.Set("X-Next-Cursor", encodeCursor(page.CurrentPage+1, page.ItemsPerPage))
+	}
+	if totalPages > 0 {
+		addLink(totalPages, "last")
+	}
+	if len(links) > 0 {
+		h.Add("Link", strings.Join(links, ", "))
+	}
+	h.Set("X-Total-Count", strconv.Itoa(page.TotalItems))
+
+	items := page.Items
+	if string(items) == "null" {
+		items = json.RawMessage("[]")
+	}
+	return append(items, '\n'), true
+}
+
+// cursorPageV2 заменяет страницу пагинации курсорами (CursorResponse) списком
+// элементов, а курсоры соседних страниц переносит в заголовки Link (prev, next)
+// и X-Next-Cursor
+func cursorPageV2(h http.Header, requestURL *url.URL, body []byte) ([]byte, bool) {
+	var page struct {
+		Items      json.RawMessage `json:"items"`
+		Limit      *int            `json:"limit"`
+		NextCursor string          `json:"next_cursor"`
+		PrevCursor string          `json:"prev_cursor"`
+	}
+	if err := json.Unmarshal(body, &page); err != nil || page.Items == nil || page.Limit == nil {
+		return nil, false
+	}
+
+	var links []string
+	if page.PrevCursor != "" {
+		links = append(links, fmt.Sprintf("<%s>; rel=\"prev\"", cursorPageURL(requestURL, page.PrevCursor)))
+	}
+	if page.NextCursor != "" {
+		links = append(links, fmt.Sprintf("<%s>; rel=\"next\"", cursorPageURL(requestURL, page.NextCursor)))
+		h.Set("X-Next-Cursor", page.NextCursor)
+	}
+	if len(links) > 0 {
+		h.Add("Link", strings.Join(links, ", "))
+	}
+
+	items := page.Items
+	if string(items) == "null" {
+		items = json.RawMessage("[]")
+	}
+	return append(items, '\n'), true
+}
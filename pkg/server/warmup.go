@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Ключ контекста, по которому cacheMiddleware пропускает чтение из кэша
+const cacheRefreshKey contextKey = "cacheRefresh"
+
+// warmCache прогревает кэш при запуске и затем с интервалом из конфигурации
+func (s *Server) warmCache(ctx context.Context) {
+	s.warmCacheOnce(ctx)
+
+	interval := s.config.Cache.Warmup.Interval.Std()
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.warmCacheOnce(ctx)
+		}
+	}
+}
+
+// warmCacheOnce запрашивает все пути для прогрева через цепочку обработчиков.
+// При настроенных арендаторах прогревается раздел арендатора по умолчанию.
+func (s *Server) warmCacheOnce(ctx context.Context) {
+	refreshCtx := context.WithValue(ctx, cacheRefreshKey, true)
+	if s.tenants != nil {
+		refreshCtx = withTenant(refreshCtx, s.tenants.fallback)
+	}
+	for _, path := range s.config.Cache.Warmup.Paths {
+		rw, err := s.serveInternal(refreshCtx, http.MethodGet, path, nil, nil, "warmup")
+		if err != nil {
+			slog.WarnContext(ctx, "Некорректный путь для прогрева кэша", "path", path, "error", err)
+			continue
+		}
+
+		if rw.status != http.StatusOK {
+			slog.WarnContext(ctx, "Прогрев кэша завершился ошибкой", "path", path, "status", rw.status)
+		}
+	}
+}
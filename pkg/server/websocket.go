@@ -0,0 +1,199 @@
+package server
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Таймаут установки соединения с WebSocket backend-сервисом
+const websocketDialTimeout = 10 * time.Second
+
+// isUpgradeRequest проверяет, что клиент запрашивает переход на WebSocket
+func isUpgradeRequest(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") &&
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// headerContainsToken проверяет наличие значения в заголовке со списком через запятую
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serveWebSocket устанавливает соединение с WebSocket backend-сервисом и
+// передает данные между клиентом и сервисом в обе стороны
+func (p *proxyRoute) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !isUpgradeRequest(r) {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "Ожидается запрос на установку WebSocket соединения")
+		return
+	}
+
+	// Ограничиваем количество одновременных соединений маршрута
+	if p.conns != nil {
+		select {
+		case p.conns <- struct{}{}:
+			defer func() { <-p.conns }()
+		default:
+			slog.WarnContext(r.Context(), "Превышен лимит WebSocket соединений маршрута", "max_connections", p.config.MaxConnections)
+			writeError(w, r, http.StatusServiceUnavailable, codeTooManyConnections, "Превышено количество соединений")
+			return
+		}
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		slog.ErrorContext(r.Context(), "ResponseWriter не поддерживает Hijack, WebSocket невозможен")
+		writeError(w, r, http.StatusInternalServerError, codeInternal, "Внутренняя ошибка сервера")
+		return
+	}
+
+	target := p.targetURL(r)
+	upstreamConn, err := dialWebSocketUpstream(target.Scheme, target.Host)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка при подключении к backend-сервису", "backend", target.Host, "error", err)
+		writeError(w, r, http.StatusBadGateway, codeUpstreamUnavailable, "Сервис недоступен")
+		return
+	}
+	defer upstreamConn.Close()
+
+	// Передаем запрос на установку соединения, сохраняя заголовки Upgrade
+	if target.Scheme == "wss" {
+		target.Scheme = "https"
+	} else {
+		target.Scheme = "http"
+	}
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, target.String(), nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка при создании запроса к backend-сервису", "url", target.Redacted(), "error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, "Внутренняя ошибка сервера")
+		return
+	}
+	for name, values := range r.Header {
+		req.Header[name] = append([]string(nil), values...)
+	}
+	setForwardedHeaders(req.Header, r)
+	p.requestIDs.apply(req)
+	p.claimHeaders.apply(req)
+
+	if err := req.Write(upstreamConn); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка при отправке запроса к backend-сервису", "backend", target.Host, "error", err)
+		writeError(w, r, http.StatusBadGateway, codeUpstreamUnavailable, "Сервис недоступен")
+		return
+	}
+
+	upstreamReader := bufio.NewReader(upstreamConn)
+	resp, err := http.ReadResponse(upstreamReader, req)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка при чтении ответа backend-сервиса", "backend", target.Host, "error", err)
+		writeError(w, r, http.StatusBadGateway, codeUpstreamUnavailable, "Сервис недоступен")
+		return
+	}
+
+	// Сервис отказал в установке соединения - передаем его ответ клиенту как есть
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer resp.Body.Close()
+		copyHeaders(w.Header(), resp.Header)
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+		return
+	}
+
+	clientConn, clientBuf, err := hijacker.Hijack()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка при перехвате соединения", "error", err)
+		return
+	}
+	defer clientConn.Close()
+
+	// Отправляем клиенту ответ 101 Switching Protocols от сервиса
+	if err := resp.Write(clientBuf); err != nil || clientBuf.Flush() != nil {
+		slog.WarnContext(r.Context(), "Ошибка при отправке ответа клиенту", "error", err)
+		return
+	}
+
+	idle := p.config.IdleTimeout.Std()
+	client := &idleConn{Conn: clientConn, reader: clientBuf.Reader, timeout: idle}
+	upstream := &idleConn{Conn: upstreamConn, reader: upstreamReader, timeout: idle}
+
+	slog.InfoContext(r.Context(), "Установлено WebSocket соединение", "path", r.URL.Path, "backend", target.Host)
+	start := time.Now()
+
+	// Соединение завершается, как только одна из сторон его закрыла или истек таймаут простоя
+	var once sync.Once
+	done := make(chan struct{})
+	closeBoth := func() {
+		once.Do(func() {
+			clientConn.Close()
+			upstreamConn.Close()
+			close(done)
+		})
+	}
+
+	go func() {
+		io.Copy(upstream, client)
+		closeBoth()
+	}()
+	go func() {
+		io.Copy(client, upstream)
+		closeBoth()
+	}()
+	<-done
+
+	slog.InfoContext(r.Context(), "WebSocket соединение закрыто", "path", r.URL.Path, "backend", target.Host, "duration", time.Since(start))
+}
+
+// dialWebSocketUpstream открывает TCP (или TLS для wss) соединение с backend-сервисом
+func dialWebSocketUpstream(scheme, host string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: websocketDialTimeout}
+
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname, port = host, "80"
+		if scheme == "wss" {
+			port = "443"
+		}
+	}
+	addr := net.JoinHostPort(hostname, port)
+
+	if scheme == "wss" {
+		return tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: hostname})
+	}
+	return dialer.Dial("tcp", addr)
+}
+
+// idleConn продлевает таймаут соединения при каждой операции чтения и записи,
+// чтобы закрывать только простаивающие соединения
+type idleConn struct {
+	net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+}
+
+// Read читает данные, учитывая уже буферизованные при установке соединения
+func (c *idleConn) Read(p []byte) (int, error) {
+	if c.timeout > 0 {
+		c.Conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	return c.reader.Read(p)
+}
+
+// Write записывает данные и продлевает таймаут простоя
+func (c *idleConn) Write(p []byte) (int, error) {
+	if c.timeout > 0 {
+		c.Conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	return c.Conn.Write(p)
+}
@@ -0,0 +1,81 @@
+// Package testbackends содержит фейковые сервисы новостей и комментариев,
+// которые хранят данные в памяти, и позволяет запустить шлюз поверх них для
+// интеграционных тестов без настоящих сервисов.
+package testbackends
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Behavior - поведение фейкового сервиса
+type Behavior struct {
+	// Latency - задержка перед ответом
+	Latency time.Duration
+	// Jitter - случайная добавка к задержке от 0 до Jitter
+	Jitter time.Duration
+	// FailureRate - доля запросов, завершающихся ошибкой, от 0 до 1
+	FailureRate float64
+	// FailureStatus - код ответа при ошибке (по умолчанию 500)
+	FailureStatus int
+}
+
+// backend - общая часть фейковых сервисов: поведение и счетчик запросов
+type backend struct {
+	mu       sync.Mutex
+	behavior Behavior
+	rnd      *rand.Rand
+	requests atomic.Int64
+}
+
+func newBackend() backend {
+	return backend{rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// SetBehavior задает задержку и долю ошибок для следующих запросов
+func (b *backend) SetBehavior(behavior Behavior) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.behavior = behavior
+}
+
+// Requests возвращает количество полученных запросов
+func (b *backend) Requests() int64 {
+	return b.requests.Load()
+}
+
+// intercept учитывает запрос, выдерживает задержку и при необходимости
+// отвечает ошибкой. Возвращает true, если ответ уже отправлен.
+func (b *backend) intercept(w http.ResponseWriter, r *http.Request) bool {
+	b.requests.Add(1)
+
+	b.mu.Lock()
+	behavior := b.behavior
+	delay := behavior.Latency
+	if behavior.Jitter > 0 {
+		delay += time.Duration(b.rnd.Int63n(int64(behavior.Jitter)))
+	}
+	fail := behavior.FailureRate > 0 && b.rnd.Float64() < behavior.FailureRate
+	b.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return true
+		}
+	}
+
+	if fail {
+		status := behavior.FailureStatus
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, http.StatusText(status), status)
+		return true
+	}
+	return false
+}
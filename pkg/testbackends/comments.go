@@ -0,0 +1,107 @@
+package testbackends
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+)
+
+// CommentItem - комментарий в формате сервиса комментариев
+type CommentItem struct {
+	ID        int64  `json:"id"`
+	NewsID    int64  `json:"news_id"`
+	ParentID  int64  `json:"parent_id,omitempty"`
+	Text      string `json:"text"`
+	UserID    string `json:"user_id,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Comments - фейковый сервис комментариев
+//
+//	GET  /api/comm_news?id={newsId}      - комментарии к новости
+//	POST /api/comm_add_news?id={newsId}  - добавление комментария {"text": "...", "user_id": "..."}
+type Comments struct {
+	*httptest.Server
+	backend
+	comments []CommentItem
+	nextID   int64
+}
+
+// NewComments запускает фейковый сервис комментариев с указанными комментариями
+func NewComments(comments []CommentItem) *Comments {
+	c := &Comments{backend: newBackend(), comments: comments}
+	for _, comment := range comments {
+		if comment.ID > c.nextID {
+			c.nextID = comment.ID
+		}
+	}
+	c.Server = httptest.NewServer(http.HandlerFunc(c.serveHTTP))
+	return c
+}
+
+// ForNews возвращает комментарии к новости, включая добавленные через API
+func (c *Comments) ForNews(newsID int64) []CommentItem {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := []CommentItem{}
+	for _, comment := range c.comments {
+		if comment.NewsID == newsID {
+			result = append(result, comment)
+		}
+	}
+	return result
+}
+
+func (c *Comments) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if c.intercept(w, r) {
+		return
+	}
+
+	newsID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Некорректный ID новости", http.StatusBadRequest)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/api/comm_news":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(c.ForNews(newsID))
+	case "/api/comm_add_news":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			Text     string `json:"text"`
+			ParentID int64  `json:"parent_id"`
+			UserID   string `json:"user_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Text == "" {
+			http.Error(w, "Некорректный комментарий", http.StatusBadRequest)
+			return
+		}
+
+		c.mu.Lock()
+		c.nextID++
+		comment := CommentItem{
+			ID:        c.nextID,
+			NewsID:    newsID,
+			ParentID:  body.ParentID,
+			Text:      body.Text,
+			UserID:    body.UserID,
+			CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		}
+		c.comments = append(c.comments, comment)
+		c.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(comment)
+	default:
+		http.NotFound(w, r)
+	}
+}
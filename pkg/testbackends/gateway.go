@@ -0,0 +1,58 @@
+package testbackends
+
+import (
+	"net/http/httptest"
+
+	"apigw/pkg/config"
+	"apigw/pkg/server"
+)
+
+// Gateway - шлюз, запущенный поверх фейковых сервисов
+type Gateway struct {
+	// Server - HTTP сервер шлюза, URL - его адрес
+	*httptest.Server
+	Gateway  *server.Server
+	News     *News
+	Comments *Comments
+}
+
+// NewGateway запускает фейковые сервисы с указанными данными и шлюз, настроенный
+// на них. cfg может быть nil - тогда используется конфигурация по умолчанию;
+// адреса сервисов в cfg заменяются адресами фейковых сервисов.
+func NewGateway(cfg *config.Config, news []NewsItem, comments []CommentItem) (*Gateway, error) {
+	if cfg == nil {
+		cfg = config.NewConfig()
+	}
+
+	g := &Gateway{
+		News:     NewNews(news),
+		Comments: NewComments(comments),
+	}
+	if cfg.Services == nil {
+		cfg.Services = make(config.ServicesConfig)
+	}
+	// Остальные настройки сервисов (клиент, пагинация) сохраняются
+	newsService := cfg.Services[config.ServiceNews]
+	newsService.URL = g.News.URL
+	cfg.Services[config.ServiceNews] = newsService
+	commentsService := cfg.Services[config.ServiceComments]
+	commentsService.URL = g.Comments.URL
+	cfg.Services[config.ServiceComments] = commentsService
+
+	srv, err := server.NewServer(cfg)
+	if err != nil {
+		g.News.Close()
+		g.Comments.Close()
+		return nil, err
+	}
+	g.Gateway = srv
+	g.Server = httptest.NewServer(srv.Handler())
+	return g, nil
+}
+
+// Close останавливает шлюз и фейковые сервисы
+func (g *Gateway) Close() {
+	g.Server.Close()
+	g.News.Close()
+	g.Comments.Close()
+}
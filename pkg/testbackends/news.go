@@ -0,0 +1,199 @@
+package testbackends
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewsItem - новость в формате сервиса новостей
+type NewsItem struct {
+	ID          int64  `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	PubDate     string `json:"pub_date"`
+	SourceURL   string `json:"source_url"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// GenerateNews создает n новостей с ID от 1 до n и датами публикации по дням
+func GenerateNews(n int) []NewsItem {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	items := make([]NewsItem, n)
+	for i := range items {
+		id := int64(i + 1)
+		items[i] = NewsItem{
+			ID:          id,
+			Title:       fmt.Sprintf("Новость %d", id),
+			Description: fmt.Sprintf("Описание новости %d", id),
+			PubDate:     start.AddDate(0, 0, i).Format("2006-01-02"),
+			SourceURL:   fmt.Sprintf("https://example.com/news/%d", id),
+			CreatedAt:   start.Format(time.RFC3339),
+		}
+	}
+	return items
+}
+
+// News - фейковый сервис новостей
+//
+//	GET /api/news/      - все новости
+//	GET /api/news/?page={page}&count={count}&s={term} - страница новостей,
+//	    общее количество в заголовке X-Total-Count
+//	GET /api/news/?sort={pub_date|title}&order={asc|desc} - новости (или
+//	    страница) в порядке sort; равные значения упорядочиваются по ID
+//	GET /api/news/?from={date}&until={date} - новости (или страница),
+//	    опубликованные не раньше from и раньше until
+//	GET /api/news/{id}  - массив из одной новости или 404
+type News struct {
+	*httptest.Server
+	backend
+	items []NewsItem
+}
+
+// NewNews запускает фейковый сервис новостей с указанными новостями
+func NewNews(items []NewsItem) *News {
+	n := &News{backend: newBackend(), items: items}
+	n.Server = httptest.NewServer(http.HandlerFunc(n.serveHTTP))
+	return n
+}
+
+// SetItems заменяет набор новостей
+func (n *News) SetItems(items []NewsItem) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.items = items
+}
+
+func (n *News) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if n.intercept(w, r) {
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, "/api/news/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	n.mu.Lock()
+	items := n.items
+	n.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	idStr := strings.TrimPrefix(r.URL.Path, "/api/news/")
+	if idStr == "" {
+		query := r.URL.Query()
+		items = newsInPeriod(sortNews(items, query), query)
+		if query.Has("page") || query.Has("count") || query.Has("s") {
+			page, total := newsPage(items, query)
+			w.Header().Set("X-Total-Count", strconv.Itoa(total))
+			json.NewEncoder(w).Encode(page)
+			return
+		}
+		json.NewEncoder(w).Encode(items)
+		return
+	}
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "Некорректный ID новости", http.StatusBadRequest)
+		return
+	}
+	for _, item := range items {
+		if item.ID == id {
+			json.NewEncoder(w).Encode([]NewsItem{item})
+			return
+		}
+	}
+	http.Error(w, "Новость не найдена", http.StatusNotFound)
+}
+
+// newsPage выбирает новости, заголовок которых содержит s, и возвращает
+// страницу page по count новостей и общее количество подходящих новостей
+func newsPage(items []NewsItem, query url.Values) ([]NewsItem, int) {
+	term := strings.ToLower(query.Get("s"))
+	matched := []NewsItem{}
+	for _, item := range items {
+		if strings.Contains(strings.ToLower(item.Title), term) {
+			matched = append(matched, item)
+		}
+	}
+
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	count, err := strconv.Atoi(query.Get("count"))
+	if err != nil || count < 1 {
+		count = 10
+	}
+	start := (page - 1) * count
+	if start >= len(matched) {
+		return []NewsItem{}, len(matched)
+	}
+	end := start + count
+	if end > len(matched) {
+		end = len(matched)
+	}
+	return matched[start:end], len(matched)
+}
+
+// sortNews возвращает копию новостей, отсортированную по параметрам sort и order
+func sortNews(items []NewsItem, query url.Values) []NewsItem {
+	field := query.Get("sort")
+	if field != "pub_date" && field != "title" {
+		return items
+	}
+	desc := query.Get("order") == "desc"
+
+	sorted := append([]NewsItem(nil), items...)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if desc {
+			a, b = b, a
+		}
+		switch {
+		case field == "pub_date" && a.PubDate != b.PubDate:
+			return a.PubDate < b.PubDate
+		case field == "title" && strings.ToLower(a.Title) != strings.ToLower(b.Title):
+			return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+		}
+		return a.ID < b.ID
+	})
+	return sorted
+}
+
+// newsInPeriod возвращает новости, опубликованные в период из параметров
+// from (включительно) и until (не включительно). Даты - в формате
+// 2006-01-02 или RFC3339.
+func newsInPeriod(items []NewsItem, query url.Values) []NewsItem {
+	from, hasFrom := parseNewsDate(query.Get("from"))
+	until, hasUntil := parseNewsDate(query.Get("until"))
+	if !hasFrom && !hasUntil {
+		return items
+	}
+
+	matched := []NewsItem{}
+	for _, item := range items {
+		published, ok := parseNewsDate(item.PubDate)
+		if !ok || (hasFrom && published.Before(from)) || (hasUntil && !published.Before(until)) {
+			continue
+		}
+		matched = append(matched, item)
+	}
+	return matched
+}
+
+// parseNewsDate разбирает дату в формате 2006-01-02 или RFC3339
+func parseNewsDate(value string) (time.Time, bool) {
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,217 @@
+package testbackends
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"apigw/pkg/config"
+)
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// getJSON выполняет GET запрос и декодирует JSON ответ в v
+func getJSON(t *testing.T, url string, v interface{}) *http.Response {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK && v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("GET %s: некорректный JSON: %v", url, err)
+		}
+	}
+	return resp
+}
+
+// newsIDs возвращает ID новостей по порядку
+func newsIDs(items []NewsItem) []int64 {
+	ids := make([]int64, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	return ids
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestGenerateNews(t *testing.T) {
+	items := GenerateNews(3)
+	if !equalIDs(newsIDs(items), []int64{1, 2, 3}) {
+		t.Fatalf("ID новостей %v, ожидались [1 2 3]", newsIDs(items))
+	}
+	if items[0].PubDate != "2024-01-01" || items[2].PubDate != "2024-01-03" {
+		t.Errorf("даты публикации %s и %s, ожидались 2024-01-01 и 2024-01-03", items[0].PubDate, items[2].PubDate)
+	}
+}
+
+func TestNewsList(t *testing.T) {
+	news := NewNews(GenerateNews(25))
+	defer news.Close()
+
+	tests := []struct {
+		name  string
+		query string
+		ids   []int64
+		total string
+	}{
+		{"все новости", "", nil, ""},
+		{"страница", "?page=3&count=10", []int64{21, 22, 23, 24, 25}, "25"},
+		{"поиск", "?s=новость%201&count=3", []int64{1, 10, 11}, "11"},
+		{"сортировка", "?sort=pub_date&order=desc&count=2", []int64{25, 24}, "25"},
+		{"период", "?from=2024-01-05&until=2024-01-08", []int64{5, 6, 7}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var items []NewsItem
+			resp := getJSON(t, news.URL+"/api/news/"+tt.query, &items)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("статус %d", resp.StatusCode)
+			}
+			if tt.ids == nil {
+				if len(items) != 25 {
+					t.Errorf("получено %d новостей, ожидалось 25", len(items))
+				}
+			} else if !equalIDs(newsIDs(items), tt.ids) {
+				t.Errorf("ID новостей %v, ожидались %v", newsIDs(items), tt.ids)
+			}
+			if total := resp.Header.Get("X-Total-Count"); total != tt.total {
+				t.Errorf("X-Total-Count %q, ожидался %q", total, tt.total)
+			}
+		})
+	}
+}
+
+func TestNewsItem(t *testing.T) {
+	news := NewNews(GenerateNews(5))
+	defer news.Close()
+
+	var items []NewsItem
+	if resp := getJSON(t, news.URL+"/api/news/3", &items); resp.StatusCode != http.StatusOK {
+		t.Fatalf("статус %d", resp.StatusCode)
+	}
+	if len(items) != 1 || items[0].ID != 3 {
+		t.Errorf("ответ %+v, ожидался массив с новостью 3", items)
+	}
+
+	if resp := getJSON(t, news.URL+"/api/news/6", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("статус отсутствующей новости %d, ожидался 404", resp.StatusCode)
+	}
+
+	news.SetItems(GenerateNews(6))
+	if resp := getJSON(t, news.URL+"/api/news/6", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("статус новости после SetItems %d, ожидался 200", resp.StatusCode)
+	}
+}
+
+func TestComments(t *testing.T) {
+	comments := NewComments([]CommentItem{
+		{ID: 1, NewsID: 1, Text: "Первый"},
+		{ID: 2, NewsID: 2, Text: "Второй"},
+	})
+	defer comments.Close()
+
+	resp, err := http.Post(comments.URL+"/api/comm_add_news?id=1", "application/json", strings.NewReader(`{"text":"Третий","user_id":"u1"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var added CommentItem
+	json.NewDecoder(resp.Body).Decode(&added)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || added.ID != 3 || added.UserID != "u1" {
+		t.Fatalf("добавление: статус %d, комментарий %+v", resp.StatusCode, added)
+	}
+
+	var list []CommentItem
+	getJSON(t, comments.URL+"/api/comm_news?id=1", &list)
+	if len(list) != 2 || list[1].Text != "Третий" {
+		t.Errorf("комментарии новости 1: %+v", list)
+	}
+	if got := comments.ForNews(2); len(got) != 1 {
+		t.Errorf("ForNews(2) вернул %d комментариев, ожидался 1", len(got))
+	}
+
+	if resp := getJSON(t, comments.URL+"/api/comm_add_news?id=1", nil); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET добавления: статус %d, ожидался 405", resp.StatusCode)
+	}
+}
+
+func TestBehavior(t *testing.T) {
+	news := NewNews(GenerateNews(1))
+	defer news.Close()
+
+	news.SetBehavior(Behavior{FailureRate: 1, FailureStatus: http.StatusServiceUnavailable})
+	if resp := getJSON(t, news.URL+"/api/news/1", nil); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("статус при FailureRate 1: %d, ожидался 503", resp.StatusCode)
+	}
+
+	news.SetBehavior(Behavior{Latency: 50 * time.Millisecond})
+	start := time.Now()
+	if resp := getJSON(t, news.URL+"/api/news/1", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("статус с задержкой: %d, ожидался 200", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("ответ получен через %s, задержка 50ms", elapsed)
+	}
+
+	if requests := news.Requests(); requests != 2 {
+		t.Errorf("Requests() = %d, ожидалось 2", requests)
+	}
+}
+
+func TestGateway(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.Services = config.ServicesConfig{
+		config.ServiceNews: {URL: "http://news.invalid", Pagination: true},
+	}
+	g, err := NewGateway(cfg, GenerateNews(30), []CommentItem{{ID: 1, NewsID: 2, Text: "Комментарий"}})
+	if err != nil {
+		t.Fatalf("NewGateway: %v", err)
+	}
+	defer g.Close()
+
+	if service := cfg.Services[config.ServiceNews]; service.URL != g.News.URL || !service.Pagination {
+		t.Errorf("настройки сервиса новостей: %+v", service)
+	}
+
+	var page struct {
+		Items      []struct{ ID int64 } `json:"items"`
+		TotalItems int                  `json:"total_items"`
+	}
+	if resp := getJSON(t, g.URL+"/api/news?page=2&count=10", &page); resp.StatusCode != http.StatusOK {
+		t.Fatalf("/api/news: статус %d", resp.StatusCode)
+	}
+	if len(page.Items) != 10 || page.Items[0].ID != 11 || page.TotalItems != 30 {
+		t.Errorf("страница новостей: %+v", page)
+	}
+
+	var comments []CommentItem
+	if resp := getJSON(t, g.URL+"/api/comments?id=2", &comments); resp.StatusCode != http.StatusOK || len(comments) != 1 {
+		t.Errorf("/api/comments: статус %d, комментарии %+v", resp.StatusCode, comments)
+	}
+
+	g.News.SetBehavior(Behavior{FailureRate: 1})
+	if resp := getJSON(t, g.URL+"/api/news/1", nil); resp.StatusCode < 500 {
+		t.Errorf("статус шлюза при ошибке сервиса новостей: %d, ожидался 5xx", resp.StatusCode)
+	}
+}
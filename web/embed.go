@@ -0,0 +1,11 @@
+// Package web содержит файлы фронтенда, встраиваемые в бинарный файл шлюза.
+// Чтобы раздавать свой фронтенд без отдельного каталога, замените содержимое
+// web/dist собранными файлами приложения и пересоберите шлюз.
+package web
+
+import "embed"
+
+// Dist - встроенные файлы фронтенда (каталог dist)
+//
+//go:embed dist
+var Dist embed.FS